@@ -0,0 +1,266 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"chimerascan/database"
+	"chimerascan/models"
+	"chimerascan/rbac"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Действия, записываемые в журнал
+const (
+	ActionLogin             = "auth.login"
+	ActionLogout            = "auth.logout"
+	ActionIdentityLink      = "identity.link"
+	ActionIdentityUnlink    = "identity.unlink"
+	ActionSessionRevoke     = "session.revoke"
+	ActionTokenCreate       = "token.create"
+	ActionTokenRevoke       = "token.revoke"
+	ActionScanStart         = "scan.start"
+	ActionScanRerun         = "scan.rerun"
+	ActionScanUpdate        = "scan.update"
+	ActionScanStop          = "scan.stop"
+	ActionScanDelete        = "scan.delete"
+	ActionScanMove          = "scan.move"
+	ActionReportDownload    = "report.download"
+	ActionEvidenceView      = "evidence.view"
+	ActionProjectCreate     = "project.create"
+	ActionProjectUpdate     = "project.update"
+	ActionProjectDelete     = "project.delete"
+	ActionProjectArchive    = "project.archive"
+	ActionProjectUnarchive  = "project.unarchive"
+	ActionProjectRestore    = "project.restore"
+	ActionProjectMove       = "project.move"
+	ActionRepositoryLink    = "project.repository.link"
+	ActionRepositoryUnlink  = "project.repository.unlink"
+	ActionIssuesExport      = "issues.export"
+	ActionWebhookCreate     = "webhook.create"
+	ActionWebhookDelete     = "webhook.delete"
+	ActionOrgCreate         = "organization.create"
+	ActionOrgDelete         = "organization.delete"
+	ActionMemberAdd         = "member.add"
+	ActionMemberUpdate      = "member.update"
+	ActionMemberRemove      = "member.remove"
+	ActionNotificationsEdit = "notifications.update"
+	ActionUserDisable       = "user.disable"
+	ActionUserEnable        = "user.enable"
+	ActionUserDelete        = "user.delete"
+	ActionReportsPurge      = "reports.purge"
+	ActionRetentionUpdate   = "retention.update"
+	ActionRetentionRun      = "retention.run"
+)
+
+// Entry - действие пользователя запроса над объектом
+type Entry struct {
+	Action     string
+	TargetType string
+	TargetID   string
+	// OrganizationID можно не указывать для проектов и сканирований, уже проверенных через rbac
+	OrganizationID *uuid.UUID
+	Details        map[string]interface{}
+}
+
+// Record записывает событие в журнал. Пользователь, токен, IP и User-Agent берутся из запроса.
+// Ошибка записи не прерывает обработку запроса.
+func Record(c *gin.Context, entry Entry) {
+	event := models.AuditEvent{
+		ID:             uuid.New(),
+		Action:         entry.Action,
+		TargetType:     entry.TargetType,
+		TargetID:       entry.TargetID,
+		OrganizationID: entry.OrganizationID,
+		IPAddress:      c.ClientIP(),
+		UserAgent:      c.Request.UserAgent(),
+		Details:        entry.Details,
+		CreatedAt:      time.Now(),
+	}
+
+	if event.OrganizationID == nil {
+		if entry.TargetType == rbac.KindOrganization {
+			if orgID, err := uuid.Parse(entry.TargetID); err == nil {
+				event.OrganizationID = &orgID
+			}
+		} else {
+			event.OrganizationID = rbac.CachedOrganization(c, entry.TargetType, entry.TargetID)
+		}
+	}
+
+	if value, ok := c.Get("userID"); ok {
+		if userID, ok := value.(uuid.UUID); ok {
+			event.ActorID = &userID
+		}
+	}
+	if value, ok := c.Get("user"); ok {
+		if user, ok := value.(*models.User); ok && user != nil {
+			event.ActorName = user.Username
+		}
+	}
+	if value, ok := c.Get("apiToken"); ok {
+		if token, ok := value.(*models.APIToken); ok && token != nil {
+			event.ActorTokenID = &token.ID
+		}
+	}
+
+	if err := Insert(event); err != nil {
+		log.Printf("Failed to record audit event %s: %v", entry.Action, err)
+	}
+}
+
+// Insert добавляет событие в журнал
+func Insert(event models.AuditEvent) error {
+	details := []byte("{}")
+	if len(event.Details) > 0 {
+		var err error
+		if details, err = json.Marshal(event.Details); err != nil {
+			return err
+		}
+	}
+
+	_, err := database.DB.Exec(`
+		INSERT INTO audit_events (id, actor_id, actor_name, actor_token_id, action, target_type, target_id,
+			organization_id, ip_address, user_agent, details, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, event.ID, event.ActorID, event.ActorName, event.ActorTokenID, event.Action, event.TargetType, event.TargetID,
+		event.OrganizationID, event.IPAddress, event.UserAgent, string(details), event.CreatedAt)
+	return err
+}
+
+// Filter - условия выборки из журнала. Должен быть задан OrganizationID или ActorID.
+type Filter struct {
+	OrganizationID *uuid.UUID
+	ActorID        *uuid.UUID
+	Action         string // точное совпадение или префикс, например "scan."
+	TargetType     string
+	TargetID       string
+	Since          *time.Time
+	Until          *time.Time
+	Limit          int
+	Offset         int
+}
+
+func (f Filter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if f.OrganizationID != nil {
+		add("organization_id = $%d", *f.OrganizationID)
+	}
+	if f.ActorID != nil {
+		add("actor_id = $%d", *f.ActorID)
+	}
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, ".") {
+			add("action LIKE $%d", f.Action+"%")
+		} else {
+			add("action = $%d", f.Action)
+		}
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $%d", f.TargetID)
+	}
+	if f.Since != nil {
+		add("created_at >= $%d", *f.Since)
+	}
+	if f.Until != nil {
+		add("created_at < $%d", *f.Until)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+const selectEvents = `SELECT id, actor_id, actor_name, actor_token_id, action, target_type, target_id,
+	organization_id, ip_address, user_agent, details, created_at FROM audit_events`
+
+func query(f Filter) (*sql.Rows, error) {
+	where, args := f.where()
+	q := selectEvents + where + " ORDER BY created_at DESC"
+
+	if f.Limit > 0 {
+		args = append(args, f.Limit)
+		q += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+	if f.Offset > 0 {
+		args = append(args, f.Offset)
+		q += fmt.Sprintf(" OFFSET $%d", len(args))
+	}
+
+	return database.DB.Query(q, args...)
+}
+
+func scanEvent(rows *sql.Rows) (models.AuditEvent, error) {
+	var event models.AuditEvent
+	var details []byte
+	err := rows.Scan(&event.ID, &event.ActorID, &event.ActorName, &event.ActorTokenID, &event.Action,
+		&event.TargetType, &event.TargetID, &event.OrganizationID, &event.IPAddress, &event.UserAgent,
+		&details, &event.CreatedAt)
+	if err != nil {
+		return event, err
+	}
+	if len(details) > 0 {
+		json.Unmarshal(details, &event.Details)
+	}
+	return event, nil
+}
+
+// List возвращает события журнала, новые первыми
+func List(f Filter) ([]models.AuditEvent, error) {
+	rows, err := query(f)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// ExportJSONLines пишет события в формате JSON Lines (одно событие на строку) для загрузки в SIEM
+func ExportJSONLines(w io.Writer, f Filter) (int, error) {
+	rows, err := query(f)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	encoder := json.NewEncoder(w)
+	count := 0
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return count, err
+		}
+		if err := encoder.Encode(event); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, rows.Err()
+}
@@ -0,0 +1,112 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chimerascan/database"
+	"chimerascan/models"
+	"chimerascan/rbac"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecord_CapturesRequestContext(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("DELETE", "/api/scans/x", nil)
+	c.Request.Header.Set("User-Agent", "ci-bot/1.0")
+	c.Request.RemoteAddr = "203.0.113.7:5555"
+
+	user := &models.User{ID: uuid.New(), Username: "alice"}
+	token := &models.APIToken{ID: uuid.New()}
+	c.Set("user", user)
+	c.Set("userID", user.ID)
+	c.Set("apiToken", token)
+
+	// Организация сканирования уже известна после проверки прав
+	scanID := uuid.New()
+	orgID := uuid.New()
+	mock.ExpectQuery(`SELECT s.user_id, p.organization_id, m.role FROM scans s`).
+		WithArgs(scanID, user.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "organization_id", "role"}).
+			AddRow(uuid.New(), orgID, rbac.RoleAdmin))
+	require.NoError(t, rbac.AuthorizeScan(c, scanID.String(), rbac.PermScanDelete))
+
+	mock.ExpectExec(`INSERT INTO audit_events`).
+		WithArgs(sqlmock.AnyArg(), &user.ID, "alice", &token.ID, ActionScanDelete, rbac.KindScan, scanID.String(),
+			&orgID, "203.0.113.7", "ci-bot/1.0", `{"reason":"cleanup"}`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	Record(c, Entry{
+		Action:     ActionScanDelete,
+		TargetType: rbac.KindScan,
+		TargetID:   scanID.String(),
+		Details:    map[string]interface{}{"reason": "cleanup"},
+	})
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFilterWhere(t *testing.T) {
+	orgID := uuid.New()
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	where, args := Filter{OrganizationID: &orgID, Action: "scan.", Since: &since}.where()
+
+	assert.Equal(t, " WHERE organization_id = $1 AND action LIKE $2 AND created_at >= $3", where)
+	assert.Equal(t, []interface{}{orgID, "scan.%", since}, args)
+}
+
+func TestExportJSONLines(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	actorID := uuid.New()
+	rows := sqlmock.NewRows([]string{"id", "actor_id", "actor_name", "actor_token_id", "action", "target_type",
+		"target_id", "organization_id", "ip_address", "user_agent", "details", "created_at"}).
+		AddRow(uuid.New(), actorID, "alice", nil, ActionScanStart, "scan", "s1", nil, "10.0.0.1", "curl", []byte(`{}`), time.Now()).
+		AddRow(uuid.New(), actorID, "alice", nil, ActionReportDownload, "scan", "s1", nil, "10.0.0.1", "curl", []byte(`{"format":"pdf"}`), time.Now())
+
+	mock.ExpectQuery(`SELECT id, actor_id, actor_name, .* FROM audit_events WHERE actor_id = \$1 ORDER BY created_at DESC`).
+		WithArgs(actorID).
+		WillReturnRows(rows)
+
+	var buf bytes.Buffer
+	count, err := ExportJSONLines(&buf, Filter{ActorID: &actorID})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	scanner := bufio.NewScanner(&buf)
+	var actions []string
+	for scanner.Scan() {
+		var event models.AuditEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		actions = append(actions, event.Action)
+	}
+	assert.Equal(t, []string{ActionScanStart, ActionReportDownload}, actions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
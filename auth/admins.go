@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"strings"
+
+	"chimerascan/models"
+)
+
+// Администраторы из конфигурации: email в нижнем регистре
+var bootstrapAdmins = map[string]bool{}
+
+// InitAdmins задает email администраторов из конфигурации.
+// Так назначается первый администратор, остальных можно отметить флагом is_admin в таблице users.
+func InitAdmins(emails []string) {
+	bootstrapAdmins = map[string]bool{}
+	for _, email := range emails {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			bootstrapAdmins[email] = true
+		}
+	}
+}
+
+// IsAdmin сообщает, является ли пользователь администратором
+func IsAdmin(user *models.User) bool {
+	if user == nil || user.DisabledAt != nil {
+		return false
+	}
+	return user.IsAdmin || bootstrapAdmins[strings.ToLower(user.Email)]
+}
@@ -2,18 +2,15 @@ package auth
 
 import (
 	"crypto/rand"
-	"database/sql"
 	"encoding/base64"
 	"encoding/json"
 	"net/http"
-	"os"
-	"time"
+	"strings"
 
-	"chimerascan/database"
+	"chimerascan/config"
 	"chimerascan/models"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/github"
 )
@@ -21,26 +18,34 @@ import (
 var (
 	githubOAuthConfig *oauth2.Config
 	gitlabOAuthConfig *oauth2.Config
+	gitlabBaseURL     = "https://gitlab.com"
+
+	// PublicBaseURL - внешний адрес приложения, от которого строятся адреса возврата OAuth
+	PublicBaseURL = "http://localhost:8080"
 )
 
-func InitOAuth() {
+// CallbackURL возвращает адрес возврата для провайдера входа
+func CallbackURL(provider string) string {
+	return strings.TrimSuffix(PublicBaseURL, "/") + "/auth/" + provider + "/callback"
+}
+
+// InitOAuth настраивает вход через GitHub и GitLab; publicBaseURL - внешний адрес приложения
+func InitOAuth(cfg config.OAuth, publicBaseURL string) {
+	PublicBaseURL = strings.TrimSuffix(publicBaseURL, "/")
+	gitlabBaseURL = strings.TrimSuffix(cfg.GitLabBaseURL, "/")
+
 	githubOAuthConfig = &oauth2.Config{
-		ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
-		ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
-		RedirectURL:  "http://localhost:8080/auth/github/callback",
+		ClientID:     cfg.GitHub.ClientID,
+		ClientSecret: cfg.GitHub.ClientSecret,
+		RedirectURL:  CallbackURL("github"),
 		Scopes:       []string{"user:email"},
 		Endpoint:     github.Endpoint,
 	}
 
-	gitlabBaseURL := os.Getenv("GITLAB_BASE_URL")
-	if gitlabBaseURL == "" {
-		gitlabBaseURL = "https://gitlab.com"
-	}
-
 	gitlabOAuthConfig = &oauth2.Config{
-		ClientID:     os.Getenv("GITLAB_CLIENT_ID"),
-		ClientSecret: os.Getenv("GITLAB_CLIENT_SECRET"),
-		RedirectURL:  "http://localhost:8080/auth/gitlab/callback",
+		ClientID:     cfg.GitLab.ClientID,
+		ClientSecret: cfg.GitLab.ClientSecret,
+		RedirectURL:  CallbackURL("gitlab"),
 		Scopes:       []string{"read_user"},
 		Endpoint: oauth2.Endpoint{
 			AuthURL:  gitlabBaseURL + "/oauth/authorize",
@@ -54,10 +59,23 @@ func GenerateStateOauthCookie(c *gin.Context) string {
 	rand.Read(b)
 	state := base64.URLEncoding.EncodeToString(b)
 
-	c.SetCookie("oauthstate", state, 3600, "/", "localhost", false, true)
+	SetCookie(c, "oauthstate", state, 3600)
 	return state
 }
 
+// GenerateOIDCCookies создает nonce и PKCE verifier для входа через OIDC
+// и сохраняет их в cookie до возврата от провайдера
+func GenerateOIDCCookies(c *gin.Context) (nonce, verifier string) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	nonce = base64.RawURLEncoding.EncodeToString(b)
+	verifier = oauth2.GenerateVerifier()
+
+	SetCookie(c, "oidcnonce", nonce, 600)
+	SetCookie(c, "oidcverifier", verifier, 600)
+	return nonce, verifier
+}
+
 func GetGitHubOAuthConfig() *oauth2.Config {
 	return githubOAuthConfig
 }
@@ -92,7 +110,7 @@ func GitHubUserInfo(token string) (map[string]interface{}, error) {
 // GitLabUserInfo получает информацию о пользователе из GitLab
 func GitLabUserInfo(token string) (map[string]interface{}, error) {
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", os.Getenv("GITLAB_BASE_URL")+"/api/v4/user", nil)
+	req, err := http.NewRequest("GET", gitlabBaseURL+"/api/v4/user", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -112,56 +130,14 @@ func GitLabUserInfo(token string) (map[string]interface{}, error) {
 	return user, nil
 }
 
-// CreateOrGetUser создает или получает пользователя из базы данных
-func CreateOrGetUser(providerID, email, username, provider string) (*models.User, error) {
-	var user models.User
-	query := `SELECT id, provider_id, email, username, created_at FROM users WHERE provider_id = $1`
-
-	err := database.DB.QueryRow(query, providerID).Scan(
-		&user.ID, &user.ProviderID, &user.Email, &user.Username, &user.CreatedAt,
-	)
-
-	if err == sql.ErrNoRows {
-		user.ID = uuid.New()
-		user.ProviderID = providerID
-		user.Email = email
-		user.Username = username
-		user.CreatedAt = time.Now()
-
-		insertQuery := `INSERT INTO users (id, provider_id, email, username, created_at) 
-                       VALUES ($1, $2, $3, $4, $5)`
-		_, err := database.DB.Exec(insertQuery, user.ID, user.ProviderID, user.Email, user.Username, user.CreatedAt)
-		if err != nil {
-			return nil, err
-		}
-	} else if err != nil {
-		return nil, err
-	}
-
-	return &user, nil
-}
-
-// GetUserFromRequest получает пользователя из cookies
+// GetUserFromRequest получает пользователя по cookie сессии.
+// ID сессии сохраняется в контексте под ключом "sessionID".
 func GetUserFromRequest(c *gin.Context) (*models.User, error) {
-	userIDStr, err := c.Cookie("user_id")
-	if err != nil {
-		return nil, err
-	}
-
-	userID, err := uuid.Parse(userIDStr)
+	sessionID, user, err := lookupSession(c)
 	if err != nil {
 		return nil, err
 	}
 
-	var user models.User
-	query := `SELECT id, provider_id, email, username, created_at FROM users WHERE id = $1`
-	err = database.DB.QueryRow(query, userID).Scan(
-		&user.ID, &user.ProviderID, &user.Email, &user.Username, &user.CreatedAt,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return &user, nil
+	c.Set("sessionID", sessionID)
+	return user, nil
 }
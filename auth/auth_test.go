@@ -45,13 +45,21 @@ func TestCreateOrGetUser_NewUser(t *testing.T) {
 	email := "test@example.com"
 	username := "testuser"
 
-	mock.ExpectQuery(`SELECT id, provider_id, email, username, created_at FROM users WHERE provider_id = \$1`).
-		WithArgs(providerID).
+	mock.ExpectQuery(`SELECT u.id, u.provider_id, u.email, u.username, u.created_at, u.is_admin, u.disabled_at FROM user_identities i JOIN users u ON u.id = i.user_id WHERE i.provider = \$1 AND i.subject = \$2`).
+		WithArgs("github", providerID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`FROM user_identities i`).
+		WithArgs(LegacyProvider, providerID).
 		WillReturnError(sql.ErrNoRows)
 
+	mock.ExpectBegin()
 	mock.ExpectExec(`INSERT INTO users \(id, provider_id, email, username, created_at\)`).
-		WithArgs(sqlmock.AnyArg(), providerID, email, username, sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), "github:"+providerID, email, username, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO user_identities`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "github", providerID, email, sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	user, err := CreateOrGetUser(providerID, email, username, "github")
 
@@ -80,11 +88,11 @@ func TestCreateOrGetUser_ExistingUser(t *testing.T) {
 	userID := uuid.New()
 	createdAt := time.Now()
 
-	rows := sqlmock.NewRows([]string{"id", "provider_id", "email", "username", "created_at"}).
-		AddRow(userID, providerID, email, username, createdAt)
+	rows := sqlmock.NewRows([]string{"id", "provider_id", "email", "username", "created_at", "is_admin", "disabled_at"}).
+		AddRow(userID, "github:"+providerID, email, username, createdAt, false, nil)
 
-	mock.ExpectQuery(`SELECT id, provider_id, email, username, created_at FROM users WHERE provider_id = \$1`).
-		WithArgs(providerID).
+	mock.ExpectQuery(`FROM user_identities i JOIN users u ON u.id = i.user_id WHERE i.provider = \$1 AND i.subject = \$2`).
+		WithArgs("github", providerID).
 		WillReturnRows(rows)
 
 	user, err := CreateOrGetUser(providerID, email, username, "github")
@@ -97,6 +105,121 @@ func TestCreateOrGetUser_ExistingUser(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestCreateOrGetUser_ClaimsLegacyAccountByEmail(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	userID := uuid.New()
+
+	mock.ExpectQuery(`FROM user_identities i`).
+		WithArgs("gitlab", "42").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`FROM user_identities i`).
+		WithArgs(LegacyProvider, "42").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "provider_id", "email", "username", "created_at", "is_admin", "disabled_at"}).
+			AddRow(userID, "42", "Dev@Example.com", "dev", time.Now(), false, nil))
+	mock.ExpectExec(`UPDATE user_identities SET provider = \$1 WHERE provider = \$2 AND subject = \$3`).
+		WithArgs("gitlab", LegacyProvider, "42").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	user, err := CreateOrGetUser("42", "dev@example.com", "dev", "gitlab")
+
+	assert.NoError(t, err)
+	assert.Equal(t, userID, user.ID, "Legacy account with its projects and scans should be kept")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateOrGetUser_SameIDOtherProviderGetsNewAccount(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	legacyUserID := uuid.New()
+
+	mock.ExpectQuery(`FROM user_identities i`).
+		WithArgs("gitlab", "42").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`FROM user_identities i`).
+		WithArgs(LegacyProvider, "42").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "provider_id", "email", "username", "created_at", "is_admin", "disabled_at"}).
+			AddRow(legacyUserID, "42", "octocat@users.noreply.github.com", "octocat", time.Now(), false, nil))
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO users`).
+		WithArgs(sqlmock.AnyArg(), "gitlab:42", "someone@gitlab.example", "someone", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO user_identities`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "gitlab", "42", "someone@gitlab.example", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	user, err := CreateOrGetUser("42", "someone@gitlab.example", "someone", "gitlab")
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, legacyUserID, user.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLinkIdentity_InUseByAnotherUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	mock.ExpectQuery(`SELECT user_id FROM user_identities WHERE provider = \$1 AND subject = \$2`).
+		WithArgs("gitlab", "7").
+		WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(uuid.New()))
+
+	err = LinkIdentity(uuid.New(), "gitlab", "7", "dev@example.com")
+	assert.Equal(t, ErrIdentityInUse, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUnlinkIdentity_LastIdentity(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	userID := uuid.New()
+	identityID := uuid.New()
+
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM user_identities WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs(identityID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	deleted, err := UnlinkIdentity(userID, identityID)
+	assert.False(t, deleted)
+	assert.Equal(t, ErrLastIdentity, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetUserFromRequest_Valid(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -112,19 +235,20 @@ func TestGetUserFromRequest_Valid(t *testing.T) {
 	c, _ := gin.CreateTestContext(w)
 
 	userID := uuid.New()
+	sessionID := uuid.New()
 	cookie := &http.Cookie{
-		Name:  "user_id",
-		Value: userID.String(),
+		Name:  SessionCookieName,
+		Value: "opaque-token",
 	}
 	c.Request = &http.Request{
 		Header: http.Header{"Cookie": []string{cookie.String()}},
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "provider_id", "email", "username", "created_at"}).
-		AddRow(userID, "github_123", "test@example.com", "testuser", time.Now())
+	rows := sqlmock.NewRows([]string{"id", "last_seen_at", "expires_at", "id", "provider_id", "email", "username", "created_at", "is_admin", "disabled_at"}).
+		AddRow(sessionID, time.Now(), time.Now().Add(time.Hour), userID, "github_123", "test@example.com", "testuser", time.Now(), false, nil)
 
-	mock.ExpectQuery(`SELECT id, provider_id, email, username, created_at FROM users WHERE id = \$1`).
-		WithArgs(userID).
+	mock.ExpectQuery(`SELECT s.id, s.last_seen_at, s.expires_at, u.id, u.provider_id, u.email, u.username, u.created_at, u.is_admin, u.disabled_at FROM sessions s`).
+		WithArgs(hashToken("opaque-token")).
 		WillReturnRows(rows)
 
 	user, err := GetUserFromRequest(c)
@@ -132,17 +256,60 @@ func TestGetUserFromRequest_Valid(t *testing.T) {
 	assert.NoError(t, err, "Should not return error")
 	assert.NotNil(t, user, "User should not be nil")
 	assert.Equal(t, userID, user.ID, "User IDs should match")
+	assert.Equal(t, sessionID, c.MustGet("sessionID"), "Session ID should be stored in context")
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestGetUserFromRequest_InvalidUUID(t *testing.T) {
+func TestGetUserFromRequest_IdleSession(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	sessionID := uuid.New()
+	cookie := &http.Cookie{
+		Name:  SessionCookieName,
+		Value: "opaque-token",
+	}
+	c.Request = &http.Request{
+		Header: http.Header{"Cookie": []string{cookie.String()}},
+	}
+
+	rows := sqlmock.NewRows([]string{"id", "last_seen_at", "expires_at", "id", "provider_id", "email", "username", "created_at", "is_admin", "disabled_at"}).
+		AddRow(sessionID, time.Now().Add(-SessionIdleTimeout-time.Minute), time.Now().Add(time.Hour), uuid.New(), "github_123", "test@example.com", "testuser", time.Now(), false, nil)
+
+	mock.ExpectQuery(`SELECT s.id, s.last_seen_at, s.expires_at`).
+		WithArgs(hashToken("opaque-token")).
+		WillReturnRows(rows)
+	mock.ExpectExec(`DELETE FROM sessions WHERE id = \$1`).
+		WithArgs(sessionID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	user, err := GetUserFromRequest(c)
+
+	assert.ErrorIs(t, err, ErrSessionExpired, "Idle session should be rejected")
+	assert.Nil(t, user, "User should be nil on error")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUserFromRequest_LegacyUserIDCookie(t *testing.T) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 
+	// Старый cookie с UUID пользователя больше не принимается
 	cookie := &http.Cookie{
 		Name:  "user_id",
-		Value: "not-a-valid-uuid",
+		Value: uuid.New().String(),
 	}
 	c.Request = &http.Request{
 		Header: http.Header{"Cookie": []string{cookie.String()}},
@@ -150,7 +317,95 @@ func TestGetUserFromRequest_InvalidUUID(t *testing.T) {
 
 	user, err := GetUserFromRequest(c)
 
-	assert.Error(t, err, "Should return error for invalid UUID")
+	assert.ErrorIs(t, err, ErrNoSession, "Should require a session cookie")
 	assert.Nil(t, user, "User should be nil on error")
-	assert.Contains(t, err.Error(), "invalid UUID", "Error should mention UUID")
+}
+
+func TestCreateSession_RotatesAndSetsSecureCookie(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/auth/github/callback", nil)
+	c.Request.AddCookie(&http.Cookie{Name: SessionCookieName, Value: "old-token"})
+
+	userID := uuid.New()
+
+	mock.ExpectExec(`DELETE FROM sessions WHERE token_hash = \$1`).
+		WithArgs(hashToken("old-token")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM sessions WHERE user_id = \$1`).
+		WithArgs(userID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO sessions`).
+		WithArgs(sqlmock.AnyArg(), userID, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err = CreateSession(c, userID)
+	assert.NoError(t, err, "Should not return error")
+
+	cookies := w.Result().Cookies()
+	assert.Len(t, cookies, 1, "Should set one cookie")
+	assert.Equal(t, SessionCookieName, cookies[0].Name)
+	assert.NotEqual(t, "old-token", cookies[0].Value, "Token should be rotated")
+	assert.True(t, cookies[0].Secure, "Cookie should be Secure")
+	assert.True(t, cookies[0].HttpOnly, "Cookie should be HttpOnly")
+	assert.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuthenticateAPIToken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	userID := uuid.New()
+	tokenID := uuid.New()
+	raw := APITokenPrefix + "secret"
+	columns := []string{"id", "name", "token_prefix", "scopes", "expires_at", "last_used_at", "created_at",
+		"id", "provider_id", "email", "username", "created_at", "is_admin", "disabled_at"}
+
+	mock.ExpectQuery(`SELECT t.id, t.name, t.token_prefix, t.scopes`).
+		WithArgs(hashToken(raw)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(tokenID, "ci", "cs_secret", []byte(`["scan:read"]`), nil, nil, time.Now(),
+			userID, "github_123", "test@example.com", "testuser", time.Now(), false, nil))
+	mock.ExpectExec(`UPDATE api_tokens SET last_used_at = \$1 WHERE id = \$2`).
+		WithArgs(sqlmock.AnyArg(), tokenID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	user, token, err := AuthenticateAPIToken(raw)
+	assert.NoError(t, err, "Should not return error")
+	assert.Equal(t, userID, user.ID, "User IDs should match")
+	assert.True(t, HasScope(token, ScopeScanRead), "Token should have scan:read")
+	assert.False(t, HasScope(token, ScopeScanWrite), "Token should not have scan:write")
+
+	expired := time.Now().Add(-time.Hour)
+	mock.ExpectQuery(`SELECT t.id, t.name, t.token_prefix, t.scopes`).
+		WithArgs(hashToken(raw)).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(tokenID, "ci", "cs_secret", []byte(`[]`), expired, nil, time.Now(),
+			userID, "github_123", "test@example.com", "testuser", time.Now(), false, nil))
+
+	_, _, err = AuthenticateAPIToken(raw)
+	assert.ErrorIs(t, err, ErrInvalidToken, "Expired token should be rejected")
+
+	_, _, err = AuthenticateAPIToken("not-a-token")
+	assert.ErrorIs(t, err, ErrInvalidToken, "Token without prefix should be rejected")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
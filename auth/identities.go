@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"chimerascan/database"
+	"chimerascan/models"
+
+	"github.com/google/uuid"
+)
+
+// LegacyProvider - учетные записи, созданные до появления user_identities, у которых провайдер неизвестен
+const LegacyProvider = "legacy"
+
+var (
+	// ErrIdentityInUse - учетная запись провайдера уже привязана к другому пользователю
+	ErrIdentityInUse = errors.New("identity is linked to another user")
+	// ErrLastIdentity - нельзя отвязать единственный способ входа
+	ErrLastIdentity = errors.New("cannot unlink the last identity")
+)
+
+const userByIdentityQuery = `
+	SELECT u.id, u.provider_id, u.email, u.username, u.created_at, u.is_admin, u.disabled_at
+	FROM user_identities i
+	JOIN users u ON u.id = i.user_id
+	WHERE i.provider = $1 AND i.subject = $2`
+
+// CreateOrGetUser находит пользователя по учетной записи провайдера (provider, providerID)
+// или создает нового. Учетная запись без известного провайдера переходит к нему,
+// только если совпадает email, чтобы одинаковые ID GitHub и GitLab не попадали в один аккаунт.
+func CreateOrGetUser(providerID, email, username, provider string) (*models.User, error) {
+	user, err := userByIdentity(provider, providerID)
+	if err != sql.ErrNoRows {
+		return user, err
+	}
+
+	user, err = claimLegacyIdentity(providerID, email, provider)
+	if err != sql.ErrNoRows {
+		return user, err
+	}
+
+	user = &models.User{
+		ID:         uuid.New(),
+		ProviderID: provider + ":" + providerID,
+		Email:      email,
+		Username:   username,
+		CreatedAt:  time.Now(),
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO users (id, provider_id, email, username, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		user.ID, user.ProviderID, user.Email, user.Username, user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`INSERT INTO user_identities (id, user_id, provider, subject, email, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		uuid.New(), user.ID, provider, providerID, email, user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func userByIdentity(provider, subject string) (*models.User, error) {
+	var user models.User
+	err := database.DB.QueryRow(userByIdentityQuery, provider, subject).Scan(
+		&user.ID, &user.ProviderID, &user.Email, &user.Username, &user.CreatedAt, &user.IsAdmin, &user.DisabledAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func claimLegacyIdentity(subject, email, provider string) (*models.User, error) {
+	user, err := userByIdentity(LegacyProvider, subject)
+	if err != nil {
+		return nil, err
+	}
+	if email == "" || !strings.EqualFold(user.Email, email) {
+		return nil, sql.ErrNoRows
+	}
+
+	_, err = database.DB.Exec(`UPDATE user_identities SET provider = $1 WHERE provider = $2 AND subject = $3`,
+		provider, LegacyProvider, subject)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// LinkIdentity привязывает учетную запись провайдера к пользователю
+func LinkIdentity(userID uuid.UUID, provider, subject, email string) error {
+	var ownerID uuid.UUID
+	err := database.DB.QueryRow(`SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2`,
+		provider, subject).Scan(&ownerID)
+	if err == nil {
+		if ownerID != userID {
+			return ErrIdentityInUse
+		}
+		return nil
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = database.DB.Exec(`INSERT INTO user_identities (id, user_id, provider, subject, email, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		uuid.New(), userID, provider, subject, email, time.Now())
+	return err
+}
+
+// ListIdentities возвращает привязанные учетные записи пользователя
+func ListIdentities(userID uuid.UUID) ([]models.Identity, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, user_id, provider, subject, email, created_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []models.Identity{}
+	for rows.Next() {
+		var identity models.Identity
+		if err := rows.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject,
+			&identity.Email, &identity.CreatedAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+// UnlinkIdentity отвязывает учетную запись провайдера; последний способ входа отвязать нельзя
+func UnlinkIdentity(userID, identityID uuid.UUID) (bool, error) {
+	var count int
+	if err := database.DB.QueryRow(`SELECT COUNT(*) FROM user_identities WHERE user_id = $1`, userID).Scan(&count); err != nil {
+		return false, err
+	}
+
+	var exists bool
+	err := database.DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM user_identities WHERE id = $1 AND user_id = $2)`,
+		identityID, userID).Scan(&exists)
+	if err != nil || !exists {
+		return false, err
+	}
+	if count <= 1 {
+		return false, ErrLastIdentity
+	}
+
+	result, err := database.DB.Exec(`DELETE FROM user_identities WHERE id = $1 AND user_id = $2`, identityID, userID)
+	if err != nil {
+		return false, err
+	}
+	affected, _ := result.RowsAffected()
+	return affected > 0, nil
+}
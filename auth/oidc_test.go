@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"chimerascan/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Локальный издатель OIDC: discovery, JWKS и token endpoint с проверкой PKCE
+type mockIssuer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	kid       string
+	clientID  string
+	challenge string
+	nonce     string
+	claims    map[string]interface{}
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	m := &mockIssuer{key: key, kid: "test-key", clientID: "chimerascan"}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": m.kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     m.sign(t, m.idTokenClaims(m.nonce)),
+		})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+func (m *mockIssuer) idTokenClaims(nonce string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss":                m.URL,
+		"aud":                m.clientID,
+		"sub":                "user-42",
+		"email":              "alice@corp.example",
+		"email_verified":     true,
+		"preferred_username": "alice",
+		"nonce":              nonce,
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range m.claims {
+		claims[k] = v
+	}
+	return claims
+}
+
+func (m *mockIssuer) sign(t *testing.T, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": m.kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func newTestProvider(t *testing.T, m *mockIssuer) *OIDCProvider {
+	provider, err := NewOIDCProvider(context.Background(), OIDCSettings{
+		Name:        "Corp SSO",
+		Issuer:      m.URL,
+		ClientID:    m.clientID,
+		RedirectURL: "https://scan.example.com/auth/oidc/callback",
+	})
+	require.NoError(t, err)
+	return provider
+}
+
+func TestOIDC_LoginFlowWithPKCEAndNonce(t *testing.T) {
+	m := newMockIssuer(t)
+	provider := newTestProvider(t, m)
+
+	authURL, err := url.Parse(provider.AuthCodeURL("state-1", "nonce-1", "verifier-verifier-verifier-verifier-verifier"))
+	require.NoError(t, err)
+
+	query := authURL.Query()
+	assert.Equal(t, m.URL+"/authorize", authURL.Scheme+"://"+authURL.Host+authURL.Path)
+	assert.Equal(t, "nonce-1", query.Get("nonce"))
+	assert.Equal(t, "S256", query.Get("code_challenge_method"))
+	assert.Equal(t, "https://scan.example.com/auth/oidc/callback", query.Get("redirect_uri"))
+	assert.Contains(t, strings.Fields(query.Get("scope")), "openid")
+
+	m.challenge = query.Get("code_challenge")
+	m.nonce = "nonce-1"
+
+	claims, err := provider.Exchange(context.Background(), "good-code", "verifier-verifier-verifier-verifier-verifier", "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "user-42", claims.Subject)
+	assert.Equal(t, "alice@corp.example", claims.Email)
+	assert.Equal(t, "alice@corp.example", claims.VerifiedEmail())
+	assert.Equal(t, "alice", claims.Username())
+
+	// Неверный verifier отклоняется провайдером
+	_, err = provider.Exchange(context.Background(), "good-code", "wrong-verifier", "nonce-1")
+	assert.Error(t, err)
+}
+
+func TestOIDC_UnverifiedEmailIsNotTrusted(t *testing.T) {
+	m := newMockIssuer(t)
+	provider := newTestProvider(t, m)
+
+	verifier := "verifier-verifier-verifier-verifier-verifier"
+	authURL, err := url.Parse(provider.AuthCodeURL("state-1", "nonce-1", verifier))
+	require.NoError(t, err)
+	m.challenge = authURL.Query().Get("code_challenge")
+	m.nonce = "nonce-1"
+
+	// Email администратора, который провайдер не подтверждал
+	InitAdmins([]string{"admin@corp.example"})
+	defer InitAdmins(nil)
+
+	for _, verified := range []interface{}{false, nil} {
+		m.claims = map[string]interface{}{"email": "admin@corp.example", "email_verified": verified}
+		claims, err := provider.Exchange(context.Background(), "good-code", verifier, "nonce-1")
+		require.NoError(t, err)
+		assert.Equal(t, "admin@corp.example", claims.Email)
+		assert.Empty(t, claims.VerifiedEmail())
+		assert.False(t, IsAdmin(&models.User{Email: claims.VerifiedEmail()}))
+	}
+}
+
+func TestOIDC_VerifyIDTokenRejectsInvalidTokens(t *testing.T) {
+	m := newMockIssuer(t)
+	provider := newTestProvider(t, m)
+	ctx := context.Background()
+
+	valid := m.sign(t, m.idTokenClaims("n"))
+	_, err := provider.VerifyIDToken(ctx, valid, "n")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		token func() string
+		nonce string
+	}{
+		{"nonce mismatch", func() string { return valid }, "other"},
+		{"wrong audience", func() string {
+			claims := m.idTokenClaims("n")
+			claims["aud"] = "someone-else"
+			return m.sign(t, claims)
+		}, "n"},
+		{"wrong issuer", func() string {
+			claims := m.idTokenClaims("n")
+			claims["iss"] = "https://evil.example.com"
+			return m.sign(t, claims)
+		}, "n"},
+		{"expired", func() string {
+			claims := m.idTokenClaims("n")
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			return m.sign(t, claims)
+		}, "n"},
+		{"tampered payload", func() string {
+			parts := strings.Split(valid, ".")
+			claims := m.idTokenClaims("n")
+			claims["sub"] = "admin"
+			payload, _ := json.Marshal(claims)
+			return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+		}, "n"},
+		{"alg none", func() string {
+			parts := strings.Split(valid, ".")
+			header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","kid":"test-key"}`))
+			return header + "." + parts[1] + "."
+		}, "n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := provider.VerifyIDToken(ctx, tt.token(), tt.nonce)
+			assert.ErrorIs(t, err, ErrInvalidIDToken)
+		})
+	}
+}
+
+func TestOIDC_DiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockIssuer(t)
+
+	_, err := NewOIDCProvider(context.Background(), OIDCSettings{
+		Issuer:   strings.Replace(m.URL, "127.0.0.1", "localhost", 1),
+		ClientID: m.clientID,
+	})
+	assert.Error(t, err)
+}
+
+func TestCallbackURL_UsesPublicBaseURL(t *testing.T) {
+	old := PublicBaseURL
+	defer func() { PublicBaseURL = old }()
+
+	PublicBaseURL = "https://scan.example.com/"
+	assert.Equal(t, "https://scan.example.com/auth/oidc/callback", CallbackURL("oidc"))
+}
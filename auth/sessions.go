@@ -0,0 +1,216 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"chimerascan/config"
+	"chimerascan/database"
+	"chimerascan/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// SessionCookieName - cookie с непрозрачным токеном сессии
+const SessionCookieName = "session"
+
+// CSRFHeader - заголовок с CSRF-токеном для изменяющих запросов из браузера
+const CSRFHeader = "X-CSRF-Token"
+
+var (
+	// SessionTTL - максимальное время жизни сессии
+	SessionTTL = 7 * 24 * time.Hour
+	// SessionIdleTimeout - сессия завершается после указанного времени бездействия
+	SessionIdleTimeout = 24 * time.Hour
+	// SecureCookies включает флаг Secure у cookie
+	SecureCookies = true
+
+	// Время последней активности обновляется не чаще указанного интервала
+	sessionTouchInterval = time.Minute
+)
+
+var (
+	ErrNoSession      = errors.New("session not found")
+	ErrSessionExpired = errors.New("session expired")
+)
+
+// InitSessions задает параметры сессий из конфигурации
+func InitSessions(cfg config.Sessions) {
+	SessionTTL = time.Duration(cfg.TTL)
+	SessionIdleTimeout = time.Duration(cfg.IdleTimeout)
+	SecureCookies = cfg.CookieSecure == nil || *cfg.CookieSecure
+}
+
+// SetCookie устанавливает HttpOnly cookie с флагами Secure и SameSite=Lax
+func SetCookie(c *gin.Context, name, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    url.QueryEscape(value),
+		Path:     "/",
+		MaxAge:   maxAge,
+		Secure:   SecureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// В базе хранится только хеш токена
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSession создает новую сессию пользователя и устанавливает cookie.
+// Сессия из текущего cookie при этом отзывается, чтобы токен менялся при каждом входе.
+func CreateSession(c *gin.Context, userID uuid.UUID) error {
+	if oldToken, err := c.Cookie(SessionCookieName); err == nil && oldToken != "" {
+		database.DB.Exec(`DELETE FROM sessions WHERE token_hash = $1`, hashToken(oldToken))
+	}
+
+	now := time.Now()
+
+	// Заодно удаляем истекшие сессии пользователя
+	database.DB.Exec(`
+		DELETE FROM sessions
+		WHERE user_id = $1 AND (expires_at < $2 OR last_seen_at < $3)
+	`, userID, now, now.Add(-SessionIdleTimeout))
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	_, err := database.DB.Exec(`
+		INSERT INTO sessions (id, user_id, token_hash, user_agent, ip_address, created_at, last_seen_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, uuid.New(), userID, hashToken(token), c.Request.UserAgent(), c.ClientIP(), now, now, now.Add(SessionTTL))
+	if err != nil {
+		return err
+	}
+
+	SetCookie(c, SessionCookieName, token, int(SessionTTL.Seconds()))
+	return nil
+}
+
+// Поиск активной сессии по cookie запроса
+func lookupSession(c *gin.Context) (uuid.UUID, *models.User, error) {
+	token, err := c.Cookie(SessionCookieName)
+	if err != nil || token == "" {
+		return uuid.Nil, nil, ErrNoSession
+	}
+
+	var sessionID uuid.UUID
+	var lastSeenAt, expiresAt time.Time
+	var user models.User
+	err = database.DB.QueryRow(`
+		SELECT s.id, s.last_seen_at, s.expires_at, u.id, u.provider_id, u.email, u.username, u.created_at, u.is_admin, u.disabled_at
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.token_hash = $1
+	`, hashToken(token)).Scan(
+		&sessionID, &lastSeenAt, &expiresAt,
+		&user.ID, &user.ProviderID, &user.Email, &user.Username, &user.CreatedAt, &user.IsAdmin, &user.DisabledAt,
+	)
+	if err != nil {
+		return uuid.Nil, nil, ErrNoSession
+	}
+
+	now := time.Now()
+	if now.After(expiresAt) || now.Sub(lastSeenAt) > SessionIdleTimeout {
+		database.DB.Exec(`DELETE FROM sessions WHERE id = $1`, sessionID)
+		return uuid.Nil, nil, ErrSessionExpired
+	}
+
+	if now.Sub(lastSeenAt) > sessionTouchInterval {
+		database.DB.Exec(`UPDATE sessions SET last_seen_at = $1 WHERE id = $2`, now, sessionID)
+	}
+
+	return sessionID, &user, nil
+}
+
+// CSRFToken возвращает CSRF-токен сессии текущего запроса или пустую строку без сессии.
+// Токен выводится из токена сессии, поэтому не хранится отдельно и меняется при каждом входе.
+func CSRFToken(c *gin.Context) string {
+	token, err := c.Cookie(SessionCookieName)
+	if err != nil || token == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte("csrf"))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// RevokeSession завершает сессию текущего запроса и удаляет cookie
+func RevokeSession(c *gin.Context) error {
+	defer SetCookie(c, SessionCookieName, "", -1)
+
+	token, err := c.Cookie(SessionCookieName)
+	if err != nil || token == "" {
+		return nil
+	}
+
+	_, err = database.DB.Exec(`DELETE FROM sessions WHERE token_hash = $1`, hashToken(token))
+	return err
+}
+
+// ListSessions возвращает активные сессии пользователя
+func ListSessions(userID uuid.UUID) ([]models.Session, error) {
+	now := time.Now()
+	rows, err := database.DB.Query(`
+		SELECT id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, last_seen_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND expires_at > $2 AND last_seen_at > $3
+		ORDER BY last_seen_at DESC
+	`, userID, now, now.Add(-SessionIdleTimeout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		session := models.Session{UserID: userID}
+		if err := rows.Scan(&session.ID, &session.UserAgent, &session.IPAddress,
+			&session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeUserSession завершает сессию пользователя по ID
+func RevokeUserSession(userID, sessionID uuid.UUID) (bool, error) {
+	result, err := database.DB.Exec(`
+		DELETE FROM sessions WHERE id = $1 AND user_id = $2
+	`, sessionID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
+
+// RevokeOtherSessions завершает все сессии пользователя, кроме указанной
+func RevokeOtherSessions(userID, keepID uuid.UUID) (int64, error) {
+	result, err := database.DB.Exec(`
+		DELETE FROM sessions WHERE user_id = $1 AND id <> $2
+	`, userID, keepID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
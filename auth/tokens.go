@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"chimerascan/database"
+	"chimerascan/models"
+
+	"github.com/google/uuid"
+)
+
+// Области действия персональных токенов
+const (
+	ScopeScanWrite     = "scan:write"
+	ScopeScanRead      = "scan:read"
+	ScopeReportsRead   = "reports:read"
+	ScopeProjectsAdmin = "projects:admin"
+	ScopeAuditRead     = "audit:read"
+)
+
+// Scopes - все поддерживаемые области действия
+var Scopes = []string{ScopeScanWrite, ScopeScanRead, ScopeReportsRead, ScopeProjectsAdmin, ScopeAuditRead}
+
+// APITokenPrefix отличает персональные токены от прочих секретов
+const APITokenPrefix = "cs_"
+
+var ErrInvalidToken = errors.New("invalid or expired API token")
+
+// IsValidScope проверяет название области действия
+func IsValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope проверяет, выдана ли токену область действия
+func HasScope(token *models.APIToken, scope string) bool {
+	for _, s := range token.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateAPIToken выпускает персональный токен. Открытое значение возвращается
+// только в поле Token результата, в БД хранится хеш.
+func CreateAPIToken(userID uuid.UUID, name string, scopes []string, expiresAt *time.Time) (*models.APIToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	raw := APITokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	token := &models.APIToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    raw[:len(APITokenPrefix)+6],
+		Token:     raw,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	scopesJSON, _ := json.Marshal(token.Scopes)
+
+	_, err := database.DB.Exec(`
+		INSERT INTO api_tokens (id, user_id, name, token_prefix, token_hash, scopes, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, token.ID, token.UserID, token.Name, token.Prefix, hashToken(raw), string(scopesJSON), token.ExpiresAt, token.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// AuthenticateAPIToken находит пользователя по персональному токену и отмечает его использование
+func AuthenticateAPIToken(raw string) (*models.User, *models.APIToken, error) {
+	if !strings.HasPrefix(raw, APITokenPrefix) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	var token models.APIToken
+	var user models.User
+	var scopesJSON []byte
+	err := database.DB.QueryRow(`
+		SELECT t.id, t.name, t.token_prefix, t.scopes, t.expires_at, t.last_used_at, t.created_at,
+		       u.id, u.provider_id, u.email, u.username, u.created_at, u.is_admin, u.disabled_at
+		FROM api_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1
+	`, hashToken(raw)).Scan(
+		&token.ID, &token.Name, &token.Prefix, &scopesJSON, &token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt,
+		&user.ID, &user.ProviderID, &user.Email, &user.Username, &user.CreatedAt, &user.IsAdmin, &user.DisabledAt,
+	)
+	if err != nil {
+		return nil, nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	if token.ExpiresAt != nil && now.After(*token.ExpiresAt) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	token.UserID = user.ID
+	json.Unmarshal(scopesJSON, &token.Scopes)
+
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) > sessionTouchInterval {
+		database.DB.Exec(`UPDATE api_tokens SET last_used_at = $1 WHERE id = $2`, now, token.ID)
+		token.LastUsedAt = &now
+	}
+
+	return &user, &token, nil
+}
+
+// ListAPITokens возвращает токены пользователя без секретов
+func ListAPITokens(userID uuid.UUID) ([]models.APIToken, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, name, token_prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_tokens
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []models.APIToken{}
+	for rows.Next() {
+		token := models.APIToken{UserID: userID}
+		var scopesJSON []byte
+		if err := rows.Scan(&token.ID, &token.Name, &token.Prefix, &scopesJSON,
+			&token.ExpiresAt, &token.LastUsedAt, &token.CreatedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal(scopesJSON, &token.Scopes)
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// RevokeAPIToken удаляет токен пользователя
+func RevokeAPIToken(userID, tokenID uuid.UUID) (bool, error) {
+	result, err := database.DB.Exec(`
+		DELETE FROM api_tokens WHERE id = $1 AND user_id = $2
+	`, tokenID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	return rowsAffected > 0, nil
+}
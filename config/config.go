@@ -0,0 +1,582 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Config - настройки приложения. Значения берутся из значений по умолчанию,
+// затем из файла конфигурации (YAML или TOML) и переменных окружения, которые имеют приоритет.
+type Config struct {
+	Server    Server    `yaml:"server" toml:"server"`
+	Database  Database  `yaml:"database" toml:"database"`
+	OAuth     OAuth     `yaml:"oauth" toml:"oauth"`
+	OIDC      OIDC      `yaml:"oidc" toml:"oidc"`
+	Sessions  Sessions  `yaml:"sessions" toml:"sessions"`
+	Admin     Admin     `yaml:"admin" toml:"admin"`
+	Storage   Storage   `yaml:"storage" toml:"storage"`
+	Redaction Redaction `yaml:"redaction" toml:"redaction"`
+	Evidence  Evidence  `yaml:"evidence" toml:"evidence"`
+	SMTP      SMTP      `yaml:"smtp" toml:"smtp"`
+	Issues    Issues    `yaml:"issues" toml:"issues"`
+	Quota     Quota     `yaml:"quota" toml:"quota"`
+	RateLimit RateLimit `yaml:"rate_limit" toml:"rate_limit"`
+	Scanner   Scanner   `yaml:"scanner" toml:"scanner"`
+	Retention Retention `yaml:"retention" toml:"retention"`
+}
+
+type Server struct {
+	Port          string `yaml:"port" toml:"port"`
+	PublicBaseURL string `yaml:"public_base_url" toml:"public_base_url"`
+}
+
+// Database - подключение к БД: PostgreSQL или файл SQLite для установок на одном сервере.
+// AutoMigrate применяет новые миграции при запуске; без него схему обновляет команда migrate
+type Database struct {
+	Driver      string `yaml:"driver" toml:"driver"`
+	AutoMigrate bool   `yaml:"auto_migrate" toml:"auto_migrate"`
+	Path        string `yaml:"path" toml:"path"`
+	Host        string `yaml:"host" toml:"host"`
+	Port        string `yaml:"port" toml:"port"`
+	User        string `yaml:"user" toml:"user"`
+	Password    string `yaml:"password" toml:"password"`
+	Name        string `yaml:"name" toml:"name"`
+	SSLMode     string `yaml:"sslmode" toml:"sslmode"`
+}
+
+type OAuthClient struct {
+	ClientID     string `yaml:"client_id" toml:"client_id"`
+	ClientSecret string `yaml:"client_secret" toml:"client_secret"`
+}
+
+type OAuth struct {
+	GitHub        OAuthClient `yaml:"github" toml:"github"`
+	GitLab        OAuthClient `yaml:"gitlab" toml:"gitlab"`
+	GitLabBaseURL string      `yaml:"gitlab_base_url" toml:"gitlab_base_url"`
+}
+
+// OIDC - вход через OpenID Connect; пустой Issuer отключает вход
+type OIDC struct {
+	Name         string   `yaml:"name" toml:"name" json:"name"`
+	Issuer       string   `yaml:"issuer" toml:"issuer" json:"issuer"`
+	ClientID     string   `yaml:"client_id" toml:"client_id" json:"client_id"`
+	ClientSecret string   `yaml:"client_secret" toml:"client_secret" json:"client_secret"`
+	Scopes       []string `yaml:"scopes" toml:"scopes" json:"scopes"`
+}
+
+type Sessions struct {
+	TTL         Duration `yaml:"ttl" toml:"ttl"`
+	IdleTimeout Duration `yaml:"idle_timeout" toml:"idle_timeout"`
+	// CookieSecure - флаг Secure у cookie; если не задан, включается для https в public_base_url
+	CookieSecure *bool `yaml:"cookie_secure,omitempty" toml:"cookie_secure,omitempty"`
+}
+
+type Admin struct {
+	Emails []string `yaml:"emails" toml:"emails"`
+}
+
+type S3 struct {
+	Endpoint  string `yaml:"endpoint" toml:"endpoint"`
+	Region    string `yaml:"region" toml:"region"`
+	Bucket    string `yaml:"bucket" toml:"bucket"`
+	AccessKey string `yaml:"access_key" toml:"access_key"`
+	SecretKey string `yaml:"secret_key" toml:"secret_key"`
+	PathStyle bool   `yaml:"path_style" toml:"path_style"`
+}
+
+// Storage - хранилище отчетов: local (каталог ReportsDir) или s3
+type Storage struct {
+	Backend    string `yaml:"backend" toml:"backend"`
+	ReportsDir string `yaml:"reports_dir" toml:"reports_dir"`
+	S3         S3     `yaml:"s3" toml:"s3"`
+}
+
+type Redaction struct {
+	// RulesFile - JSON-файл с дополнительными правилами скрытия
+	RulesFile string `yaml:"rules_file" toml:"rules_file"`
+}
+
+type Evidence struct {
+	// InlineLimit - размер ответа в байтах, который хранится прямо в БД
+	InlineLimit int `yaml:"inline_limit" toml:"inline_limit"`
+}
+
+// SMTP - отправка писем; пустой Host отключает уведомления
+type SMTP struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     string `yaml:"port" toml:"port"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+	From     string `yaml:"from" toml:"from"`
+}
+
+// Issues - адреса трекеров по умолчанию для проектов без base_url;
+// пустой GitLabBaseURL совпадает с адресом GitLab для входа
+type Issues struct {
+	GitHubAPIURL  string `yaml:"github_api_url" toml:"github_api_url"`
+	GitLabBaseURL string `yaml:"gitlab_base_url" toml:"gitlab_base_url"`
+	// TokenKey - ключ AES-256 в base64 для токенов трекеров в БД; без него токены не сохраняются
+	TokenKey string `yaml:"token_key" toml:"token_key"`
+}
+
+// Quota - квоты по умолчанию; 0 снимает ограничение
+type Quota struct {
+	ConcurrentScans   int   `yaml:"concurrent_scans" toml:"concurrent_scans"`
+	ScansPerDay       int   `yaml:"scans_per_day" toml:"scans_per_day"`
+	TargetsPerProject int   `yaml:"targets_per_project" toml:"targets_per_project"`
+	ReportStorageMB   int64 `yaml:"report_storage_mb" toml:"report_storage_mb"`
+}
+
+// RateLimit - ограничение частоты запросов к API; RPS = 0 отключает ограничение
+type RateLimit struct {
+	RPS   float64 `yaml:"rps" toml:"rps"`
+	Burst int     `yaml:"burst" toml:"burst"`
+}
+
+// Retention - правила хранения сканирований по умолчанию; 0 отключает правило.
+// Проекты и организации переопределяют их, Interval - период запуска очистки (0 - очистка отключена)
+type Retention struct {
+	Interval           Duration `yaml:"interval" toml:"interval"`
+	KeepScansPerTarget int      `yaml:"keep_scans_per_target" toml:"keep_scans_per_target"`
+	DeleteAfterDays    int      `yaml:"delete_after_days" toml:"delete_after_days"`
+	SummaryAfterDays   int      `yaml:"summary_after_days" toml:"summary_after_days"`
+}
+
+// Scanner - запуск Nuclei и модели для описаний находок
+type Scanner struct {
+	Image     string `yaml:"image" toml:"image"`
+	RateLimit int    `yaml:"rate_limit" toml:"rate_limit"`
+	// Timeout - тайм-аут одного запроса Nuclei в секундах
+	Timeout int    `yaml:"timeout" toml:"timeout"`
+	AIModel string `yaml:"ai_model" toml:"ai_model"`
+}
+
+// Duration читается из строк вида "24h" или "30m"
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Default возвращает конфигурацию по умолчанию
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Port:          "8080",
+			PublicBaseURL: "http://localhost:8080",
+		},
+		Database: Database{
+			Driver:      "postgres",
+			AutoMigrate: true,
+			Path:        "chimerascan.db",
+			Port:        "5432",
+			SSLMode:     "disable",
+		},
+		OAuth: OAuth{
+			GitLabBaseURL: "https://gitlab.com",
+		},
+		Sessions: Sessions{
+			TTL:         Duration(7 * 24 * time.Hour),
+			IdleTimeout: Duration(24 * time.Hour),
+		},
+		Storage: Storage{
+			Backend:    "local",
+			ReportsDir: "reports",
+			S3:         S3{PathStyle: true},
+		},
+		Evidence: Evidence{
+			InlineLimit: 64 * 1024,
+		},
+		Issues: Issues{
+			GitHubAPIURL: "https://api.github.com",
+		},
+		Quota: Quota{
+			ConcurrentScans:   3,
+			ScansPerDay:       50,
+			TargetsPerProject: 50,
+			ReportStorageMB:   1024,
+		},
+		RateLimit: RateLimit{
+			RPS:   10,
+			Burst: 30,
+		},
+		Scanner: Scanner{
+			Image:     "projectdiscovery/nuclei:latest",
+			RateLimit: 50,
+			Timeout:   90,
+			AIModel:   "phi:2.7b",
+		},
+		Retention: Retention{
+			Interval: Duration(time.Hour),
+		},
+	}
+}
+
+// Load собирает конфигурацию из файла path (может быть пустым) и переменных окружения и проверяет ее
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+	cfg.resolve()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file format %q: use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// Чтение переменных окружения; ошибки разбора собираются в одно сообщение
+type envReader struct {
+	errs []error
+}
+
+func (r *envReader) string(dst *string, name string) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		*dst = v
+	}
+}
+
+func (r *envReader) int(dst *int, name string) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: %q is not an integer", name, v))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (r *envReader) int64(dst *int64, name string) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: %q is not an integer", name, v))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (r *envReader) float(dst *float64, name string) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: %q is not a number", name, v))
+			return
+		}
+		*dst = n
+	}
+}
+
+func (r *envReader) bool(dst *bool, name string) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: %q is not a boolean", name, v))
+			return
+		}
+		*dst = b
+	}
+}
+
+func (r *envReader) optionalBool(dst **bool, name string) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: %q is not a boolean", name, v))
+			return
+		}
+		*dst = &b
+	}
+}
+
+func (r *envReader) duration(dst *Duration, name string) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		if err := dst.UnmarshalText([]byte(v)); err != nil {
+			r.errs = append(r.errs, fmt.Errorf("%s: %q is not a duration (e.g. 24h)", name, v))
+		}
+	}
+}
+
+// Список через запятую или пробелы
+func (r *envReader) list(dst *[]string, name string) {
+	if v, ok := os.LookupEnv(name); ok && v != "" {
+		*dst = strings.Fields(strings.ReplaceAll(v, ",", " "))
+	}
+}
+
+func (cfg *Config) loadEnv() error {
+	// Старый формат настроек OIDC: JSON-файл, переменные OIDC_* имеют приоритет
+	if path := os.Getenv("OIDC_CONFIG"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read OIDC config: %w", err)
+		}
+		if err := json.Unmarshal(data, &cfg.OIDC); err != nil {
+			return fmt.Errorf("invalid OIDC config: %w", err)
+		}
+	}
+
+	r := &envReader{}
+
+	r.string(&cfg.Server.Port, "SERVER_PORT")
+	r.string(&cfg.Server.PublicBaseURL, "PUBLIC_BASE_URL")
+
+	r.string(&cfg.Database.Driver, "DB_DRIVER")
+	r.string(&cfg.Database.Path, "DB_PATH")
+	r.bool(&cfg.Database.AutoMigrate, "DB_AUTO_MIGRATE")
+	r.string(&cfg.Database.Host, "DB_HOST")
+	r.string(&cfg.Database.Port, "DB_PORT")
+	r.string(&cfg.Database.User, "DB_USER")
+	r.string(&cfg.Database.Password, "DB_PASSWORD")
+	r.string(&cfg.Database.Name, "DB_NAME")
+	r.string(&cfg.Database.SSLMode, "DB_SSLMODE")
+
+	r.string(&cfg.OAuth.GitHub.ClientID, "GITHUB_CLIENT_ID")
+	r.string(&cfg.OAuth.GitHub.ClientSecret, "GITHUB_CLIENT_SECRET")
+	r.string(&cfg.OAuth.GitLab.ClientID, "GITLAB_CLIENT_ID")
+	r.string(&cfg.OAuth.GitLab.ClientSecret, "GITLAB_CLIENT_SECRET")
+	r.string(&cfg.OAuth.GitLabBaseURL, "GITLAB_BASE_URL")
+
+	r.string(&cfg.OIDC.Issuer, "OIDC_ISSUER")
+	r.string(&cfg.OIDC.ClientID, "OIDC_CLIENT_ID")
+	r.string(&cfg.OIDC.ClientSecret, "OIDC_CLIENT_SECRET")
+	r.list(&cfg.OIDC.Scopes, "OIDC_SCOPES")
+	r.string(&cfg.OIDC.Name, "OIDC_PROVIDER_NAME")
+
+	r.duration(&cfg.Sessions.TTL, "SESSION_TTL")
+	r.duration(&cfg.Sessions.IdleTimeout, "SESSION_IDLE_TIMEOUT")
+	r.optionalBool(&cfg.Sessions.CookieSecure, "COOKIE_SECURE")
+
+	r.list(&cfg.Admin.Emails, "ADMIN_EMAILS")
+
+	r.string(&cfg.Storage.Backend, "REPORT_STORAGE")
+	r.string(&cfg.Storage.ReportsDir, "REPORTS_DIR")
+	r.string(&cfg.Storage.S3.Endpoint, "S3_ENDPOINT")
+	r.string(&cfg.Storage.S3.Region, "S3_REGION")
+	r.string(&cfg.Storage.S3.Bucket, "S3_BUCKET")
+	r.string(&cfg.Storage.S3.AccessKey, "S3_ACCESS_KEY")
+	r.string(&cfg.Storage.S3.SecretKey, "S3_SECRET_KEY")
+	r.bool(&cfg.Storage.S3.PathStyle, "S3_PATH_STYLE")
+
+	r.string(&cfg.Redaction.RulesFile, "REDACTION_CONFIG")
+	r.int(&cfg.Evidence.InlineLimit, "EVIDENCE_INLINE_LIMIT")
+
+	r.string(&cfg.SMTP.Host, "SMTP_HOST")
+	r.string(&cfg.SMTP.Port, "SMTP_PORT")
+	r.string(&cfg.SMTP.Username, "SMTP_USERNAME")
+	r.string(&cfg.SMTP.Password, "SMTP_PASSWORD")
+	r.string(&cfg.SMTP.From, "SMTP_FROM")
+
+	r.string(&cfg.Issues.GitHubAPIURL, "GITHUB_API_URL")
+	r.string(&cfg.Issues.GitLabBaseURL, "GITLAB_ISSUES_BASE_URL")
+	r.string(&cfg.Issues.TokenKey, "ISSUE_TOKEN_KEY")
+
+	r.int(&cfg.Quota.ConcurrentScans, "QUOTA_CONCURRENT_SCANS")
+	r.int(&cfg.Quota.ScansPerDay, "QUOTA_SCANS_PER_DAY")
+	r.int(&cfg.Quota.TargetsPerProject, "QUOTA_TARGETS_PER_PROJECT")
+	r.int64(&cfg.Quota.ReportStorageMB, "QUOTA_REPORT_STORAGE_MB")
+	r.float(&cfg.RateLimit.RPS, "RATE_LIMIT_RPS")
+	r.int(&cfg.RateLimit.Burst, "RATE_LIMIT_BURST")
+
+	r.string(&cfg.Scanner.Image, "SCANNER_IMAGE")
+	r.int(&cfg.Scanner.RateLimit, "SCANNER_RATE_LIMIT")
+	r.int(&cfg.Scanner.Timeout, "SCANNER_TIMEOUT")
+	r.string(&cfg.Scanner.AIModel, "AI_MODEL")
+
+	r.duration(&cfg.Retention.Interval, "RETENTION_INTERVAL")
+	r.int(&cfg.Retention.KeepScansPerTarget, "RETENTION_KEEP_SCANS_PER_TARGET")
+	r.int(&cfg.Retention.DeleteAfterDays, "RETENTION_DELETE_AFTER_DAYS")
+	r.int(&cfg.Retention.SummaryAfterDays, "RETENTION_SUMMARY_AFTER_DAYS")
+
+	if len(r.errs) > 0 {
+		return invalid(r.errs)
+	}
+	return nil
+}
+
+// resolve заполняет значения, которые по умолчанию зависят от других настроек
+func (cfg *Config) resolve() {
+	if cfg.Sessions.CookieSecure == nil {
+		secure := strings.HasPrefix(strings.ToLower(cfg.Server.PublicBaseURL), "https://")
+		cfg.Sessions.CookieSecure = &secure
+	}
+	if cfg.Issues.GitLabBaseURL == "" {
+		cfg.Issues.GitLabBaseURL = cfg.OAuth.GitLabBaseURL
+	}
+}
+
+// Validate проверяет конфигурацию и возвращает все найденные ошибки сразу
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(isPort(cfg.Server.Port), "server.port (SERVER_PORT): %q is not a valid port", cfg.Server.Port)
+	check(isHTTPURL(cfg.Server.PublicBaseURL), "server.public_base_url (PUBLIC_BASE_URL): %q is not an absolute http(s) URL", cfg.Server.PublicBaseURL)
+
+	switch cfg.Database.Driver {
+	case "postgres":
+		check(cfg.Database.Host != "", "database.host (DB_HOST) is required")
+		check(isPort(cfg.Database.Port), "database.port (DB_PORT): %q is not a valid port", cfg.Database.Port)
+		check(cfg.Database.User != "", "database.user (DB_USER) is required")
+		check(cfg.Database.Name != "", "database.name (DB_NAME) is required")
+	case "sqlite":
+		check(cfg.Database.Path != "", "database.path (DB_PATH) is required for sqlite")
+	default:
+		check(false, "database.driver (DB_DRIVER): %q is not supported, use postgres or sqlite", cfg.Database.Driver)
+	}
+
+	check(isHTTPURL(cfg.OAuth.GitLabBaseURL), "oauth.gitlab_base_url (GITLAB_BASE_URL): %q is not an absolute http(s) URL", cfg.OAuth.GitLabBaseURL)
+	if cfg.OIDC.Issuer != "" {
+		check(isHTTPURL(cfg.OIDC.Issuer), "oidc.issuer (OIDC_ISSUER): %q is not an absolute http(s) URL", cfg.OIDC.Issuer)
+		check(cfg.OIDC.ClientID != "", "oidc.client_id (OIDC_CLIENT_ID) is required when oidc.issuer is set")
+	}
+
+	check(cfg.Sessions.TTL > 0, "sessions.ttl (SESSION_TTL) must be positive")
+	check(cfg.Sessions.IdleTimeout > 0, "sessions.idle_timeout (SESSION_IDLE_TIMEOUT) must be positive")
+
+	switch cfg.Storage.Backend {
+	case "local":
+		check(cfg.Storage.ReportsDir != "", "storage.reports_dir (REPORTS_DIR) is required for local storage")
+	case "s3":
+		check(cfg.Storage.S3.Bucket != "", "storage.s3.bucket (S3_BUCKET) is required for s3 storage")
+		check(cfg.Storage.S3.AccessKey != "" && cfg.Storage.S3.SecretKey != "",
+			"storage.s3.access_key and storage.s3.secret_key (S3_ACCESS_KEY, S3_SECRET_KEY) are required for s3 storage")
+		check(cfg.Storage.S3.Endpoint == "" || isHTTPURL(cfg.Storage.S3.Endpoint),
+			"storage.s3.endpoint (S3_ENDPOINT): %q is not an absolute http(s) URL", cfg.Storage.S3.Endpoint)
+	default:
+		check(false, "storage.backend (REPORT_STORAGE): unknown backend %q, expected local or s3", cfg.Storage.Backend)
+	}
+
+	check(cfg.Evidence.InlineLimit > 0, "evidence.inline_limit (EVIDENCE_INLINE_LIMIT) must be positive")
+
+	if cfg.SMTP.Host != "" {
+		check(cfg.SMTP.From != "", "smtp.from (SMTP_FROM) is required when smtp.host is set")
+		check(cfg.SMTP.Port == "" || isPort(cfg.SMTP.Port), "smtp.port (SMTP_PORT): %q is not a valid port", cfg.SMTP.Port)
+	}
+
+	check(isHTTPURL(cfg.Issues.GitHubAPIURL), "issues.github_api_url (GITHUB_API_URL): %q is not an absolute http(s) URL", cfg.Issues.GitHubAPIURL)
+	check(isHTTPURL(cfg.Issues.GitLabBaseURL), "issues.gitlab_base_url (GITLAB_ISSUES_BASE_URL): %q is not an absolute http(s) URL", cfg.Issues.GitLabBaseURL)
+	if cfg.Issues.TokenKey != "" {
+		key, err := base64.StdEncoding.DecodeString(cfg.Issues.TokenKey)
+		check(err == nil && len(key) == 32, "issues.token_key (ISSUE_TOKEN_KEY) must be 32 bytes encoded as base64")
+	}
+
+	check(cfg.Quota.ConcurrentScans >= 0, "quota.concurrent_scans (QUOTA_CONCURRENT_SCANS) must not be negative")
+	check(cfg.Quota.ScansPerDay >= 0, "quota.scans_per_day (QUOTA_SCANS_PER_DAY) must not be negative")
+	check(cfg.Quota.TargetsPerProject >= 0, "quota.targets_per_project (QUOTA_TARGETS_PER_PROJECT) must not be negative")
+	check(cfg.Quota.ReportStorageMB >= 0, "quota.report_storage_mb (QUOTA_REPORT_STORAGE_MB) must not be negative")
+	check(cfg.RateLimit.RPS >= 0, "rate_limit.rps (RATE_LIMIT_RPS) must not be negative")
+	check(cfg.RateLimit.Burst > 0, "rate_limit.burst (RATE_LIMIT_BURST) must be positive")
+
+	check(cfg.Scanner.Image != "", "scanner.image (SCANNER_IMAGE) is required")
+	check(cfg.Scanner.RateLimit > 0, "scanner.rate_limit (SCANNER_RATE_LIMIT) must be positive")
+	check(cfg.Scanner.Timeout > 0, "scanner.timeout (SCANNER_TIMEOUT) must be positive")
+	check(cfg.Scanner.AIModel != "", "scanner.ai_model (AI_MODEL) is required")
+
+	check(cfg.Retention.Interval >= 0, "retention.interval (RETENTION_INTERVAL) must not be negative")
+	check(cfg.Retention.KeepScansPerTarget >= 0, "retention.keep_scans_per_target (RETENTION_KEEP_SCANS_PER_TARGET) must not be negative")
+	check(cfg.Retention.DeleteAfterDays >= 0, "retention.delete_after_days (RETENTION_DELETE_AFTER_DAYS) must not be negative")
+	check(cfg.Retention.SummaryAfterDays >= 0, "retention.summary_after_days (RETENTION_SUMMARY_AFTER_DAYS) must not be negative")
+
+	if len(errs) > 0 {
+		return invalid(errs)
+	}
+	return nil
+}
+
+func invalid(errs []error) error {
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = "  - " + err.Error()
+	}
+	return errors.New("invalid configuration:\n" + strings.Join(lines, "\n"))
+}
+
+func isPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port > 0 && port < 65536
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+const redacted = "<redacted>"
+
+// Redacted возвращает копию конфигурации со скрытыми секретами; пустые значения остаются пустыми
+func (cfg *Config) Redacted() *Config {
+	c := *cfg
+	for _, secret := range []*string{
+		&c.Database.Password,
+		&c.OAuth.GitHub.ClientSecret,
+		&c.OAuth.GitLab.ClientSecret,
+		&c.OIDC.ClientSecret,
+		&c.Storage.S3.AccessKey,
+		&c.Storage.S3.SecretKey,
+		&c.SMTP.Password,
+		&c.Issues.TokenKey,
+	} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	return &c
+}
+
+// YAML возвращает конфигурацию со скрытыми секретами в формате YAML
+func (cfg *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(cfg.Redacted())
+}
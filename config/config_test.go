@@ -0,0 +1,212 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Минимальное окружение, при котором конфигурация проходит проверку
+func setRequiredEnv(t *testing.T) {
+	t.Setenv("DB_HOST", "localhost")
+	t.Setenv("DB_USER", "chimera")
+	t.Setenv("DB_NAME", "chimerascan")
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	setRequiredEnv(t)
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+
+	assert.Equal(t, "8080", cfg.Server.Port)
+	assert.Equal(t, "projectdiscovery/nuclei:latest", cfg.Scanner.Image)
+	assert.Equal(t, Duration(7*24*time.Hour), cfg.Sessions.TTL)
+	assert.False(t, *cfg.Sessions.CookieSecure, "Default public URL is plain HTTP")
+	assert.True(t, cfg.Database.AutoMigrate)
+}
+
+func TestLoad_CookieSecureFollowsPublicURL(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("PUBLIC_BASE_URL", "https://scan.example.com")
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+	assert.True(t, *cfg.Sessions.CookieSecure)
+
+	t.Setenv("COOKIE_SECURE", "false")
+	cfg, err = Load("")
+	require.NoError(t, err)
+	assert.False(t, *cfg.Sessions.CookieSecure, "Explicit setting wins")
+
+	t.Setenv("COOKIE_SECURE", "maybe")
+	_, err = Load("")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `COOKIE_SECURE: "maybe" is not a boolean`)
+}
+
+func TestLoad_IssueTokenKey(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("ISSUE_TOKEN_KEY", "c2hvcnQ=")
+
+	_, err := Load("")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "issues.token_key (ISSUE_TOKEN_KEY) must be 32 bytes")
+
+	t.Setenv("ISSUE_TOKEN_KEY", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+	cfg, err := Load("")
+	require.NoError(t, err)
+	out, err := cfg.YAML()
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "AAAA", "The key is redacted")
+}
+
+func TestLoad_GitLabURLs(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("GITLAB_BASE_URL", "https://gitlab.corp.example")
+
+	cfg, err := Load("")
+	require.NoError(t, err)
+	assert.Equal(t, "https://gitlab.corp.example", cfg.OAuth.GitLabBaseURL)
+	assert.Equal(t, "https://gitlab.corp.example", cfg.Issues.GitLabBaseURL, "Issues default to the login instance")
+
+	t.Setenv("GITLAB_ISSUES_BASE_URL", "https://issues.corp.example")
+	cfg, err = Load("")
+	require.NoError(t, err)
+	assert.Equal(t, "https://gitlab.corp.example", cfg.OAuth.GitLabBaseURL)
+	assert.Equal(t, "https://issues.corp.example", cfg.Issues.GitLabBaseURL)
+}
+
+func TestLoad_YAMLFileWithEnvOverride(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("SERVER_PORT", "9090")
+
+	path := writeFile(t, "chimerascan.yaml", `
+server:
+  port: "8000"
+  public_base_url: https://scan.example.com
+sessions:
+  ttl: 12h
+scanner:
+  image: registry.local/nuclei:v3
+  rate_limit: 20
+admin:
+  emails: [root@example.com]
+`)
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "9090", cfg.Server.Port, "Environment overrides the file")
+	assert.Equal(t, "https://scan.example.com", cfg.Server.PublicBaseURL)
+	assert.Equal(t, Duration(12*time.Hour), cfg.Sessions.TTL)
+	assert.Equal(t, "registry.local/nuclei:v3", cfg.Scanner.Image)
+	assert.Equal(t, 20, cfg.Scanner.RateLimit)
+	assert.Equal(t, 90, cfg.Scanner.Timeout, "Values missing in the file keep defaults")
+	assert.Equal(t, []string{"root@example.com"}, cfg.Admin.Emails)
+}
+
+func TestLoad_TOMLFile(t *testing.T) {
+	setRequiredEnv(t)
+
+	path := writeFile(t, "chimerascan.toml", `
+[storage]
+backend = "s3"
+
+[storage.s3]
+bucket = "reports"
+access_key = "AKIA"
+secret_key = "secret"
+
+[rate_limit]
+rps = 2.5
+burst = 5
+`)
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "s3", cfg.Storage.Backend)
+	assert.Equal(t, "reports", cfg.Storage.S3.Bucket)
+	assert.Equal(t, 2.5, cfg.RateLimit.RPS)
+	assert.Equal(t, 5, cfg.RateLimit.Burst)
+}
+
+func TestLoad_ReportsAllErrors(t *testing.T) {
+	t.Setenv("DB_HOST", "")
+	t.Setenv("DB_USER", "")
+	t.Setenv("DB_NAME", "")
+	t.Setenv("REPORT_STORAGE", "ftp")
+	t.Setenv("PUBLIC_BASE_URL", "scan.example.com")
+
+	_, err := Load("")
+	require.Error(t, err)
+
+	for _, want := range []string{"database.host (DB_HOST) is required", "database.name", "storage.backend", "server.public_base_url"} {
+		assert.Contains(t, err.Error(), want)
+	}
+}
+
+func TestLoad_InvalidEnvValue(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("SESSION_TTL", "week")
+	t.Setenv("QUOTA_SCANS_PER_DAY", "many")
+
+	_, err := Load("")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `SESSION_TTL: "week" is not a duration`)
+	assert.Contains(t, err.Error(), `QUOTA_SCANS_PER_DAY: "many" is not an integer`)
+}
+
+func TestLoad_UnsupportedFileFormat(t *testing.T) {
+	setRequiredEnv(t)
+
+	_, err := Load(writeFile(t, "chimerascan.ini", "port=1"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unsupported config file format")
+}
+
+func TestYAML_RedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Password = "db-password"
+	cfg.OAuth.GitHub.ClientSecret = "gh-secret"
+	cfg.Storage.S3.AccessKey = "AKIAEXAMPLE"
+	cfg.SMTP.Password = ""
+
+	out, err := cfg.YAML()
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(out), "db-password")
+	assert.NotContains(t, string(out), "gh-secret")
+	assert.NotContains(t, string(out), "AKIAEXAMPLE")
+	assert.Equal(t, 3, strings.Count(string(out), redacted))
+	assert.Contains(t, string(out), "ttl: 168h0m0s")
+	assert.Equal(t, "db-password", cfg.Database.Password, "Original config is not modified")
+}
+
+func TestLoad_SQLiteDriver(t *testing.T) {
+	t.Setenv("DB_HOST", "")
+	t.Setenv("DB_USER", "")
+	t.Setenv("DB_NAME", "")
+	t.Setenv("DB_DRIVER", "sqlite")
+
+	cfg, err := Load("")
+	require.NoError(t, err, "PostgreSQL settings are not required for sqlite")
+	assert.Equal(t, "chimerascan.db", cfg.Database.Path)
+
+	t.Setenv("DB_DRIVER", "mysql")
+	_, err = Load("")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `database.driver (DB_DRIVER): "mysql" is not supported`)
+}
@@ -4,66 +4,88 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"chimerascan/config"
+
 	_ "github.com/lib/pq"
 )
 
+// Поддерживаемые драйверы БД
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 var DB *sql.DB
 
-func InitDB() error {
-	connStr := fmt.Sprintf(
-		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_NAME"),
-		os.Getenv("DB_SSLMODE"),
-	)
+// Driver - драйвер текущего подключения DB
+var Driver = DriverPostgres
 
-	var err error
-	DB, err = sql.Open("postgres", connStr)
-	if err != nil {
-		return err
-	}
+// IsSQLite сообщает, что приложение работает с SQLite и запросам нужен ее диалект
+func IsSQLite() bool {
+	return Driver == DriverSQLite
+}
 
-	if err = DB.Ping(); err != nil {
+// InitDB подключается к БД и применяет новые миграции, если это не отключено в настройках.
+// Без автоматических миграций запуск только проверяет, что схема не осталась в грязном состоянии
+func InitDB(cfg config.Database) error {
+	if err := Open(cfg); err != nil {
 		return err
 	}
 
 	log.Println("Connected to database successfully")
 
-	// Запуск миграций (автоматически при запуске)
-	if err := runMigrations(); err != nil {
+	if !cfg.AutoMigrate {
+		return CheckMigrations()
+	}
+
+	if err := MigrateUp(0); err != nil {
 		return err
 	}
 
+	log.Println("Database migrations applied successfully")
 	return nil
 }
 
-func runMigrations() error {
-	driver, err := postgres.WithInstance(DB, &postgres.Config{})
-	if err != nil {
-		return err
+// Open подключается к БД без применения миграций
+func Open(cfg config.Database) error {
+	driver := cfg.Driver
+	if driver == "" {
+		driver = DriverPostgres
 	}
 
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://migrations",
-		"postgres", driver)
+	var err error
+	switch driver {
+	case DriverPostgres:
+		DB, err = openPostgres(cfg)
+	case DriverSQLite:
+		DB, err = openSQLite(cfg.Path)
+	default:
+		return fmt.Errorf("unsupported database driver %q", driver)
+	}
 	if err != nil {
 		return err
 	}
+	Driver = driver
+	return nil
+}
 
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return err
+func openPostgres(cfg config.Database) (*sql.DB, error) {
+	connStr := fmt.Sprintf(
+		"host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name, cfg.SSLMode,
+	)
+
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, err
 	}
 
-	log.Println("Database migrations applied successfully")
-	return nil
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
 
 func CloseDB() {
@@ -72,60 +94,14 @@ func CloseDB() {
 	}
 }
 
-// Создание таблиц для интеграционного теста
+// InitTestDB открывает SQLite в памяти и применяет к ней встроенные миграции
 func InitTestDB() error {
 	var err error
-	DB, err = sql.Open("sqlite3", ":memory:")
+	DB, err = openSQLite(":memory:")
 	if err != nil {
 		return err
 	}
+	Driver = DriverSQLite
 
-	if err = DB.Ping(); err != nil {
-		return err
-	}
-
-	return createTestTables()
-}
-
-func createTestTables() error {
-	queries := []string{
-		`CREATE TABLE users (
-            id TEXT PRIMARY KEY,
-            provider_id TEXT NOT NULL,
-            email TEXT NOT NULL,
-            username TEXT NOT NULL,
-            created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-        )`,
-
-		`CREATE TABLE projects (
-            id TEXT PRIMARY KEY,
-            name TEXT NOT NULL,
-            description TEXT,
-            user_id TEXT NOT NULL,
-            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-            FOREIGN KEY (user_id) REFERENCES users(id)
-        )`,
-
-		`CREATE TABLE scans (
-            id TEXT PRIMARY KEY,
-            target_url TEXT NOT NULL,
-            status TEXT NOT NULL,
-            project_id TEXT,
-            started_at DATETIME,
-            finished_at DATETIME,
-            user_id TEXT NOT NULL,
-            created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-            FOREIGN KEY (user_id) REFERENCES users(id),
-            FOREIGN KEY (project_id) REFERENCES projects(id)
-        )`,
-	}
-
-	for _, query := range queries {
-		_, err := DB.Exec(query)
-		if err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return MigrateUp(0)
 }
@@ -3,29 +3,34 @@ package database
 import (
 	"os"
 	"testing"
+	"time"
+
+	"chimerascan/config"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInitDB_MissingRequiredEnv(t *testing.T) {
+	full := config.Database{Host: "127.0.0.1", Port: "1", User: "test", Name: "test", SSLMode: "disable"}
+
 	testCases := []struct {
-		name   string
-		envVar string
+		name  string
+		clear func(cfg *config.Database)
 	}{
-		{"Missing DB_HOST", "DB_HOST"},
-		{"Missing DB_PORT", "DB_PORT"},
-		{"Missing DB_USER", "DB_USER"},
-		{"Missing DB_NAME", "DB_NAME"},
+		{"Missing DB_HOST", func(cfg *config.Database) { cfg.Host = "" }},
+		{"Missing DB_PORT", func(cfg *config.Database) { cfg.Port = "" }},
+		{"Missing DB_USER", func(cfg *config.Database) { cfg.User = "" }},
+		{"Missing DB_NAME", func(cfg *config.Database) { cfg.Name = "" }},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			oldValue := os.Getenv(tc.envVar)
-			os.Unsetenv(tc.envVar)
-			defer os.Setenv(tc.envVar, oldValue)
+			cfg := full
+			tc.clear(&cfg)
 
-			err := InitDB()
-			assert.Error(t, err, "Should return error when %s is missing", tc.envVar)
+			err := InitDB(cfg)
+			assert.Error(t, err, "Should return error for %s", tc.name)
 		})
 	}
 }
@@ -76,11 +81,7 @@ func TestDatabase_ConnectionString(t *testing.T) {
 }
 
 func TestInitDB_MissingEnv(t *testing.T) {
-	oldDBHost := os.Getenv("DB_HOST")
-	os.Unsetenv("DB_HOST")
-	defer os.Setenv("DB_HOST", oldDBHost)
-
-	err := InitDB()
+	err := InitDB(config.Database{})
 	assert.Error(t, err, "Should return error when DB_HOST is missing")
 	assert.NotNil(t, err, "Error should not be nil")
 }
@@ -94,3 +95,52 @@ func TestCloseDB(t *testing.T) {
 		CloseDB()
 	}
 }
+
+func TestInitTestDB_SQLiteMigrations(t *testing.T) {
+	require.NoError(t, InitTestDB())
+	defer CloseDB()
+	assert.True(t, IsSQLite())
+
+	_, err := DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`,
+		"11111111-1111-1111-1111-111111111111", "oidc:abc", "a@example.com", "alice")
+	require.NoError(t, err)
+
+	// Параметры в порядке, отличном от порядка появления в запросе, как в запросах rbac
+	var username string
+	err = DB.QueryRow(`SELECT username FROM users WHERE email = $2 AND id = $1`,
+		"11111111-1111-1111-1111-111111111111", "a@example.com").Scan(&username)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", username)
+
+	var oldest NullTime
+	require.NoError(t, DB.QueryRow(`SELECT MIN(created_at) FROM users`).Scan(&oldest))
+	assert.True(t, oldest.Valid)
+	assert.WithinDuration(t, time.Now(), oldest.Time, time.Minute)
+
+	_, err = DB.Exec(`INSERT INTO audit_events (id, action) VALUES ($1, $2)`, "22222222-2222-2222-2222-222222222222", "project.create")
+	require.NoError(t, err)
+	_, err = DB.Exec(`DELETE FROM audit_events`)
+	assert.Error(t, err, "audit_events is append-only")
+}
+
+func TestRebind_SkipsQuotedText(t *testing.T) {
+	for query, want := range map[string]string{
+		`SELECT * FROM scans WHERE id = $1 AND user_id = $12`: `SELECT * FROM scans WHERE id = ?1 AND user_id = ?12`,
+		`SELECT '$1', "col$2", name FROM t WHERE a = $1`:      `SELECT '$1', "col$2", name FROM t WHERE a = ?1`,
+		`SELECT 'it''s $1' WHERE a = $2`:                      `SELECT 'it''s $1' WHERE a = ?2`,
+		"SELECT a -- costs $5\nFROM t WHERE b = $1":           "SELECT a -- costs $5\nFROM t WHERE b = ?1",
+		`SELECT /* $1 */ a FROM t WHERE b = $1`:               `SELECT /* $1 */ a FROM t WHERE b = ?1`,
+		`SELECT 'unterminated $1`:                             `SELECT 'unterminated $1`,
+		`SELECT $ FROM t`:                                     `SELECT $ FROM t`,
+	} {
+		assert.Equal(t, want, rebind(query), query)
+	}
+
+	require.NoError(t, InitTestDB())
+	defer CloseDB()
+
+	var literal, param string
+	require.NoError(t, DB.QueryRow(`SELECT 'price: $1', $1`, "value").Scan(&literal, &param))
+	assert.Equal(t, "price: $1", literal)
+	assert.Equal(t, "value", param)
+}
@@ -0,0 +1,31 @@
+package database
+
+import "fmt"
+
+// Фрагменты SQL, которые пишутся по-разному в PostgreSQL и SQLite.
+// Остальные запросы приложения одинаковы для обоих драйверов.
+
+// WeekStart - дата понедельника недели, в которую попадает время expr, в формате YYYY-MM-DD
+func WeekStart(expr string) string {
+	if IsSQLite() {
+		return fmt.Sprintf("DATE(%s, 'weekday 0', '-6 days')", expr)
+	}
+	return fmt.Sprintf("TO_CHAR(DATE_TRUNC('week', %s), 'YYYY-MM-DD')", expr)
+}
+
+// SecondsBetween - число секунд от времени from до времени to
+func SecondsBetween(from, to string) string {
+	if IsSQLite() {
+		return fmt.Sprintf("((JULIANDAY(%s) - JULIANDAY(%s)) * 86400.0)", to, from)
+	}
+	return fmt.Sprintf("EXTRACT(EPOCH FROM (%s - %s))", to, from)
+}
+
+// JSONArrayJoin присоединяет элементы JSON-массива column как строки alias со столбцом value.
+// Значения, не являющиеся массивом (NULL, null, объект), дают пустой набор
+func JSONArrayJoin(column, alias string) string {
+	if IsSQLite() {
+		return fmt.Sprintf("CROSS JOIN json_each(CASE WHEN json_type(%[1]s) = 'array' THEN %[1]s ELSE '[]' END) AS %[2]s", column, alias)
+	}
+	return fmt.Sprintf("CROSS JOIN LATERAL jsonb_array_elements_text(CASE WHEN jsonb_typeof(%[1]s) = 'array' THEN %[1]s ELSE '[]'::jsonb END) AS %[2]s(value)", column, alias)
+}
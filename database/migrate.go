@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"chimerascan/migrations"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratedb "github.com/golang-migrate/migrate/v4/database"
+	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite3"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+)
+
+// MigrationState - миграция из встроенного набора и признак того, что она применена
+type MigrationState struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+// withMigrate выполняет fn над миграциями текущего драйвера.
+// Закрытие migrate.Migrate закрыло бы и DB, поэтому для PostgreSQL берется отдельное соединение
+func withMigrate(fn func(m *migrate.Migrate) error) error {
+	src, err := iofs.New(migrations.FS, Driver)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	var driver migratedb.Driver
+	if IsSQLite() {
+		driver, err = sqlite3.WithInstance(DB, &sqlite3.Config{})
+	} else {
+		ctx := context.Background()
+		conn, connErr := DB.Conn(ctx)
+		if connErr != nil {
+			return connErr
+		}
+		defer conn.Close()
+		driver, err = postgres.WithConnection(ctx, conn, &postgres.Config{})
+	}
+	if err != nil {
+		return err
+	}
+
+	m, err := migrate.NewWithInstance("iofs", src, Driver, driver)
+	if err != nil {
+		return err
+	}
+	m.Log = migrateLogger{}
+
+	return explainDirty(fn(m))
+}
+
+// Грязное состояние остается после миграции, упавшей на середине: migrate не знает,
+// какая часть изменений применилась, и отказывается продолжать
+func explainDirty(err error) error {
+	var dirty migrate.ErrDirty
+	if !errors.As(err, &dirty) {
+		return err
+	}
+	return fmt.Errorf(
+		"database schema is dirty: migration %d failed part-way. Repair the schema by hand, "+
+			"then run `chimerascan migrate force %d` if the migration is now fully applied "+
+			"or `chimerascan migrate force %d` if it was rolled back",
+		dirty.Version, dirty.Version, dirty.Version-1,
+	)
+}
+
+// MigrateUp применяет steps новых миграций; 0 - все
+func MigrateUp(steps int) error {
+	return withMigrate(func(m *migrate.Migrate) error {
+		var err error
+		if steps > 0 {
+			err = m.Steps(steps)
+		} else {
+			err = m.Up()
+		}
+		if err == migrate.ErrNoChange {
+			return nil
+		}
+		return err
+	})
+}
+
+// MigrateDown откатывает steps последних миграций; 0 - все
+func MigrateDown(steps int) error {
+	return withMigrate(func(m *migrate.Migrate) error {
+		var err error
+		if steps > 0 {
+			err = m.Steps(-steps)
+		} else {
+			err = m.Down()
+		}
+		if err == migrate.ErrNoChange {
+			return nil
+		}
+		return err
+	})
+}
+
+// ForceMigrationVersion записывает версию схемы и снимает признак грязного состояния, не выполняя миграций
+func ForceMigrationVersion(version int) error {
+	return withMigrate(func(m *migrate.Migrate) error {
+		return m.Force(version)
+	})
+}
+
+// MigrationVersion возвращает текущую версию схемы; 0 - миграции не применялись
+func MigrationVersion() (version uint, dirty bool, err error) {
+	err = withMigrate(func(m *migrate.Migrate) error {
+		version, dirty, err = m.Version()
+		if err == migrate.ErrNilVersion {
+			return nil
+		}
+		return err
+	})
+	return version, dirty, err
+}
+
+// MigrationStatus перечисляет встроенные миграции и отмечает примененные
+func MigrationStatus() ([]MigrationState, error) {
+	current, _, err := MigrationVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := iofs.New(migrations.FS, Driver)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var states []MigrationState
+	version, err := src.First()
+	for err == nil {
+		up, name, readErr := src.ReadUp(version)
+		if readErr != nil {
+			return nil, readErr
+		}
+		up.Close()
+		states = append(states, MigrationState{Version: version, Name: name, Applied: version <= current})
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return states, nil
+}
+
+// CheckMigrations проверяет схему при запуске без автоматических миграций:
+// грязное состояние - ошибка, непримененные миграции только упоминаются в журнале
+func CheckMigrations() error {
+	version, dirty, err := MigrationVersion()
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return explainDirty(migrate.ErrDirty{Version: int(version)})
+	}
+
+	states, err := MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	pending := 0
+	for _, state := range states {
+		if !state.Applied {
+			pending++
+		}
+	}
+	if pending > 0 {
+		log.Printf("Automatic migrations are disabled and %d migrations are pending: run `chimerascan migrate up`", pending)
+	}
+	return nil
+}
+
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...interface{}) {
+	log.Printf("migrate: "+format, v...)
+}
+
+func (migrateLogger) Verbose() bool {
+	return false
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations_StatusAndDown(t *testing.T) {
+	require.NoError(t, InitTestDB())
+	defer CloseDB()
+
+	states, err := MigrationStatus()
+	require.NoError(t, err)
+	require.NotEmpty(t, states)
+	assert.Equal(t, "init_schema", states[0].Name)
+	for _, state := range states {
+		assert.True(t, state.Applied, "migration %d should be applied", state.Version)
+	}
+
+	latest := states[len(states)-1].Version
+	require.NoError(t, MigrateDown(1))
+
+	version, dirty, err := MigrationVersion()
+	require.NoError(t, err)
+	assert.Equal(t, latest-1, version)
+	assert.False(t, dirty)
+
+	states, err = MigrationStatus()
+	require.NoError(t, err)
+	assert.False(t, states[len(states)-1].Applied)
+
+	require.NoError(t, CheckMigrations(), "Pending migrations do not prevent startup")
+	require.NoError(t, MigrateUp(0))
+}
+
+func TestMigrations_DirtyState(t *testing.T) {
+	require.NoError(t, InitTestDB())
+	defer CloseDB()
+
+	_, err := DB.Exec(`UPDATE schema_migrations SET dirty = TRUE`)
+	require.NoError(t, err)
+
+	err = CheckMigrations()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database schema is dirty")
+	assert.Contains(t, err.Error(), "chimerascan migrate force")
+
+	err = MigrateUp(0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "database schema is dirty")
+
+	version, _, err := MigrationVersion()
+	require.NoError(t, err)
+	require.NoError(t, ForceMigrationVersion(int(version)))
+	assert.NoError(t, CheckMigrations())
+}
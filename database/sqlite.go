@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Имя драйвера SQLite с поддержкой запросов в синтаксисе PostgreSQL
+const sqliteDriverName = "chimerascan-sqlite"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqliteDriver{})
+}
+
+func openSQLite(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path)
+	if path == ":memory:" {
+		dsn = "file::memory:?_foreign_keys=on"
+	} else {
+		dsn += "&_journal_mode=WAL"
+	}
+
+	db, err := sql.Open(sqliteDriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Каждое подключение к :memory: открывает свою пустую базу
+	if path == ":memory:" {
+		db.SetMaxOpenConns(1)
+	}
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Параметры PostgreSQL $1, $2 ... переводятся в ?1, ?2: SQLite нумерует параметры вида $N
+// в порядке появления в запросе, а ?N - по номеру, как PostgreSQL. Строки в кавычках,
+// идентификаторы в двойных кавычках и комментарии остаются без изменений
+func rebind(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	for i := 0; i < len(query); {
+		var end int
+		switch {
+		case query[i] == '\'' || query[i] == '"':
+			// Удвоенная кавычка внутри строки разбирается как две строки подряд
+			end = skipTo(query, i+1, query[i:i+1])
+		case strings.HasPrefix(query[i:], "--"):
+			end = skipTo(query, i+2, "\n")
+		case strings.HasPrefix(query[i:], "/*"):
+			end = skipTo(query, i+2, "*/")
+		case query[i] == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			b.WriteByte('?')
+			i++
+			continue
+		default:
+			end = i + 1
+		}
+		b.WriteString(query[i:end])
+		i = end
+	}
+	return b.String()
+}
+
+// skipTo возвращает позицию после первого вхождения sep начиная с from или конец запроса
+func skipTo(query string, from int, sep string) int {
+	if n := strings.Index(query[from:], sep); n >= 0 {
+		return from + n + len(sep)
+	}
+	return len(query)
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// Время сохраняется строкой, поэтому приводится к UTC: так строки сравниваются в порядке времени
+func utcArgs(args []driver.NamedValue) []driver.NamedValue {
+	for i, arg := range args {
+		if t, ok := arg.Value.(time.Time); ok {
+			args[i].Value = t.UTC()
+		}
+	}
+	return args
+}
+
+type sqliteDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+func (d *sqliteDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+type sqliteConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c *sqliteConn) Prepare(query string) (driver.Stmt, error) {
+	return c.SQLiteConn.Prepare(rebind(query))
+}
+
+func (c *sqliteConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.SQLiteConn.PrepareContext(ctx, rebind(query))
+}
+
+func (c *sqliteConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.SQLiteConn.ExecContext(ctx, rebind(query), utcArgs(args))
+}
+
+func (c *sqliteConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.SQLiteConn.QueryContext(ctx, rebind(query), utcArgs(args))
+}
+
+// NullTime читает время и из PostgreSQL, и из SQLite, где результат агрегатных функций
+// (MIN, MAX) над временем возвращается строкой
+type NullTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *NullTime) Scan(value interface{}) error {
+	t.Time, t.Valid = time.Time{}, false
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		t.Time, t.Valid = v, true
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+	return fmt.Errorf("cannot scan %T into NullTime", value)
+}
+
+func (t *NullTime) parse(s string) error {
+	for _, layout := range sqlite3.SQLiteTimestampFormats {
+		if parsed, err := time.ParseInLocation(layout, s, time.UTC); err == nil {
+			t.Time, t.Valid = parsed, true
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse time %q", s)
+}
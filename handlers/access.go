@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"net/http"
+
+	"chimerascan/rbac"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ответ на ошибку проверки прав: недоступный ресурс не отличается от несуществующего
+func respondAccessError(c *gin.Context, err error, notFound string) {
+	switch err {
+	case rbac.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	case rbac.ErrForbidden:
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+	}
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/auth"
+	"chimerascan/models"
+	"chimerascan/rbac"
+	"chimerascan/storage"
+	"chimerascan/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Пользователь в консоли администратора
+type adminUser struct {
+	models.User
+	ScanCount int `json:"scan_count"`
+}
+
+// Сканирование в консоли администратора
+type adminScan struct {
+	models.Scan
+	Username string `json:"username"`
+}
+
+// AdminGetUsers возвращает всех пользователей
+func (s *Server) AdminGetUsers(c *gin.Context) {
+	summaries, err := s.Users.ListUsers(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+
+	users := make([]adminUser, 0, len(summaries))
+	for _, summary := range summaries {
+		user := adminUser{User: summary.User, ScanCount: summary.ScanCount}
+		user.IsAdmin = auth.IsAdmin(&user.User)
+		users = append(users, user)
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+// ID пользователя из параметра :id; администратор не может отключить или удалить сам себя
+func adminTargetUser(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return uuid.Nil, false
+	}
+	if userID == c.MustGet("userID").(uuid.UUID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot apply this action to yourself"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// AdminDisableUser отключает пользователя и завершает его сессии
+func (s *Server) AdminDisableUser(c *gin.Context) {
+	userID, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	err := s.Users.DisableUser(c.Request.Context(), userID, time.Now())
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable user"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{Action: audit.ActionUserDisable, TargetType: "user", TargetID: userID.String()})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User disabled successfully"})
+}
+
+// AdminEnableUser снова разрешает пользователю вход
+func (s *Server) AdminEnableUser(c *gin.Context) {
+	userID, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	err := s.Users.EnableUser(c.Request.Context(), userID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable user"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{Action: audit.ActionUserEnable, TargetType: "user", TargetID: userID.String()})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User enabled successfully"})
+}
+
+// AdminDeleteUser удаляет пользователя вместе с его личными проектами, сканированиями, файлами
+// отчетов и вынесенными ответами; выполняющиеся личные сканирования останавливаются.
+// Проекты и сканирования пользователя в организациях переходят к другому владельцу организации.
+// Последнего владельца организации удалить нельзя, чтобы организация не осталась без управления.
+func (s *Server) AdminDeleteUser(c *gin.Context) {
+	userID, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	deleted, err := s.Users.DeleteUser(ctx, userID)
+	switch err {
+	case nil:
+	case store.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	case store.ErrLastOwner:
+		c.JSON(http.StatusConflict, gin.H{"error": "User is the last owner of an organization"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		return
+	}
+
+	// Процесс Nuclei не должен пережить запись своего сканирования
+	for _, scanID := range deleted.ActiveScans {
+		killScanProcess(scanID)
+	}
+
+	removed := removeReportFiles(ctx, deleted.Files.ReportPaths) + storage.DeleteAll(ctx, deleted.Files.EvidenceKeys)
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionUserDelete,
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Details:    map[string]interface{}{"report_files": removed},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// AdminGetScans возвращает выполняющиеся сканирования всех пользователей
+func (s *Server) AdminGetScans(c *gin.Context) {
+	active, err := s.Scans.ListActiveScans(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scans"})
+		return
+	}
+
+	scans := make([]adminScan, 0, len(active))
+	for _, scan := range active {
+		scans = append(scans, adminScan{Scan: scan.Scan, Username: scan.Username})
+	}
+
+	c.JSON(http.StatusOK, scans)
+}
+
+// AdminCancelScan останавливает сканирование любого пользователя
+func (s *Server) AdminCancelScan(c *gin.Context) {
+	scanID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid scan ID"})
+		return
+	}
+
+	if _, err := s.Scans.GetScan(c.Request.Context(), scanID); err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scan not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scan"})
+		return
+	}
+
+	canceled, err := s.cancelScan(c.Request.Context(), scanID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel scan"})
+		return
+	}
+	if !canceled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Scan is not running"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionScanStop,
+		TargetType: rbac.KindScan,
+		TargetID:   scanID.String(),
+		Details:    map[string]interface{}{"admin": true},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Scan canceled successfully"})
+}
+
+// AdminRestoreProject восстанавливает удаленный проект вместе с его сканированиями
+func (s *Server) AdminRestoreProject(c *gin.Context) {
+	projectID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+		return
+	}
+
+	err = s.Projects.RestoreProject(c.Request.Context(), projectID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Deleted project not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore project"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{Action: audit.ActionProjectRestore, TargetType: rbac.KindProject, TargetID: projectID.String()})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project restored successfully"})
+}
+
+// AdminGetQueue возвращает глубину очереди сканирований
+func (s *Server) AdminGetQueue(c *gin.Context) {
+	queued, inProgress, err := s.Scans.ScanQueue(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"queued":           queued,
+		"in_progress":      inProgress,
+		"active_processes": activeScanCount(),
+	})
+}
+
+// AdminPurgeReports удаляет файлы отчетов сканирований, завершенных раньше older_than_days дней назад.
+// Записи сканирований и найденные уязвимости сохраняются.
+func (s *Server) AdminPurgeReports(c *gin.Context) {
+	var req struct {
+		OlderThanDays int `json:"older_than_days" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	reports, err := s.Scans.ReportsFinishedBefore(ctx, time.Now().AddDate(0, 0, -req.OlderThanDays))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to purge reports"})
+		return
+	}
+
+	var removed int
+	for scanID, paths := range reports {
+		if err := s.Scans.ClearScanReports(ctx, scanID); err != nil {
+			log.Printf("Failed to clear reports of scan %s: %v", scanID, err)
+			continue
+		}
+		removed += removeReportFiles(ctx, paths)
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionReportsPurge,
+		TargetType: "reports",
+		Details: map[string]interface{}{
+			"older_than_days": req.OlderThanDays,
+			"scans":           len(reports),
+			"files":           removed,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"scans": len(reports), "files": removed})
+}
+
+// Удаление файлов отчетов из хранилища; возвращает число удаленных файлов
+func removeReportFiles(ctx context.Context, paths []string) int {
+	keys := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path != "" {
+			keys = append(keys, storage.ReportKey(path))
+		}
+	}
+	return storage.DeleteAll(ctx, keys)
+}
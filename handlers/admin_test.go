@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"os/exec"
+	"testing"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/database"
+	"chimerascan/models"
+	"chimerascan/rbac"
+	"chimerascan/storage"
+	"chimerascan/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminDisableUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	adminID := uuid.New()
+	target := models.User{ID: uuid.New(), Username: "target", CreatedAt: time.Now()}
+	s.store.AddUser(target)
+
+	w := call(s.AdminDisableUser, adminID, "POST", "/api/admin/users/"+adminID.String()+"/disable", idParam(adminID), nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Admin cannot disable themselves")
+
+	w = call(s.AdminDisableUser, adminID, "POST", "/api/admin/users/"+target.ID.String()+"/disable", idParam(target.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	user, err := s.store.GetUser(context.Background(), target.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, user.DisabledAt)
+	if assert.Len(t, s.events, 1) {
+		assert.Equal(t, audit.ActionUserDisable, s.events[0].Action)
+	}
+
+	missing := uuid.New()
+	w = call(s.AdminDisableUser, adminID, "POST", "/api/admin/users/"+missing.String()+"/disable", idParam(missing), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminDeleteUser_LastOrganizationOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	target := models.User{ID: uuid.New(), Username: "owner", CreatedAt: time.Now()}
+	s.store.AddUser(target)
+	s.store.AddMember(uuid.New(), target.ID, rbac.RoleOwner)
+
+	w := call(s.AdminDeleteUser, uuid.New(), "DELETE", "/api/admin/users/"+target.ID.String(), idParam(target.ID), nil)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	_, err := s.store.GetUser(context.Background(), target.ID)
+	assert.NoError(t, err, "User is kept")
+	assert.Empty(t, s.events)
+}
+
+func TestAdminDeleteUser_KeepsOrganizationProjects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	s := newMemoryServer()
+	ownerID, memberID, bobID := uuid.New(), uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{ownerID, memberID, bobID} {
+		s.store.AddUser(models.User{ID: id, Username: id.String()})
+	}
+	orgID := s.newOrganization(t, ownerID)
+	s.store.AddMember(orgID, memberID, rbac.RoleAnalyst)
+	s.store.AddMember(orgID, bobID, rbac.RoleAnalyst)
+	project := models.Project{ID: uuid.New(), Name: "Shared", UserID: memberID, OrganizationID: &orgID, CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateProject(ctx, &project))
+	bobScan := s.addScan(t, bobID, &project.ID, time.Now())
+
+	w := call(s.AdminDeleteUser, uuid.New(), "DELETE", "/api/admin/users/"+memberID.String(), idParam(memberID), nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	kept, err := s.store.GetProject(ctx, project.ID)
+	require.NoError(t, err, "The organization project survives its creator")
+	assert.Equal(t, ownerID, kept.UserID)
+	scan, err := s.store.GetScan(ctx, bobScan.ID)
+	require.NoError(t, err)
+	if assert.NotNil(t, scan.ProjectID) {
+		assert.Equal(t, project.ID, *scan.ProjectID)
+	}
+}
+
+func TestAdminDeleteUser_RemovesFilesAndStopsScans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+	reports, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	oldReports := storage.Reports
+	storage.Reports = reports
+	defer func() { storage.Reports = oldReports }()
+
+	ctx := context.Background()
+	s := NewServer(store.NewPostgres(database.DB))
+	s.Audit = func(*gin.Context, audit.Entry) {}
+	userID := uuid.New()
+	_, err = database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, userID, userID.String(), "user@example.com", "user")
+	require.NoError(t, err)
+
+	completed := models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: "Queued", UserID: userID, CreatedAt: time.Now()}
+	running := models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: "Queued", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, s.Scans.CreateScans(ctx, []*models.Scan{&completed, &running}))
+	require.NoError(t, s.Scans.StartScan(ctx, completed.ID, time.Now()))
+	require.NoError(t, s.Scans.CompleteScan(ctx, completed.ID, store.ScanCompletion{FinishedAt: time.Now(), ReportPaths: map[string]string{"json": "scan.json"}}))
+	require.NoError(t, s.Scans.StartScan(ctx, running.ID, time.Now()))
+	evidenceKey := "evidence/" + completed.ID.String() + "/response.gz"
+	vuln := models.Vulnerability{ID: uuid.New(), ScanID: completed.ID, TemplateID: "xss", Name: "XSS", Severity: "high", SeverityAI: "high", Host: "example.com", EvidenceKey: &evidenceKey}
+	require.NoError(t, s.Vulnerabilities.CreateVulnerability(ctx, &vuln))
+	for _, key := range []string{"scan.json", evidenceKey} {
+		require.NoError(t, reports.Put(ctx, key, []byte("data"), "text/plain"))
+	}
+
+	// Процесс выполняющегося сканирования
+	cmd := exec.Command("sleep", "30")
+	require.NoError(t, cmd.Start())
+	activeScansMu.Lock()
+	activeScans[running.ID] = cmd
+	activeScansMu.Unlock()
+
+	w := call(s.AdminDeleteUser, uuid.New(), "DELETE", "/api/admin/users/"+userID.String(), idParam(userID), nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.Error(t, cmd.Wait(), "Scan process is killed")
+	for _, key := range []string{"scan.json", evidenceKey} {
+		_, err := reports.Get(ctx, key)
+		assert.ErrorIs(t, err, storage.ErrNotFound, key)
+	}
+	_, err = s.Scans.GetScan(ctx, running.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func TestAdminPurgeReports(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reports, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	oldReports := storage.Reports
+	storage.Reports = reports
+	defer func() { storage.Reports = oldReports }()
+
+	ctx := context.Background()
+	s := newMemoryServer()
+	userID := uuid.New()
+	old := s.addScan(t, userID, nil, time.Now().AddDate(0, 0, -40))
+	recent := s.addScan(t, userID, nil, time.Now())
+
+	jsonKey := "chimerascan_report_" + old.ID.String() + "_1.json"
+	pdfKey := "chimerascan_report_" + old.ID.String() + "_1.pdf"
+	recentKey := "chimerascan_report_" + recent.ID.String() + "_1.json"
+	reports.Put(ctx, jsonKey, []byte(`{}`), "application/json")
+	reports.Put(ctx, pdfKey, []byte(`%PDF`), "application/pdf")
+	reports.Put(ctx, recentKey, []byte(`{}`), "application/json")
+
+	require.NoError(t, s.store.UpdateScanStatus(ctx, old.ID, "In Progress", nil))
+	require.NoError(t, s.store.CompleteScan(ctx, old.ID, store.ScanCompletion{
+		FinishedAt:  time.Now().AddDate(0, 0, -40),
+		ReportPaths: map[string]string{"json": jsonKey, "pdf": pdfKey},
+	}))
+	s.completeScan(t, recent.ID, map[string]string{"json": recentKey})
+
+	w := call(s.AdminPurgeReports, uuid.New(), "POST", "/api/admin/reports/purge", nil, map[string]int{"older_than_days": 30})
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"scans": 1, "files": 2}`, w.Body.String())
+
+	_, err = reports.Get(ctx, jsonKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound, "Report file should be removed from storage")
+	_, err = reports.Get(ctx, recentKey)
+	assert.NoError(t, err, "Recent reports are kept")
+
+	scan, err := s.store.GetScan(ctx, old.ID)
+	require.NoError(t, err)
+	assert.Empty(t, scan.ReportJSONPath)
+	if assert.Len(t, s.events, 1) {
+		assert.Equal(t, audit.ActionReportsPurge, s.events[0].Action)
+	}
+}
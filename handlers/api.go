@@ -1,25 +1,32 @@
 package handlers
 
 import (
-	"database/sql"
+	"io"
 	"log"
 	"net/http"
+	"slices"
 	"time"
 
-	"chimerascan/database"
+	"chimerascan/audit"
 	"chimerascan/models"
+	"chimerascan/quota"
+	"chimerascan/rbac"
+	"chimerascan/storage"
+	"chimerascan/store"
+	"chimerascan/webhooks"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 // CreateProject создает новый проект
-func CreateProject(c *gin.Context) {
+func (s *Server) CreateProject(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 
 	var req struct {
-		Name        string `json:"name" binding:"required"`
-		Description string `json:"description"`
+		Name           string `json:"name" binding:"required"`
+		Description    string `json:"description"`
+		OrganizationID string `json:"organization_id"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -27,79 +34,200 @@ func CreateProject(c *gin.Context) {
 		return
 	}
 
+	var organizationID *uuid.UUID
+	if req.OrganizationID != "" {
+		if err := s.authorize(c, rbac.KindOrganization, req.OrganizationID, rbac.PermProjectManage); err != nil {
+			respondAccessError(c, err, "Organization not found")
+			return
+		}
+		orgID := uuid.MustParse(req.OrganizationID)
+		organizationID = &orgID
+	}
+
 	project := models.Project{
-		ID:          uuid.New(),
-		Name:        req.Name,
-		Description: req.Description,
-		UserID:      userID,
-		CreatedAt:   time.Now(),
+		ID:             uuid.New(),
+		Name:           req.Name,
+		Description:    req.Description,
+		UserID:         userID,
+		OrganizationID: organizationID,
+		CreatedAt:      time.Now(),
 	}
 
-	query := `INSERT INTO projects (id, name, description, user_id, created_at) VALUES ($1, $2, $3, $4, $5)`
-	_, err := database.DB.Exec(query, project.ID, project.Name, project.Description, project.UserID, project.CreatedAt)
-	if err != nil {
+	if err := s.Projects.CreateProject(c.Request.Context(), &project); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create project"})
 		return
 	}
 
+	s.Audit(c, audit.Entry{
+		Action:         audit.ActionProjectCreate,
+		TargetType:     rbac.KindProject,
+		TargetID:       project.ID.String(),
+		OrganizationID: organizationID,
+		Details:        map[string]interface{}{"name": project.Name},
+	})
+
 	c.JSON(http.StatusCreated, project)
 }
 
-// GetProjects возвращает список проектов пользователя
-func GetProjects(c *gin.Context) {
+// GetProjects возвращает страницу личных проектов пользователя и проектов его организаций.
+// Фильтры: archived=true (только архивные), name (подстрока названия); сортировка по created_at или name
+func (s *Server) GetProjects(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 
-	rows, err := database.DB.Query(`
-		SELECT id, name, description, created_at 
-		FROM projects 
-		WHERE user_id = $1 
-		ORDER BY created_at DESC
-	`, userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
+	query := store.ProjectQuery{Archived: c.Query("archived") == "true", Name: c.Query("name")}
+	var ok bool
+	if query.Sort, query.After, query.Limit, ok = pageParams(c, store.ProjectSortFields); !ok {
 		return
 	}
-	defer rows.Close()
 
-	var projects []models.Project
-	for rows.Next() {
-		var project models.Project
-		err := rows.Scan(&project.ID, &project.Name, &project.Description, &project.CreatedAt)
-		if err != nil {
-			continue
-		}
-		projects = append(projects, project)
+	page, err := s.Projects.ListProjects(c.Request.Context(), userID, query)
+	if err != nil {
+		respondListError(c, err, "Failed to fetch projects")
+		return
 	}
 
-	c.JSON(http.StatusOK, projects)
+	c.JSON(http.StatusOK, newListPage(c, page, func(project models.Project) (string, uuid.UUID) {
+		return store.ProjectSortValue(project, query.Sort.Field), project.ID
+	}))
 }
 
-// DeleteProject удаляет проект
-func DeleteProject(c *gin.Context) {
+// GetProject возвращает проект с числом сканирований и последним сканированием
+func (s *Server) GetProject(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 	projectID := c.Param("id")
 
-	result, err := database.DB.Exec(`
-		DELETE FROM projects 
-		WHERE id = $1 AND user_id = $2
-	`, projectID, userID)
+	if !s.authorizeProject(c, projectID, rbac.PermView) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	project, err := s.Projects.GetProject(ctx, uuid.MustParse(projectID))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch project"})
+		return
+	}
+
+	latest, err := s.Scans.ListScans(ctx, userID, store.ScanQuery{
+		ProjectID: &project.ID,
+		Sort:      store.Sort{Field: "created_at", Desc: true},
+		Limit:     1,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch project"})
+		return
+	}
+
+	detail := struct {
+		*models.Project
+		ScanCount int          `json:"scan_count"`
+		LastScan  *models.Scan `json:"last_scan"`
+	}{Project: project, ScanCount: latest.Total}
+	if len(latest.Items) > 0 {
+		detail.LastScan = &latest.Items[0]
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// GetProjectScans возвращает страницу сканирований проекта с теми же фильтрами, что и GetScans
+func (s *Server) GetProjectScans(c *gin.Context) {
+	projectID := c.Param("id")
+
+	query, ok := scanQuery(c)
+	if !ok {
+		return
+	}
+
+	if !s.authorizeProject(c, projectID, rbac.PermView) {
+		return
+	}
+
+	pid := uuid.MustParse(projectID)
+	query.ProjectID = &pid
+	s.respondScans(c, query)
+}
+
+// ArchiveProject переносит проект в архив: он скрывается из списка проектов,
+// а новые сканирования в нем запустить нельзя
+func (s *Server) ArchiveProject(c *gin.Context) {
+	s.setProjectArchived(c, true)
+}
+
+// UnarchiveProject возвращает проект из архива
+func (s *Server) UnarchiveProject(c *gin.Context) {
+	s.setProjectArchived(c, false)
+}
+
+func (s *Server) setProjectArchived(c *gin.Context, archived bool) {
+	projectID := c.Param("id")
+
+	if !s.authorizeProject(c, projectID, rbac.PermProjectManage) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	err := s.Projects.SetProjectArchived(c.Request.Context(), uuid.MustParse(projectID), archived)
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
 		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+		return
+	}
+
+	action, message := audit.ActionProjectArchive, "Project archived successfully"
+	if !archived {
+		action, message = audit.ActionProjectUnarchive, "Project unarchived successfully"
+	}
+	s.Audit(c, audit.Entry{Action: action, TargetType: rbac.KindProject, TargetID: projectID})
+
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// Проверка, что в проект можно добавлять сканирования; при отказе ответ уже отправлен
+func (s *Server) requireActiveProject(c *gin.Context, projectID uuid.UUID) bool {
+	project, err := s.Projects.GetProject(c.Request.Context(), projectID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch project"})
+		return false
+	}
+	if project.ArchivedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Project is archived"})
+		return false
+	}
+	return true
+}
+
+// DeleteProject удаляет проект. Проект только помечается удаленным: его сканирования
+// остаются в проекте, а администратор может его восстановить
+func (s *Server) DeleteProject(c *gin.Context) {
+	projectID := c.Param("id")
+
+	if !s.authorizeProject(c, projectID, rbac.PermProjectDelete) {
+		return
+	}
+
+	err := s.Projects.DeleteProject(c.Request.Context(), uuid.MustParse(projectID))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete project"})
+		return
 	}
 
+	s.Audit(c, audit.Entry{Action: audit.ActionProjectDelete, TargetType: rbac.KindProject, TargetID: projectID})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Project deleted successfully"})
 }
 
 // UpdateProject обновляет проект
-func UpdateProject(c *gin.Context) {
-	userID := c.MustGet("userID").(uuid.UUID)
+func (s *Server) UpdateProject(c *gin.Context) {
 	projectID := c.Param("id")
 
 	var req struct {
@@ -112,69 +240,58 @@ func UpdateProject(c *gin.Context) {
 		return
 	}
 
-	result, err := database.DB.Exec(`
-        UPDATE projects 
-        SET name = $1, description = $2 
-        WHERE id = $3 AND user_id = $4
-    `, req.Name, req.Description, projectID, userID)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+	if !s.authorizeProject(c, projectID, rbac.PermProjectManage) {
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	err := s.Projects.UpdateProject(c.Request.Context(), uuid.MustParse(projectID), req.Name, req.Description)
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
 		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update project"})
+		return
 	}
 
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionProjectUpdate,
+		TargetType: rbac.KindProject,
+		TargetID:   projectID,
+		Details:    map[string]interface{}{"name": req.Name},
+	})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Project updated successfully"})
 }
 
 // GetProjectsForScan возвращает проекты для выпадающего списка
-func GetProjectsForScan(c *gin.Context) {
+func (s *Server) GetProjectsForScan(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 	scanID := c.Param("id")
 
-	rows, err := database.DB.Query(`
-        SELECT id, name 
-        FROM projects 
-        WHERE user_id = $1 
-        ORDER BY name
-    `, userID)
+	if !s.authorizeScan(c, scanID, rbac.PermView) {
+		return
+	}
 
+	visible, err := s.Projects.ListProjects(c.Request.Context(), userID, store.ProjectQuery{Sort: store.Sort{Field: "name"}})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch projects"})
 		return
 	}
-	defer rows.Close()
 
 	var projects []map[string]interface{}
-	for rows.Next() {
-		var project struct {
-			ID   uuid.UUID `db:"id"`
-			Name string    `db:"name"`
-		}
-		err := rows.Scan(&project.ID, &project.Name)
-		if err != nil {
-			continue
-		}
+	for _, project := range visible.Items {
 		projects = append(projects, map[string]interface{}{
 			"id":   project.ID.String(),
 			"name": project.Name,
 		})
 	}
 
-	var currentProjectID *string
-	err = database.DB.QueryRow(`
-        SELECT project_id::text 
-        FROM scans 
-        WHERE id = $1 AND user_id = $2
-    `, scanID, userID).Scan(&currentProjectID)
-
-	if err != nil && err != sql.ErrNoRows {
+	var currentProjectID *uuid.UUID
+	scan, err := s.Scans.GetScan(c.Request.Context(), uuid.MustParse(scanID))
+	if err != nil && err != store.ErrNotFound {
 		log.Printf("Error getting current project: %v", err)
+	} else if scan != nil {
+		currentProjectID = scan.ProjectID
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -184,18 +301,23 @@ func GetProjectsForScan(c *gin.Context) {
 }
 
 // StartScan запускает сканирование
-func StartScan(c *gin.Context) {
+func (s *Server) StartScan(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
 
 	var req struct {
 		TargetURL string `json:"target_url" binding:"required,url"`
 		ProjectID string `json:"project_id"`
+		scanDetailsRequest
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	scanID := uuid.New()
 	now := time.Now()
@@ -203,11 +325,17 @@ func StartScan(c *gin.Context) {
 	var projectID *uuid.UUID
 	if req.ProjectID != "" {
 		pid, err := uuid.Parse(req.ProjectID)
-		if err == nil {
-			projectID = &pid
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+		if !s.authorizeProject(c, req.ProjectID, rbac.PermScanStart) || !s.requireActiveProject(c, pid) {
+			return
 		}
+		projectID = &pid
 	}
 
+	config := defaultScanConfig()
 	scan := models.Scan{
 		ID:        scanID,
 		TargetURL: req.TargetURL,
@@ -216,21 +344,22 @@ func StartScan(c *gin.Context) {
 		StartedAt: &now,
 		UserID:    userID,
 		CreatedAt: now,
+		Config:    &config,
+	}
+	if req.Labels != nil {
+		scan.Labels = *req.Labels
+	}
+	if req.Notes != nil {
+		scan.Notes = *req.Notes
+	}
+	if req.Metadata != nil {
+		scan.Metadata = *req.Metadata
 	}
 
-	query := `
-		INSERT INTO scans (id, target_url, status, project_id, started_at, user_id, created_at) 
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`
-	_, err := database.DB.Exec(query,
-		scan.ID, scan.TargetURL, scan.Status, scan.ProjectID, scan.StartedAt, scan.UserID, scan.CreatedAt)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create scan"})
+	if !s.launchScan(c, &scan, audit.ActionScanStart, nil) {
 		return
 	}
 
-	go runNucleiScan(scan.ID, req.TargetURL)
-
 	c.JSON(http.StatusAccepted, gin.H{
 		"scan_id": scanID,
 		"message": "Scan started successfully",
@@ -238,41 +367,194 @@ func StartScan(c *gin.Context) {
 	})
 }
 
-// GetScans возвращает список сканирований пользователя
-func GetScans(c *gin.Context) {
+// RerunScan запускает новое сканирование с той же целью, проектом, параметрами Nuclei,
+// метками и метаданными и связывает его с исходным как перепроверку. С vulnerability_ids перепроверяет только
+// эти находки: Nuclei запускается с их шаблонами по адресам, где они были найдены
+func (s *Server) RerunScan(c *gin.Context) {
 	userID := c.MustGet("userID").(uuid.UUID)
+	scanID := c.Param("id")
 
-	rows, err := database.DB.Query(`
-		SELECT id, target_url, status, project_id, started_at, finished_at, created_at
-		FROM scans 
-		WHERE user_id = $1 
-		ORDER BY created_at DESC
-	`, userID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scans"})
+	var req struct {
+		VulnerabilityIDs []uuid.UUID `json:"vulnerability_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !s.authorizeScan(c, scanID, rbac.PermScanStart) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	original, err := s.Scans.GetScan(ctx, uuid.MustParse(scanID))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scan not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scan"})
 		return
 	}
-	defer rows.Close()
 
-	var scans []models.Scan
-	for rows.Next() {
-		var scan models.Scan
-		err := rows.Scan(
-			&scan.ID, &scan.TargetURL, &scan.Status, &scan.ProjectID,
-			&scan.StartedAt, &scan.FinishedAt, &scan.CreatedAt,
-		)
+	if original.ProjectID != nil && !s.requireActiveProject(c, *original.ProjectID) {
+		return
+	}
+
+	config := defaultScanConfig()
+	if original.Config != nil {
+		config = *original.Config
+	}
+
+	details := map[string]interface{}{"retest_of": original.ID}
+	if len(req.VulnerabilityIDs) > 0 {
+		vulns, err := s.Vulnerabilities.ListVulnerabilities(ctx, original.ID)
 		if err != nil {
-			continue
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch vulnerabilities"})
+			return
 		}
-		scans = append(scans, scan)
+		selected := make(map[uuid.UUID]models.Vulnerability, len(vulns))
+		for _, vuln := range vulns {
+			selected[vuln.ID] = vuln
+		}
+
+		config.Templates, config.Targets = nil, nil
+		for _, id := range req.VulnerabilityIDs {
+			vuln, ok := selected[id]
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Vulnerability " + id.String() + " not found in scan"})
+				return
+			}
+			// matched-at приходит из ответа Nuclei: не-URL (host:port) и подозрительные адреса
+			// заменяются целью исходного сканирования
+			target := vuln.MatchedAt
+			if !isValidURL(target) {
+				target = original.TargetURL
+			}
+			if !slices.Contains(config.Templates, vuln.TemplateID) {
+				config.Templates = append(config.Templates, vuln.TemplateID)
+			}
+			if !slices.Contains(config.Targets, target) {
+				config.Targets = append(config.Targets, target)
+			}
+		}
+		details["vulnerability_ids"] = req.VulnerabilityIDs
+	}
+
+	scan := newRerun(original, userID, config)
+	if !s.launchScan(c, &scan, audit.ActionScanRerun, details) {
+		return
 	}
 
-	c.JSON(http.StatusOK, scans)
+	c.JSON(http.StatusAccepted, gin.H{
+		"scan_id":   scan.ID,
+		"retest_of": original.ID,
+		"message":   "Scan started successfully",
+		"status":    "Queued",
+	})
 }
 
-// AddScanToProject добавляет сканирование в проект
-func AddScanToProject(c *gin.Context) {
+// newRerun готовит повторное сканирование: цель, проект, метки и метаданные берутся из исходного
+func newRerun(original *models.Scan, userID uuid.UUID, config models.ScanConfig) models.Scan {
+	now := time.Now()
+	return models.Scan{
+		ID:        uuid.New(),
+		TargetURL: original.TargetURL,
+		Status:    "Queued",
+		ProjectID: original.ProjectID,
+		StartedAt: &now,
+		UserID:    userID,
+		CreatedAt: now,
+		Config:    &config,
+		RetestOf:  &original.ID,
+		Labels:    original.Labels,
+		Metadata:  original.Metadata,
+	}
+}
+
+// launchScan проверяет квоту, сохраняет сканирование в очереди, записывает аудит
+// и запускает Nuclei. При ошибке ответ уже отправлен
+func (s *Server) launchScan(c *gin.Context, scan *models.Scan, action string, details map[string]interface{}) bool {
+	if !s.createScanWithinQuota(c, scan) {
+		return false
+	}
+
+	s.queueScan(c, *scan, action, details)
+	return true
+}
+
+// createScanWithinQuota проверяет квоту и сохраняет сканирование под блокировкой квот владельца,
+// чтобы параллельные запросы не превысили квоту. При ошибке ответ уже отправлен
+func (s *Server) createScanWithinQuota(c *gin.Context, scan *models.Scan) bool {
+	subject := quotaSubject(c, scan.ProjectID)
+	unlock, err := quota.Lock(c.Request.Context(), s.Quotas, subject)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+		return false
+	}
+	defer unlock()
+
+	if err := quota.CheckScanStart(c.Request.Context(), s.Quotas, subject, scan.ProjectID, scan.TargetURL); err != nil {
+		respondQuotaError(c, err)
+		return false
+	}
+
+	if err := s.Scans.CreateScan(c.Request.Context(), scan); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create scan"})
+		return false
+	}
+	return true
+}
+
+// queueScan уведомляет о сохраненном сканировании, пишет аудит и запускает Nuclei
+func (s *Server) queueScan(c *gin.Context, scan models.Scan, action string, details map[string]interface{}) {
+	s.notifyScanEvent(scan.ID, webhooks.EventScanQueued, map[string]interface{}{"status": scan.Status})
+
+	var orgID *uuid.UUID
+	if scan.ProjectID != nil {
+		orgID = rbac.CachedOrganization(c, rbac.KindProject, scan.ProjectID.String())
+	}
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	details["target_url"] = scan.TargetURL
+	details["project_id"] = scan.ProjectID
+	s.Audit(c, audit.Entry{
+		Action:         action,
+		TargetType:     rbac.KindScan,
+		TargetID:       scan.ID.String(),
+		OrganizationID: orgID,
+		Details:        details,
+	})
+
+	startNucleiScan(s, scan)
+}
+
+// GetScans возвращает страницу личных сканирований пользователя и сканирований проектов его организаций.
+// Фильтры: status (можно несколько, через запятую), project_id, target (подстрока адреса), since и until;
+// сортировка по created_at, target_url или status
+func (s *Server) GetScans(c *gin.Context) {
+	query, ok := scanQuery(c)
+	if !ok {
+		return
+	}
+
+	s.respondScans(c, query)
+}
+
+func (s *Server) respondScans(c *gin.Context, query store.ScanQuery) {
 	userID := c.MustGet("userID").(uuid.UUID)
+
+	page, err := s.Scans.ListScans(c.Request.Context(), userID, query)
+	if err != nil {
+		respondListError(c, err, "Failed to fetch scans")
+		return
+	}
+
+	c.JSON(http.StatusOK, newListPage(c, page, scanCursorKey(query.Sort)))
+}
+
+// AddScanToProject добавляет сканирование в проект
+func (s *Server) AddScanToProject(c *gin.Context) {
 	scanID := c.Param("id")
 
 	var req struct {
@@ -284,6 +566,10 @@ func AddScanToProject(c *gin.Context) {
 		return
 	}
 
+	if !s.authorizeScan(c, scanID, rbac.PermProjectManage) {
+		return
+	}
+
 	var projectID *uuid.UUID
 	if req.ProjectID != nil && *req.ProjectID != "" {
 		pid, err := uuid.Parse(*req.ProjectID)
@@ -292,53 +578,74 @@ func AddScanToProject(c *gin.Context) {
 			return
 		}
 
-		var projectExists bool
-		err = database.DB.QueryRow(`
-            SELECT EXISTS(SELECT 1 FROM projects WHERE id = $1 AND user_id = $2)
-        `, pid, userID).Scan(&projectExists)
-
-		if err != nil || !projectExists {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Project not found"})
+		// В целевой проект пользователь должен иметь право запускать сканирования
+		if err := s.authorize(c, rbac.KindProject, pid.String(), rbac.PermScanStart); err != nil {
+			if err == rbac.ErrForbidden {
+				respondAccessError(c, err, "Project not found")
+			} else {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Project not found"})
+			}
+			return
+		}
+		if !s.requireActiveProject(c, pid) {
 			return
 		}
 
 		projectID = &pid
 	}
 
-	_, err := database.DB.Exec(`
-        UPDATE scans 
-        SET project_id = $1 
-        WHERE id = $2 AND user_id = $3
-    `, projectID, scanID, userID)
-
-	if err != nil {
+	err := s.Scans.SetScanProject(c.Request.Context(), uuid.MustParse(scanID), projectID)
+	if err != nil && err != store.ErrNotFound {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add scan to project"})
 		return
 	}
 
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionScanMove,
+		TargetType: rbac.KindScan,
+		TargetID:   scanID,
+		Details:    map[string]interface{}{"project_id": projectID},
+	})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Scan updated successfully"})
 }
 
-// DeleteScan удаляет сканирование
-func DeleteScan(c *gin.Context) {
-	userID := c.MustGet("userID").(uuid.UUID)
+// DeleteScan удаляет сканирование вместе с файлами отчетов и доказательств
+func (s *Server) DeleteScan(c *gin.Context) {
 	scanID := c.Param("id")
 
-	result, err := database.DB.Exec(`
-		DELETE FROM scans 
-		WHERE id = $1 AND user_id = $2
-	`, scanID, userID)
+	if !s.authorizeScan(c, scanID, rbac.PermScanDelete) {
+		return
+	}
 
-	if err != nil {
+	files, err := s.Scans.ScanFiles(c.Request.Context(), uuid.MustParse(scanID))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scan not found"})
+		return
+	} else if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete scan"})
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
+	err = s.Scans.DeleteScan(c.Request.Context(), uuid.MustParse(scanID))
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Scan not found"})
 		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete scan"})
+		return
 	}
 
+	// Файлы удаляются после записи в БД: сбой оставит лишний файл, а не ссылку на удаленный
+	removed := removeReportFiles(c.Request.Context(), files.ReportPaths) +
+		storage.DeleteAll(c.Request.Context(), files.EvidenceKeys)
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionScanDelete,
+		TargetType: rbac.KindScan,
+		TargetID:   scanID,
+		Details:    map[string]interface{}{"report_files": removed},
+	})
+
 	c.JSON(http.StatusOK, gin.H{"message": "Scan deleted successfully"})
 }
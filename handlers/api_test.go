@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"bytes"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"chimerascan/audit"
 	"chimerascan/database"
+	"chimerascan/quota"
+	"chimerascan/store"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/gin-gonic/gin"
@@ -31,8 +35,8 @@ func TestCreateProject_Success(t *testing.T) {
 	projectName := "Test Project"
 	projectDescription := "Test Description"
 
-	mock.ExpectExec(`INSERT INTO projects \(id, name, description, user_id, created_at\) VALUES \(\$1, \$2, \$3, \$4, \$5\)`).
-		WithArgs(sqlmock.AnyArg(), projectName, projectDescription, userID, sqlmock.AnyArg()).
+	mock.ExpectExec(`INSERT INTO projects \(id, name, description, user_id, organization_id, created_at\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6\)`).
+		WithArgs(sqlmock.AnyArg(), projectName, projectDescription, userID, nil, sqlmock.AnyArg()).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	requestBody := map[string]interface{}{
@@ -49,7 +53,9 @@ func TestCreateProject_Success(t *testing.T) {
 	c.Request = req
 	c.Set("userID", userID)
 
-	CreateProject(c)
+	expectAudit(mock, audit.ActionProjectCreate)
+
+	mockServer().CreateProject(c)
 
 	assert.Equal(t, http.StatusCreated, w.Code, "Should return 201 status")
 
@@ -81,7 +87,7 @@ func TestCreateProject_InvalidJSON(t *testing.T) {
 	c.Request = req
 	c.Set("userID", uuid.New())
 
-	CreateProject(c)
+	mockServer().CreateProject(c)
 
 	assert.Equal(t, http.StatusBadRequest, w.Code, "Should return 400 for invalid JSON")
 
@@ -107,12 +113,16 @@ func TestGetProjects_Success(t *testing.T) {
 	projectID1 := uuid.New()
 	projectID2 := uuid.New()
 
-	rows := sqlmock.NewRows([]string{"id", "name", "description", "created_at"}).
-		AddRow(projectID1, "Project 1", "Description 1", time.Now()).
-		AddRow(projectID2, "Project 2", "Description 2", time.Now())
+	rows := sqlmock.NewRows([]string{"id", "name", "description", "user_id", "organization_id", "created_at", "archived_at"}).
+		AddRow(projectID1, "Project 1", "Description 1", userID, nil, time.Now(), nil).
+		AddRow(projectID2, "Project 2", "Description 2", uuid.New(), uuid.New(), time.Now(), nil)
 
-	mock.ExpectQuery(`SELECT id, name, description, created_at FROM projects WHERE user_id = \$1 ORDER BY created_at DESC`).
+	const condition = `WHERE \(\(p.organization_id IS NULL AND p.user_id = \$1\) OR p.organization_id IN \(SELECT organization_id FROM organization_members WHERE user_id = \$1\)\) AND p.deleted_at IS NULL AND p.archived_at IS NULL`
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM projects p ` + condition).
 		WithArgs(userID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectQuery(`SELECT p.id, p.name, p.description, p.user_id, p.organization_id, p.created_at, p.archived_at FROM projects p `+condition+` ORDER BY p.created_at DESC, p.id DESC LIMIT \$2`).
+		WithArgs(userID, defaultPageLimit+1).
 		WillReturnRows(rows)
 
 	req, _ := http.NewRequest("GET", "/api/projects", nil)
@@ -122,7 +132,7 @@ func TestGetProjects_Success(t *testing.T) {
 	c.Request = req
 	c.Set("userID", userID)
 
-	GetProjects(c)
+	mockServer().GetProjects(c)
 
 	if w.Code != 200 {
 		t.Logf("Status: %d, Body: %s", w.Code, w.Body.String())
@@ -130,8 +140,15 @@ func TestGetProjects_Success(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code, "Should return 200 status")
 
-	bodyStr := w.Body.String()
-	assert.NotEqual(t, "null", bodyStr, "Response should not be null")
+	var response struct {
+		Items      []map[string]interface{} `json:"items"`
+		Total      int                      `json:"total"`
+		NextCursor *string                  `json:"next_cursor"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Items, 2)
+	assert.Equal(t, 2, response.Total)
+	assert.Nil(t, response.NextCursor)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -150,8 +167,9 @@ func TestDeleteProject_Success(t *testing.T) {
 	userID := uuid.New()
 	projectID := uuid.New()
 
-	mock.ExpectExec(`DELETE FROM projects WHERE id = \$1 AND user_id = \$2`).
-		WithArgs(projectID, userID).
+	expectProjectAccess(mock, projectID, userID, userID)
+	mock.ExpectExec(`UPDATE projects SET deleted_at = \$1 WHERE id = \$2 AND deleted_at IS NULL`).
+		WithArgs(sqlmock.AnyArg(), projectID).
 		WillReturnResult(sqlmock.NewResult(0, 1)) // 1 row affected
 
 	req, _ := http.NewRequest("DELETE", "/api/projects/"+projectID.String(), nil)
@@ -162,7 +180,9 @@ func TestDeleteProject_Success(t *testing.T) {
 	c.Set("userID", userID)
 	c.Params = gin.Params{{Key: "id", Value: projectID.String()}}
 
-	DeleteProject(c)
+	expectAudit(mock, audit.ActionProjectDelete)
+
+	mockServer().DeleteProject(c)
 
 	assert.Equal(t, http.StatusOK, w.Code, "Should return 200 status")
 
@@ -187,9 +207,9 @@ func TestDeleteProject_NotFound(t *testing.T) {
 	userID := uuid.New()
 	projectID := uuid.New()
 
-	mock.ExpectExec(`DELETE FROM projects WHERE id = \$1 AND user_id = \$2`).
+	mock.ExpectQuery(`SELECT p.user_id, p.organization_id, m.role FROM projects p`).
 		WithArgs(projectID, userID).
-		WillReturnResult(sqlmock.NewResult(0, 0)) // 0 rows affected
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "organization_id", "role"})) // проект не найден
 
 	req, _ := http.NewRequest("DELETE", "/api/projects/"+projectID.String(), nil)
 
@@ -199,7 +219,7 @@ func TestDeleteProject_NotFound(t *testing.T) {
 	c.Set("userID", userID)
 	c.Params = gin.Params{{Key: "id", Value: projectID.String()}}
 
-	DeleteProject(c)
+	mockServer().DeleteProject(c)
 
 	assert.Equal(t, http.StatusNotFound, w.Code, "Should return 404 for non-existent project")
 
@@ -223,8 +243,9 @@ func TestUpdateProject_Success(t *testing.T) {
 	userID := uuid.New()
 	projectID := uuid.New()
 
-	mock.ExpectExec(`UPDATE projects SET name = \$1, description = \$2 WHERE id = \$3 AND user_id = \$4`).
-		WithArgs("Updated Name", "Updated Desc", projectID, userID).
+	expectProjectAccess(mock, projectID, userID, userID)
+	mock.ExpectExec(`UPDATE projects SET name = \$1, description = \$2 WHERE id = \$3`).
+		WithArgs("Updated Name", "Updated Desc", projectID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	requestBody := map[string]interface{}{
@@ -242,7 +263,9 @@ func TestUpdateProject_Success(t *testing.T) {
 	c.Set("userID", userID)
 	c.Params = gin.Params{{Key: "id", Value: projectID.String()}}
 
-	UpdateProject(c)
+	expectAudit(mock, audit.ActionProjectUpdate)
+
+	mockServer().UpdateProject(c)
 
 	assert.Equal(t, http.StatusOK, w.Code, "Should return 200 status")
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -255,19 +278,32 @@ func TestStartScan_Success(t *testing.T) {
 	}
 	defer db.Close()
 
-	oldDB := database.DB
-	database.DB = db
-	defer func() { database.DB = oldDB }()
+	oldDB, oldDriver := database.DB, database.Driver
+	database.DB, database.Driver = db, database.DriverPostgres // квоты блокируются через pg_advisory_lock
+	defer func() { database.DB, database.Driver = oldDB, oldDriver }()
 
 	userID := uuid.New()
 
-	mock.ExpectExec(`INSERT INTO scans \(id, target_url, status, project_id, started_at, user_id, created_at\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7\)`).
-		WithArgs(sqlmock.AnyArg(), "https://example.com", "Queued", nil, sqlmock.AnyArg(), userID, sqlmock.AnyArg()).
+	expectQuotaUsage(mock, userID, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO scans \(id, target_url, status, project_id, started_at, user_id, created_at, config, retest_of, notes\) VALUES \(\$1, \$2, \$3, \$4, \$5, \$6, \$7, \$8, \$9, \$10\)`).
+		WithArgs(sqlmock.AnyArg(), "https://example.com", "Queued", nil, sqlmock.AnyArg(), userID, sqlmock.AnyArg(), sqlmock.AnyArg(), nil, "Nightly").
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO scan_labels \(scan_id, label\) VALUES \(\$1, \$2\)`).
+		WithArgs(sqlmock.AnyArg(), "ci").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`INSERT INTO scan_metadata \(scan_id, key, value\) VALUES \(\$1, \$2, \$3\)`).
+		WithArgs(sqlmock.AnyArg(), "build_sha", "4f2a9c1").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	expectQuotaUnlock(mock)
 
 	requestBody := map[string]interface{}{
 		"target_url": "https://example.com",
 		"project_id": "",
+		"labels":     []string{" ci ", "ci"},
+		"notes":      "Nightly",
+		"metadata":   map[string]string{"build_sha": "4f2a9c1"},
 	}
 	jsonBody, _ := json.Marshal(requestBody)
 
@@ -279,7 +315,9 @@ func TestStartScan_Success(t *testing.T) {
 	c.Request = req
 	c.Set("userID", userID)
 
-	StartScan(c)
+	expectAudit(mock, audit.ActionScanStart)
+
+	mockServer().StartScan(c)
 
 	assert.Equal(t, http.StatusAccepted, w.Code, "Should return 202 status")
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -299,26 +337,71 @@ func TestGetScans_Success(t *testing.T) {
 	userID := uuid.New()
 	scanID := uuid.New()
 
-	rows := sqlmock.NewRows([]string{"id", "target_url", "status", "project_id", "started_at", "finished_at", "created_at"}).
-		AddRow(scanID, "https://example.com", "Completed", nil, time.Now(), time.Now(), time.Now())
+	rows := sqlmock.NewRows([]string{"id", "target_url", "status", "project_id", "started_at", "finished_at", "created_at", "user_id", "retest_of", "notes"}).
+		AddRow(scanID, "https://example.com", "Completed", nil, time.Now(), time.Now(), time.Now(), userID, nil, "").
+		AddRow(uuid.New(), "https://example.org", "Failed", nil, time.Now(), time.Now(), time.Now(), userID, nil, "")
 
-	mock.ExpectQuery(`SELECT id, target_url, status, project_id, started_at, finished_at, created_at FROM scans WHERE user_id = \$1 ORDER BY created_at DESC`).
-		WithArgs(userID).
+	mock.ExpectQuery(`SELECT COUNT\(\*\) FROM scans s LEFT JOIN projects p ON p.id = s.project_id WHERE .* AND s.status IN \(\$2, \$3\)`).
+		WithArgs(userID, "Completed", "Failed").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectQuery(`SELECT s.id, s.target_url, s.status, s.project_id, s.started_at, s.finished_at, s.created_at, s.user_id, s.retest_of, s.notes FROM scans s LEFT JOIN projects p ON p.id = s.project_id WHERE \(\(p.organization_id IS NULL AND s.user_id = \$1\).* ORDER BY s.target_url ASC, s.id ASC LIMIT \$4`).
+		WithArgs(userID, "Completed", "Failed", 2).
 		WillReturnRows(rows)
-
-	req, _ := http.NewRequest("GET", "/api/scans", nil)
+	mock.ExpectQuery(`SELECT scan_id, label FROM scan_labels WHERE scan_id IN \(\$1\)`).
+		WithArgs(scanID).
+		WillReturnRows(sqlmock.NewRows([]string{"scan_id", "label"}).AddRow(scanID, "ci"))
+	mock.ExpectQuery(`SELECT scan_id, key, value FROM scan_metadata WHERE scan_id IN \(\$1\)`).
+		WithArgs(scanID).
+		WillReturnRows(sqlmock.NewRows([]string{"scan_id", "key", "value"}))
+	mock.ExpectQuery(`SELECT scan_id, LOWER\(severity\), COUNT\(\*\) FROM vulnerabilities WHERE scan_id IN \(\$1\)`).
+		WithArgs(scanID).
+		WillReturnRows(sqlmock.NewRows([]string{"scan_id", "severity", "count"}).AddRow(scanID, "high", 2))
+
+	req, _ := http.NewRequest("GET", "/api/scans?status=Completed,Failed&sort=target_url&limit=1", nil)
 
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 	c.Request = req
 	c.Set("userID", userID)
 
-	GetScans(c)
+	mockServer().GetScans(c)
 
 	assert.Equal(t, http.StatusOK, w.Code, "Should return 200 status")
+
+	var response struct {
+		Items []struct {
+			ID             uuid.UUID      `json:"id"`
+			Labels         []string       `json:"labels"`
+			SeverityCounts map[string]int `json:"severity_counts"`
+		} `json:"items"`
+		Total      int     `json:"total"`
+		NextCursor *string `json:"next_cursor"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Items, 1)
+	assert.Equal(t, scanID, response.Items[0].ID)
+	assert.Equal(t, []string{"ci"}, response.Items[0].Labels)
+	assert.Equal(t, map[string]int{"high": 2}, response.Items[0].SeverityCounts)
+	assert.Equal(t, 3, response.Total)
+	assert.NotNil(t, response.NextCursor)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestGetScans_InvalidParams(t *testing.T) {
+	for _, query := range []string{"status=Done", "sort=severity", "limit=0", "since=yesterday", "project_id=1", "cursor=abc", "metadata=env"} {
+		req, _ := http.NewRequest("GET", "/api/scans?"+query, nil)
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = req
+		c.Set("userID", uuid.New())
+
+		mockServer().GetScans(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
 func TestAddScanToProject_Success(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -334,13 +417,12 @@ func TestAddScanToProject_Success(t *testing.T) {
 	scanID := uuid.New()
 	projectID := uuid.New()
 
-	rows := sqlmock.NewRows([]string{"exists"}).AddRow(true)
-	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM projects WHERE id = \$1 AND user_id = \$2\)`).
-		WithArgs(projectID, userID).
-		WillReturnRows(rows)
+	expectScanAccess(mock, scanID, userID, userID)
+	expectProjectAccess(mock, projectID, userID, userID)
+	expectActiveProject(mock, projectID, userID)
 
-	mock.ExpectExec(`UPDATE scans SET project_id = \$1 WHERE id = \$2 AND user_id = \$3`).
-		WithArgs(projectID, scanID, userID).
+	mock.ExpectExec(`UPDATE scans SET project_id = \$1 WHERE id = \$2`).
+		WithArgs(projectID, scanID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	requestBody := map[string]interface{}{
@@ -357,7 +439,9 @@ func TestAddScanToProject_Success(t *testing.T) {
 	c.Set("userID", userID)
 	c.Params = gin.Params{{Key: "id", Value: scanID.String()}}
 
-	AddScanToProject(c)
+	expectAudit(mock, audit.ActionScanMove)
+
+	mockServer().AddScanToProject(c)
 
 	assert.Equal(t, http.StatusOK, w.Code, "Should return 200 status")
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -377,8 +461,10 @@ func TestDeleteScan_Success(t *testing.T) {
 	userID := uuid.New()
 	scanID := uuid.New()
 
-	mock.ExpectExec(`DELETE FROM scans WHERE id = \$1 AND user_id = \$2`).
-		WithArgs(scanID, userID).
+	expectScanAccess(mock, scanID, userID, userID)
+	expectScanFiles(mock, scanID)
+	mock.ExpectExec(`DELETE FROM scans WHERE id = \$1`).
+		WithArgs(scanID).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
 	req, _ := http.NewRequest("DELETE", "/api/scans/"+scanID.String(), nil)
@@ -389,8 +475,167 @@ func TestDeleteScan_Success(t *testing.T) {
 	c.Set("userID", userID)
 	c.Params = gin.Params{{Key: "id", Value: scanID.String()}}
 
-	DeleteScan(c)
+	expectAudit(mock, audit.ActionScanDelete)
+
+	mockServer().DeleteScan(c)
 
 	assert.Equal(t, http.StatusOK, w.Code, "Should return 200 status")
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestDeleteScan_OrganizationViewerForbidden(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	userID := uuid.New()
+	scanID := uuid.New()
+
+	mock.ExpectQuery(`SELECT s.user_id, p.organization_id, m.role FROM scans s`).
+		WithArgs(scanID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "organization_id", "role"}).
+			AddRow(uuid.New(), uuid.New(), "viewer"))
+
+	req, _ := http.NewRequest("DELETE", "/api/scans/"+scanID.String(), nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set("userID", userID)
+	c.Params = gin.Params{{Key: "id", Value: scanID.String()}}
+
+	mockServer().DeleteScan(c)
+
+	assert.Equal(t, http.StatusForbidden, w.Code, "Viewer should not delete scans")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestStopScan_OtherUsersPersonalScan(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	userID := uuid.New()
+	scanID := uuid.New()
+
+	expectScanAccess(mock, scanID, userID, uuid.New())
+
+	req, _ := http.NewRequest("POST", "/api/scan/stop/"+scanID.String(), nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set("userID", userID)
+	c.Params = gin.Params{{Key: "id", Value: scanID.String()}}
+
+	mockServer().StopScan(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code, "Personal scans of other users should be hidden")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// mockServer возвращает обработчики поверх database.DB, подмененной в тесте на sqlmock
+func mockServer() *Server {
+	return NewServer(store.NewPostgres(database.DB))
+}
+
+// expectScanAccess ожидает проверку прав на личное сканирование владельца ownerID
+func expectScanAccess(mock sqlmock.Sqlmock, scanID, userID, ownerID uuid.UUID) {
+	mock.ExpectQuery(`SELECT s.user_id, p.organization_id, m.role FROM scans s`).
+		WithArgs(scanID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "organization_id", "role"}).AddRow(ownerID, nil, nil))
+}
+
+// expectProjectAccess ожидает проверку прав на личный проект владельца ownerID
+func expectProjectAccess(mock sqlmock.Sqlmock, projectID, userID, ownerID uuid.UUID) {
+	mock.ExpectQuery(`SELECT p.user_id, p.organization_id, m.role FROM projects p`).
+		WithArgs(projectID, userID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "organization_id", "role"}).AddRow(ownerID, nil, nil))
+}
+
+// expectActiveProject ожидает чтение проекта, не перенесенного в архив
+func expectActiveProject(mock sqlmock.Sqlmock, projectID, ownerID uuid.UUID) {
+	mock.ExpectQuery(`SELECT id, name, description, user_id, organization_id, archived_at, created_at FROM projects`).
+		WithArgs(projectID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "description", "user_id", "organization_id", "archived_at", "created_at"}).
+			AddRow(projectID, "Project", nil, ownerID, nil, nil, time.Now()))
+}
+
+// expectAudit ожидает запись действия в журнал аудита
+func expectAudit(mock sqlmock.Sqlmock, action string) {
+	mock.ExpectExec(`INSERT INTO audit_events`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), action,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+}
+
+// expectQuotaUsage ожидает блокировку и проверку квот пользователя без индивидуальных значений
+func expectQuotaUsage(mock sqlmock.Sqlmock, userID uuid.UUID, concurrent int) {
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT concurrent_scans, scans_per_day, targets_per_project, report_storage_bytes FROM quotas`).
+		WithArgs("user", userID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`FROM scans s LEFT JOIN projects p ON p.id = s.project_id WHERE p.organization_id IS NULL AND s.user_id = \$1`).
+		WithArgs(userID, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"concurrent", "today", "oldest", "storage"}).AddRow(concurrent, concurrent, nil, 0))
+}
+
+// expectQuotaUnlock ожидает снятие блокировки квот после сохранения сканирования
+func expectQuotaUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_unlock_all\(\)`).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
+func TestStartScan_ConcurrentQuotaExceeded(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB, oldDriver := database.DB, database.Driver
+	database.DB, database.Driver = db, database.DriverPostgres // квоты блокируются через pg_advisory_lock
+	defer func() { database.DB, database.Driver = oldDB, oldDriver }()
+
+	userID := uuid.New()
+	expectQuotaUsage(mock, userID, quota.Defaults.ConcurrentScans)
+	expectQuotaUnlock(mock)
+
+	jsonBody, _ := json.Marshal(map[string]interface{}{"target_url": "https://example.com"})
+	req, _ := http.NewRequest("POST", "/api/scan/start", bytes.NewBuffer(jsonBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set("userID", userID)
+
+	mockServer().StartScan(c)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"quota":"concurrent_scans"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// Сканирование без отчетов и вынесенных ответов: удалять из хранилища нечего
+func expectScanFiles(mock sqlmock.Sqlmock, scanID uuid.UUID) {
+	mock.ExpectQuery(`SELECT report_json_path, report_pdf_path, report_html_path FROM scans WHERE id = \$1`).
+		WithArgs(scanID).
+		WillReturnRows(sqlmock.NewRows([]string{"report_json_path", "report_pdf_path", "report_html_path"}).AddRow(nil, nil, nil))
+	mock.ExpectQuery(`SELECT evidence_key FROM vulnerabilities`).
+		WithArgs(scanID).
+		WillReturnRows(sqlmock.NewRows([]string{"evidence_key"}))
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/rbac"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// Фильтр журнала из параметров запроса. С organization_id доступен журнал организации
+// (роли admin и owner), без него - только собственные действия пользователя.
+// При ошибке ответ уже отправлен.
+func (s *Server) auditFilter(c *gin.Context) (audit.Filter, bool) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	filter := audit.Filter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+	}
+
+	if orgID := c.Query("organization_id"); orgID != "" {
+		if !s.authorizeOrganization(c, orgID, rbac.PermAuditView) {
+			return filter, false
+		}
+		id := uuid.MustParse(orgID)
+		filter.OrganizationID = &id
+
+		if actor := c.Query("actor_id"); actor != "" {
+			actorID, err := uuid.Parse(actor)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid actor_id"})
+				return filter, false
+			}
+			filter.ActorID = &actorID
+		}
+	} else {
+		filter.ActorID = &userID
+	}
+
+	for param, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ", expected RFC 3339 timestamp"})
+				return filter, false
+			}
+			*target = &t
+		}
+	}
+
+	return filter, true
+}
+
+// GetAuditEvents возвращает события журнала аудита
+func (s *Server) GetAuditEvents(c *gin.Context) {
+	filter, ok := s.auditFilter(c)
+	if !ok {
+		return
+	}
+
+	filter.Limit = defaultAuditLimit
+	if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+		filter.Limit = limit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+	if offset, err := strconv.Atoi(c.Query("offset")); err == nil && offset > 0 {
+		filter.Offset = offset
+	}
+
+	events, err := audit.List(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// ExportAuditEvents выгружает журнал аудита в формате JSON Lines
+func (s *Server) ExportAuditEvents(c *gin.Context) {
+	filter, ok := s.auditFilter(c)
+	if !ok {
+		return
+	}
+
+	filename := "audit-" + time.Now().UTC().Format("20060102-150405") + ".jsonl"
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", "attachment; filename="+filename)
+	c.Status(http.StatusOK)
+
+	if _, err := audit.ExportJSONLines(c.Writer, filter); err != nil {
+		// Заголовки уже отправлены, поэтому ошибку можно только записать в лог
+		log.Printf("Audit export failed: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chimerascan/database"
+	"chimerascan/rbac"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAuditEvents_OrganizationRequiresAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	userID := uuid.New()
+	orgID := uuid.New()
+	s.store.AddMember(orgID, userID, rbac.RoleAnalyst)
+
+	w := call(s.GetAuditEvents, userID, "GET", "/api/audit?organization_id="+orgID.String(), nil, nil)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestGetAuditEvents_DefaultsToOwnActions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	userID := uuid.New()
+
+	mock.ExpectQuery(`FROM audit_events WHERE actor_id = \$1 AND action = \$2 ORDER BY created_at DESC LIMIT \$3`).
+		WithArgs(userID, "scan.delete", defaultAuditLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "actor_id", "actor_name", "actor_token_id", "action", "target_type",
+			"target_id", "organization_id", "ip_address", "user_agent", "details", "created_at"}).
+			AddRow(uuid.New(), userID, "alice", nil, "scan.delete", "scan", uuid.New().String(), nil, "127.0.0.1", "", []byte(`{}`), time.Now()))
+
+	req, _ := http.NewRequest("GET", "/api/audit?action=scan.delete", nil)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Set("userID", userID)
+
+	mockServer().GetAuditEvents(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"action":"scan.delete"`)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,21 +1,94 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 
+	"chimerascan/audit"
 	"chimerascan/auth"
 
 	"github.com/gin-gonic/gin"
 )
 
 func LoginPage(c *gin.Context) {
-	c.HTML(http.StatusOK, "login.html", gin.H{})
+	data := gin.H{}
+	if provider := auth.GetOIDCProvider(); provider != nil {
+		data["oidcName"] = provider.Name
+	}
+	c.HTML(http.StatusOK, "login.html", data)
+}
+
+// При входе с ?link=1 учетная запись провайдера привязывается к текущему пользователю
+func rememberLinkIntent(c *gin.Context) {
+	if c.Query("link") != "" {
+		auth.SetCookie(c, "oauthlink", "1", 600)
+	} else {
+		auth.SetCookie(c, "oauthlink", "", -1)
+	}
+}
+
+// completeLogin завершает вход через провайдера: привязывает учетную запись
+// к текущему пользователю или создает сессию для найденного пользователя
+func completeLogin(c *gin.Context, provider, subject, email, username string) {
+	if link, err := c.Cookie("oauthlink"); err == nil && link != "" {
+		auth.SetCookie(c, "oauthlink", "", -1)
+
+		if current, err := auth.GetUserFromRequest(c); err == nil && current != nil {
+			c.Set("user", current)
+			c.Set("userID", current.ID)
+
+			switch err := auth.LinkIdentity(current.ID, provider, subject, email); err {
+			case nil:
+				audit.Record(c, audit.Entry{
+					Action:     audit.ActionIdentityLink,
+					TargetType: "user",
+					TargetID:   current.ID.String(),
+					Details:    map[string]interface{}{"provider": provider, "subject": subject},
+				})
+				c.Redirect(http.StatusFound, "/dashboard?linked="+provider)
+			case auth.ErrIdentityInUse:
+				c.Redirect(http.StatusFound, "/dashboard?error=identity_in_use")
+			default:
+				log.Printf("Failed to link %s identity: %v", provider, err)
+				c.Redirect(http.StatusFound, "/dashboard?error=identity_link_failed")
+			}
+			return
+		}
+	}
+
+	user, err := auth.CreateOrGetUser(subject, email, username, provider)
+	if err != nil {
+		c.Redirect(http.StatusTemporaryRedirect, "/?error=user_creation_failed")
+		return
+	}
+	if user.DisabledAt != nil {
+		c.Redirect(http.StatusFound, "/?error=account_disabled")
+		return
+	}
+
+	if err := auth.CreateSession(c, user.ID); err != nil {
+		c.Redirect(http.StatusTemporaryRedirect, "/?error=session_creation_failed")
+		return
+	}
+	auth.SetCookie(c, "username", user.Username, int(auth.SessionTTL.Seconds()))
+
+	c.Set("user", user)
+	c.Set("userID", user.ID)
+	audit.Record(c, audit.Entry{
+		Action:     audit.ActionLogin,
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		Details:    map[string]interface{}{"provider": provider},
+	})
+
+	c.Redirect(http.StatusFound, "/dashboard")
 }
 
 func GitHubAuth(c *gin.Context) {
 	oauthConfig := auth.GetGitHubOAuthConfig()
 	state := auth.GenerateStateOauthCookie(c)
+	rememberLinkIntent(c)
 	url := oauthConfig.AuthCodeURL(state)
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
@@ -54,21 +127,13 @@ func GitHubCallback(c *gin.Context) {
 		email = username + "@users.noreply.github.com"
 	}
 
-	user, err := auth.CreateOrGetUser(providerID, email, username, "github")
-	if err != nil {
-		c.Redirect(http.StatusTemporaryRedirect, "/?error=user_creation_failed")
-		return
-	}
-
-	c.SetCookie("user_id", user.ID.String(), 3600*24, "/", "localhost", false, true)
-	c.SetCookie("username", user.Username, 3600*24, "/", "localhost", false, true)
-
-	c.Redirect(http.StatusFound, "/dashboard")
+	completeLogin(c, "github", providerID, email, username)
 }
 
 func GitLabAuth(c *gin.Context) {
 	oauthConfig := auth.GetGitLabOAuthConfig()
 	state := auth.GenerateStateOauthCookie(c)
+	rememberLinkIntent(c)
 	url := oauthConfig.AuthCodeURL(state)
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
@@ -101,20 +166,65 @@ func GitLabCallback(c *gin.Context) {
 	email := userInfo["email"].(string)
 	username := userInfo["username"].(string)
 
-	user, err := auth.CreateOrGetUser(providerID, email, username, "gitlab")
-	if err != nil {
-		c.Redirect(http.StatusTemporaryRedirect, "/?error=user_creation_failed")
+	completeLogin(c, "gitlab", providerID, email, username)
+}
+
+func OIDCAuth(c *gin.Context) {
+	provider := auth.GetOIDCProvider()
+	if provider == nil {
+		c.Redirect(http.StatusTemporaryRedirect, "/?error=oidc_disabled")
 		return
 	}
 
-	c.SetCookie("user_id", user.ID.String(), 3600*24, "/", "localhost", false, true)
-	c.SetCookie("username", user.Username, 3600*24, "/", "localhost", false, true)
+	state := auth.GenerateStateOauthCookie(c)
+	nonce, verifier := auth.GenerateOIDCCookies(c)
+	rememberLinkIntent(c)
+	c.Redirect(http.StatusTemporaryRedirect, provider.AuthCodeURL(state, nonce, verifier))
+}
 
-	c.Redirect(http.StatusFound, "/dashboard")
+func OIDCCallback(c *gin.Context) {
+	provider := auth.GetOIDCProvider()
+	if provider == nil {
+		c.Redirect(http.StatusTemporaryRedirect, "/?error=oidc_disabled")
+		return
+	}
+
+	state := c.Query("state")
+	cookieState, err := c.Cookie("oauthstate")
+	if err != nil || state != cookieState {
+		c.Redirect(http.StatusTemporaryRedirect, "/?error=invalid_state")
+		return
+	}
+
+	nonce, nonceErr := c.Cookie("oidcnonce")
+	verifier, verifierErr := c.Cookie("oidcverifier")
+	auth.SetCookie(c, "oidcnonce", "", -1)
+	auth.SetCookie(c, "oidcverifier", "", -1)
+	if nonceErr != nil || verifierErr != nil || nonce == "" || verifier == "" {
+		c.Redirect(http.StatusTemporaryRedirect, "/?error=invalid_state")
+		return
+	}
+
+	claims, err := provider.Exchange(c, c.Query("code"), verifier, nonce)
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		c.Redirect(http.StatusTemporaryRedirect, "/?error=token_exchange_failed")
+		return
+	}
+
+	completeLogin(c, "oidc", claims.Subject, claims.VerifiedEmail(), claims.Username())
 }
 
 func Logout(c *gin.Context) {
-	c.SetCookie("user_id", "", -1, "/", "localhost", false, true)
-	c.SetCookie("username", "", -1, "/", "localhost", false, true)
+	if user, err := auth.GetUserFromRequest(c); err == nil && user != nil {
+		c.Set("user", user)
+		c.Set("userID", user.ID)
+		audit.Record(c, audit.Entry{Action: audit.ActionLogout, TargetType: "user", TargetID: user.ID.String()})
+	}
+
+	if err := auth.RevokeSession(c); err != nil {
+		log.Printf("Failed to revoke session: %v", err)
+	}
+	auth.SetCookie(c, "username", "", -1)
 	c.Redirect(http.StatusFound, "/")
 }
@@ -0,0 +1,563 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/models"
+	"chimerascan/quota"
+	"chimerascan/rbac"
+	"chimerascan/storage"
+	"chimerascan/store"
+	"chimerascan/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Не больше стольких сканирований в одной массовой операции
+const maxBulkScans = 500
+
+// Статусы результата по одному сканированию
+const (
+	bulkOK    = "ok"
+	bulkError = "error"
+)
+
+// bulkFilter - фильтр сканирований с теми же условиями, что у GET /api/scans
+type bulkFilter struct {
+	Status    []string          `json:"status"`
+	ProjectID *uuid.UUID        `json:"project_id"`
+	Target    string            `json:"target"`
+	Since     string            `json:"since"`
+	Until     string            `json:"until"`
+	Labels    []string          `json:"labels"`
+	Metadata  map[string]string `json:"metadata"`
+}
+
+// bulkSelection - сканирования массовой операции: список scan_ids или фильтр filter
+type bulkSelection struct {
+	ScanIDs []uuid.UUID `json:"scan_ids"`
+	Filter  *bulkFilter `json:"filter"`
+}
+
+// bulkResult - результат операции над одним сканированием
+type bulkResult struct {
+	ScanID    uuid.UUID  `json:"scan_id"`
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	NewScanID *uuid.UUID `json:"new_scan_id,omitempty"`
+}
+
+func (r *bulkResult) fail(message string) {
+	r.Status = bulkError
+	r.Error = message
+}
+
+// query переводит фильтр в запрос к хранилищу. Пустой фильтр запрещен,
+// чтобы операция не затронула все сканирования по ошибке
+func (f *bulkFilter) query() (store.ScanQuery, error) {
+	query := store.ScanQuery{
+		ProjectID: f.ProjectID,
+		Target:    f.Target,
+		Limit:     maxBulkScans,
+	}
+
+	for _, status := range f.Status {
+		if !slices.Contains(scanStatuses, status) {
+			return query, fmt.Errorf("Invalid status, expected one of: %s", strings.Join(scanStatuses, ", "))
+		}
+		query.Statuses = append(query.Statuses, status)
+	}
+
+	for _, bound := range []struct {
+		param, value string
+		target       **time.Time
+	}{{"since", f.Since, &query.Since}, {"until", f.Until, &query.Until}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := parseTimeParam(bound.value)
+		if err != nil {
+			return query, fmt.Errorf("Invalid %s, expected RFC 3339 timestamp or YYYY-MM-DD date", bound.param)
+		}
+		*bound.target = &t
+	}
+
+	for _, label := range f.Labels {
+		if label = strings.TrimSpace(label); label != "" {
+			query.Labels = append(query.Labels, label)
+		}
+	}
+	for key := range f.Metadata {
+		if !metadataKeyPattern.MatchString(key) {
+			return query, fmt.Errorf("Invalid metadata key %q", key)
+		}
+	}
+	query.Metadata = f.Metadata
+
+	if len(query.Statuses) == 0 && query.ProjectID == nil && query.Target == "" && query.Since == nil &&
+		query.Until == nil && len(query.Labels) == 0 && len(query.Metadata) == 0 {
+		return query, fmt.Errorf("Filter must have at least one condition")
+	}
+	return query, nil
+}
+
+// bulkScans возвращает сканирования операции без повторов. Фильтр применяется
+// к сканированиям, видимым пользователю. При ошибке ответ уже отправлен
+func (s *Server) bulkScans(c *gin.Context, sel bulkSelection) ([]uuid.UUID, bool) {
+	if (len(sel.ScanIDs) > 0) == (sel.Filter != nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either scan_ids or filter is required"})
+		return nil, false
+	}
+
+	var ids []uuid.UUID
+	if sel.Filter != nil {
+		query, err := sel.Filter.query()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return nil, false
+		}
+		page, err := s.Scans.ListScans(c.Request.Context(), c.MustGet("userID").(uuid.UUID), query)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scans"})
+			return nil, false
+		}
+		if page.HasMore {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Filter matches more than %d scans", maxBulkScans)})
+			return nil, false
+		}
+		for _, scan := range page.Items {
+			ids = append(ids, scan.ID)
+		}
+	} else {
+		for _, id := range sel.ScanIDs {
+			if !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	if len(ids) > maxBulkScans {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Too many scans, at most %d allowed", maxBulkScans)})
+		return nil, false
+	}
+	return ids, true
+}
+
+// authorizeBulk проверяет право perm на каждое сканирование; недоступные отмечаются ошибкой
+func (s *Server) authorizeBulk(c *gin.Context, ids []uuid.UUID, perm rbac.Permission) []bulkResult {
+	results := make([]bulkResult, len(ids))
+	for i, id := range ids {
+		results[i] = bulkResult{ScanID: id, Status: bulkOK}
+		switch err := s.authorize(c, rbac.KindScan, id.String(), perm); err {
+		case nil:
+		case rbac.ErrNotFound:
+			results[i].fail("Scan not found")
+		case rbac.ErrForbidden:
+			results[i].fail("Insufficient permissions")
+		default:
+			results[i].fail("Failed to check permissions")
+		}
+	}
+	return results
+}
+
+// Сканирования, по которым еще нет ошибки
+func bulkPending(results []bulkResult) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, result := range results {
+		if result.Status == bulkOK {
+			ids = append(ids, result.ScanID)
+		}
+	}
+	return ids
+}
+
+func respondBulk(c *gin.Context, results []bulkResult) {
+	succeeded := len(bulkPending(results))
+	c.JSON(http.StatusOK, gin.H{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+	})
+}
+
+// Ответ на сбой транзакции: изменения не применены ни к одному сканированию
+func respondBulkError(c *gin.Context, err error, message string) {
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusConflict, gin.H{"error": "Scans changed during the operation, nothing was applied"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// BulkDeleteScans удаляет сканирования вместе с их отчетами и доказательствами
+func (s *Server) BulkDeleteScans(c *gin.Context) {
+	var req bulkSelection
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ids, ok := s.bulkScans(c, req)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	results := s.authorizeBulk(c, ids, rbac.PermScanDelete)
+	files := make(map[uuid.UUID]store.ScanFiles, len(results))
+	for i := range results {
+		if results[i].Status != bulkOK {
+			continue
+		}
+		scanFiles, err := s.Scans.ScanFiles(ctx, results[i].ScanID)
+		if err == store.ErrNotFound {
+			results[i].fail("Scan not found")
+			continue
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete scans"})
+			return
+		}
+		files[results[i].ScanID] = scanFiles
+	}
+
+	if err := s.Scans.DeleteScans(ctx, bulkPending(results)); err != nil {
+		respondBulkError(c, err, "Failed to delete scans")
+		return
+	}
+
+	// Файлы удаляются после записи в БД, как и при удалении одного сканирования
+	for _, id := range bulkPending(results) {
+		removed := removeReportFiles(ctx, files[id].ReportPaths) + storage.DeleteAll(ctx, files[id].EvidenceKeys)
+		s.Audit(c, audit.Entry{
+			Action:     audit.ActionScanDelete,
+			TargetType: rbac.KindScan,
+			TargetID:   id.String(),
+			Details:    map[string]interface{}{"report_files": removed, "bulk": true},
+		})
+	}
+
+	respondBulk(c, results)
+}
+
+// BulkMoveScans переносит сканирования в проект project_id; пустой project_id убирает их из проектов
+func (s *Server) BulkMoveScans(c *gin.Context) {
+	var req struct {
+		bulkSelection
+		ProjectID *string `json:"project_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ids, ok := s.bulkScans(c, req.bulkSelection)
+	if !ok {
+		return
+	}
+
+	var projectID *uuid.UUID
+	if req.ProjectID != nil && *req.ProjectID != "" {
+		pid, err := uuid.Parse(*req.ProjectID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+
+		// Проверки целевого проекта те же, что в AddScanToProject
+		if err := s.authorize(c, rbac.KindProject, pid.String(), rbac.PermScanStart); err != nil {
+			if err == rbac.ErrForbidden {
+				respondAccessError(c, err, "Project not found")
+			} else {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Project not found"})
+			}
+			return
+		}
+		if !s.requireActiveProject(c, pid) {
+			return
+		}
+
+		projectID = &pid
+	}
+
+	results := s.authorizeBulk(c, ids, rbac.PermProjectManage)
+	if err := s.Scans.SetScansProject(c.Request.Context(), bulkPending(results), projectID); err != nil {
+		respondBulkError(c, err, "Failed to move scans")
+		return
+	}
+
+	for _, id := range bulkPending(results) {
+		s.Audit(c, audit.Entry{
+			Action:     audit.ActionScanMove,
+			TargetType: rbac.KindScan,
+			TargetID:   id.String(),
+			Details:    map[string]interface{}{"project_id": projectID, "bulk": true},
+		})
+	}
+
+	respondBulk(c, results)
+}
+
+// BulkCancelScans останавливает сканирования в очереди и выполняющиеся; завершенные не меняются
+func (s *Server) BulkCancelScans(c *gin.Context) {
+	var req bulkSelection
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ids, ok := s.bulkScans(c, req)
+	if !ok {
+		return
+	}
+
+	results := s.authorizeBulk(c, ids, rbac.PermScanStop)
+	canceled, err := s.Scans.CancelScans(c.Request.Context(), bulkPending(results))
+	if err != nil {
+		respondBulkError(c, err, "Failed to cancel scans")
+		return
+	}
+
+	for i := range results {
+		if results[i].Status != bulkOK {
+			continue
+		}
+		id := results[i].ScanID
+		if !slices.Contains(canceled, id) {
+			results[i].fail("Scan is not running")
+			continue
+		}
+
+		killScanProcess(id)
+		s.notifyScanEvent(id, webhooks.EventScanCanceled, map[string]interface{}{"status": "Canceled"})
+		s.Audit(c, audit.Entry{
+			Action:     audit.ActionScanStop,
+			TargetType: rbac.KindScan,
+			TargetID:   id.String(),
+			Details:    map[string]interface{}{"bulk": true},
+		})
+	}
+
+	respondBulk(c, results)
+}
+
+// BulkRerunScans повторно запускает сканирования с их исходными настройками.
+// Квоты проверяются с учетом сканирований, уже принятых этим запросом
+func (s *Server) BulkRerunScans(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req bulkSelection
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ids, ok := s.bulkScans(c, req)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	results := s.authorizeBulk(c, ids, rbac.PermScanStart)
+	originals := make([]*models.Scan, len(results))
+	subjects := make([]quota.Subject, len(results))
+	for i := range results {
+		if results[i].Status != bulkOK {
+			continue
+		}
+
+		original, err := s.Scans.GetScan(ctx, results[i].ScanID)
+		if err == store.ErrNotFound {
+			results[i].fail("Scan not found")
+			continue
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scan"})
+			return
+		}
+
+		if original.ProjectID != nil {
+			project, err := s.Projects.GetProject(ctx, *original.ProjectID)
+			if err == store.ErrNotFound {
+				results[i].fail("Project not found")
+				continue
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch project"})
+				return
+			}
+			if project.ArchivedAt != nil {
+				results[i].fail("Project is archived")
+				continue
+			}
+		}
+
+		originals[i] = original
+		subjects[i] = quotaSubject(c, original.ProjectID)
+	}
+
+	scans, ok := s.createRerunsWithinQuota(c, userID, results, originals, subjects)
+	if !ok {
+		return
+	}
+
+	for _, scan := range scans {
+		s.queueScan(c, *scan, audit.ActionScanRerun, map[string]interface{}{"retest_of": scan.RetestOf, "bulk": true})
+	}
+
+	respondBulk(c, results)
+}
+
+// createRerunsWithinQuota проверяет квоты и сохраняет перезапуски под блокировкой квот всех
+// владельцев. Сканирования, не прошедшие проверку, отмечаются в results. При ошибке ответ уже отправлен
+func (s *Server) createRerunsWithinQuota(c *gin.Context, userID uuid.UUID, results []bulkResult, originals []*models.Scan, subjects []quota.Subject) ([]*models.Scan, bool) {
+	var locked []quota.Subject
+	for i := range results {
+		if results[i].Status == bulkOK {
+			locked = append(locked, subjects[i])
+		}
+	}
+	unlock, err := quota.Lock(c.Request.Context(), s.Quotas, locked...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+		return nil, false
+	}
+	defer unlock()
+
+	var scans []*models.Scan
+	pending := map[quota.Subject]int{}
+	for i := range results {
+		if results[i].Status != bulkOK {
+			continue
+		}
+
+		original, subject := originals[i], subjects[i]
+		err := quota.CheckScanStartPending(c.Request.Context(), s.Quotas, subject, original.ProjectID, original.TargetURL, pending[subject])
+		if exceeded, ok := err.(*quota.ExceededError); ok {
+			results[i].fail("Quota exceeded: " + exceeded.Quota)
+			continue
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+			return nil, false
+		}
+		pending[subject]++
+
+		config := defaultScanConfig()
+		if original.Config != nil {
+			config = *original.Config
+		}
+		scan := newRerun(original, userID, config)
+		scans = append(scans, &scan)
+		results[i].NewScanID = &scan.ID
+	}
+
+	if err := s.Scans.CreateScans(c.Request.Context(), scans); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create scans"})
+		return nil, false
+	}
+	return scans, true
+}
+
+// BulkExportScans отдает zip-архив с отчетами сканирований (<scan_id>/report.<формат>)
+// и results.json с результатом по каждому сканированию. Если выгружать нечего, архив
+// не создается и результаты возвращаются в JSON
+func (s *Server) BulkExportScans(c *gin.Context) {
+	var req bulkSelection
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ids, ok := s.bulkScans(c, req)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	results := s.authorizeBulk(c, ids, rbac.PermView)
+	reports := make(map[uuid.UUID]map[string]string, len(results))
+	for i := range results {
+		if results[i].Status != bulkOK {
+			continue
+		}
+		scan, err := s.Scans.GetScan(ctx, results[i].ScanID)
+		if err == store.ErrNotFound {
+			results[i].fail("Scan not found")
+			continue
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scan"})
+			return
+		}
+
+		paths := map[string]string{}
+		for format, path := range map[string]string{"json": scan.ReportJSONPath, "pdf": scan.ReportPDFPath, "html": scan.ReportHTMLPath} {
+			if path != "" {
+				paths[format] = path
+			}
+		}
+		if len(paths) == 0 {
+			results[i].fail("Scan has no reports")
+			continue
+		}
+		reports[scan.ID] = paths
+	}
+
+	if len(bulkPending(results)) == 0 {
+		respondBulk(c, results)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="scans_%s.zip"`, time.Now().Format("20060102_150405")))
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	for i := range results {
+		if results[i].Status != bulkOK {
+			continue
+		}
+		id := results[i].ScanID
+		for _, rf := range reportFormats {
+			path, ok := reports[id][rf.Format]
+			if !ok {
+				continue
+			}
+			if err := addReportToZip(c, archive, id.String()+"/report."+rf.Format, path); err != nil {
+				results[i].fail("Failed to read " + rf.Format + " report")
+				break
+			}
+		}
+		if results[i].Status == bulkOK {
+			s.Audit(c, audit.Entry{
+				Action:     audit.ActionReportDownload,
+				TargetType: rbac.KindScan,
+				TargetID:   id.String(),
+				Details:    map[string]interface{}{"format": "zip", "bulk": true},
+			})
+		}
+	}
+
+	// Заголовки уже отправлены, поэтому ошибки чтения отчетов попадают только в results.json
+	if manifest, err := archive.Create("results.json"); err == nil {
+		json.NewEncoder(manifest).Encode(results)
+	}
+	archive.Close()
+}
+
+// Копирует отчет из хранилища в архив под именем name
+func addReportToZip(c *gin.Context, archive *zip.Writer, name, path string) error {
+	reader, err := storage.Reports.Get(c.Request.Context(), storage.ReportKey(path))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, reader)
+	return err
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"unicode/utf8"
+
+	"chimerascan/audit"
+	"chimerascan/rbac"
+	"chimerascan/redaction"
+	"chimerascan/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// EvidenceInlineLimit - максимальный размер ответа в байтах, который хранится прямо в БД.
+// Более крупные ответы сжимаются и переносятся в хранилище отчетов.
+var EvidenceInlineLimit = 64 * 1024
+
+// Скрытие чувствительных данных в находках до сохранения и формирования отчетов
+func redactResults(results []NucleiResult) {
+	for i := range results {
+		results[i].Request = redaction.Default.Apply(results[i].Request)
+		results[i].Response = redaction.Default.Apply(results[i].Response)
+		results[i].CurlCommand = redaction.Default.Apply(results[i].CurlCommand)
+	}
+}
+
+// Перенос большого ответа в сжатый файл доказательств.
+// Возвращает ключ файла в хранилище или nil, если ответ помещается в БД.
+func offloadEvidence(scanID, vulnID uuid.UUID, result *NucleiResult) *string {
+	if len(result.Response) <= EvidenceInlineLimit {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(result.Response)); err != nil {
+		log.Printf("Failed to compress evidence: %v", err)
+		return nil
+	}
+	if err := gz.Close(); err != nil {
+		log.Printf("Failed to compress evidence: %v", err)
+		return nil
+	}
+
+	key := fmt.Sprintf("evidence/%s/%s.response.gz", scanID, vulnID)
+	if err := storage.Reports.Put(context.Background(), key, buf.Bytes(), "application/gzip"); err != nil {
+		log.Printf("Failed to store evidence: %v", err)
+		return nil
+	}
+
+	result.Response = truncateUTF8(result.Response, EvidenceInlineLimit)
+	return &key
+}
+
+// Обрезка строки без разрыва многобайтовых символов
+func truncateUTF8(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
+
+// Чтение сжатого ответа из хранилища
+func loadEvidence(ctx context.Context, key string) (string, error) {
+	reader, err := storage.Reports.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return "", err
+	}
+	defer gz.Close()
+
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// GetVulnerabilityEvidence возвращает запрос и полный ответ для находки
+func (s *Server) GetVulnerabilityEvidence(c *gin.Context) {
+	vulnID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vulnerability not found"})
+		return
+	}
+
+	vuln, err := s.Vulnerabilities.GetVulnerability(c.Request.Context(), vulnID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Vulnerability not found"})
+		return
+	}
+
+	if err := s.authorize(c, rbac.KindScan, vuln.ScanID.String(), rbac.PermView); err != nil {
+		respondAccessError(c, err, "Vulnerability not found")
+		return
+	}
+
+	evidence := struct {
+		ID           uuid.UUID `json:"id"`
+		Request      string    `json:"request"`
+		CurlCommand  string    `json:"curl_command"`
+		Response     string    `json:"response"`
+		ResponseSize int       `json:"response_size"`
+		Truncated    bool      `json:"truncated"`
+	}{
+		ID:           vuln.ID,
+		Request:      vuln.Request,
+		CurlCommand:  vuln.CurlCommand,
+		Response:     vuln.Response,
+		ResponseSize: vuln.ResponseSize,
+	}
+
+	if vuln.EvidenceKey != nil && *vuln.EvidenceKey != "" {
+		full, err := loadEvidence(c.Request.Context(), *vuln.EvidenceKey)
+		if err != nil {
+			log.Printf("Failed to load evidence %s: %v", *vuln.EvidenceKey, err)
+			evidence.Truncated = true
+		} else {
+			evidence.Response = full
+		}
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionEvidenceView,
+		TargetType: rbac.KindScan,
+		TargetID:   vuln.ScanID.String(),
+		Details:    map[string]interface{}{"vulnerability_id": evidence.ID},
+	})
+
+	c.JSON(http.StatusOK, evidence)
+}
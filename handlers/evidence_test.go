@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/models"
+	"chimerascan/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactResults(t *testing.T) {
+	results := []NucleiResult{{
+		Request:     "GET / HTTP/1.1\r\nCookie: session=abc123\r\n\r\n",
+		CurlCommand: "curl -H 'Authorization: Bearer secret-token' 'https://example.com'",
+	}}
+
+	redactResults(results)
+
+	assert.NotContains(t, results[0].Request, "abc123")
+	assert.NotContains(t, results[0].CurlCommand, "secret-token")
+}
+
+func TestOffloadEvidence_LargeResponse(t *testing.T) {
+	store, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+	oldStore := storage.Reports
+	storage.Reports = store
+	defer func() { storage.Reports = oldStore }()
+
+	oldLimit := EvidenceInlineLimit
+	EvidenceInlineLimit = 16
+	defer func() { EvidenceInlineLimit = oldLimit }()
+
+	full := "HTTP/1.1 200 OK\r\n\r\n" + strings.Repeat("x", 100)
+	result := NucleiResult{Response: full}
+
+	key := offloadEvidence(uuid.New(), uuid.New(), &result)
+
+	assert.NotNil(t, key, "Large response should be offloaded")
+	assert.Len(t, result.Response, 16, "Inline response should be truncated")
+
+	loaded, err := loadEvidence(t.Context(), *key)
+	assert.NoError(t, err)
+	assert.Equal(t, full, loaded)
+
+	small := NucleiResult{Response: "ok"}
+	assert.Nil(t, offloadEvidence(uuid.New(), uuid.New(), &small), "Small response should stay inline")
+}
+
+// Находка во сканировании ownerID с ответом, сохраненным в базе целиком
+func addEvidenceVulnerability(t *testing.T, s *memoryServer, ownerID uuid.UUID) models.Vulnerability {
+	scan := s.addScan(t, ownerID, nil, time.Now())
+	vuln := models.Vulnerability{
+		ID:           uuid.New(),
+		ScanID:       scan.ID,
+		Request:      "GET / HTTP/1.1",
+		Response:     "HTTP/1.1 200 OK",
+		CurlCommand:  "curl https://example.com",
+		ResponseSize: 15,
+	}
+	require.NoError(t, s.store.CreateVulnerability(context.Background(), &vuln))
+	return vuln
+}
+
+func TestGetVulnerabilityEvidence_NotOwner(t *testing.T) {
+	s := newMemoryServer()
+	vuln := addEvidenceVulnerability(t, s, uuid.New())
+
+	w := call(s.GetVulnerabilityEvidence, uuid.New(), "GET", "/api/vulnerabilities/"+vuln.ID.String()+"/evidence", idParam(vuln.ID), nil)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, s.events)
+}
+
+func TestGetVulnerabilityEvidence_Inline(t *testing.T) {
+	userID := uuid.New()
+	s := newMemoryServer()
+	vuln := addEvidenceVulnerability(t, s, userID)
+
+	w := call(s.GetVulnerabilityEvidence, userID, "GET", "/api/vulnerabilities/"+vuln.ID.String()+"/evidence", idParam(vuln.ID), nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "HTTP/1.1 200 OK", response["response"])
+	assert.Equal(t, false, response["truncated"])
+	require.Len(t, s.events, 1)
+	assert.Equal(t, audit.ActionEvidenceView, s.events[0].Action)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"chimerascan/audit"
+	"chimerascan/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GetIdentities возвращает учетные записи провайдеров, привязанные к пользователю
+func GetIdentities(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	identities, err := auth.ListIdentities(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch identities"})
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+// DeleteIdentity отвязывает учетную запись провайдера
+func DeleteIdentity(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	identityID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid identity ID"})
+		return
+	}
+
+	deleted, err := auth.UnlinkIdentity(userID, identityID)
+	if err == auth.ErrLastIdentity {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot unlink the last sign-in method"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink identity"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Identity not found"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionIdentityUnlink, TargetType: "identity", TargetID: identityID.String()})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Identity unlinked successfully"})
+}
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"chimerascan/audit"
 	"chimerascan/database"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -49,18 +50,23 @@ func TestIntegration_Project_Create_And_Get(t *testing.T) {
 		c.Request = req
 		c.Set("userID", userID)
 
-		CreateProject(c)
+		expectAudit(mock, audit.ActionProjectCreate)
+
+		mockServer().CreateProject(c)
 
 		assert.Equal(t, 201, w.Code)
 		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 
 	t.Run("Get Projects", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "name", "description", "created_at"}).
-			AddRow(uuid.New(), "Project 1", "Desc 1", time.Now())
+		rows := sqlmock.NewRows([]string{"id", "name", "description", "user_id", "organization_id", "created_at", "archived_at"}).
+			AddRow(uuid.New(), "Project 1", "Desc 1", userID, nil, time.Now(), nil)
 
-		mock.ExpectQuery(`SELECT id, name, description, created_at`).
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM projects p`).
 			WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(`SELECT p.id, p.name, p.description, p.user_id, p.organization_id, p.created_at, p.archived_at`).
+			WithArgs(userID, defaultPageLimit+1).
 			WillReturnRows(rows)
 
 		req, _ := http.NewRequest("GET", "/api/projects", nil)
@@ -70,7 +76,7 @@ func TestIntegration_Project_Create_And_Get(t *testing.T) {
 		c.Request = req
 		c.Set("userID", userID)
 
-		GetProjects(c)
+		mockServer().GetProjects(c)
 
 		assert.Equal(t, 200, w.Code)
 		assert.NoError(t, mock.ExpectationsWereMet())
@@ -82,7 +88,7 @@ func TestIntegration_API_Validation(t *testing.T) {
 
 	tests := []struct {
 		name       string
-		handler    gin.HandlerFunc
+		handler    func(*Server, *gin.Context)
 		method     string
 		path       string
 		body       interface{}
@@ -91,7 +97,7 @@ func TestIntegration_API_Validation(t *testing.T) {
 	}{
 		{
 			name:       "Create project with empty name",
-			handler:    CreateProject,
+			handler:    (*Server).CreateProject,
 			method:     "POST",
 			path:       "/api/projects",
 			body:       map[string]interface{}{"name": "", "description": "test"},
@@ -100,7 +106,7 @@ func TestIntegration_API_Validation(t *testing.T) {
 		},
 		{
 			name:       "Start scan with invalid URL",
-			handler:    StartScan,
+			handler:    (*Server).StartScan,
 			method:     "POST",
 			path:       "/api/scan/start",
 			body:       map[string]interface{}{"target_url": "invalid", "project_id": ""},
@@ -140,7 +146,7 @@ func TestIntegration_API_Validation(t *testing.T) {
 			c.Request = req
 			c.Set("userID", tt.userID)
 
-			tt.handler(c)
+			tt.handler(mockServer(), c)
 
 			assert.Equal(t, tt.wantStatus, w.Code, "Expected status %d, got %d", tt.wantStatus, w.Code)
 		})
@@ -156,9 +162,9 @@ func TestIntegration_Complete_Workflow(t *testing.T) {
 	}
 	defer db.Close()
 
-	oldDB := database.DB
-	database.DB = db
-	defer func() { database.DB = oldDB }()
+	oldDB, oldDriver := database.DB, database.Driver
+	database.DB, database.Driver = db, database.DriverPostgres // квоты блокируются через pg_advisory_lock
+	defer func() { database.DB, database.Driver = oldDB, oldDriver }()
 
 	userID := uuid.New()
 	projectID := uuid.New()
@@ -182,14 +188,25 @@ func TestIntegration_Complete_Workflow(t *testing.T) {
 		c.Request = req
 		c.Set("userID", userID)
 
-		CreateProject(c)
+		expectAudit(mock, audit.ActionProjectCreate)
+
+		mockServer().CreateProject(c)
 
 		assert.Equal(t, 201, w.Code)
 	})
 
 	t.Run("2. Start Scan", func(t *testing.T) {
+		expectProjectAccess(mock, projectID, userID, userID)
+		expectActiveProject(mock, projectID, userID)
+		expectQuotaUsage(mock, userID, 0)
+		mock.ExpectQuery(`SELECT COUNT\(DISTINCT target_url\)`).
+			WithArgs(projectID, "https://example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"targets", "same"}).AddRow(1, 1))
+		mock.ExpectBegin()
 		mock.ExpectExec(`INSERT INTO scans`).
 			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+		expectQuotaUnlock(mock)
 
 		reqBody := map[string]interface{}{
 			"target_url": "https://example.com",
@@ -205,18 +222,32 @@ func TestIntegration_Complete_Workflow(t *testing.T) {
 		c.Request = req
 		c.Set("userID", userID)
 
-		StartScan(c)
+		expectAudit(mock, audit.ActionScanStart)
+
+		mockServer().StartScan(c)
 
 		assert.Equal(t, 202, w.Code)
 	})
 
 	t.Run("3. Get Scans", func(t *testing.T) {
-		rows := sqlmock.NewRows([]string{"id", "target_url", "status", "project_id", "started_at", "finished_at", "created_at"}).
-			AddRow(scanID, "https://example.com", "Queued", projectID, time.Now(), nil, time.Now())
+		rows := sqlmock.NewRows([]string{"id", "target_url", "status", "project_id", "started_at", "finished_at", "created_at", "user_id", "retest_of", "notes"}).
+			AddRow(scanID, "https://example.com", "Queued", projectID, time.Now(), nil, time.Now(), userID, nil, "")
 
-		mock.ExpectQuery(`SELECT id, target_url, status, project_id, started_at, finished_at, created_at`).
+		mock.ExpectQuery(`SELECT COUNT\(\*\) FROM scans s`).
 			WithArgs(userID).
+			WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+		mock.ExpectQuery(`SELECT s.id, s.target_url, s.status, s.project_id, s.started_at, s.finished_at, s.created_at, s.user_id`).
+			WithArgs(userID, defaultPageLimit+1).
 			WillReturnRows(rows)
+		mock.ExpectQuery(`SELECT scan_id, label FROM scan_labels`).
+			WithArgs(scanID).
+			WillReturnRows(sqlmock.NewRows([]string{"scan_id", "label"}))
+		mock.ExpectQuery(`SELECT scan_id, key, value FROM scan_metadata`).
+			WithArgs(scanID).
+			WillReturnRows(sqlmock.NewRows([]string{"scan_id", "key", "value"}))
+		mock.ExpectQuery(`SELECT scan_id, LOWER\(severity\), COUNT\(\*\) FROM vulnerabilities`).
+			WithArgs(scanID).
+			WillReturnRows(sqlmock.NewRows([]string{"scan_id", "severity", "count"}))
 
 		req, _ := http.NewRequest("GET", "/api/scans", nil)
 
@@ -225,14 +256,16 @@ func TestIntegration_Complete_Workflow(t *testing.T) {
 		c.Request = req
 		c.Set("userID", userID)
 
-		GetScans(c)
+		mockServer().GetScans(c)
 
 		assert.Equal(t, 200, w.Code)
 	})
 
 	t.Run("4. Delete Scan", func(t *testing.T) {
-		mock.ExpectExec(`DELETE FROM scans WHERE id = \$1 AND user_id = \$2`).
-			WithArgs(scanID, userID).
+		expectScanAccess(mock, scanID, userID, userID)
+		expectScanFiles(mock, scanID)
+		mock.ExpectExec(`DELETE FROM scans WHERE id = \$1`).
+			WithArgs(scanID).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		req, _ := http.NewRequest("DELETE", "/api/scans/"+scanID.String(), nil)
@@ -243,14 +276,17 @@ func TestIntegration_Complete_Workflow(t *testing.T) {
 		c.Set("userID", userID)
 		c.Params = []gin.Param{{Key: "id", Value: scanID.String()}}
 
-		DeleteScan(c)
+		expectAudit(mock, audit.ActionScanDelete)
+
+		mockServer().DeleteScan(c)
 
 		assert.Equal(t, 200, w.Code)
 	})
 
 	t.Run("5. Delete Project", func(t *testing.T) {
-		mock.ExpectExec(`DELETE FROM projects WHERE id = \$1 AND user_id = \$2`).
-			WithArgs(projectID, userID).
+		expectProjectAccess(mock, projectID, userID, userID)
+		mock.ExpectExec(`UPDATE projects SET deleted_at = \$1 WHERE id = \$2 AND deleted_at IS NULL`).
+			WithArgs(sqlmock.AnyArg(), projectID).
 			WillReturnResult(sqlmock.NewResult(0, 1))
 
 		req, _ := http.NewRequest("DELETE", "/api/projects/"+projectID.String(), nil)
@@ -261,7 +297,9 @@ func TestIntegration_Complete_Workflow(t *testing.T) {
 		c.Set("userID", userID)
 		c.Params = []gin.Param{{Key: "id", Value: projectID.String()}}
 
-		DeleteProject(c)
+		expectAudit(mock, audit.ActionProjectDelete)
+
+		mockServer().DeleteProject(c)
 
 		assert.Equal(t, 200, w.Code)
 	})
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/issues"
+	"chimerascan/models"
+	"chimerascan/rbac"
+	"chimerascan/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Формат репозитория: owner/repo для GitHub, group/subgroup/project или числовой ID для GitLab
+var repositoryPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+(/[A-Za-z0-9_.-]+)+$|^[0-9]+$`)
+
+// Загрузка привязки проекта к репозиторию с расшифрованным токеном; права на проект проверяются до вызова
+func (s *Server) loadProjectRepository(ctx context.Context, projectID uuid.UUID) (*models.ProjectRepository, error) {
+	repo, err := s.Issues.ProjectRepository(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	repo.Token, err = issues.OpenToken(repo.Token)
+	if err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// GetProjectRepository возвращает привязку проекта к репозиторию
+func (s *Server) GetProjectRepository(c *gin.Context) {
+	if !s.authorizeProject(c, c.Param("id"), rbac.PermView) {
+		return
+	}
+
+	repo, err := s.loadProjectRepository(c.Request.Context(), uuid.MustParse(c.Param("id")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, repo)
+}
+
+// LinkProjectRepository привязывает проект к репозиторию GitHub или GitLab
+func (s *Server) LinkProjectRepository(c *gin.Context) {
+	projectID := c.Param("id")
+
+	var req struct {
+		Provider      string `json:"provider" binding:"required,oneof=github gitlab"`
+		Repository    string `json:"repository" binding:"required"`
+		BaseURL       string `json:"base_url"`
+		Token         string `json:"token"`
+		TitleTemplate string `json:"title_template"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !repositoryPattern.MatchString(req.Repository) || strings.Contains(req.Repository, "..") ||
+		(req.Provider == issues.ProviderGitHub && strings.Count(req.Repository, "/") != 1) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid repository"})
+		return
+	}
+
+	if req.BaseURL != "" {
+		u, err := url.Parse(req.BaseURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid base URL"})
+			return
+		}
+	}
+
+	if req.TitleTemplate != "" {
+		if err := issues.ValidateTitleTemplate(req.TitleTemplate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid title template: " + err.Error()})
+			return
+		}
+	}
+
+	if !s.authorizeProject(c, projectID, rbac.PermProjectManage) {
+		return
+	}
+
+	// Сохраненный токен годится только для того же трекера: иначе его получил бы
+	// хост, указанный в новом base_url
+	ctx := c.Request.Context()
+	linked, err := s.Issues.ProjectRepository(ctx, uuid.MustParse(projectID))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link repository"})
+		return
+	}
+	if req.Token == "" && linked.Token != "" && (linked.Provider != req.Provider || linked.BaseURL != req.BaseURL) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A new token is required when the provider or base URL changes"})
+		return
+	}
+
+	sealed, err := issues.SealToken(req.Token)
+	if err == issues.ErrNoTokenKey {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Issue tracker tokens cannot be stored: ISSUE_TOKEN_KEY is not configured"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link repository"})
+		return
+	}
+
+	// Пустой токен сохраняет ранее указанный, только если трекер не изменился
+	err = s.Issues.LinkProjectRepository(ctx, models.ProjectRepository{
+		ProjectID:     linked.ProjectID,
+		Provider:      req.Provider,
+		Repository:    req.Repository,
+		BaseURL:       req.BaseURL,
+		Token:         sealed,
+		TitleTemplate: req.TitleTemplate,
+	})
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link repository"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionRepositoryLink,
+		TargetType: rbac.KindProject,
+		TargetID:   projectID,
+		Details:    map[string]interface{}{"provider": req.Provider, "repository": req.Repository},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Repository linked successfully"})
+}
+
+// UnlinkProjectRepository удаляет привязку проекта к репозиторию
+func (s *Server) UnlinkProjectRepository(c *gin.Context) {
+	projectID := c.Param("id")
+
+	if !s.authorizeProject(c, projectID, rbac.PermProjectManage) {
+		return
+	}
+
+	err := s.Issues.UnlinkProjectRepository(c.Request.Context(), uuid.MustParse(projectID))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink repository"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{Action: audit.ActionRepositoryUnlink, TargetType: rbac.KindProject, TargetID: projectID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Repository unlinked successfully"})
+}
+
+// Трекер задач для привязанного проекта
+func (s *Server) projectTracker(c *gin.Context) (*models.ProjectRepository, issues.Tracker, bool) {
+	if !s.authorizeProject(c, c.Param("id"), rbac.PermIssuesExport) {
+		return nil, nil, false
+	}
+
+	repo, err := s.loadProjectRepository(c.Request.Context(), uuid.MustParse(c.Param("id")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return nil, nil, false
+	}
+
+	if repo.Provider == "" || repo.Repository == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project is not linked to a repository"})
+		return nil, nil, false
+	}
+
+	tracker, err := issues.NewTracker(repo.Provider, repo.BaseURL, repo.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+
+	return repo, tracker, true
+}
+
+// ExportFindingsToIssues создает задачи в трекере по выбранным находкам проекта
+func (s *Server) ExportFindingsToIssues(c *gin.Context) {
+	var req struct {
+		VulnerabilityIDs []string `json:"vulnerability_ids" binding:"required,min=1,max=100"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	repo, tracker, ok := s.projectTracker(c)
+	if !ok {
+		return
+	}
+
+	type exportResult struct {
+		VulnerabilityID string `json:"vulnerability_id"`
+		IssueURL        string `json:"issue_url,omitempty"`
+		State           string `json:"state,omitempty"`
+		Error           string `json:"error,omitempty"`
+	}
+
+	ctx := c.Request.Context()
+	results := make([]exportResult, 0, len(req.VulnerabilityIDs))
+	for _, vulnID := range req.VulnerabilityIDs {
+		res := exportResult{VulnerabilityID: vulnID}
+
+		id, err := uuid.Parse(vulnID)
+		if err != nil {
+			res.Error = "Invalid vulnerability ID"
+			results = append(results, res)
+			continue
+		}
+
+		vuln, scan, err := s.projectVulnerability(ctx, repo.ProjectID, id)
+		if err != nil {
+			res.Error = "Vulnerability not found"
+			results = append(results, res)
+			continue
+		}
+
+		if vuln.IssueURL != nil && *vuln.IssueURL != "" {
+			res.IssueURL = *vuln.IssueURL
+			if vuln.IssueState != nil {
+				res.State = *vuln.IssueState
+			}
+			results = append(results, res)
+			continue
+		}
+
+		finding := issues.Finding{
+			Name:           vuln.Name,
+			TemplateID:     vuln.TemplateID,
+			Severity:       vuln.SeverityAI,
+			Host:           vuln.Host,
+			MatchedAt:      vuln.MatchedAt,
+			TargetURL:      scan.TargetURL,
+			Description:    vuln.DescriptionRu,
+			Recommendation: vuln.RecommendationAI,
+			CurlCommand:    vuln.CurlCommand,
+			Request:        vuln.Request,
+			Response:       vuln.Response,
+		}
+		if finding.Description == "" {
+			finding.Description = vuln.Description
+		}
+
+		issue, err := issues.Render(repo.TitleTemplate, finding)
+		if err != nil {
+			res.Error = "Failed to render issue: " + err.Error()
+			results = append(results, res)
+			continue
+		}
+
+		created, err := tracker.CreateIssue(c.Request.Context(), repo.Repository, issue)
+		if err != nil {
+			log.Printf("Failed to create issue for vulnerability %s: %v", vulnID, err)
+			res.Error = "Failed to create issue"
+			results = append(results, res)
+			continue
+		}
+
+		err = s.Issues.SetVulnerabilityIssue(ctx, id, created.URL, created.Number, created.State, time.Now())
+		if err != nil {
+			log.Printf("Failed to store issue link for vulnerability %s: %v", vulnID, err)
+		}
+
+		res.IssueURL = created.URL
+		res.State = created.State
+		results = append(results, res)
+	}
+
+	var exported []string
+	for _, res := range results {
+		if res.IssueURL != "" {
+			exported = append(exported, res.VulnerabilityID)
+		}
+	}
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionIssuesExport,
+		TargetType: rbac.KindProject,
+		TargetID:   repo.ProjectID.String(),
+		Details:    map[string]interface{}{"repository": repo.Repository, "vulnerability_ids": exported},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// Находка проекта вместе с ее сканированием; находки других проектов не найдены
+func (s *Server) projectVulnerability(ctx context.Context, projectID, id uuid.UUID) (*models.Vulnerability, *models.Scan, error) {
+	vuln, err := s.Vulnerabilities.GetVulnerability(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	scan, err := s.Scans.GetScan(ctx, vuln.ScanID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if scan.ProjectID == nil || *scan.ProjectID != projectID {
+		return nil, nil, store.ErrNotFound
+	}
+	return vuln, scan, nil
+}
+
+// SyncProjectIssues обновляет состояние задач, созданных по находкам проекта
+func (s *Server) SyncProjectIssues(c *gin.Context) {
+	repo, tracker, ok := s.projectTracker(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	linked, err := s.Issues.ProjectIssues(ctx, repo.ProjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch issues"})
+		return
+	}
+
+	synced, failed := 0, 0
+	for vulnID, number := range linked {
+		state, err := tracker.IssueState(ctx, repo.Repository, number)
+		if err != nil {
+			log.Printf("Failed to sync issue #%d: %v", number, err)
+			failed++
+			continue
+		}
+
+		if err := s.Issues.SetVulnerabilityIssueState(ctx, vulnID, state, time.Now()); err != nil {
+			failed++
+			continue
+		}
+		synced++
+	}
+
+	c.JSON(http.StatusOK, gin.H{"synced": synced, "failed": failed})
+}
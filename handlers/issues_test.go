@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/config"
+	"chimerascan/database"
+	"chimerascan/issues"
+	"chimerascan/models"
+	"chimerascan/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportFindingsToIssues_GitHub(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Локальная замена GitHub API
+	created := 0
+	fakeGitHub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer pat", r.Header.Get("Authorization"))
+
+		if r.Method == http.MethodGet {
+			assert.Equal(t, "/repos/acme/shop/issues/5", r.URL.Path)
+			w.Write([]byte(`{"state":"closed"}`))
+			return
+		}
+
+		assert.Equal(t, "/repos/acme/shop/issues", r.URL.Path)
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		assert.Equal(t, "[ChimeraScan] high: SQL Injection (example.com)", payload["title"])
+		assert.Contains(t, payload["body"], "Использовать параметризованные запросы")
+
+		created++
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"number":5,"html_url":"https://github.com/acme/shop/issues/5","state":"open"}`))
+	}))
+	defer fakeGitHub.Close()
+
+	ctx := context.Background()
+	s := newMemoryServer()
+	userID := uuid.New()
+	project := models.Project{ID: uuid.New(), Name: "Shop", UserID: userID, CreatedAt: time.Now()}
+	other := models.Project{ID: uuid.New(), Name: "Other", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateProject(ctx, &project))
+	require.NoError(t, s.store.CreateProject(ctx, &other))
+	require.NoError(t, s.store.LinkProjectRepository(ctx, models.ProjectRepository{
+		ProjectID: project.ID, Provider: issues.ProviderGitHub, Repository: "acme/shop", BaseURL: fakeGitHub.URL, Token: "pat",
+	}))
+
+	vuln := models.Vulnerability{
+		ID: uuid.New(), ScanID: s.addScan(t, userID, &project.ID, time.Now()).ID,
+		TemplateID: "sqli-error", Name: "SQL Injection", Severity: "high", SeverityAI: "high",
+		Host: "example.com", MatchedAt: "https://example.com/?id=1", RecommendationAI: "Использовать параметризованные запросы",
+	}
+	foreign := models.Vulnerability{ID: uuid.New(), ScanID: s.addScan(t, userID, &other.ID, time.Now()).ID, TemplateID: "xss", Name: "XSS", Severity: "high"}
+	require.NoError(t, s.store.CreateVulnerability(ctx, &vuln))
+	require.NoError(t, s.store.CreateVulnerability(ctx, &foreign))
+
+	export := func() []map[string]string {
+		w := call(s.ExportFindingsToIssues, userID, "POST", "/api/projects/"+project.ID.String()+"/issues", idParam(project.ID),
+			map[string]interface{}{"vulnerability_ids": []string{vuln.ID.String(), foreign.ID.String()}})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var resp struct {
+			Results []map[string]string `json:"results"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		require.Len(t, resp.Results, 2)
+		return resp.Results
+	}
+
+	results := export()
+	assert.Equal(t, "https://github.com/acme/shop/issues/5", results[0]["issue_url"])
+	assert.Equal(t, "Vulnerability not found", results[1]["error"], "Findings of other projects are not exported")
+
+	// Повторный экспорт не создает вторую задачу
+	results = export()
+	assert.Equal(t, "https://github.com/acme/shop/issues/5", results[0]["issue_url"])
+	assert.Equal(t, 1, created)
+	if assert.Len(t, s.events, 2) {
+		assert.Equal(t, audit.ActionIssuesExport, s.events[0].Action)
+	}
+
+	w := call(s.SyncProjectIssues, userID, "POST", "/api/projects/"+project.ID.String()+"/issues/sync", idParam(project.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"synced": 1, "failed": 0}`, w.Body.String())
+	stored, err := s.store.GetVulnerability(ctx, vuln.ID)
+	require.NoError(t, err)
+	if assert.NotNil(t, stored.IssueState) {
+		assert.Equal(t, "closed", *stored.IssueState)
+	}
+}
+
+func TestExportFindingsToIssues_NotLinked(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	userID := uuid.New()
+	project := models.Project{ID: uuid.New(), Name: "Shop", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateProject(context.Background(), &project))
+
+	w := call(s.ExportFindingsToIssues, userID, "POST", "/api/projects/"+project.ID.String()+"/issues", idParam(project.ID),
+		map[string]interface{}{"vulnerability_ids": []string{uuid.New().String()}})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Empty(t, s.events)
+}
+
+func TestLinkProjectRepository_TokenFollowsTracker(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+	trackers := config.Issues{GitHubAPIURL: issues.DefaultBaseURL(issues.ProviderGitHub), GitLabBaseURL: issues.DefaultBaseURL(issues.ProviderGitLab)}
+	defer issues.Init(trackers)
+	trackers.TokenKey = base64.StdEncoding.EncodeToString(make([]byte, 32))
+	issues.Init(trackers)
+
+	ctx := context.Background()
+	s := NewServer(store.NewPostgres(database.DB))
+	s.Audit = func(*gin.Context, audit.Entry) {}
+	userID, projectID := uuid.New(), uuid.New()
+	_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, userID, userID.String(), "user@example.com", "user")
+	require.NoError(t, err)
+	_, err = database.DB.Exec(`INSERT INTO projects (id, name, user_id) VALUES ($1, $2, $3)`, projectID, "Shop", userID)
+	require.NoError(t, err)
+
+	link := func(body map[string]string) int {
+		return call(s.LinkProjectRepository, userID, "PUT", "/api/projects/"+projectID.String()+"/repository", idParam(projectID), body).Code
+	}
+	token := func() string {
+		repo, err := s.loadProjectRepository(ctx, projectID)
+		require.NoError(t, err)
+		return repo.Token
+	}
+
+	require.Equal(t, http.StatusOK, link(map[string]string{"provider": "github", "repository": "acme/shop", "token": "pat"}))
+	var stored string
+	require.NoError(t, database.DB.QueryRow(`SELECT issue_token FROM projects WHERE id = $1`, projectID).Scan(&stored))
+	assert.NotContains(t, stored, "pat", "The token is encrypted at rest")
+	assert.Equal(t, "pat", token())
+
+	// Тот же трекер: пустой токен сохраняет прежний
+	require.Equal(t, http.StatusOK, link(map[string]string{"provider": "github", "repository": "acme/web"}))
+	assert.Equal(t, "pat", token())
+
+	// Другой хост не получает сохраненный токен
+	assert.Equal(t, http.StatusBadRequest, link(map[string]string{"provider": "github", "repository": "acme/web", "base_url": "https://attacker.example"}))
+	assert.Equal(t, http.StatusBadRequest, link(map[string]string{"provider": "gitlab", "repository": "acme/web"}))
+	assert.Equal(t, "pat", token())
+
+	require.Equal(t, http.StatusOK, link(map[string]string{"provider": "gitlab", "repository": "acme/web", "token": "glpat"}))
+	assert.Equal(t, "glpat", token())
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"chimerascan/audit"
+	"chimerascan/rbac"
+	"chimerascan/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Ограничения меток, заметок и метаданных сканирования
+const (
+	maxScanLabels       = 20
+	maxLabelLength      = 64
+	maxScanMetadata     = 20
+	maxMetadataKeyLen   = 64
+	maxMetadataValueLen = 256
+	maxScanNotesLength  = 4000
+)
+
+// Ключ метаданных: буквы, цифры, "_", "-" и "."
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// scanDetailsRequest - метки, заметки и метаданные в запросе; не указанное поле не меняется
+type scanDetailsRequest struct {
+	Labels   *[]string          `json:"labels"`
+	Notes    *string            `json:"notes"`
+	Metadata *map[string]string `json:"metadata"`
+}
+
+// Проверка меток: пробелы по краям отбрасываются, повторы убираются; запятая запрещена,
+// так как метки в фильтре перечисляются через запятую
+func normalizeLabels(labels []string) ([]string, error) {
+	normalized := []string{}
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if label == "" || len(label) > maxLabelLength || strings.Contains(label, ",") {
+			return nil, fmt.Errorf("Invalid label %q: expected 1-%d characters without commas", label, maxLabelLength)
+		}
+		if !slices.Contains(normalized, label) {
+			normalized = append(normalized, label)
+		}
+	}
+	if len(normalized) > maxScanLabels {
+		return nil, fmt.Errorf("Too many labels, at most %d allowed", maxScanLabels)
+	}
+	return normalized, nil
+}
+
+func validateMetadata(metadata map[string]string) error {
+	if len(metadata) > maxScanMetadata {
+		return fmt.Errorf("Too many metadata keys, at most %d allowed", maxScanMetadata)
+	}
+	for key, value := range metadata {
+		if len(key) > maxMetadataKeyLen || !metadataKeyPattern.MatchString(key) {
+			return fmt.Errorf("Invalid metadata key %q: expected up to %d letters, digits, '_', '-' or '.'", key, maxMetadataKeyLen)
+		}
+		if len(value) > maxMetadataValueLen {
+			return fmt.Errorf("Metadata value of %q is longer than %d characters", key, maxMetadataValueLen)
+		}
+	}
+	return nil
+}
+
+// validate проверяет указанные поля и нормализует метки
+func (req *scanDetailsRequest) validate() error {
+	if req.Labels != nil {
+		labels, err := normalizeLabels(*req.Labels)
+		if err != nil {
+			return err
+		}
+		req.Labels = &labels
+	}
+	if req.Notes != nil && len(*req.Notes) > maxScanNotesLength {
+		return fmt.Errorf("Notes are longer than %d characters", maxScanNotesLength)
+	}
+	if req.Metadata != nil {
+		return validateMetadata(*req.Metadata)
+	}
+	return nil
+}
+
+// UpdateScanDetails меняет метки, заметки и метаданные сканирования
+func (s *Server) UpdateScanDetails(c *gin.Context) {
+	scanID := c.Param("id")
+
+	var req scanDetailsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !s.authorizeScan(c, scanID, rbac.PermScanStart) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	scan, err := s.Scans.GetScan(ctx, uuid.MustParse(scanID))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scan not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scan"})
+		return
+	}
+
+	if req.Labels != nil {
+		scan.Labels = *req.Labels
+	}
+	if req.Notes != nil {
+		scan.Notes = *req.Notes
+	}
+	if req.Metadata != nil {
+		scan.Metadata = *req.Metadata
+	}
+
+	err = s.Scans.UpdateScanDetails(ctx, scan.ID, scan.Labels, scan.Notes, scan.Metadata)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scan not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update scan"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionScanUpdate,
+		TargetType: rbac.KindScan,
+		TargetID:   scanID,
+		Details:    map[string]interface{}{"labels": scan.Labels, "metadata": scan.Metadata},
+	})
+
+	c.JSON(http.StatusOK, scan)
+}
+
+// Фильтры меток (label, можно несколько, через запятую) и метаданных (metadata=ключ:значение,
+// можно несколько). При ошибке ответ уже отправлен
+func scanDetailsFilter(c *gin.Context, query *store.ScanQuery) bool {
+	for _, value := range c.QueryArray("label") {
+		for _, label := range strings.Split(value, ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				query.Labels = append(query.Labels, label)
+			}
+		}
+	}
+
+	for _, value := range c.QueryArray("metadata") {
+		key, val, ok := strings.Cut(value, ":")
+		if !ok || !metadataKeyPattern.MatchString(key) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid metadata filter, expected key:value"})
+			return false
+		}
+		if query.Metadata == nil {
+			query.Metadata = map[string]string{}
+		}
+		query.Metadata[key] = val
+	}
+	return true
+}
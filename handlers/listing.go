@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"chimerascan/models"
+	"chimerascan/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// listPage - ответ со страницей списка; next_cursor передается в cursor для следующей страницы
+type listPage struct {
+	Items      interface{} `json:"items"`
+	Total      int         `json:"total"`
+	NextCursor *string     `json:"next_cursor"`
+}
+
+// Курсор в запросе: сортировка, для которой он выдан, значение поля сортировки и ID записи
+type pageCursor struct {
+	Sort  string    `json:"s"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+// Параметры sort (поле, с "-" - по убыванию; по умолчанию -created_at), cursor и limit.
+// При ошибке ответ уже отправлен
+func pageParams(c *gin.Context, fields []string) (sort store.Sort, after *store.Cursor, limit int, ok bool) {
+	sortParam := c.DefaultQuery("sort", "-created_at")
+	sort = store.Sort{Field: strings.TrimPrefix(sortParam, "-"), Desc: strings.HasPrefix(sortParam, "-")}
+	if !slices.Contains(fields, sort.Field) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort, expected one of: " + strings.Join(fields, ", ")})
+		return sort, nil, 0, false
+	}
+
+	if value := c.Query("cursor"); value != "" {
+		var cursor pageCursor
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err == nil {
+			err = json.Unmarshal(data, &cursor)
+		}
+		if err != nil || cursor.Sort != sortParam {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return sort, nil, 0, false
+		}
+		after = &store.Cursor{Value: cursor.Value, ID: cursor.ID}
+	}
+
+	limit = defaultPageLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+			return sort, nil, 0, false
+		}
+		limit = min(n, maxPageLimit)
+	}
+	return sort, after, limit, true
+}
+
+// newListPage строит ответ; key возвращает значение поля сортировки и ID записи для курсора
+func newListPage[T any](c *gin.Context, page store.Page[T], key func(T) (string, uuid.UUID)) listPage {
+	response := listPage{Items: page.Items, Total: page.Total}
+	if page.HasMore && len(page.Items) > 0 {
+		value, id := key(page.Items[len(page.Items)-1])
+		data, _ := json.Marshal(pageCursor{Sort: c.DefaultQuery("sort", "-created_at"), Value: value, ID: id})
+		next := base64.RawURLEncoding.EncodeToString(data)
+		response.NextCursor = &next
+	}
+	return response
+}
+
+// Ответ на ошибку хранилища при чтении списка
+func respondListError(c *gin.Context, err error, message string) {
+	if err == store.ErrInvalidCursor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// Время из параметра запроса в формате RFC 3339 или YYYY-MM-DD
+func parseTimeParam(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, value)
+	}
+	return t, err
+}
+
+// Статусы сканирования
+var scanStatuses = store.ScanStatuses
+
+// Фильтр списка сканирований из параметров status (можно несколько, через запятую),
+// project_id, target, since, until, label и metadata. При ошибке ответ уже отправлен
+func scanQuery(c *gin.Context) (store.ScanQuery, bool) {
+	var query store.ScanQuery
+
+	for _, value := range c.QueryArray("status") {
+		for _, status := range strings.Split(value, ",") {
+			if !slices.Contains(scanStatuses, status) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status, expected one of: " + strings.Join(scanStatuses, ", ")})
+				return query, false
+			}
+			query.Statuses = append(query.Statuses, status)
+		}
+	}
+
+	if value := c.Query("project_id"); value != "" {
+		projectID, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project_id"})
+			return query, false
+		}
+		query.ProjectID = &projectID
+	}
+
+	query.Target = c.Query("target")
+
+	for param, target := range map[string]**time.Time{"since": &query.Since, "until": &query.Until} {
+		if value := c.Query(param); value != "" {
+			t, err := parseTimeParam(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ", expected RFC 3339 timestamp or YYYY-MM-DD date"})
+				return query, false
+			}
+			*target = &t
+		}
+	}
+
+	if !scanDetailsFilter(c, &query) {
+		return query, false
+	}
+
+	var ok bool
+	query.Sort, query.After, query.Limit, ok = pageParams(c, store.ScanSortFields)
+	return query, ok
+}
+
+// Ключ курсора сканирования для сортировки запроса
+func scanCursorKey(sort store.Sort) func(models.Scan) (string, uuid.UUID) {
+	return func(scan models.Scan) (string, uuid.UUID) { return store.ScanSortValue(scan, sort.Field), scan.ID }
+}
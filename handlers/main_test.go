@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"os"
+	"testing"
+
+	"chimerascan/models"
+)
+
+// Сканирования в тестах не запускаются: горутина Nuclei пережила бы тест
+// и обратилась к уже закрытой или восстановленной БД
+func TestMain(m *testing.M) {
+	startNucleiScan = func(*Server, models.Scan) {}
+	os.Exit(m.Run())
+}
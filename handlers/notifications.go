@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/models"
+	"chimerascan/notify"
+	"chimerascan/store"
+	"chimerascan/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PublicBaseURL - внешний адрес приложения для ссылок в уведомлениях
+var PublicBaseURL = "http://localhost:8080"
+
+// Уведомления о завершении сканирования: вебхуки и письмо владельцу
+func (s *Server) notifyScanCompletion(scanID uuid.UUID, results []NucleiResult, reportPaths map[string]string) {
+	if !webhooks.Enabled() && !notify.Enabled() {
+		return
+	}
+
+	newHigh := s.newHighFindings(scanID, results)
+
+	s.emitCompletionWebhooks(scanID, results, reportPaths, newHigh)
+	s.sendScanSummaryEmail(scanID, results, reportPaths, newHigh)
+}
+
+// Письмо с итогами сканирования
+func (s *Server) sendScanSummaryEmail(scanID uuid.UUID, results []NucleiResult, reportPaths map[string]string, newHigh []NucleiResult) {
+	if !notify.Enabled() {
+		return
+	}
+
+	scan, err := s.Scans.GetScan(context.Background(), scanID)
+	if err != nil {
+		log.Printf("Failed to load scan for email notification: %v", err)
+		return
+	}
+
+	summary := notify.ScanSummary{
+		ScanID:        scanID,
+		TargetURL:     scan.TargetURL,
+		FinishedAt:    time.Now(),
+		TotalCount:    len(results),
+		SeverityStats: calculateSeverityStats(results),
+		Reports:       map[string]string{},
+	}
+	if scan.FinishedAt != nil {
+		summary.FinishedAt = *scan.FinishedAt
+	}
+
+	for format := range reportPaths {
+		summary.Reports[format] = reportURL(scanID, format)
+	}
+
+	for _, result := range newHigh {
+		summary.NewHigh = append(summary.NewHigh, notify.Finding{
+			TemplateID: result.TemplateID,
+			Name:       result.Info.Name,
+			Host:       result.Host,
+			MatchedAt:  result.MatchedAt,
+		})
+	}
+
+	notify.ScanCompleted(scan.UserID, summary)
+}
+
+// Абсолютная ссылка на скачивание отчета
+func reportURL(scanID uuid.UUID, format string) string {
+	return strings.TrimRight(PublicBaseURL, "/") + "/api/report/" + scanID.String() + "/" + format
+}
+
+// GetNotificationPreferences возвращает настройки уведомлений пользователя
+func (s *Server) GetNotificationPreferences(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	prefs, err := s.Notifications.NotificationPreferences(c.Request.Context(), userID)
+	if err == store.ErrNotFound {
+		// Пользователь еще не менял настройки
+		prefs = &models.NotificationPreferences{
+			UserID:       userID,
+			EmailEnabled: true,
+			DigestMode:   notify.DigestOff,
+		}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notification preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdateNotificationPreferences сохраняет настройки уведомлений пользователя
+func (s *Server) UpdateNotificationPreferences(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req struct {
+		EmailEnabled bool    `json:"email_enabled"`
+		Email        *string `json:"email" binding:"omitempty,email"`
+		OnlyHigh     bool    `json:"only_high"`
+		DigestMode   string  `json:"digest_mode"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.DigestMode == "" {
+		req.DigestMode = notify.DigestOff
+	}
+	if req.DigestMode != notify.DigestOff && req.DigestMode != notify.DigestDaily {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid digest mode"})
+		return
+	}
+
+	prefs := models.NotificationPreferences{
+		UserID:       userID,
+		EmailEnabled: req.EmailEnabled,
+		Email:        req.Email,
+		OnlyHigh:     req.OnlyHigh,
+		DigestMode:   req.DigestMode,
+		UpdatedAt:    time.Now(),
+	}
+
+	if err := s.Notifications.SaveNotificationPreferences(c.Request.Context(), prefs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification preferences"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionNotificationsEdit,
+		TargetType: "user",
+		TargetID:   userID.String(),
+		Details:    map[string]interface{}{"email_enabled": prefs.EmailEnabled, "email": prefs.Email},
+	})
+
+	c.JSON(http.StatusOK, prefs)
+}
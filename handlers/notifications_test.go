@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"chimerascan/audit"
+	"chimerascan/models"
+	"chimerascan/notify"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationPreferences(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	userID := uuid.New()
+
+	get := func() models.NotificationPreferences {
+		w := call(s.GetNotificationPreferences, userID, "GET", "/api/notifications/preferences", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		var prefs models.NotificationPreferences
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &prefs))
+		return prefs
+	}
+
+	prefs := get()
+	assert.True(t, prefs.EmailEnabled, "Email is on until the user changes it")
+	assert.Equal(t, notify.DigestOff, prefs.DigestMode)
+
+	w := call(s.UpdateNotificationPreferences, userID, "PUT", "/api/notifications/preferences", nil,
+		map[string]interface{}{"email_enabled": true, "digest_mode": "weekly"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = call(s.UpdateNotificationPreferences, userID, "PUT", "/api/notifications/preferences", nil,
+		map[string]interface{}{"email_enabled": false, "only_high": true, "digest_mode": notify.DigestDaily})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	prefs = get()
+	assert.False(t, prefs.EmailEnabled)
+	assert.True(t, prefs.OnlyHigh)
+	assert.Equal(t, notify.DigestDaily, prefs.DigestMode)
+	if assert.Len(t, s.events, 1) {
+		assert.Equal(t, audit.ActionNotificationsEdit, s.events[0].Action)
+	}
+}
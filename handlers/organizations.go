@@ -0,0 +1,376 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/models"
+	"chimerascan/rbac"
+	"chimerascan/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateOrganization создает организацию; создатель становится ее владельцем
+func (s *Server) CreateOrganization(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req struct {
+		Name string `json:"name" binding:"required,max=255"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	org := models.Organization{
+		ID:        uuid.New(),
+		Name:      req.Name,
+		Role:      rbac.RoleOwner,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.Organizations.CreateOrganization(c.Request.Context(), &org, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionOrgCreate,
+		TargetType: rbac.KindOrganization,
+		TargetID:   org.ID.String(),
+		Details:    map[string]interface{}{"name": org.Name},
+	})
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// GetOrganizations возвращает организации пользователя с его ролью
+func (s *Server) GetOrganizations(c *gin.Context) {
+	organizations, err := s.Organizations.ListOrganizations(c.Request.Context(), c.MustGet("userID").(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch organizations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, organizations)
+}
+
+// DeleteOrganization удаляет организацию вместе с ее проектами
+func (s *Server) DeleteOrganization(c *gin.Context) {
+	orgID := c.Param("id")
+
+	if !s.authorizeOrganization(c, orgID, rbac.PermOrgDelete) {
+		return
+	}
+
+	err := s.Organizations.DeleteOrganization(c.Request.Context(), uuid.MustParse(orgID))
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete organization"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{Action: audit.ActionOrgDelete, TargetType: rbac.KindOrganization, TargetID: orgID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Organization deleted successfully"})
+}
+
+// GetOrganizationMembers возвращает участников организации
+func (s *Server) GetOrganizationMembers(c *gin.Context) {
+	orgID := c.Param("id")
+
+	if !s.authorizeOrganization(c, orgID, rbac.PermView) {
+		return
+	}
+
+	members, err := s.Organizations.ListOrganizationMembers(c.Request.Context(), uuid.MustParse(orgID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// Роль владельца может выдавать и отзывать только владелец
+func canAssignRole(callerRole, role string) bool {
+	return role != rbac.RoleOwner || callerRole == rbac.RoleOwner
+}
+
+// Роли пользователя запроса и участника memberID в организации; пустая роль участника - он не состоит в ней
+func (s *Server) memberRoles(c *gin.Context, orgID, memberID uuid.UUID) (callerRole, memberRole string, err error) {
+	ctx := c.Request.Context()
+	callerRole, err = s.Users.OrganizationRole(ctx, orgID, c.MustGet("userID").(uuid.UUID))
+	if err != nil {
+		return "", "", err
+	}
+	memberRole, err = s.Users.OrganizationRole(ctx, orgID, memberID)
+	return callerRole, memberRole, err
+}
+
+// AddOrganizationMember добавляет пользователя в организацию по ID, имени или email
+func (s *Server) AddOrganizationMember(c *gin.Context) {
+	orgID := c.Param("id")
+
+	var req struct {
+		User string `json:"user" binding:"required"`
+		Role string `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !rbac.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + req.Role})
+		return
+	}
+
+	if !s.authorizeOrganization(c, orgID, rbac.PermMembersManage) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	callerRole, err := s.Users.OrganizationRole(ctx, uuid.MustParse(orgID), c.MustGet("userID").(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+	if !canAssignRole(callerRole, req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can grant the owner role"})
+		return
+	}
+
+	user, err := s.Users.FindUser(ctx, req.User)
+	switch err {
+	case nil:
+	case store.ErrAmbiguous:
+		c.JSON(http.StatusConflict, gin.H{"error": "Several users match " + req.User + "; specify the user ID"})
+		return
+	case store.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	member := models.OrganizationMember{
+		OrganizationID: uuid.MustParse(orgID),
+		UserID:         user.ID,
+		Username:       user.Username,
+		Email:          user.Email,
+		Role:           req.Role,
+		CreatedAt:      time.Now(),
+	}
+
+	err = s.Organizations.AddOrganizationMember(ctx, member)
+	if err == store.ErrExists {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is already a member"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionMemberAdd,
+		TargetType: rbac.KindOrganization,
+		TargetID:   orgID,
+		Details:    map[string]interface{}{"user_id": member.UserID, "role": member.Role},
+	})
+
+	c.JSON(http.StatusCreated, member)
+}
+
+// UpdateOrganizationMember меняет роль участника
+func (s *Server) UpdateOrganizationMember(c *gin.Context) {
+	orgID := c.Param("id")
+
+	memberID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !rbac.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown role: " + req.Role})
+		return
+	}
+
+	if !s.authorizeOrganization(c, orgID, rbac.PermMembersManage) {
+		return
+	}
+
+	callerRole, currentRole, err := s.memberRoles(c, uuid.MustParse(orgID), memberID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+	if currentRole == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	if !canAssignRole(callerRole, currentRole) || !canAssignRole(callerRole, req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can change the owner role"})
+		return
+	}
+
+	err = s.Organizations.UpdateOrganizationMember(c.Request.Context(), uuid.MustParse(orgID), memberID, req.Role)
+	switch err {
+	case nil:
+	case store.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	case store.ErrLastOwner:
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization must have at least one owner"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionMemberUpdate,
+		TargetType: rbac.KindOrganization,
+		TargetID:   orgID,
+		Details:    map[string]interface{}{"user_id": memberID, "old_role": currentRole, "role": req.Role},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member updated successfully"})
+}
+
+// RemoveOrganizationMember исключает участника; любой участник может выйти из организации сам
+func (s *Server) RemoveOrganizationMember(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	orgID := c.Param("id")
+
+	memberID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	perm := rbac.PermMembersManage
+	if memberID == userID {
+		perm = rbac.PermView
+	}
+	if !s.authorizeOrganization(c, orgID, perm) {
+		return
+	}
+
+	callerRole, currentRole, err := s.memberRoles(c, uuid.MustParse(orgID), memberID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	if currentRole == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	if memberID != userID && !canAssignRole(callerRole, currentRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only owners can remove owners"})
+		return
+	}
+
+	err = s.Organizations.RemoveOrganizationMember(c.Request.Context(), uuid.MustParse(orgID), memberID)
+	switch err {
+	case nil:
+	case store.ErrNotFound:
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	case store.ErrLastOwner:
+		c.JSON(http.StatusConflict, gin.H{"error": "Organization must have at least one owner"})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionMemberRemove,
+		TargetType: rbac.KindOrganization,
+		TargetID:   orgID,
+		Details:    map[string]interface{}{"user_id": memberID, "role": currentRole},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+// MoveProjectToOrganization переносит проект в организацию или делает его личным (organization_id: null)
+func (s *Server) MoveProjectToOrganization(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	projectID := c.Param("id")
+
+	var req struct {
+		OrganizationID *string `json:"organization_id"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !s.authorizeProject(c, projectID, rbac.PermProjectDelete) {
+		return
+	}
+
+	var organizationID *uuid.UUID
+	if req.OrganizationID != nil && *req.OrganizationID != "" {
+		if !s.authorizeOrganization(c, *req.OrganizationID, rbac.PermProjectManage) {
+			return
+		}
+		orgID := uuid.MustParse(*req.OrganizationID)
+		organizationID = &orgID
+	}
+
+	// Личный проект принадлежит тому, кто вывел его из организации
+	err := s.Projects.MoveProject(c.Request.Context(), uuid.MustParse(projectID), organizationID, userID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move project"})
+		return
+	}
+
+	// Перенос виден в журналах обеих организаций
+	sourceOrgID := rbac.CachedOrganization(c, rbac.KindProject, projectID)
+	details := map[string]interface{}{"from_organization_id": sourceOrgID, "to_organization_id": organizationID}
+	s.Audit(c, audit.Entry{
+		Action:         audit.ActionProjectMove,
+		TargetType:     rbac.KindProject,
+		TargetID:       projectID,
+		OrganizationID: sourceOrgID,
+		Details:        details,
+	})
+	if organizationID != nil && (sourceOrgID == nil || *sourceOrgID != *organizationID) {
+		s.Audit(c, audit.Entry{
+			Action:         audit.ActionProjectMove,
+			TargetType:     rbac.KindProject,
+			TargetID:       projectID,
+			OrganizationID: organizationID,
+			Details:        details,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project moved successfully"})
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/models"
+	"chimerascan/rbac"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newOrganization создает организацию с владельцем ownerID
+func (s *memoryServer) newOrganization(t *testing.T, ownerID uuid.UUID) uuid.UUID {
+	org := models.Organization{ID: uuid.New(), Name: "Acme", CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateOrganization(context.Background(), &org, ownerID))
+	return org.ID
+}
+
+func TestAddOrganizationMember_AmbiguousUser(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+
+	ownerID, aliceID, impostorID := uuid.New(), uuid.New(), uuid.New()
+	s.store.AddUser(models.User{ID: ownerID, Email: "owner@example.com", Username: "owner"})
+	s.store.AddUser(models.User{ID: aliceID, Email: "alice@example.com", Username: "alice"})
+	// Логин совпадает с email другого пользователя
+	s.store.AddUser(models.User{ID: impostorID, Email: "impostor@example.com", Username: "alice@example.com"})
+	orgID := s.newOrganization(t, ownerID)
+
+	add := func(user string) (int, models.OrganizationMember) {
+		w := call(s.AddOrganizationMember, ownerID, "POST", "/api/organizations/"+orgID.String()+"/members", idParam(orgID),
+			map[string]string{"user": user, "role": "analyst"})
+		var member models.OrganizationMember
+		if w.Code == http.StatusCreated {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &member))
+		}
+		return w.Code, member
+	}
+
+	code, _ := add("alice@example.com")
+	assert.Equal(t, http.StatusConflict, code, "Email of one user is the username of another")
+
+	code, _ = add("nobody")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, member := add(aliceID.String())
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, aliceID, member.UserID)
+
+	code, _ = add(aliceID.String())
+	assert.Equal(t, http.StatusConflict, code, "Already a member")
+
+	code, member = add("impostor@example.com")
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, impostorID, member.UserID)
+}
+
+func TestOrganizationMembers_KeepLastOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	ownerID, adminID := uuid.New(), uuid.New()
+	s.store.AddUser(models.User{ID: ownerID, Username: "owner"})
+	s.store.AddUser(models.User{ID: adminID, Username: "admin"})
+	orgID := s.newOrganization(t, ownerID)
+	s.store.AddMember(orgID, adminID, rbac.RoleAdmin)
+
+	member := func(userID uuid.UUID) gin.Params {
+		return gin.Params{{Key: "id", Value: orgID.String()}, {Key: "userId", Value: userID.String()}}
+	}
+	path := "/api/organizations/" + orgID.String() + "/members/" + ownerID.String()
+
+	w := call(s.UpdateOrganizationMember, ownerID, "PUT", path, member(ownerID), map[string]string{"role": rbac.RoleAdmin})
+	assert.Equal(t, http.StatusConflict, w.Code, "The last owner cannot step down")
+
+	w = call(s.RemoveOrganizationMember, ownerID, "DELETE", path, member(ownerID), nil)
+	assert.Equal(t, http.StatusConflict, w.Code, "The last owner cannot leave")
+
+	w = call(s.RemoveOrganizationMember, adminID, "DELETE", path, member(ownerID), nil)
+	assert.Equal(t, http.StatusForbidden, w.Code, "Only owners can remove owners")
+	assert.Empty(t, s.events)
+
+	w = call(s.UpdateOrganizationMember, ownerID, "PUT", path, member(adminID), map[string]string{"role": rbac.RoleOwner})
+	require.Equal(t, http.StatusOK, w.Code)
+	w = call(s.RemoveOrganizationMember, ownerID, "DELETE", path, member(ownerID), nil)
+	require.Equal(t, http.StatusOK, w.Code, "Another owner remains")
+
+	role, err := s.store.OrganizationRole(context.Background(), orgID, ownerID)
+	require.NoError(t, err)
+	assert.Empty(t, role)
+	if assert.Len(t, s.events, 2) {
+		assert.Equal(t, audit.ActionMemberUpdate, s.events[0].Action)
+		assert.Equal(t, audit.ActionMemberRemove, s.events[1].Action)
+	}
+}
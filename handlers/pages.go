@@ -3,35 +3,42 @@ package handlers
 import (
 	"net/http"
 
+	"chimerascan/auth"
+
 	"github.com/gin-gonic/gin"
 )
 
 func DashboardPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "dashboard.html", gin.H{
-		"Title": "Главная панель",
+		"Title":     "Главная панель",
+		"CSRFToken": auth.CSRFToken(c),
 	})
 }
 
 func ScanPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "scan.html", gin.H{
-		"Title": "Запуск сканирования",
+		"Title":     "Запуск сканирования",
+		"CSRFToken": auth.CSRFToken(c),
 	})
 }
 
 func ProjectsPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "projects.html", gin.H{
-		"Title": "Архив проектов",
+		"Title":     "Архив проектов",
+		"CSRFToken": auth.CSRFToken(c),
 	})
 }
 
 func ScansPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "scans.html", gin.H{
-		"Title": "Архив сканирований",
+		"Title":     "Архив сканирований",
+		"CSRFToken": auth.CSRFToken(c),
 	})
 }
 
 func ProjectPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "project.html", gin.H{
-		"Title": "Проект",
+		"Title":     "Проект",
+		"CSRFToken": auth.CSRFToken(c),
 	})
 }
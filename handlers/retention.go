@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/rbac"
+	"chimerascan/retention"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Правила хранения не могут быть отрицательными; nil наследует значение
+func validRetentionPolicy(policy retention.Policy) bool {
+	for _, v := range []*int{policy.KeepScansPerTarget, policy.DeleteAfterDays, policy.SummaryAfterDays} {
+		if v != nil && *v < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// GetProjectRetention возвращает правила хранения проекта и действующие с учетом организации правила
+func (s *Server) GetProjectRetention(c *gin.Context) {
+	projectID := c.Param("id")
+
+	if !s.authorizeProject(c, projectID, rbac.PermView) {
+		return
+	}
+
+	id := uuid.MustParse(projectID)
+	policy, err := retention.GetPolicy(retention.Subject{Type: retention.SubjectProject, ID: id})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch retention policy"})
+		return
+	}
+	rules, err := retention.RulesFor(id, rbac.CachedOrganization(c, rbac.KindProject, projectID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch retention policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policy": policy, "effective": rules})
+}
+
+// UpdateProjectRetention задает правила хранения проекта
+func (s *Server) UpdateProjectRetention(c *gin.Context) {
+	projectID := c.Param("id")
+
+	var policy retention.Policy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validRetentionPolicy(policy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Retention values must not be negative"})
+		return
+	}
+
+	if !s.authorizeProject(c, projectID, rbac.PermProjectManage) {
+		return
+	}
+
+	subject := retention.Subject{Type: retention.SubjectProject, ID: uuid.MustParse(projectID)}
+	if err := retention.SetPolicy(subject, policy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update retention policy"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionRetentionUpdate,
+		TargetType: rbac.KindProject,
+		TargetID:   projectID,
+		Details:    map[string]interface{}{"policy": policy},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Retention policy updated successfully"})
+}
+
+// GetOrganizationRetention возвращает правила хранения организации
+func (s *Server) GetOrganizationRetention(c *gin.Context) {
+	orgID := c.Param("id")
+
+	if !s.authorizeOrganization(c, orgID, rbac.PermView) {
+		return
+	}
+
+	policy, err := retention.GetPolicy(retention.Subject{Type: retention.SubjectOrganization, ID: uuid.MustParse(orgID)})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch retention policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policy": policy, "effective": retention.Defaults.Override(policy)})
+}
+
+// UpdateOrganizationRetention задает правила хранения для проектов организации
+func (s *Server) UpdateOrganizationRetention(c *gin.Context) {
+	orgID := c.Param("id")
+
+	var policy retention.Policy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !validRetentionPolicy(policy) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Retention values must not be negative"})
+		return
+	}
+
+	if !s.authorizeOrganization(c, orgID, rbac.PermProjectManage) {
+		return
+	}
+
+	id := uuid.MustParse(orgID)
+	if err := retention.SetPolicy(retention.Subject{Type: retention.SubjectOrganization, ID: id}, policy); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update retention policy"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:         audit.ActionRetentionUpdate,
+		TargetType:     rbac.KindOrganization,
+		TargetID:       orgID,
+		OrganizationID: &id,
+		Details:        map[string]interface{}{"policy": policy},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Retention policy updated successfully"})
+}
+
+// AdminRunRetention применяет правила хранения, не дожидаясь очередного запуска очистки
+func (s *Server) AdminRunRetention(c *gin.Context) {
+	result, err := retention.Run(c.Request.Context(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply retention policies"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionRetentionRun,
+		TargetType: "retention",
+		Details: map[string]interface{}{
+			"scans_deleted":    result.ScansDeleted,
+			"scans_summarized": result.ScansSummarized,
+			"files":            result.FilesDeleted,
+		},
+	})
+
+	c.JSON(http.StatusOK, result)
+}
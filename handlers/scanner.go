@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"html/template"
@@ -9,11 +11,18 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"chimerascan/database"
+	"chimerascan/audit"
+	"chimerascan/config"
+	"chimerascan/models"
+	"chimerascan/rbac"
+	"chimerascan/storage"
+	"chimerascan/store"
+	"chimerascan/webhooks"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -54,10 +63,34 @@ type ScanReport struct {
 	SeverityStats map[string]int `json:"severity_stats"`
 }
 
+// ScannerSettings - образ Nuclei, его ограничения и модель для описаний находок
+var ScannerSettings = config.Default().Scanner
+
 var (
-	activeScans = make(map[uuid.UUID]*exec.Cmd)
+	activeScans   = make(map[uuid.UUID]*exec.Cmd)
+	activeScansMu sync.Mutex
 )
 
+// Остановка процесса сканирования, если он запущен на этом сервере
+func killScanProcess(scanID uuid.UUID) {
+	activeScansMu.Lock()
+	defer activeScansMu.Unlock()
+
+	if cmd, exists := activeScans[scanID]; exists && cmd.Process != nil {
+		if err := cmd.Process.Kill(); err != nil {
+			log.Printf("Failed to kill scan process: %v", err)
+		}
+		delete(activeScans, scanID)
+	}
+}
+
+// Число процессов сканирования, запущенных на этом сервере
+func activeScanCount() int {
+	activeScansMu.Lock()
+	defer activeScansMu.Unlock()
+	return len(activeScans)
+}
+
 // Валидация ссылки
 func isValidURL(urlStr string) bool {
 	if len(urlStr) > 2000 || len(urlStr) == 0 {
@@ -101,45 +134,98 @@ func isValidURL(urlStr string) bool {
 	return true
 }
 
-// Запуск сканирования
-func runNucleiScan(scanID uuid.UUID, targetURL string) {
-	if !isValidURL(targetURL) {
-		log.Printf("Invalid target URL format: %s", targetURL)
-		updateScanStatus(scanID, "Failed")
-		return
+// Параметры запуска Nuclei из текущих настроек сканера
+func defaultScanConfig() models.ScanConfig {
+	return models.ScanConfig{
+		Image:     ScannerSettings.Image,
+		RateLimit: ScannerSettings.RateLimit,
+		Timeout:   ScannerSettings.Timeout,
 	}
+}
 
-	log.Printf("Starting Nuclei scan for %s (ID: %s)", targetURL, scanID)
-
-	updateScanStatus(scanID, "In Progress")
+// Аргументы docker run для Nuclei; адреса из config.Targets заменяют targetURL
+func nucleiArgs(targetURL string, config models.ScanConfig) []string {
+	targets := config.Targets
+	if len(targets) == 0 {
+		targets = []string{targetURL}
+	}
 
-	cmd := exec.Command("docker", "run", "--rm",
-		"projectdiscovery/nuclei:latest",
-		"-u", targetURL,
+	args := []string{"run", "--rm", config.Image}
+	for _, target := range targets {
+		args = append(args, "-u", target)
+	}
+	if len(config.Templates) > 0 {
+		args = append(args, "-id", strings.Join(config.Templates, ","))
+	}
+	return append(args,
 		"-j",
 		"-silent",
 		"-no-interactsh",
-		"-rate-limit", "50",
-		"-timeout", "90")
+		"-rate-limit", strconv.Itoa(config.RateLimit),
+		"-timeout", strconv.Itoa(config.Timeout))
+}
 
+// startNucleiScan запускает сканирование в фоне. Тесты подменяют его, чтобы не запускать
+// Nuclei и не оставлять горутины, которые переживут подмененную в тесте БД
+var startNucleiScan = func(s *Server, scan models.Scan) {
+	go s.runNucleiScan(scan)
+}
+
+// Запуск сканирования с сохраненными параметрами; без них - с текущими настройками сканера
+func (s *Server) runNucleiScan(scan models.Scan) {
+	scanID, targetURL := scan.ID, scan.TargetURL
+	config := defaultScanConfig()
+	if scan.Config != nil {
+		config = *scan.Config
+	}
+
+	for _, target := range append([]string{targetURL}, config.Targets...) {
+		if !isValidURL(target) {
+			log.Printf("Invalid target URL format: %s", target)
+			s.updateScanStatus(scanID, "Failed")
+			return
+		}
+	}
+
+	if !s.startScan(scanID) {
+		return
+	}
+
+	log.Printf("Starting Nuclei scan for %s (ID: %s)", targetURL, scanID)
+
+	cmd := exec.Command("docker", nucleiArgs(targetURL, config)...)
+
+	activeScansMu.Lock()
 	activeScans[scanID] = cmd
+	activeScansMu.Unlock()
 
 	output, err := cmd.Output()
 
+	activeScansMu.Lock()
 	delete(activeScans, scanID)
+	activeScansMu.Unlock()
 
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			log.Printf("Nuclei exited with code: %d", exitErr.ExitCode())
 		} else {
 			log.Printf("Nuclei scan error: %v", err)
-			updateScanStatus(scanID, "Failed")
+			s.updateScanStatus(scanID, "Failed")
 			return
 		}
 	}
 
+	// Сканирование, отмененное во время работы Nuclei, не сохраняется
+	if !s.scanInProgress(scanID) {
+		log.Printf("Scan %s is no longer in progress, results discarded", scanID)
+		return
+	}
+
 	results := parseNucleiOutput(output)
 
+	// Секреты и персональные данные скрываются до AI-анализа, сохранения и отчетов
+	redactResults(results)
+
 	if len(results) > 0 {
 		log.Println("AI анализ уязвимостей...")
 		for i := range results {
@@ -155,13 +241,13 @@ func runNucleiScan(scanID uuid.UUID, targetURL string) {
 		}
 	}
 
-	rawOutput, _ := json.Marshal(results)
+	s.saveVulnerabilities(scanID, results)
 
-	saveVulnerabilities(scanID, results)
+	rawOutput, _ := json.Marshal(results)
 
-	reportPaths := generateReports(scanID, targetURL, results)
+	reportPaths, reportSize := generateReports(scanID, targetURL, results)
 
-	updateScanCompletion(scanID, rawOutput, reportPaths)
+	s.updateScanCompletion(scanID, results, rawOutput, reportPaths, reportSize)
 
 	log.Printf("Nuclei scan completed for %s. Found %d vulnerabilities", targetURL, len(results))
 }
@@ -259,7 +345,7 @@ func getRecommendationFromAI(vuln NucleiResult) string {
 
 // Вызов ollama
 func callOllama(prompt string) string {
-	cmd := exec.Command("ollama", "run", "phi:2.7b", prompt)
+	cmd := exec.Command("ollama", "run", ScannerSettings.AIModel, prompt)
 	output, err := cmd.Output()
 	if err != nil {
 		return fmt.Sprintf("Ошибка AI: %v", err)
@@ -267,24 +353,21 @@ func callOllama(prompt string) string {
 	return string(output)
 }
 
-// Сохранение уязвимостей в БД
-func saveVulnerabilities(scanID uuid.UUID, results []NucleiResult) {
-	for _, result := range results {
+// Сохранение уязвимостей в БД.
+// Слишком большие ответы переносятся в хранилище и обрезаются в results.
+func (s *Server) saveVulnerabilities(scanID uuid.UUID, results []NucleiResult) {
+	for i := range results {
+		result := &results[i]
 		vulnID := uuid.New()
 
+		responseSize := len(result.Response)
+		evidenceKey := offloadEvidence(scanID, vulnID, result)
+
 		referenceJSON, _ := json.Marshal(result.Info.Reference)
 		tagsJSON, _ := json.Marshal(result.Info.Tags)
 		classificationJSON, _ := json.Marshal(result.Info.Classification)
 		metadataJSON, _ := json.Marshal(result.Metadata)
 
-		query := `
-			INSERT INTO vulnerabilities (
-				id, scan_id, template_id, name, severity, severity_ai, description, 
-				description_ru, reference, tags, classification, host, matched_at, ip,
-				timestamp, curl_command, request, response, metadata, recommendation_ai
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20)
-		`
-
 		var timestamp *time.Time
 		if result.Timestamp != "" {
 			if ts, err := time.Parse(time.RFC3339, result.Timestamp); err == nil {
@@ -292,28 +375,53 @@ func saveVulnerabilities(scanID uuid.UUID, results []NucleiResult) {
 			}
 		}
 
-		_, err := database.DB.Exec(query,
-			vulnID, scanID, result.TemplateID, result.Info.Name,
-			strings.ToLower(result.Info.Severity), result.SeverityAI, result.Info.Description,
-			result.DescriptionRU, string(referenceJSON), string(tagsJSON), string(classificationJSON),
-			result.Host, result.MatchedAt, result.IP, timestamp,
-			result.CurlCommand, result.Request, result.Response, string(metadataJSON), result.RecommendationAI,
-		)
-
+		err := s.Vulnerabilities.CreateVulnerability(context.Background(), &models.Vulnerability{
+			ID:               vulnID,
+			ScanID:           scanID,
+			TemplateID:       result.TemplateID,
+			Name:             result.Info.Name,
+			Severity:         strings.ToLower(result.Info.Severity),
+			SeverityAI:       result.SeverityAI,
+			Description:      result.Info.Description,
+			DescriptionRu:    result.DescriptionRU,
+			Reference:        referenceJSON,
+			Tags:             tagsJSON,
+			Classification:   classificationJSON,
+			Host:             result.Host,
+			MatchedAt:        result.MatchedAt,
+			IP:               result.IP,
+			Timestamp:        timestamp,
+			CurlCommand:      result.CurlCommand,
+			Request:          result.Request,
+			Response:         result.Response,
+			ResponseSize:     responseSize,
+			EvidenceKey:      evidenceKey,
+			Metadata:         metadataJSON,
+			RecommendationAI: result.RecommendationAI,
+		})
 		if err != nil {
 			log.Printf("Failed to save vulnerability: %v", err)
 		}
 	}
 }
 
-// Генерация отчетов
-func generateReports(scanID uuid.UUID, targetURL string, results []NucleiResult) map[string]string {
-	timestamp := time.Now().Unix()
-	reportsDir := "reports"
+// Форматы отчетов и их MIME-типы
+var reportFormats = []struct {
+	Format      string
+	ContentType string
+	Render      func(ScanReport) ([]byte, error)
+}{
+	{"json", "application/json", renderJSONReport},
+	{"pdf", "application/pdf", renderPDFReport},
+	{"html", "text/html; charset=utf-8", renderHTMLReport},
+}
 
-	os.MkdirAll(reportsDir, 0755)
+// Генерация отчетов; возвращает ключи отчетов по форматам и их суммарный размер
+func generateReports(scanID uuid.UUID, targetURL string, results []NucleiResult) (map[string]string, int64) {
+	timestamp := time.Now().Unix()
 
 	reportPaths := map[string]string{}
+	var totalSize int64
 
 	stats := calculateSeverityStats(results)
 
@@ -325,57 +433,69 @@ func generateReports(scanID uuid.UUID, targetURL string, results []NucleiResult)
 		SeverityStats: stats,
 	}
 
-	jsonPath := filepath.Join(reportsDir, fmt.Sprintf("chimerascan_report_%s_%d.json", scanID.String(), timestamp))
-	saveJSONReport(report, jsonPath)
-	reportPaths["json"] = jsonPath
+	for _, rf := range reportFormats {
+		key := fmt.Sprintf("chimerascan_report_%s_%d.%s", scanID.String(), timestamp, rf.Format)
 
-	pdfPath := filepath.Join(reportsDir, fmt.Sprintf("chimerascan_report_%s_%d.pdf", scanID.String(), timestamp))
-	savePDFReport(report, pdfPath)
-	reportPaths["pdf"] = pdfPath
+		data, err := rf.Render(report)
+		if err != nil {
+			log.Printf("Failed to render %s report: %v", rf.Format, err)
+			continue
+		}
 
-	htmlPath := filepath.Join(reportsDir, fmt.Sprintf("chimerascan_report_%s_%d.html", scanID.String(), timestamp))
-	saveHTMLReport(report, htmlPath)
-	reportPaths["html"] = htmlPath
+		if err := storage.Reports.Put(context.Background(), key, data, rf.ContentType); err != nil {
+			log.Printf("Failed to store %s report: %v", rf.Format, err)
+			continue
+		}
 
-	return reportPaths
+		log.Printf("%s report saved: %s", strings.ToUpper(rf.Format), key)
+		reportPaths[rf.Format] = key
+		totalSize += int64(len(data))
+	}
+
+	return reportPaths, totalSize
 }
 
 // Подсчет уязвимостей по уровням риска для отчетов
 func calculateSeverityStats(results []NucleiResult) map[string]int {
 	stats := map[string]int{
-		"info":   0,
-		"low":    0,
-		"medium": 0,
-		"high":   0,
+		"info":     0,
+		"low":      0,
+		"medium":   0,
+		"high":     0,
+		"critical": 0,
 	}
 
 	for _, result := range results {
-		stats[result.SeverityAI]++
+		stats[severityLevel(result)]++
 	}
 	return stats
 }
 
-// Сохранения отчета JSON
-func saveJSONReport(report ScanReport, filename string) {
-	file, err := os.Create(filename)
-	if err != nil {
-		log.Printf("Ошибка создания JSON файла: %v", err)
-		return
+// Уровень риска находки для статистики: critical, если так ее оценил шаблон Nuclei или AI
+// (как в isHighSeverity), иначе оценка AI
+func severityLevel(result NucleiResult) string {
+	for _, severity := range []string{result.Info.Severity, result.SeverityAI} {
+		if strings.EqualFold(strings.TrimSpace(severity), "critical") {
+			return "critical"
+		}
 	}
-	defer file.Close()
+	return strings.ToLower(strings.TrimSpace(result.SeverityAI))
+}
 
-	encoder := json.NewEncoder(file)
+// Формирование отчета JSON
+func renderJSONReport(report ScanReport) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
 	encoder.SetIndent("", "  ")
 
 	if err := encoder.Encode(report); err != nil {
-		log.Printf("Ошибка кодирования JSON: %v", err)
-		return
+		return nil, err
 	}
-	log.Printf("JSON отчет сохранен: %s", filename)
+	return buf.Bytes(), nil
 }
 
-// Сохранения отчета PDF
-func savePDFReport(report ScanReport, filename string) {
+// Формирование отчета PDF
+func renderPDFReport(report ScanReport) ([]byte, error) {
 	pdf := gofpdf.New("P", "mm", "A4", "")
 	pdf.AddPage()
 
@@ -450,6 +570,9 @@ func savePDFReport(report ScanReport, filename string) {
 	pdf.Ln(6)
 	pdf.SetX(mainTextX)
 	pdf.Cell(40, 8, fmt.Sprintf("High:    %d", report.SeverityStats["high"]))
+	pdf.Ln(6)
+	pdf.SetX(mainTextX)
+	pdf.Cell(40, 8, fmt.Sprintf("Critical: %d", report.SeverityStats["critical"]))
 	pdf.Ln(15)
 
 	if report.TotalCount > 0 {
@@ -613,16 +736,15 @@ func savePDFReport(report ScanReport, filename string) {
 	pdf.SetFont("Arial", "I", 8)
 	pdf.CellFormat(0, 10, fmt.Sprintf("Generated by ChimeraScan on %s", time.Now().Format("2006-01-02 15:04:05")), "", 0, "C", false, 0, "")
 
-	err := pdf.OutputFileAndClose(filename)
-	if err != nil {
-		log.Printf("Error creating PDF: %v", err)
-		return
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
 	}
-	log.Printf("PDF report saved: %s", filename)
+	return buf.Bytes(), nil
 }
 
-// Сохранения отчета HTML
-func saveHTMLReport(report ScanReport, filename string) {
+// Формирование отчета HTML
+func renderHTMLReport(report ScanReport) ([]byte, error) {
 	const htmlTemplate = `<!DOCTYPE html>
 <html>
 <head>
@@ -968,6 +1090,10 @@ func saveHTMLReport(report ScanReport, filename string) {
                     <strong>High</strong>
                     <div style="font-size: 1.5rem; color: var(--color-error); margin-top: 5px;">{{.SeverityStats.high}}</div>
                 </div>
+                <div class="stat-item">
+                    <strong>Critical</strong>
+                    <div style="font-size: 1.5rem; color: var(--color-error); margin-top: 5px;">{{.SeverityStats.critical}}</div>
+                </div>
             </div>
         </div>  
 
@@ -1059,68 +1185,85 @@ func saveHTMLReport(report ScanReport, filename string) {
 
 	tmpl, err := template.New("report").Funcs(funcMap).Parse(htmlTemplate)
 	if err != nil {
-		log.Printf("Ошибка парсинга HTML шаблона: %v", err)
-		return
+		return nil, err
 	}
 
-	file, err := os.Create(filename)
-	if err != nil {
-		log.Printf("Ошибка создания HTML файла: %v", err)
-		return
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, report); err != nil {
+		return nil, err
 	}
-	defer file.Close()
+	return buf.Bytes(), nil
+}
 
-	if err := tmpl.Execute(file, report); err != nil {
-		log.Printf("Ошибка выполнения HTML шаблона: %v", err)
-		return
+// События подписчиков для статусов сканирования
+var scanStatusEvents = map[string]string{
+	"Failed": webhooks.EventScanFailed,
+}
+
+// Перевод сканирования из очереди в работу; false, если его уже отменили
+func (s *Server) startScan(scanID uuid.UUID) bool {
+	err := s.Scans.StartScan(context.Background(), scanID, time.Now())
+	if err == store.ErrNotFound {
+		log.Printf("Scan %s is no longer queued, not starting", scanID)
+		return false
+	} else if err != nil {
+		log.Printf("Failed to start scan: %v", err)
+		return false
 	}
-	log.Printf("HTML отчет сохранен: %s", filename)
+
+	s.notifyScanEvent(scanID, webhooks.EventScanStarted, map[string]interface{}{"status": "In Progress"})
+	return true
+}
+
+// Проверка, что сканирование не отменили, пока работал Nuclei
+func (s *Server) scanInProgress(scanID uuid.UUID) bool {
+	scan, err := s.Scans.GetScan(context.Background(), scanID)
+	if err != nil {
+		log.Printf("Failed to get scan status: %v", err)
+		return false
+	}
+	return scan.Status == "In Progress"
 }
 
 // Обновление статуса
-func updateScanStatus(scanID uuid.UUID, status string) {
-	var query string
-	if status == "In Progress" {
-		now := time.Now()
-		query = `UPDATE scans SET status = $1, started_at = $2 WHERE id = $3`
-		_, err := database.DB.Exec(query, status, now, scanID)
-		if err != nil {
-			log.Printf("Failed to update scan status: %v", err)
-		}
-	} else {
-		query = `UPDATE scans SET status = $1 WHERE id = $2`
-		_, err := database.DB.Exec(query, status, scanID)
-		if err != nil {
-			log.Printf("Failed to update scan status: %v", err)
-		}
+func (s *Server) updateScanStatus(scanID uuid.UUID, status string) {
+	if err := s.Scans.UpdateScanStatus(context.Background(), scanID, status, nil); err != nil {
+		log.Printf("Failed to update scan status: %v", err)
+		return
+	}
+
+	if event, ok := scanStatusEvents[status]; ok {
+		s.notifyScanEvent(scanID, event, map[string]interface{}{"status": status})
 	}
 }
 
 // Обновление записи сканирования после завершения
-func updateScanCompletion(scanID uuid.UUID, rawOutput []byte, reportPaths map[string]string) {
-	now := time.Now()
-
-	query := `
-		UPDATE scans 
-		SET status = $1, finished_at = $2, raw_nuclei_output = $3,
-		    report_json_path = $4, report_pdf_path = $5, report_html_path = $6
-		WHERE id = $7
-	`
-
-	_, err := database.DB.Exec(query,
-		"Completed", now, string(rawOutput),
-		reportPaths["json"], reportPaths["pdf"], reportPaths["html"],
-		scanID,
-	)
-
-	if err != nil {
+func (s *Server) updateScanCompletion(scanID uuid.UUID, results []NucleiResult, rawOutput []byte, reportPaths map[string]string, reportSize int64) {
+	err := s.Scans.CompleteScan(context.Background(), scanID, store.ScanCompletion{
+		FinishedAt:      time.Now(),
+		RawNucleiOutput: string(rawOutput),
+		ReportPaths:     reportPaths,
+		ReportSize:      reportSize,
+	})
+	if err == store.ErrNotFound {
+		// Сканирование отменили после сохранения результатов: отчеты ему уже не нужны
+		log.Printf("Scan %s is no longer in progress, reports discarded", scanID)
+		var paths []string
+		for _, path := range reportPaths {
+			paths = append(paths, path)
+		}
+		removeReportFiles(context.Background(), paths)
+		return
+	} else if err != nil {
 		log.Printf("Failed to update scan completion: %v", err)
+		return
 	}
+
+	s.notifyScanCompletion(scanID, results, reportPaths)
 }
 
 // Функция остановки сканирования
-func StopScan(c *gin.Context) {
-	userID := c.MustGet("userID").(uuid.UUID)
+func (s *Server) StopScan(c *gin.Context) {
 	scanIDStr := c.Param("id")
 
 	scanID, err := uuid.Parse(scanIDStr)
@@ -1129,80 +1272,133 @@ func StopScan(c *gin.Context) {
 		return
 	}
 
-	var scanUserID uuid.UUID
-	err = database.DB.QueryRow("SELECT user_id FROM scans WHERE id = $1", scanID).Scan(&scanUserID)
-	if err != nil || scanUserID != userID {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Scan not found"})
+	if !s.authorizeScan(c, scanIDStr, rbac.PermScanStop) {
 		return
 	}
 
-	if cmd, exists := activeScans[scanID]; exists {
-		if err := cmd.Process.Kill(); err != nil {
-			log.Printf("Failed to kill scan process: %v", err)
-		}
-		delete(activeScans, scanID)
+	canceled, err := s.cancelScan(c.Request.Context(), scanID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stop scan"})
+		return
+	}
+	if !canceled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Scan is not running"})
+		return
 	}
 
-	updateScanStatus(scanID, "Canceled")
+	s.Audit(c, audit.Entry{Action: audit.ActionScanStop, TargetType: rbac.KindScan, TargetID: scanIDStr})
 
 	c.JSON(http.StatusOK, gin.H{"message": "Scan stopped successfully"})
 }
 
+// cancelScan отменяет сканирование в очереди или выполняющееся и останавливает его процесс.
+// Завершенное сканирование не меняется, тогда возвращается false
+func (s *Server) cancelScan(ctx context.Context, scanID uuid.UUID) (bool, error) {
+	canceled, err := s.Scans.CancelScans(ctx, []uuid.UUID{scanID})
+	if err != nil || len(canceled) == 0 {
+		return false, err
+	}
+
+	killScanProcess(scanID)
+	s.notifyScanEvent(scanID, webhooks.EventScanCanceled, map[string]interface{}{"status": "Canceled"})
+	return true, nil
+}
+
 // Получение статуса
-func GetScanStatus(c *gin.Context) {
-	userID := c.MustGet("userID").(uuid.UUID)
+func (s *Server) GetScanStatus(c *gin.Context) {
 	scanID := c.Param("id")
 
-	var scan struct {
-		Status    string     `json:"status"`
-		StartedAt *time.Time `json:"started_at"`
+	if !s.authorizeScan(c, scanID, rbac.PermView) {
+		return
 	}
 
-	err := database.DB.QueryRow(`
-		SELECT status, started_at 
-		FROM scans 
-		WHERE id = $1 AND user_id = $2
-	`, scanID, userID).Scan(&scan.Status, &scan.StartedAt)
-
+	scan, err := s.Scans.GetScan(c.Request.Context(), uuid.MustParse(scanID))
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Scan not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, scan)
+	c.JSON(http.StatusOK, gin.H{
+		"status":     scan.Status,
+		"started_at": scan.StartedAt,
+	})
 }
 
-// Сохранение отчета
-func DownloadReport(c *gin.Context) {
-	userID := c.MustGet("userID").(uuid.UUID)
+// Скачивание отчета
+func (s *Server) DownloadReport(c *gin.Context) {
 	scanID := c.Param("id")
 	format := c.Param("format")
 
-	var filePath string
-	var query string
-
-	switch format {
-	case "json":
-		query = "SELECT report_json_path FROM scans WHERE id = $1 AND user_id = $2"
-	case "pdf":
-		query = "SELECT report_pdf_path FROM scans WHERE id = $1 AND user_id = $2"
-	case "html":
-		query = "SELECT report_html_path FROM scans WHERE id = $1 AND user_id = $2"
-	default:
+	if format != "json" && format != "pdf" && format != "html" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format"})
 		return
 	}
 
-	err := database.DB.QueryRow(query, scanID, userID).Scan(&filePath)
-	if err != nil || filePath == "" {
+	if !s.authorizeScan(c, scanID, rbac.PermView) {
+		return
+	}
+
+	scan, err := s.Scans.GetScan(c.Request.Context(), uuid.MustParse(scanID))
+	if err == store.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
 		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch scan"})
+		return
 	}
 
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	filePath := map[string]string{
+		"json": scan.ReportJSONPath,
+		"pdf":  scan.ReportPDFPath,
+		"html": scan.ReportHTMLPath,
+	}[format]
+	if filePath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	key := storage.ReportKey(filePath)
+
+	s.Audit(c, audit.Entry{
+		Action:     audit.ActionReportDownload,
+		TargetType: rbac.KindScan,
+		TargetID:   scanID,
+		Details:    map[string]interface{}{"format": format},
+	})
+
+	if signer, ok := storage.Reports.(storage.URLSigner); ok {
+		signedURL, err := signer.SignedURL(key, reportURLTTL)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign report URL"})
+			return
+		}
+		c.Redirect(http.StatusFound, signedURL)
+		return
+	}
+
+	reader, err := storage.Reports.Get(c.Request.Context(), key)
+	if err == storage.ErrNotFound {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Report file not found"})
 		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read report"})
+		return
 	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, -1, reportContentType(format), reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`inline; filename="%s"`, key),
+	})
+}
+
+// Время жизни подписанной ссылки на отчет
+const reportURLTTL = 5 * time.Minute
 
-	c.File(filePath)
+func reportContentType(format string) string {
+	for _, rf := range reportFormats {
+		if rf.Format == format {
+			return rf.ContentType
+		}
+	}
+	return "application/octet-stream"
 }
@@ -1,10 +1,22 @@
 package handlers
 
 import (
+	"context"
+	"net/http"
 	"strings"
 	"testing"
+	"time"
 
+	"chimerascan/audit"
+	"chimerascan/database"
+	"chimerascan/models"
+	"chimerascan/storage"
+	"chimerascan/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestIsValidURL_ValidCases(t *testing.T) {
@@ -111,11 +123,22 @@ func TestCalculateSeverityStats(t *testing.T) {
 	assert.Equal(t, 7, stats["high"]+stats["medium"]+stats["low"]+stats["info"], "Total should be 7")
 }
 
+func TestCalculateSeverityStatsCritical(t *testing.T) {
+	critical := NucleiResult{SeverityAI: "high"}
+	critical.Info.Severity = "critical"
+	results := []NucleiResult{critical, {SeverityAI: "Critical"}, {SeverityAI: "high"}}
+
+	stats := calculateSeverityStats(results)
+
+	assert.Equal(t, 2, stats["critical"], "Critical by the template or AI")
+	assert.Equal(t, 1, stats["high"])
+}
+
 func TestCalculateSeverityStatsEmpty(t *testing.T) {
 	results := []NucleiResult{}
 	stats := calculateSeverityStats(results)
 
-	expected := map[string]int{"info": 0, "low": 0, "medium": 0, "high": 0}
+	expected := map[string]int{"info": 0, "low": 0, "medium": 0, "high": 0, "critical": 0}
 	assert.Equal(t, expected, stats, "Should return zero stats for empty input")
 }
 
@@ -150,3 +173,109 @@ func TestCalculateSeverityStatsMixed(t *testing.T) {
 	assert.Equal(t, 1, stats["medium"], "Should have 1 medium")
 	assert.Equal(t, 1, stats["high"], "Should have 1 high")
 }
+
+func TestIsHighSeverity(t *testing.T) {
+	finding := func(nuclei, ai string) NucleiResult {
+		var result NucleiResult
+		result.Info.Severity, result.SeverityAI = nuclei, ai
+		return result
+	}
+
+	assert.True(t, isHighSeverity(finding("info", "high")))
+	assert.True(t, isHighSeverity(finding("critical", "")), "AI is unavailable")
+	assert.True(t, isHighSeverity(finding("High", "medium")), "AI disagrees with the template")
+	assert.True(t, isHighSeverity(finding("low", "Critical")))
+	assert.False(t, isHighSeverity(finding("medium", "low")))
+}
+
+func TestIsNewFinding_ScopedToProject(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	ctx := context.Background()
+	db := store.NewPostgres(database.DB)
+	s := NewServer(db)
+	alice, bob, carol := uuid.New(), uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{alice, bob, carol} {
+		_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, id, id.String(), id.String()+"@example.com", id.String())
+		require.NoError(t, err)
+	}
+	project := models.Project{ID: uuid.New(), Name: "Shared", UserID: alice, CreatedAt: time.Now()}
+	require.NoError(t, db.CreateProject(ctx, &project))
+
+	scan := func(userID uuid.UUID, projectID *uuid.UUID) uuid.UUID {
+		scan := models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: "Completed", ProjectID: projectID, UserID: userID, CreatedAt: time.Now()}
+		require.NoError(t, db.CreateScan(ctx, &scan))
+		return scan.ID
+	}
+
+	var finding NucleiResult
+	finding.TemplateID, finding.MatchedAt = "sqli-error", "https://example.com/?id=1"
+	first := scan(alice, &project.ID)
+	require.NoError(t, db.CreateVulnerability(ctx, &models.Vulnerability{ID: uuid.New(), ScanID: first, TemplateID: finding.TemplateID, MatchedAt: finding.MatchedAt, Severity: "high"}))
+
+	assert.False(t, s.isNewFinding(scan(bob, &project.ID), finding), "Another member already found it in the project")
+	assert.True(t, s.isNewFinding(scan(carol, nil), finding), "Personal scans only see their owner's history")
+	assert.True(t, s.isNewFinding(scan(alice, nil), finding), "Project history does not leak into personal scans")
+
+	database.DB.Close()
+	assert.False(t, s.isNewFinding(first, finding), "A failed lookup is not reported as new")
+}
+
+func TestDownloadReport_FromLocalStore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reports, err := storage.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+	oldStore := storage.Reports
+	storage.Reports = reports
+	defer func() { storage.Reports = oldStore }()
+
+	s := newMemoryServer()
+	userID := uuid.New()
+	scan := s.addScan(t, userID, nil, time.Now())
+	key := "chimerascan_report_" + scan.ID.String() + "_1.json"
+	reports.Put(context.Background(), key, []byte(`{"total_count":0}`), "application/json")
+
+	// Старые записи хранят путь с префиксом каталога reports
+	s.completeScan(t, scan.ID, map[string]string{"json": "reports/" + key})
+
+	params := gin.Params{{Key: "id", Value: scan.ID.String()}, {Key: "format", Value: "json"}}
+	w := call(s.DownloadReport, userID, "GET", "/api/report/"+scan.ID.String()+"/json", params, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"total_count":0}`, w.Body.String())
+	if assert.Len(t, s.events, 1) {
+		assert.Equal(t, audit.ActionReportDownload, s.events[0].Action)
+	}
+
+	params[1].Value = "pdf"
+	w = call(s.DownloadReport, userID, "GET", "/api/report/"+scan.ID.String()+"/pdf", params, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code, "Scan has no PDF report")
+}
+
+func TestDownloadReport_OtherUsersScan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	scan := s.addScan(t, uuid.New(), nil, time.Now())
+
+	params := gin.Params{{Key: "id", Value: scan.ID.String()}, {Key: "format", Value: "pdf"}}
+	w := call(s.DownloadReport, uuid.New(), "GET", "/api/report/"+scan.ID.String()+"/pdf", params, nil)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, s.events)
+}
+
+func TestNucleiArgs(t *testing.T) {
+	config := models.ScanConfig{Image: "nuclei", RateLimit: 10, Timeout: 30}
+	assert.Equal(t, []string{"run", "--rm", "nuclei", "-u", "https://example.com",
+		"-j", "-silent", "-no-interactsh", "-rate-limit", "10", "-timeout", "30"}, nucleiArgs("https://example.com", config))
+
+	config.Templates = []string{"xss-reflected", "sqli-error"}
+	config.Targets = []string{"https://example.com/a", "https://example.com/b"}
+	assert.Equal(t, []string{"run", "--rm", "nuclei", "-u", "https://example.com/a", "-u", "https://example.com/b",
+		"-id", "xss-reflected,sqli-error",
+		"-j", "-silent", "-no-interactsh", "-rate-limit", "10", "-timeout", "30"}, nucleiArgs("https://example.com", config))
+}
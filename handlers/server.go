@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"chimerascan/audit"
+	"chimerascan/quota"
+	"chimerascan/rbac"
+	"chimerascan/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Server - обработчики API, кроме входа, сессий и токенов (их данными владеет пакет auth).
+// Пользователи, организации, проекты, сканирования, уязвимости, вебхуки, трекеры задач
+// и квоты читаются и пишутся только через хранилища, поэтому в тестах их можно заменить
+// реализацией в памяти. Статистика, правила хранения и журнал аудита строятся своими пакетами.
+type Server struct {
+	Users           store.UserStore
+	Organizations   store.OrganizationStore
+	Projects        store.ProjectStore
+	Scans           store.ScanStore
+	Vulnerabilities store.VulnerabilityStore
+	Webhooks        store.WebhookStore
+	Issues          store.IssueStore
+	Notifications   store.NotificationStore
+	Quotas          quota.Store
+
+	// Audit записывает событие в журнал аудита
+	Audit func(c *gin.Context, entry audit.Entry)
+}
+
+// NewServer создает обработчики поверх хранилища
+func NewServer(s store.Store) *Server {
+	return &Server{
+		Users:           s,
+		Organizations:   s,
+		Projects:        s,
+		Scans:           s,
+		Vulnerabilities: s,
+		Webhooks:        s,
+		Issues:          s,
+		Notifications:   s,
+		Quotas:          s,
+		Audit:           audit.Record,
+	}
+}
+
+// Проверка права на проект; при отказе ответ уже отправлен
+func (s *Server) authorizeProject(c *gin.Context, projectID string, perm rbac.Permission) bool {
+	if err := s.authorize(c, rbac.KindProject, projectID, perm); err != nil {
+		respondAccessError(c, err, "Project not found")
+		return false
+	}
+	return true
+}
+
+// Проверка права на сканирование; при отказе ответ уже отправлен
+func (s *Server) authorizeScan(c *gin.Context, scanID string, perm rbac.Permission) bool {
+	if err := s.authorize(c, rbac.KindScan, scanID, perm); err != nil {
+		respondAccessError(c, err, "Scan not found")
+		return false
+	}
+	return true
+}
+
+// Проверка права в организации; при отказе ответ уже отправлен
+func (s *Server) authorizeOrganization(c *gin.Context, orgID string, perm rbac.Permission) bool {
+	if err := s.authorize(c, rbac.KindOrganization, orgID, perm); err != nil {
+		respondAccessError(c, err, "Organization not found")
+		return false
+	}
+	return true
+}
+
+// Проверка права на ресурс с получением роли через хранилища
+func (s *Server) authorize(c *gin.Context, kind, id string, perm rbac.Permission) error {
+	ctx := c.Request.Context()
+
+	var lookup rbac.Lookup
+	switch kind {
+	case rbac.KindProject:
+		lookup = func(id, userID uuid.UUID) (rbac.Access, error) { return s.Projects.ProjectAccess(ctx, id, userID) }
+	case rbac.KindScan:
+		lookup = func(id, userID uuid.UUID) (rbac.Access, error) { return s.Scans.ScanAccess(ctx, id, userID) }
+	default:
+		lookup = func(id, userID uuid.UUID) (rbac.Access, error) {
+			role, err := s.Users.OrganizationRole(ctx, id, userID)
+			return rbac.Access{Role: role, OrganizationID: &id}, err
+		}
+	}
+	return rbac.Authorize(c, kind, id, perm, lookup)
+}
@@ -0,0 +1,791 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/database"
+	"chimerascan/models"
+	"chimerascan/quota"
+	"chimerascan/rbac"
+	"chimerascan/storage"
+	"chimerascan/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryServer - обработчики поверх хранилища в памяти и записанные ими события аудита
+type memoryServer struct {
+	*Server
+	store  *store.Memory
+	events []audit.Entry
+}
+
+func newMemoryServer() *memoryServer {
+	mem := store.NewMemory()
+	s := &memoryServer{Server: NewServer(mem), store: mem}
+	s.Audit = func(c *gin.Context, entry audit.Entry) { s.events = append(s.events, entry) }
+	return s
+}
+
+// call выполняет обработчик от имени пользователя и возвращает ответ
+func call(handler gin.HandlerFunc, userID uuid.UUID, method, path string, params gin.Params, body interface{}) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(method, path, reader)
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("userID", userID)
+	c.Params = params
+	handler(c)
+	return w
+}
+
+func idParam(id uuid.UUID) gin.Params {
+	return gin.Params{{Key: "id", Value: id.String()}}
+}
+
+// testPage - ответ со страницей списка
+type testPage[T any] struct {
+	Items      []T     `json:"items"`
+	Total      int     `json:"total"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+func decodePage[T any](t *testing.T, w *httptest.ResponseRecorder) testPage[T] {
+	t.Helper()
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page testPage[T]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	return page
+}
+
+func (s *memoryServer) addScan(t *testing.T, userID uuid.UUID, projectID *uuid.UUID, createdAt time.Time) models.Scan {
+	scan := models.Scan{
+		ID:        uuid.New(),
+		TargetURL: "https://example.com",
+		Status:    "Completed",
+		ProjectID: projectID,
+		UserID:    userID,
+		CreatedAt: createdAt,
+	}
+	require.NoError(t, s.store.CreateScan(context.Background(), &scan))
+	return scan
+}
+
+// completeScan завершает сканирование с отчетами, как это делает runNucleiScan
+func (s *memoryServer) completeScan(t *testing.T, id uuid.UUID, reportPaths map[string]string) {
+	ctx := context.Background()
+	require.NoError(t, s.store.UpdateScanStatus(ctx, id, "In Progress", nil))
+	require.NoError(t, s.store.CompleteScan(ctx, id, store.ScanCompletion{FinishedAt: time.Now(), ReportPaths: reportPaths}))
+}
+
+func TestServer_ProjectLifecycle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	userID := uuid.New()
+
+	w := call(s.CreateProject, userID, "POST", "/api/projects", nil, map[string]string{"name": "Site", "description": "Main"})
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created models.Project
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	w = call(s.UpdateProject, userID, "PUT", "/api/projects/"+created.ID.String(), idParam(created.ID), map[string]string{"name": "Renamed"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	projects := decodePage[models.Project](t, call(s.GetProjects, userID, "GET", "/api/projects", nil, nil))
+	require.Len(t, projects.Items, 1)
+	assert.Equal(t, "Renamed", projects.Items[0].Name)
+
+	scan := s.addScan(t, userID, &created.ID, time.Now())
+
+	w = call(s.DeleteProject, userID, "DELETE", "/api/projects/"+created.ID.String(), idParam(created.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	stored, err := s.store.GetScan(context.Background(), scan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &created.ID, stored.ProjectID, "Scans stay in the deleted project")
+
+	w = call(s.GetProject, userID, "GET", "/api/projects/"+created.ID.String(), idParam(created.ID), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	projects = decodePage[models.Project](t, call(s.GetProjects, userID, "GET", "/api/projects", nil, nil))
+	assert.Empty(t, projects.Items)
+	assert.Zero(t, projects.Total)
+
+	w = call(s.AdminRestoreProject, uuid.New(), "POST", "/api/admin/projects/"+created.ID.String()+"/restore", idParam(created.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = call(s.GetProject, userID, "GET", "/api/projects/"+created.ID.String(), idParam(created.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"scan_count":1`)
+
+	var actions []string
+	for _, event := range s.events {
+		actions = append(actions, event.Action)
+	}
+	assert.Equal(t, []string{audit.ActionProjectCreate, audit.ActionProjectUpdate, audit.ActionProjectDelete, audit.ActionProjectRestore}, actions)
+}
+
+func TestServer_ProjectScans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	userID := uuid.New()
+
+	project := models.Project{ID: uuid.New(), Name: "Site", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateProject(context.Background(), &project))
+
+	now := time.Now()
+	oldest := s.addScan(t, userID, &project.ID, now.Add(-3*time.Hour))
+	middle := s.addScan(t, userID, &project.ID, now.Add(-2*time.Hour))
+	newest := s.addScan(t, userID, &project.ID, now.Add(-time.Hour))
+	s.addScan(t, userID, nil, now)
+	require.NoError(t, s.store.UpdateScanStatus(context.Background(), middle.ID, "Failed", nil))
+	for _, severity := range []string{"High", "high", "low"} {
+		vuln := models.Vulnerability{ID: uuid.New(), ScanID: newest.ID, Name: "XSS", Severity: severity}
+		require.NoError(t, s.store.CreateVulnerability(context.Background(), &vuln))
+	}
+
+	path := "/api/projects/" + project.ID.String() + "/scans"
+	page := decodePage[models.Scan](t, call(s.GetProjectScans, userID, "GET", path+"?limit=2", idParam(project.ID), nil))
+	assert.Equal(t, 3, page.Total)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, newest.ID, page.Items[0].ID)
+	assert.Equal(t, map[string]int{"high": 2, "low": 1}, page.Items[0].SeverityCounts)
+	assert.Empty(t, page.Items[1].SeverityCounts)
+	require.NotNil(t, page.NextCursor)
+
+	page = decodePage[models.Scan](t, call(s.GetProjectScans, userID, "GET", path+"?limit=2&cursor="+*page.NextCursor, idParam(project.ID), nil))
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, oldest.ID, page.Items[0].ID)
+	assert.Nil(t, page.NextCursor)
+
+	page = decodePage[models.Scan](t, call(s.GetProjectScans, userID, "GET", path+"?status=Completed&sort=created_at", idParam(project.ID), nil))
+	assert.Equal(t, 2, page.Total)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, oldest.ID, page.Items[0].ID)
+	assert.Equal(t, newest.ID, page.Items[1].ID)
+
+	w := call(s.GetProjectScans, userID, "GET", path+"?status=Done", idParam(project.ID), nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = call(s.GetProjectScans, uuid.New(), "GET", path, idParam(project.ID), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestServer_ArchivedProject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	userID := uuid.New()
+
+	project := models.Project{ID: uuid.New(), Name: "Site", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateProject(context.Background(), &project))
+	scan := s.addScan(t, userID, nil, time.Now())
+
+	w := call(s.ArchiveProject, userID, "POST", "/api/projects/"+project.ID.String()+"/archive", idParam(project.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	projects := decodePage[models.Project](t, call(s.GetProjects, userID, "GET", "/api/projects", nil, nil))
+	assert.Empty(t, projects.Items, "Archived projects are hidden by default")
+	w = call(s.GetProjects, userID, "GET", "/api/projects?archived=true", nil, nil)
+	assert.Contains(t, w.Body.String(), project.ID.String())
+
+	w = call(s.StartScan, userID, "POST", "/api/scan/start", nil, map[string]string{"target_url": "https://example.com", "project_id": project.ID.String()})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = call(s.AddScanToProject, userID, "POST", "/api/scans/"+scan.ID.String()+"/add-to-project", idParam(scan.ID), map[string]string{"project_id": project.ID.String()})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = call(s.UnarchiveProject, userID, "POST", "/api/projects/"+project.ID.String()+"/unarchive", idParam(project.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = call(s.AddScanToProject, userID, "POST", "/api/scans/"+scan.ID.String()+"/add-to-project", idParam(scan.ID), map[string]string{"project_id": project.ID.String()})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestServer_OrganizationVisibility(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	ownerID, viewerID, outsiderID := uuid.New(), uuid.New(), uuid.New()
+	orgID := uuid.New()
+	s.store.AddMember(orgID, ownerID, rbac.RoleOwner)
+	s.store.AddMember(orgID, viewerID, rbac.RoleViewer)
+
+	w := call(s.CreateProject, viewerID, "POST", "/api/projects", nil, map[string]string{"name": "Shared", "organization_id": orgID.String()})
+	assert.Equal(t, http.StatusForbidden, w.Code, "Viewer cannot create organization projects")
+
+	w = call(s.CreateProject, ownerID, "POST", "/api/projects", nil, map[string]string{"name": "Shared", "organization_id": orgID.String()})
+	require.Equal(t, http.StatusCreated, w.Code)
+	var project models.Project
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &project))
+
+	older := s.addScan(t, ownerID, &project.ID, time.Now().Add(-time.Hour))
+	newer := s.addScan(t, ownerID, &project.ID, time.Now())
+	s.addScan(t, ownerID, nil, time.Now())
+
+	scans := decodePage[models.Scan](t, call(s.GetScans, viewerID, "GET", "/api/scans", nil, nil))
+	require.Len(t, scans.Items, 2, "Personal scans of other members stay hidden")
+	assert.Equal(t, newer.ID, scans.Items[0].ID)
+	assert.Equal(t, older.ID, scans.Items[1].ID)
+
+	w = call(s.DeleteScan, viewerID, "DELETE", "/api/scans/"+newer.ID.String(), idParam(newer.ID), nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = call(s.GetScanStatus, outsiderID, "GET", "/api/scan/status/"+newer.ID.String(), idParam(newer.ID), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = call(s.GetScanStatus, viewerID, "GET", "/api/scan/status/"+newer.ID.String(), idParam(newer.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"status":"Completed"`)
+}
+
+func TestServer_AddScanToProject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	userID := uuid.New()
+
+	project := models.Project{ID: uuid.New(), Name: "Site", UserID: userID, CreatedAt: time.Now()}
+	foreign := models.Project{ID: uuid.New(), Name: "Foreign", UserID: uuid.New(), CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateProject(context.Background(), &project))
+	require.NoError(t, s.store.CreateProject(context.Background(), &foreign))
+	scan := s.addScan(t, userID, nil, time.Now())
+
+	w := call(s.AddScanToProject, userID, "POST", "/api/scans/"+scan.ID.String()+"/add-to-project", idParam(scan.ID), map[string]string{"project_id": foreign.ID.String()})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Other users' projects look nonexistent")
+
+	w = call(s.AddScanToProject, userID, "POST", "/api/scans/"+scan.ID.String()+"/add-to-project", idParam(scan.ID), map[string]string{"project_id": project.ID.String()})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = call(s.GetProjectsForScan, userID, "GET", "/api/scans/"+scan.ID.String()+"/projects", idParam(scan.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"projects": [{"id": "`+project.ID.String()+`", "name": "Site"}], "current_project_id": "`+project.ID.String()+`"}`, w.Body.String())
+}
+
+func TestServer_DeleteScanRemovesFiles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reports, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	oldReports := storage.Reports
+	storage.Reports = reports
+	defer func() { storage.Reports = oldReports }()
+
+	s := newMemoryServer()
+	userID := uuid.New()
+	scan := s.addScan(t, userID, nil, time.Now())
+
+	ctx := context.Background()
+	s.completeScan(t, scan.ID, map[string]string{"json": "reports/scan.json", "html": "scan.html"})
+	evidenceKey := "evidence/" + scan.ID.String() + "/response.gz"
+	vuln := models.Vulnerability{ID: uuid.New(), ScanID: scan.ID, Name: "XSS", Severity: "high", EvidenceKey: &evidenceKey}
+	require.NoError(t, s.store.CreateVulnerability(ctx, &vuln))
+	for _, key := range []string{"scan.json", "scan.html", evidenceKey} {
+		require.NoError(t, reports.Put(ctx, key, []byte("data"), "text/plain"))
+	}
+
+	w := call(s.DeleteScan, userID, "DELETE", "/api/scans/"+scan.ID.String(), idParam(scan.ID), nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	for _, key := range []string{"scan.json", "scan.html", evidenceKey} {
+		_, err := reports.Get(ctx, key)
+		assert.ErrorIs(t, err, storage.ErrNotFound, key)
+	}
+	require.Len(t, s.events, 1)
+	assert.Equal(t, 3, s.events[0].Details["report_files"])
+}
+
+func TestServer_DeleteScanRemovesVulnerabilities(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	userID := uuid.New()
+	scan := s.addScan(t, userID, nil, time.Now())
+
+	vuln := models.Vulnerability{ID: uuid.New(), ScanID: scan.ID, Name: "XSS", Severity: "high"}
+	require.NoError(t, s.store.CreateVulnerability(context.Background(), &vuln))
+
+	w := call(s.DeleteScan, userID, "DELETE", "/api/scans/"+scan.ID.String(), idParam(scan.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	_, err := s.store.GetVulnerability(context.Background(), vuln.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	w = call(s.DeleteScan, userID, "DELETE", "/api/scans/"+scan.ID.String(), idParam(scan.ID), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestServer_CanceledScanStaysCanceled(t *testing.T) {
+	reports, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	oldReports := storage.Reports
+	storage.Reports = reports
+	defer func() { storage.Reports = oldReports }()
+
+	ctx := context.Background()
+	s := newMemoryServer()
+	userID := uuid.New()
+
+	// Отмененное до запуска сканирование не запускается
+	queued := models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: "Queued", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateScan(ctx, &queued))
+	_, err = s.store.CancelScans(ctx, []uuid.UUID{queued.ID})
+	require.NoError(t, err)
+	s.runNucleiScan(queued)
+
+	// Отмененное во время работы сканирование не завершается, а его отчеты удаляются
+	running := models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: "Queued", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateScan(ctx, &running))
+	require.True(t, s.startScan(running.ID))
+	_, err = s.store.CancelScans(ctx, []uuid.UUID{running.ID})
+	require.NoError(t, err)
+	assert.False(t, s.scanInProgress(running.ID))
+	require.NoError(t, reports.Put(ctx, "scan.json", []byte("{}"), "application/json"))
+	s.updateScanCompletion(running.ID, nil, nil, map[string]string{"json": "scan.json"}, 2)
+
+	for _, id := range []uuid.UUID{queued.ID, running.ID} {
+		scan, err := s.store.GetScan(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "Canceled", scan.Status)
+		assert.Nil(t, scan.FinishedAt)
+	}
+	_, err = reports.Get(ctx, "scan.json")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestServer_StopScan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	userID := uuid.New()
+
+	done := s.addScan(t, userID, nil, time.Now())
+	s.completeScan(t, done.ID, map[string]string{"json": "scan.json"})
+	w := call(s.StopScan, userID, "POST", "/api/scan/stop/"+done.ID.String(), idParam(done.ID), nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	scan, err := s.store.GetScan(context.Background(), done.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Completed", scan.Status, "A finished scan keeps its status and reports")
+	assert.Equal(t, "scan.json", scan.ReportJSONPath)
+	assert.Empty(t, s.events)
+
+	running := models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: "Queued", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateScan(context.Background(), &running))
+	w = call(s.StopScan, userID, "POST", "/api/scan/stop/"+running.ID.String(), idParam(running.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	scan, err = s.store.GetScan(context.Background(), running.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Canceled", scan.Status)
+	require.Len(t, s.events, 1)
+	assert.Equal(t, audit.ActionScanStop, s.events[0].Action)
+}
+
+func TestServer_RerunScan(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctx := context.Background()
+	s := newMemoryServer()
+	userID := uuid.New()
+
+	project := models.Project{ID: uuid.New(), Name: "Site", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateProject(ctx, &project))
+
+	original := models.Scan{
+		ID:        uuid.New(),
+		TargetURL: "https://example.com",
+		Status:    "Completed",
+		ProjectID: &project.ID,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+		Config:    &models.ScanConfig{Image: "projectdiscovery/nuclei:v3.3.0", RateLimit: 10, Timeout: 30},
+		Labels:    []string{"ci"},
+		Notes:     "Before the fix",
+		Metadata:  map[string]string{"ticket": "SEC-12"},
+	}
+	require.NoError(t, s.store.CreateScan(ctx, &original))
+
+	var vulnIDs []uuid.UUID
+	for _, finding := range []struct{ template, matchedAt string }{
+		{"xss-reflected", "https://example.com/search?q=1"},
+		{"sqli-error", "https://example.com/item?id=1"},
+		{"xss-reflected", "https://example.com/item?id=1"},
+	} {
+		vuln := models.Vulnerability{ID: uuid.New(), ScanID: original.ID, TemplateID: finding.template, MatchedAt: finding.matchedAt, Severity: "high"}
+		require.NoError(t, s.store.CreateVulnerability(ctx, &vuln))
+		vulnIDs = append(vulnIDs, vuln.ID)
+	}
+
+	rerun := func(body interface{}) (*httptest.ResponseRecorder, *models.Scan) {
+		w := call(s.RerunScan, userID, "POST", "/api/scans/"+original.ID.String()+"/rerun", idParam(original.ID), body)
+		if w.Code != http.StatusAccepted {
+			return w, nil
+		}
+		var response struct {
+			ScanID uuid.UUID `json:"scan_id"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		scan, err := s.store.GetScan(ctx, response.ScanID)
+		require.NoError(t, err)
+		return w, scan
+	}
+
+	w, scan := rerun(nil)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Equal(t, original.TargetURL, scan.TargetURL)
+	assert.Equal(t, &project.ID, scan.ProjectID)
+	assert.Equal(t, &original.ID, scan.RetestOf)
+	assert.Equal(t, original.Config, scan.Config)
+	assert.Equal(t, original.Labels, scan.Labels)
+	assert.Equal(t, original.Metadata, scan.Metadata)
+	assert.Empty(t, scan.Notes)
+
+	w, scan = rerun(map[string]interface{}{"vulnerability_ids": []uuid.UUID{vulnIDs[0], vulnIDs[2]}})
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Equal(t, &original.ID, scan.RetestOf)
+	assert.Equal(t, &models.ScanConfig{
+		Image:     "projectdiscovery/nuclei:v3.3.0",
+		RateLimit: 10,
+		Timeout:   30,
+		Templates: []string{"xss-reflected"},
+		Targets:   []string{"https://example.com/search?q=1", "https://example.com/item?id=1"},
+	}, scan.Config)
+
+	w, _ = rerun(map[string]interface{}{"vulnerability_ids": []uuid.UUID{uuid.New()}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Адреса находок, которые не годятся в цель Nuclei, заменяются целью исходного сканирования
+	var unsafeIDs []uuid.UUID
+	for _, matchedAt := range []string{"example.com:22", "https://example.com/?q=$(id)", "-config /etc/passwd"} {
+		vuln := models.Vulnerability{ID: uuid.New(), ScanID: original.ID, TemplateID: "ssh-weak-cipher", MatchedAt: matchedAt, Severity: "high"}
+		require.NoError(t, s.store.CreateVulnerability(ctx, &vuln))
+		unsafeIDs = append(unsafeIDs, vuln.ID)
+	}
+	w, scan = rerun(map[string]interface{}{"vulnerability_ids": unsafeIDs})
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Equal(t, []string{original.TargetURL}, scan.Config.Targets)
+
+	w = call(s.RerunScan, uuid.New(), "POST", "/api/scans/"+original.ID.String()+"/rerun", idParam(original.ID), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	require.NoError(t, s.store.SetProjectArchived(ctx, project.ID, true))
+	w, _ = rerun(nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	require.Len(t, s.events, 3)
+	assert.Equal(t, audit.ActionScanRerun, s.events[1].Action)
+	assert.Equal(t, original.ID, s.events[1].Details["retest_of"])
+}
+
+func TestServer_ScanDetails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := newMemoryServer()
+	ownerID, viewerID := uuid.New(), uuid.New()
+	orgID := uuid.New()
+	s.store.AddMember(orgID, ownerID, rbac.RoleOwner)
+	s.store.AddMember(orgID, viewerID, rbac.RoleViewer)
+
+	project := models.Project{ID: uuid.New(), Name: "Shared", UserID: ownerID, OrganizationID: &orgID, CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateProject(context.Background(), &project))
+	scan := s.addScan(t, ownerID, &project.ID, time.Now())
+	other := s.addScan(t, ownerID, &project.ID, time.Now())
+
+	path := "/api/scans/" + scan.ID.String()
+	w := call(s.UpdateScanDetails, ownerID, "PATCH", path, idParam(scan.ID), map[string]interface{}{
+		"labels":   []string{"ci", " release ", "ci"},
+		"notes":    "Deploy of v1.4",
+		"metadata": map[string]string{"build_sha": "4f2a9c1", "env": "prod"},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var updated models.Scan
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Equal(t, []string{"ci", "release"}, updated.Labels)
+
+	w = call(s.UpdateScanDetails, ownerID, "PATCH", path, idParam(scan.ID), map[string]interface{}{"notes": "Deploy of v1.5"})
+	require.Equal(t, http.StatusOK, w.Code)
+	stored, err := s.store.GetScan(context.Background(), scan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Deploy of v1.5", stored.Notes)
+	assert.Equal(t, []string{"ci", "release"}, stored.Labels, "Omitted fields stay unchanged")
+	assert.Equal(t, map[string]string{"build_sha": "4f2a9c1", "env": "prod"}, stored.Metadata)
+
+	for _, body := range []map[string]interface{}{
+		{"labels": []string{"a,b"}},
+		{"labels": []string{""}},
+		{"metadata": map[string]string{"build sha": "1"}},
+	} {
+		w = call(s.UpdateScanDetails, ownerID, "PATCH", path, idParam(scan.ID), body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	w = call(s.UpdateScanDetails, viewerID, "PATCH", path, idParam(scan.ID), map[string]interface{}{"notes": "x"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	for _, query := range []string{"label=ci", "label=ci,release", "label=ci&metadata=env:prod", "metadata=build_sha:4f2a9c1"} {
+		page := decodePage[models.Scan](t, call(s.GetScans, viewerID, "GET", "/api/scans?"+query, nil, nil))
+		require.Len(t, page.Items, 1, query)
+		assert.Equal(t, scan.ID, page.Items[0].ID, query)
+	}
+	page := decodePage[models.Scan](t, call(s.GetScans, viewerID, "GET", "/api/scans?metadata=env:staging", nil, nil))
+	assert.Empty(t, page.Items)
+	page = decodePage[models.Scan](t, call(s.GetScans, viewerID, "GET", "/api/scans", nil, nil))
+	assert.Len(t, page.Items, 2)
+	assert.Contains(t, []uuid.UUID{page.Items[0].ID, page.Items[1].ID}, other.ID)
+
+	require.Len(t, s.events, 2)
+	assert.Equal(t, audit.ActionScanUpdate, s.events[0].Action)
+}
+
+// bulkResponse - ответ массовой операции
+type bulkResponse struct {
+	Results   []bulkResult `json:"results"`
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+}
+
+func decodeBulk(t *testing.T, w *httptest.ResponseRecorder) bulkResponse {
+	t.Helper()
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response bulkResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestServer_BulkDeleteScans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reports, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	oldReports := storage.Reports
+	storage.Reports = reports
+	defer func() { storage.Reports = oldReports }()
+
+	ctx := context.Background()
+	s := newMemoryServer()
+	userID := uuid.New()
+	first := s.addScan(t, userID, nil, time.Now())
+	second := s.addScan(t, userID, nil, time.Now())
+	foreign := s.addScan(t, uuid.New(), nil, time.Now())
+	s.completeScan(t, first.ID, map[string]string{"json": "reports/first.json"})
+	require.NoError(t, reports.Put(ctx, "first.json", []byte("{}"), "application/json"))
+
+	w := call(s.BulkDeleteScans, userID, "POST", "/api/scans/bulk/delete", nil, map[string]interface{}{})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Neither scan_ids nor filter")
+	w = call(s.BulkDeleteScans, userID, "POST", "/api/scans/bulk/delete", nil, map[string]interface{}{"filter": map[string]interface{}{}})
+	assert.Equal(t, http.StatusBadRequest, w.Code, "Empty filter")
+
+	w = call(s.BulkDeleteScans, userID, "POST", "/api/scans/bulk/delete", nil, map[string]interface{}{
+		"scan_ids": []uuid.UUID{first.ID, second.ID, first.ID, foreign.ID},
+	})
+	response := decodeBulk(t, w)
+	assert.Equal(t, 2, response.Succeeded)
+	assert.Equal(t, 1, response.Failed)
+	require.Len(t, response.Results, 3)
+	assert.Equal(t, bulkResult{ScanID: foreign.ID, Status: bulkError, Error: "Scan not found"}, response.Results[2])
+
+	for _, id := range []uuid.UUID{first.ID, second.ID} {
+		_, err := s.store.GetScan(ctx, id)
+		assert.ErrorIs(t, err, store.ErrNotFound)
+	}
+	_, err = s.store.GetScan(ctx, foreign.ID)
+	assert.NoError(t, err)
+	_, err = reports.Get(ctx, "first.json")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	require.Len(t, s.events, 2)
+	assert.Equal(t, audit.ActionScanDelete, s.events[0].Action)
+	assert.Equal(t, true, s.events[0].Details["bulk"])
+}
+
+func TestServer_BulkMoveAndCancelScans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	s := newMemoryServer()
+	userID := uuid.New()
+
+	project := models.Project{ID: uuid.New(), Name: "Site", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateProject(ctx, &project))
+	running := s.addScan(t, userID, nil, time.Now())
+	require.NoError(t, s.store.UpdateScanStatus(ctx, running.ID, "In Progress", nil))
+	done := s.addScan(t, userID, nil, time.Now())
+	require.NoError(t, s.store.UpdateScanDetails(ctx, running.ID, []string{"nightly"}, "", nil))
+	require.NoError(t, s.store.UpdateScanDetails(ctx, done.ID, []string{"nightly"}, "", nil))
+	s.addScan(t, userID, nil, time.Now())
+
+	w := call(s.BulkMoveScans, userID, "POST", "/api/scans/bulk/move", nil, map[string]interface{}{
+		"filter":     map[string]interface{}{"labels": []string{"nightly"}},
+		"project_id": project.ID.String(),
+	})
+	response := decodeBulk(t, w)
+	assert.Equal(t, 2, response.Succeeded)
+	page, err := s.store.ListScans(ctx, userID, store.ScanQuery{ProjectID: &project.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+
+	require.NoError(t, s.store.SetProjectArchived(ctx, project.ID, true))
+	w = call(s.BulkMoveScans, userID, "POST", "/api/scans/bulk/move", nil, map[string]interface{}{
+		"scan_ids":   []uuid.UUID{done.ID},
+		"project_id": project.ID.String(),
+	})
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = call(s.BulkCancelScans, userID, "POST", "/api/scans/bulk/cancel", nil, map[string]interface{}{
+		"filter": map[string]interface{}{"project_id": project.ID},
+	})
+	response = decodeBulk(t, w)
+	assert.Equal(t, 1, response.Succeeded)
+	for _, result := range response.Results {
+		if result.ScanID == done.ID {
+			assert.Equal(t, "Scan is not running", result.Error)
+		}
+	}
+	scan, err := s.store.GetScan(ctx, running.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Canceled", scan.Status)
+	scan, err = s.store.GetScan(ctx, done.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Completed", scan.Status)
+}
+
+func TestServer_BulkRerunScans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	oldDefaults := quota.Defaults
+	quota.Defaults = quota.Limits{ScansPerDay: 1}
+	defer func() { quota.Defaults = oldDefaults }()
+
+	ctx := context.Background()
+	s := newMemoryServer()
+	userID := uuid.New()
+	archived := models.Project{ID: uuid.New(), Name: "Old", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, s.store.CreateProject(ctx, &archived))
+	require.NoError(t, s.store.SetProjectArchived(ctx, archived.ID, true))
+
+	// Исходные сканирования запущены более суток назад и дневную квоту не расходуют
+	yesterday := time.Now().Add(-48 * time.Hour)
+	first := s.addScan(t, userID, nil, yesterday)
+	second := s.addScan(t, userID, nil, yesterday)
+	old := s.addScan(t, userID, &archived.ID, yesterday)
+
+	w := call(s.BulkRerunScans, userID, "POST", "/api/scans/bulk/rerun", nil, map[string]interface{}{
+		"scan_ids": []uuid.UUID{first.ID, old.ID, second.ID},
+	})
+	response := decodeBulk(t, w)
+	require.Len(t, response.Results, 3)
+	assert.Equal(t, 1, response.Succeeded)
+	assert.Equal(t, "Project is archived", response.Results[1].Error)
+	assert.Equal(t, "Quota exceeded: scans_per_day", response.Results[2].Error, "The first rerun counts against the quota")
+
+	require.NotNil(t, response.Results[0].NewScanID)
+	scan, err := s.store.GetScan(ctx, *response.Results[0].NewScanID)
+	require.NoError(t, err)
+	assert.Equal(t, &first.ID, scan.RetestOf)
+	assert.Nil(t, response.Results[2].NewScanID)
+}
+
+// slowCreateStore сохраняет сканирования с задержкой, чтобы параллельные запросы
+// успели проверить квоту до сохранения
+type slowCreateStore struct {
+	store.Store
+}
+
+func (s slowCreateStore) CreateScans(ctx context.Context, scans []*models.Scan) error {
+	if len(scans) > 0 {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return s.Store.CreateScans(ctx, scans)
+}
+
+func (s slowCreateStore) CreateScan(ctx context.Context, scan *models.Scan) error {
+	return s.CreateScans(ctx, []*models.Scan{scan})
+}
+
+func TestServer_ConcurrentScanStartsRespectQuota(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+	oldDefaults := quota.Defaults
+	quota.Defaults = quota.Limits{ConcurrentScans: 3}
+	defer func() { quota.Defaults = oldDefaults }()
+
+	// Квоты считаются по той же БД, в которую сохраняются сканирования
+	ctx := context.Background()
+	s := NewServer(slowCreateStore{store.NewPostgres(database.DB)})
+	s.Audit = func(*gin.Context, audit.Entry) {}
+	userID := uuid.New()
+	_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, userID, userID.String(), "user@example.com", "user")
+	require.NoError(t, err)
+	original := models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: "Completed", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, s.Scans.CreateScan(ctx, &original))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			call(s.StartScan, userID, "POST", "/api/scan/start", nil, map[string]interface{}{"target_url": "https://example.com"})
+		}()
+		go func() {
+			defer wg.Done()
+			call(s.BulkRerunScans, userID, "POST", "/api/scans/bulk/rerun", nil, map[string]interface{}{"scan_ids": []uuid.UUID{original.ID}})
+		}()
+	}
+	wg.Wait()
+
+	var queued int
+	require.NoError(t, database.DB.QueryRow(`SELECT COUNT(*) FROM scans WHERE status = 'Queued'`).Scan(&queued))
+	assert.Equal(t, 3, queued)
+}
+
+func TestServer_BulkExportScans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reports, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	oldReports := storage.Reports
+	storage.Reports = reports
+	defer func() { storage.Reports = oldReports }()
+
+	ctx := context.Background()
+	s := newMemoryServer()
+	userID := uuid.New()
+	completed := s.addScan(t, userID, nil, time.Now())
+	empty := s.addScan(t, userID, nil, time.Now())
+	s.completeScan(t, completed.ID, map[string]string{"json": "reports/scan.json", "html": "reports/scan.html"})
+	require.NoError(t, reports.Put(ctx, "scan.json", []byte(`{"findings":[]}`), "application/json"))
+	require.NoError(t, reports.Put(ctx, "scan.html", []byte("<html></html>"), "text/html"))
+
+	w := call(s.BulkExportScans, userID, "POST", "/api/scans/bulk/export", nil, map[string]interface{}{"scan_ids": []uuid.UUID{empty.ID}})
+	response := decodeBulk(t, w)
+	assert.Equal(t, "Scan has no reports", response.Results[0].Error)
+
+	w = call(s.BulkExportScans, userID, "POST", "/api/scans/bulk/export", nil, map[string]interface{}{"scan_ids": []uuid.UUID{completed.ID, empty.ID}})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	require.NoError(t, err)
+	files := map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		require.NoError(t, err)
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		files[file.Name] = string(data)
+	}
+	assert.Equal(t, `{"findings":[]}`, files[completed.ID.String()+"/report.json"])
+	assert.Equal(t, "<html></html>", files[completed.ID.String()+"/report.html"])
+
+	var results []bulkResult
+	require.NoError(t, json.Unmarshal([]byte(files["results.json"]), &results))
+	require.Len(t, results, 2)
+	assert.Equal(t, bulkOK, results[0].Status)
+	assert.Equal(t, bulkError, results[1].Status)
+}
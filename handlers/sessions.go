@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+
+	"chimerascan/audit"
+	"chimerascan/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Текущая сессия запроса, если пользователь вошел через cookie
+func currentSessionID(c *gin.Context) uuid.UUID {
+	if id, ok := c.Get("sessionID"); ok {
+		if sessionID, ok := id.(uuid.UUID); ok {
+			return sessionID
+		}
+	}
+	return uuid.Nil
+}
+
+// GetSessions возвращает активные сессии пользователя
+func GetSessions(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	sessions, err := auth.ListSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	current := currentSessionID(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// DeleteSession завершает сессию пользователя
+func DeleteSession(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		return
+	}
+
+	deleted, err := auth.RevokeUserSession(userID, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete session"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if sessionID == currentSessionID(c) {
+		auth.SetCookie(c, auth.SessionCookieName, "", -1)
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionSessionRevoke, TargetType: "session", TargetID: sessionID.String()})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session deleted successfully"})
+}
+
+// DeleteOtherSessions завершает все сессии пользователя, кроме текущей
+func DeleteOtherSessions(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	count, err := auth.RevokeOtherSessions(userID, currentSessionID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete sessions"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{
+		Action:     audit.ActionSessionRevoke,
+		TargetType: "session",
+		Details:    map[string]interface{}{"all_except_current": true, "count": count},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"deleted": count})
+}
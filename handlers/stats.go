@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"chimerascan/rbac"
+	"chimerascan/stats"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Период статистики по умолчанию
+const defaultStatsPeriod = 90 * 24 * time.Hour
+
+// Период из параметров since и until (RFC 3339 или YYYY-MM-DD); по умолчанию - последние 90 дней
+func statsPeriod(c *gin.Context) (since, until time.Time, ok bool) {
+	until = time.Now()
+	since = until.Add(-defaultStatsPeriod)
+
+	for param, target := range map[string]*time.Time{"since": &since, "until": &until} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeParam(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ", expected RFC 3339 timestamp or YYYY-MM-DD date"})
+			return since, until, false
+		}
+		*target = t
+	}
+
+	if !since.Before(until) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "since must be before until"})
+		return since, until, false
+	}
+	return since, until, true
+}
+
+// GetStats возвращает статистику по всем сканированиям, видимым пользователю
+func (s *Server) GetStats(c *gin.Context) {
+	since, until, ok := statsPeriod(c)
+	if !ok {
+		return
+	}
+
+	result, err := stats.Query(c.Request.Context(), stats.ForUser(c.MustGet("userID").(uuid.UUID)), since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute statistics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetProjectStats возвращает статистику по сканированиям проекта
+func (s *Server) GetProjectStats(c *gin.Context) {
+	projectID := c.Param("id")
+
+	since, until, ok := statsPeriod(c)
+	if !ok {
+		return
+	}
+
+	if !s.authorizeProject(c, projectID, rbac.PermView) {
+		return
+	}
+
+	result, err := stats.Query(c.Request.Context(), stats.ForProject(uuid.MustParse(projectID)), since, until)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute statistics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/auth"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateAPIToken выпускает персональный токен доступа
+func CreateAPIToken(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req struct {
+		Name          string   `json:"name" binding:"required,max=255"`
+		Scopes        []string `json:"scopes" binding:"required,min=1"`
+		ExpiresInDays int      `json:"expires_in_days" binding:"min=0,max=3650"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !auth.IsValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope: " + scope})
+			return
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	token, err := auth.CreateAPIToken(userID, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API token"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{
+		Action:     audit.ActionTokenCreate,
+		TargetType: "api_token",
+		TargetID:   token.ID.String(),
+		Details:    map[string]interface{}{"name": token.Name, "scopes": token.Scopes, "expires_at": token.ExpiresAt},
+	})
+
+	// Значение токена возвращается только при создании
+	c.JSON(http.StatusCreated, token)
+}
+
+// GetAPITokens возвращает персональные токены пользователя
+func GetAPITokens(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	tokens, err := auth.ListAPITokens(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// DeleteAPIToken отзывает персональный токен
+func DeleteAPIToken(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	tokenID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return
+	}
+
+	deleted, err := auth.RevokeAPIToken(userID, tokenID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete API token"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API token not found"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionTokenRevoke, TargetType: "api_token", TargetID: tokenID.String()})
+
+	c.JSON(http.StatusOK, gin.H{"message": "API token deleted successfully"})
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+
+	"chimerascan/quota"
+	"chimerascan/rbac"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Квоты сканирования в проекте организации расходуются организацией, остальные - пользователем.
+// Проект должен быть уже проверен через rbac в этом запросе.
+func quotaSubject(c *gin.Context, projectID *uuid.UUID) quota.Subject {
+	if projectID != nil {
+		if orgID := rbac.CachedOrganization(c, rbac.KindProject, projectID.String()); orgID != nil {
+			return quota.Subject{Type: quota.SubjectOrganization, ID: *orgID}
+		}
+	}
+	return quota.Subject{Type: quota.SubjectUser, ID: c.MustGet("userID").(uuid.UUID)}
+}
+
+// Ответ на исчерпанную квоту: 429 с Retry-After, если квота освободится со временем
+func respondQuotaError(c *gin.Context, err error) {
+	exceeded, ok := err.(*quota.ExceededError)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check quota"})
+		return
+	}
+
+	if exceeded.RetryAfter > 0 {
+		c.Header("Retry-After", quota.RetryAfterSeconds(exceeded.RetryAfter))
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": "Quota exceeded",
+		"quota": exceeded.Quota,
+		"limit": exceeded.Limit,
+		"used":  exceeded.Used,
+	})
+}
+
+// GetUsage возвращает квоты и потребление пользователя или организации (?organization_id=)
+func (s *Server) GetUsage(c *gin.Context) {
+	subject := quota.Subject{Type: quota.SubjectUser, ID: c.MustGet("userID").(uuid.UUID)}
+
+	if orgID := c.Query("organization_id"); orgID != "" {
+		if !s.authorizeOrganization(c, orgID, rbac.PermView) {
+			return
+		}
+		subject = quota.Subject{Type: quota.SubjectOrganization, ID: uuid.MustParse(orgID)}
+	}
+
+	limits, err := quota.LimitsFor(c.Request.Context(), s.Quotas, subject)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch usage"})
+		return
+	}
+	usage, err := quota.UsageFor(c.Request.Context(), s.Quotas, subject)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch usage"})
+		return
+	}
+
+	response := gin.H{
+		"subject": gin.H{"type": subject.Type, "id": subject.ID},
+		"limits":  limits,
+		"usage":   usage,
+	}
+	if quota.API.Enabled() {
+		response["rate_limit"] = gin.H{
+			"requests_per_second": quota.API.Rate,
+			"burst":               quota.API.Burst,
+			"remaining":           quota.API.Remaining(quota.ClientKey(c)),
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
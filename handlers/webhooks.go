@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"chimerascan/audit"
+	"chimerascan/models"
+	"chimerascan/rbac"
+	"chimerascan/store"
+	"chimerascan/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateWebhook создает подписку на события сканирований
+func (s *Server) CreateWebhook(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	var req struct {
+		URL       string   `json:"url" binding:"required,url"`
+		Secret    string   `json:"secret"`
+		Events    []string `json:"events"`
+		ProjectID string   `json:"project_id"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Доставка - запрос с сервера, поэтому адреса внутренней сети запрещены
+	if err := webhooks.ValidateURL(c.Request.Context(), req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhook URL is not allowed: " + err.Error()})
+		return
+	}
+
+	for _, event := range req.Events {
+		if !webhooks.IsValidEvent(event) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown event: " + event})
+			return
+		}
+	}
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+
+	var projectID *uuid.UUID
+	if req.ProjectID != "" {
+		pid, err := uuid.Parse(req.ProjectID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
+			return
+		}
+
+		if err := s.authorize(c, rbac.KindProject, pid.String(), rbac.PermProjectManage); err != nil {
+			if err == rbac.ErrForbidden {
+				respondAccessError(c, err, "Project not found")
+			} else {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Project not found"})
+			}
+			return
+		}
+		projectID = &pid
+	}
+
+	if req.Secret == "" {
+		b := make([]byte, 32)
+		rand.Read(b)
+		req.Secret = hex.EncodeToString(b)
+	}
+
+	webhook := models.Webhook{
+		ID:        uuid.New(),
+		UserID:    userID,
+		ProjectID: projectID,
+		URL:       req.URL,
+		Secret:    req.Secret,
+		Events:    req.Events,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+
+	if err := s.Webhooks.CreateWebhook(c.Request.Context(), &webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+
+	var orgID *uuid.UUID
+	if projectID != nil {
+		orgID = rbac.CachedOrganization(c, rbac.KindProject, projectID.String())
+	}
+	s.Audit(c, audit.Entry{
+		Action:         audit.ActionWebhookCreate,
+		TargetType:     "webhook",
+		TargetID:       webhook.ID.String(),
+		OrganizationID: orgID,
+		Details:        map[string]interface{}{"url": webhook.URL, "project_id": webhook.ProjectID, "events": webhook.Events},
+	})
+
+	// Секрет возвращается только при создании
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// GetWebhooks возвращает подписки пользователя
+func (s *Server) GetWebhooks(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+
+	webhookList, err := s.Webhooks.ListWebhooks(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhookList)
+}
+
+// DeleteWebhook удаляет подписку
+func (s *Server) DeleteWebhook(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	webhookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	err = s.Webhooks.DeleteWebhook(c.Request.Context(), webhookID, userID)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	s.Audit(c, audit.Entry{Action: audit.ActionWebhookDelete, TargetType: "webhook", TargetID: webhookID.String()})
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// GetWebhookDeliveries возвращает журнал доставок подписки
+func (s *Server) GetWebhookDeliveries(c *gin.Context) {
+	userID := c.MustGet("userID").(uuid.UUID)
+	webhookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	}
+
+	deliveries, err := s.Webhooks.ListWebhookDeliveries(c.Request.Context(), webhookID, userID, 100)
+	if err == store.ErrNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// Событие сканирования для подписчиков
+func (s *Server) notifyScanEvent(scanID uuid.UUID, eventType string, data map[string]interface{}) {
+	if !webhooks.Enabled() {
+		return
+	}
+
+	scan, err := s.Scans.GetScan(context.Background(), scanID)
+	if err != nil {
+		log.Printf("Failed to load scan for webhook event: %v", err)
+		return
+	}
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	data["scan_id"] = scanID
+	data["target_url"] = scan.TargetURL
+	data["project_id"] = scan.ProjectID
+
+	webhooks.Emit(webhooks.Event{
+		Type:      eventType,
+		UserID:    scan.UserID,
+		ProjectID: scan.ProjectID,
+		Data:      data,
+	})
+}
+
+// События завершения сканирования для подписчиков: итог и новые находки высокого уровня риска
+func (s *Server) emitCompletionWebhooks(scanID uuid.UUID, results []NucleiResult, reportPaths map[string]string, newHigh []NucleiResult) {
+	if !webhooks.Enabled() {
+		return
+	}
+
+	reports := map[string]string{}
+	for format := range reportPaths {
+		reports[format] = reportURL(scanID, format)
+	}
+
+	s.notifyScanEvent(scanID, webhooks.EventScanCompleted, map[string]interface{}{
+		"total_count":    len(results),
+		"severity_stats": calculateSeverityStats(results),
+		"reports":        reports,
+	})
+
+	for _, result := range newHigh {
+		s.notifyScanEvent(scanID, webhooks.EventFindingHigh, map[string]interface{}{
+			"template_id": result.TemplateID,
+			"name":        result.Info.Name,
+			"host":        result.Host,
+			"matched_at":  result.MatchedAt,
+		})
+	}
+}
+
+// Находки высокого и критического уровня риска, не встречавшиеся в прошлых сканированиях той же цели
+func (s *Server) newHighFindings(scanID uuid.UUID, results []NucleiResult) []NucleiResult {
+	var newHigh []NucleiResult
+	for _, result := range results {
+		if isHighSeverity(result) && s.isNewFinding(scanID, result) {
+			newHigh = append(newHigh, result)
+		}
+	}
+	return newHigh
+}
+
+// Высокий или критический уровень риска по оценке Nuclei или AI: AI может быть недоступен
+// или занизить оценку шаблона
+func isHighSeverity(result NucleiResult) bool {
+	for _, severity := range []string{result.Info.Severity, result.SeverityAI} {
+		switch strings.ToLower(strings.TrimSpace(severity)) {
+		case "high", "critical":
+			return true
+		}
+	}
+	return false
+}
+
+// Находка считается новой, если не встречалась в прошлых сканированиях той же цели:
+// в том же проекте, а для сканирований без проекта - в личных сканированиях того же пользователя.
+// Если историю прочитать не удалось, находка новой не считается
+func (s *Server) isNewFinding(scanID uuid.UUID, result NucleiResult) bool {
+	seen, err := s.Vulnerabilities.FindingSeen(context.Background(), scanID, result.TemplateID, result.MatchedAt)
+	if err != nil {
+		log.Printf("Failed to check finding history: %v", err)
+		return false
+	}
+	return !seen
+}
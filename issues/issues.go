@@ -0,0 +1,303 @@
+package issues
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"chimerascan/config"
+)
+
+// Поддерживаемые трекеры задач
+const (
+	ProviderGitHub = "github"
+	ProviderGitLab = "gitlab"
+)
+
+// Состояния задачи после нормализации
+const (
+	StateOpen   = "open"
+	StateClosed = "closed"
+)
+
+// Issue - задача для создания в трекере
+type Issue struct {
+	Title  string
+	Body   string
+	Labels []string
+}
+
+// Created - созданная в трекере задача
+type Created struct {
+	Number int
+	URL    string
+	State  string
+}
+
+// Tracker создает задачи и получает их состояние
+type Tracker interface {
+	CreateIssue(ctx context.Context, repository string, issue Issue) (*Created, error)
+	IssueState(ctx context.Context, repository string, number int) (string, error)
+}
+
+// Адреса трекеров по умолчанию
+var (
+	githubAPIURL  = "https://api.github.com"
+	gitlabBaseURL = "https://gitlab.com"
+)
+
+// Init задает адреса трекеров для проектов, в которых base_url не указан, и ключ шифрования токенов
+func Init(cfg config.Issues) {
+	githubAPIURL = cfg.GitHubAPIURL
+	gitlabBaseURL = cfg.GitLabBaseURL
+	// Формат ключа проверен при загрузке конфигурации
+	tokenKey, _ = base64.StdEncoding.DecodeString(cfg.TokenKey)
+}
+
+// DefaultBaseURL возвращает адрес API трекера, если он не задан в проекте
+func DefaultBaseURL(provider string) string {
+	switch provider {
+	case ProviderGitHub:
+		return githubAPIURL
+	case ProviderGitLab:
+		return gitlabBaseURL
+	}
+	return ""
+}
+
+// NewTracker создает клиент трекера; пустой baseURL заменяется адресом по умолчанию
+func NewTracker(provider, baseURL, token string) (Tracker, error) {
+	if token == "" {
+		return nil, errors.New("issue tracker token is required")
+	}
+	if baseURL == "" {
+		baseURL = DefaultBaseURL(provider)
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	switch provider {
+	case ProviderGitHub:
+		return &GitHubClient{BaseURL: baseURL, Token: token, HTTP: client}, nil
+	case ProviderGitLab:
+		return &GitLabClient{BaseURL: baseURL, Token: token, HTTP: client}, nil
+	}
+	return nil, fmt.Errorf("unknown issue provider: %s", provider)
+}
+
+// GitHubClient работает с REST API GitHub (или GitHub Enterprise)
+type GitHubClient struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+func (g *GitHubClient) CreateIssue(ctx context.Context, repository string, issue Issue) (*Created, error) {
+	payload := map[string]interface{}{
+		"title":  issue.Title,
+		"body":   issue.Body,
+		"labels": issue.Labels,
+	}
+
+	var resp struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+		State   string `json:"state"`
+	}
+	if err := g.do(ctx, http.MethodPost, "/repos/"+repository+"/issues", payload, &resp); err != nil {
+		return nil, err
+	}
+
+	return &Created{Number: resp.Number, URL: resp.HTMLURL, State: normalizeState(resp.State)}, nil
+}
+
+func (g *GitHubClient) IssueState(ctx context.Context, repository string, number int) (string, error) {
+	var resp struct {
+		State string `json:"state"`
+	}
+	if err := g.do(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d", repository, number), nil, &resp); err != nil {
+		return "", err
+	}
+	return normalizeState(resp.State), nil
+}
+
+func (g *GitHubClient) do(ctx context.Context, method, path string, payload, out interface{}) error {
+	return doJSON(ctx, g.HTTP, method, g.BaseURL+path, payload, out, map[string]string{
+		"Authorization": "Bearer " + g.Token,
+		"Accept":        "application/vnd.github+json",
+	})
+}
+
+// GitLabClient работает с REST API GitLab v4
+type GitLabClient struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+}
+
+func (g *GitLabClient) CreateIssue(ctx context.Context, repository string, issue Issue) (*Created, error) {
+	payload := map[string]interface{}{
+		"title":       issue.Title,
+		"description": issue.Body,
+		"labels":      strings.Join(issue.Labels, ","),
+	}
+
+	var resp struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+		State  string `json:"state"`
+	}
+	if err := g.do(ctx, http.MethodPost, "/projects/"+url.PathEscape(repository)+"/issues", payload, &resp); err != nil {
+		return nil, err
+	}
+
+	return &Created{Number: resp.IID, URL: resp.WebURL, State: normalizeState(resp.State)}, nil
+}
+
+func (g *GitLabClient) IssueState(ctx context.Context, repository string, number int) (string, error) {
+	var resp struct {
+		State string `json:"state"`
+	}
+	path := fmt.Sprintf("/projects/%s/issues/%d", url.PathEscape(repository), number)
+	if err := g.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return "", err
+	}
+	return normalizeState(resp.State), nil
+}
+
+func (g *GitLabClient) do(ctx context.Context, method, path string, payload, out interface{}) error {
+	return doJSON(ctx, g.HTTP, method, g.BaseURL+"/api/v4"+path, payload, out, map[string]string{
+		"PRIVATE-TOKEN": g.Token,
+	})
+}
+
+func doJSON(ctx context.Context, client *http.Client, method, rawURL string, payload, out interface{}, headers map[string]string) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("issue tracker returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// GitLab возвращает "opened", GitHub - "open"
+func normalizeState(state string) string {
+	if state == "opened" {
+		return StateOpen
+	}
+	return state
+}
+
+// Finding - данные находки для шаблонов задачи
+type Finding struct {
+	Name           string
+	TemplateID     string
+	Severity       string
+	Host           string
+	MatchedAt      string
+	TargetURL      string
+	Description    string
+	Recommendation string
+	CurlCommand    string
+	Request        string
+	Response       string
+}
+
+// DefaultTitleTemplate используется, если в проекте не задан свой шаблон заголовка
+const DefaultTitleTemplate = `[ChimeraScan] {{.Severity}}: {{.Name}} ({{.Host}})`
+
+// Ограничение размера ответа в тексте задачи
+const maxResponseInBody = 2000
+
+const bodyTemplate = "**Уязвимость:** {{.Name}}\n" +
+	"**Шаблон Nuclei:** `{{.TemplateID}}`\n" +
+	"**Уровень риска:** {{.Severity}}\n" +
+	"**Цель:** {{.TargetURL}}\n" +
+	"**Расположение:** {{.MatchedAt}}\n" +
+	"{{if .Description}}\n### Описание\n{{.Description}}\n{{end}}" +
+	"{{if .Recommendation}}\n### Рекомендации AI\n{{.Recommendation}}\n{{end}}" +
+	"{{if .CurlCommand}}\n### CURL команда\n```\n{{.CurlCommand}}\n```\n{{end}}" +
+	"{{if .Request}}\n### Запрос\n```http\n{{.Request}}\n```\n{{end}}" +
+	"{{if .Response}}\n### Ответ\n```http\n{{.Response}}\n```\n{{end}}" +
+	"\n_Создано ChimeraScan_\n"
+
+var bodyTmpl = template.Must(template.New("body").Parse(bodyTemplate))
+
+// ValidateTitleTemplate проверяет пользовательский шаблон заголовка
+func ValidateTitleTemplate(tmpl string) error {
+	_, err := template.New("title").Parse(tmpl)
+	return err
+}
+
+// Render формирует задачу по находке
+func Render(titleTemplate string, finding Finding) (Issue, error) {
+	if titleTemplate == "" {
+		titleTemplate = DefaultTitleTemplate
+	}
+
+	titleTmpl, err := template.New("title").Parse(titleTemplate)
+	if err != nil {
+		return Issue{}, err
+	}
+
+	var title bytes.Buffer
+	if err := titleTmpl.Execute(&title, finding); err != nil {
+		return Issue{}, err
+	}
+
+	if len(finding.Response) > maxResponseInBody {
+		finding.Response = strings.ToValidUTF8(finding.Response[:maxResponseInBody], "") + "\n..."
+	}
+
+	var body bytes.Buffer
+	if err := bodyTmpl.Execute(&body, finding); err != nil {
+		return Issue{}, err
+	}
+
+	labels := []string{"chimerascan"}
+	if finding.Severity != "" {
+		labels = append(labels, "severity:"+strings.ToLower(finding.Severity))
+	}
+
+	return Issue{
+		Title:  strings.TrimSpace(title.String()),
+		Body:   body.String(),
+		Labels: labels,
+	}, nil
+}
@@ -0,0 +1,162 @@
+package issues
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitHubClient_AgainstFakeAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer gh-token", r.Header.Get("Authorization"))
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/shop/issues":
+			var payload map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&payload)
+			assert.Equal(t, "Title", payload["title"])
+			assert.Equal(t, []interface{}{"chimerascan", "severity:high"}, payload["labels"])
+
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"number":42,"html_url":"https://github.com/acme/shop/issues/42","state":"open"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/shop/issues/42":
+			w.Write([]byte(`{"number":42,"state":"closed"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tracker, err := NewTracker(ProviderGitHub, server.URL, "gh-token")
+	require.NoError(t, err)
+
+	created, err := tracker.CreateIssue(context.Background(), "acme/shop", Issue{
+		Title:  "Title",
+		Body:   "Body",
+		Labels: []string{"chimerascan", "severity:high"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 42, created.Number)
+	assert.Equal(t, "https://github.com/acme/shop/issues/42", created.URL)
+	assert.Equal(t, StateOpen, created.State)
+
+	state, err := tracker.IssueState(context.Background(), "acme/shop", 42)
+	require.NoError(t, err)
+	assert.Equal(t, StateClosed, state)
+}
+
+func TestGitLabClient_AgainstFakeAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "gl-token", r.Header.Get("PRIVATE-TOKEN"))
+
+		switch {
+		case r.Method == http.MethodPost && r.URL.EscapedPath() == "/api/v4/projects/group%2Fshop/issues":
+			var payload map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&payload)
+			assert.Equal(t, "chimerascan,severity:low", payload["labels"])
+
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"iid":7,"web_url":"https://gitlab.example.com/group/shop/-/issues/7","state":"opened"}`))
+		case r.Method == http.MethodGet && r.URL.EscapedPath() == "/api/v4/projects/group%2Fshop/issues/7":
+			w.Write([]byte(`{"iid":7,"state":"opened"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	tracker, err := NewTracker(ProviderGitLab, server.URL, "gl-token")
+	require.NoError(t, err)
+
+	created, err := tracker.CreateIssue(context.Background(), "group/shop", Issue{
+		Title:  "Title",
+		Labels: []string{"chimerascan", "severity:low"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 7, created.Number)
+	assert.Equal(t, StateOpen, created.State)
+
+	state, err := tracker.IssueState(context.Background(), "group/shop", 7)
+	require.NoError(t, err)
+	assert.Equal(t, StateOpen, state)
+}
+
+func TestTracker_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"message":"Bad credentials"}`))
+	}))
+	defer server.Close()
+
+	tracker, err := NewTracker(ProviderGitHub, server.URL, "bad")
+	require.NoError(t, err)
+
+	_, err = tracker.CreateIssue(context.Background(), "acme/shop", Issue{Title: "x"})
+	assert.ErrorContains(t, err, "Bad credentials")
+}
+
+func TestNewTracker_Validation(t *testing.T) {
+	_, err := NewTracker(ProviderGitHub, "", "")
+	assert.Error(t, err, "Token is required")
+
+	_, err = NewTracker("jira", "", "token")
+	assert.Error(t, err, "Unknown provider")
+}
+
+func TestRender(t *testing.T) {
+	finding := Finding{
+		Name:           "Exposed .git",
+		TemplateID:     "git-config",
+		Severity:       "high",
+		Host:           "example.com",
+		MatchedAt:      "https://example.com/.git/config",
+		Recommendation: "Закрыть доступ к .git",
+		CurlCommand:    "curl https://example.com/.git/config",
+	}
+
+	issue, err := Render("", finding)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[ChimeraScan] high: Exposed .git (example.com)", issue.Title)
+	assert.Contains(t, issue.Body, "Закрыть доступ к .git")
+	assert.Contains(t, issue.Body, "curl https://example.com/.git/config")
+	assert.Equal(t, []string{"chimerascan", "severity:high"}, issue.Labels)
+
+	issue, err = Render("{{.TemplateID}} @ {{.MatchedAt}}", finding)
+	require.NoError(t, err)
+	assert.Equal(t, "git-config @ https://example.com/.git/config", issue.Title)
+
+	assert.Error(t, ValidateTitleTemplate("{{.Name"))
+}
+
+func TestSealToken(t *testing.T) {
+	oldKey := tokenKey
+	defer func() { tokenKey = oldKey }()
+
+	tokenKey = nil
+	_, err := SealToken("pat")
+	assert.ErrorIs(t, err, ErrNoTokenKey)
+
+	tokenKey = make([]byte, 32)
+	sealed, err := SealToken("pat")
+	require.NoError(t, err)
+	assert.NotContains(t, sealed, "pat")
+
+	token, err := OpenToken(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "pat", token)
+
+	token, err = OpenToken("legacy-plaintext")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy-plaintext", token, "Tokens stored before encryption stay readable")
+
+	tokenKey = bytes.Repeat([]byte{1}, 32)
+	_, err = OpenToken(sealed)
+	assert.Error(t, err, "Another key cannot decrypt the token")
+}
@@ -0,0 +1,69 @@
+package issues
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Префикс зашифрованного токена в БД; значения без него сохранены до появления шифрования
+const sealedTokenPrefix = "enc:v1:"
+
+// ErrNoTokenKey возвращается, когда ключ шифрования токенов (ISSUE_TOKEN_KEY) не задан
+var ErrNoTokenKey = errors.New("issue token encryption key is not configured")
+
+// Ключ AES-256 для токенов трекеров
+var tokenKey []byte
+
+func tokenCipher() (cipher.AEAD, error) {
+	if len(tokenKey) == 0 {
+		return nil, ErrNoTokenKey
+	}
+	block, err := aes.NewCipher(tokenKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SealToken шифрует токен трекера для хранения в БД; пустой токен остается пустым
+func SealToken(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	aead, err := tokenCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(token), nil)
+	return sealedTokenPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// OpenToken расшифровывает токен из БД. Токены, сохраненные до появления шифрования,
+// возвращаются как есть и шифруются при следующей привязке репозитория
+func OpenToken(stored string) (string, error) {
+	if !strings.HasPrefix(stored, sealedTokenPrefix) {
+		return stored, nil
+	}
+	aead, err := tokenCipher()
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, sealedTokenPrefix))
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", errors.New("malformed issue token")
+	}
+	token, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt issue token: %w", err)
+	}
+	return string(token), nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"os"
 
@@ -8,31 +10,103 @@ import (
 	"github.com/joho/godotenv"
 
 	"chimerascan/auth"
+	"chimerascan/config"
 	"chimerascan/database"
 	"chimerascan/handlers"
+	"chimerascan/issues"
 	"chimerascan/middleware"
+	"chimerascan/notify"
+	"chimerascan/quota"
+	"chimerascan/rbac"
+	"chimerascan/redaction"
+	"chimerascan/retention"
+	"chimerascan/storage"
+	"chimerascan/store"
+	"chimerascan/webhooks"
 )
 
 func main() {
+	configPath := flag.String("config", os.Getenv("CHIMERASCAN_CONFIG"), "path to a YAML or TOML config file")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	noMigrate := flag.Bool("no-migrate", false, "do not apply pending database migrations at startup")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: chimerascan [flags]\n       chimerascan [flags] migrate up|down|status|version|force\n\nflags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using system environment variables")
 	}
 
-	if err := database.InitDB(); err != nil {
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *printConfig {
+		out, err := cfg.YAML()
+		if err != nil {
+			log.Fatal("Failed to print configuration:", err)
+		}
+		fmt.Print(string(out))
+		return
+	}
+
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(cfg, flag.Args()[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *noMigrate {
+		cfg.Database.AutoMigrate = false
+	}
+
+	if err := database.InitDB(cfg.Database); err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
 	defer database.CloseDB()
 
-	auth.InitOAuth()
+	auth.InitOAuth(cfg.OAuth, cfg.Server.PublicBaseURL)
+	auth.InitSessions(cfg.Sessions)
+	auth.InitAdmins(cfg.Admin.Emails)
+
+	if err := auth.InitOIDC(cfg.OIDC); err != nil {
+		log.Fatal("Failed to initialize OIDC login:", err)
+	}
+
+	if err := storage.InitReportStore(cfg.Storage); err != nil {
+		log.Fatal("Failed to initialize report storage:", err)
+	}
+
+	if err := redaction.Init(cfg.Redaction.RulesFile); err != nil {
+		log.Fatal("Failed to initialize redaction rules:", err)
+	}
+
+	webhooks.Start()
+
+	quota.Init(cfg.Quota, cfg.RateLimit)
+	retention.Init(cfg.Retention)
+	retention.Start()
+	issues.Init(cfg.Issues)
+
+	if err := notify.Init(cfg.SMTP); err != nil {
+		log.Fatal("Failed to initialize email notifications:", err)
+	}
+
+	handlers.PublicBaseURL = auth.PublicBaseURL
+	handlers.EvidenceInlineLimit = cfg.Evidence.InlineLimit
+	handlers.ScannerSettings = cfg.Scanner
+
+	server := handlers.NewServer(store.NewPostgres(database.DB))
 
-	os.MkdirAll("reports", 0755)
-	os.MkdirAll("static/reports", 0755)
 	os.MkdirAll("templates", 0755)
 
 	router := gin.Default()
 
 	router.Static("/static", "./static")
-	router.Static("/reports", "./reports")
 
 	router.LoadHTMLGlob("templates/*.html")
 
@@ -43,11 +117,13 @@ func main() {
 		public.GET("/auth/github/callback", handlers.GitHubCallback)
 		public.GET("/auth/gitlab", handlers.GitLabAuth)
 		public.GET("/auth/gitlab/callback", handlers.GitLabCallback)
+		public.GET("/auth/oidc", handlers.OIDCAuth)
+		public.GET("/auth/oidc/callback", handlers.OIDCCallback)
 		public.GET("/logout", handlers.Logout)
 	}
 
 	protected := router.Group("/")
-	protected.Use(middleware.AuthRequired())
+	protected.Use(middleware.AuthRequired(), middleware.CSRFProtect(), middleware.RateLimit())
 	{
 		protected.GET("/dashboard", handlers.DashboardPage)
 		protected.GET("/scan", handlers.ScanPage)
@@ -55,25 +131,96 @@ func main() {
 		protected.GET("/scans", handlers.ScansPage)
 		protected.GET("/project/:id", handlers.ProjectPage)
 
-		protected.POST("/api/projects", handlers.CreateProject)
-		protected.GET("/api/projects", handlers.GetProjects)
-		protected.DELETE("/api/projects/:id", handlers.DeleteProject)
-		protected.POST("/api/scan/start", handlers.StartScan)
-		protected.POST("/api/scan/stop/:id", handlers.StopScan)
-		protected.GET("/api/scan/status/:id", handlers.GetScanStatus)
-		protected.GET("/api/scans", handlers.GetScans)
-		protected.POST("/api/scans/:id/add-to-project", handlers.AddScanToProject)
-		protected.DELETE("/api/scans/:id", handlers.DeleteScan)
-		protected.GET("/api/report/:id/:format", handlers.DownloadReport)
-		protected.PUT("/api/projects/:id", handlers.UpdateProject)
-		protected.GET("/api/scans/:id/projects", handlers.GetProjectsForScan)
-	}
+		// Персональные токены ограничены областями действия
+		scanWrite := middleware.RequireScope(auth.ScopeScanWrite)
+		scanRead := middleware.RequireScope(auth.ScopeScanRead)
+		reportsRead := middleware.RequireScope(auth.ScopeReportsRead)
+		projectsAdmin := middleware.RequireScope(auth.ScopeProjectsAdmin)
+		auditRead := middleware.RequireScope(auth.ScopeAuditRead)
+		sessionOnly := middleware.SessionRequired()
+
+		// Права участников организаций на проекты и сканирования
+		projectPerm := middleware.RequireProjectPermission
+		scanPerm := middleware.RequireScanPermission
+		orgPerm := middleware.RequireOrganizationPermission
+
+		protected.POST("/api/projects", projectsAdmin, server.CreateProject)
+		protected.GET("/api/projects", projectsAdmin, server.GetProjects)
+		protected.GET("/api/projects/:id", projectsAdmin, projectPerm(rbac.PermView), server.GetProject)
+		protected.DELETE("/api/projects/:id", projectsAdmin, projectPerm(rbac.PermProjectDelete), server.DeleteProject)
+		protected.GET("/api/projects/:id/scans", scanRead, projectPerm(rbac.PermView), server.GetProjectScans)
+		protected.POST("/api/projects/:id/archive", projectsAdmin, projectPerm(rbac.PermProjectManage), server.ArchiveProject)
+		protected.POST("/api/projects/:id/unarchive", projectsAdmin, projectPerm(rbac.PermProjectManage), server.UnarchiveProject)
+		protected.POST("/api/scan/start", scanWrite, server.StartScan)
+		protected.POST("/api/scan/stop/:id", scanWrite, scanPerm(rbac.PermScanStop), server.StopScan)
+		protected.GET("/api/scan/status/:id", scanRead, scanPerm(rbac.PermView), server.GetScanStatus)
+		protected.GET("/api/scans", scanRead, server.GetScans)
+		protected.PATCH("/api/scans/:id", scanWrite, scanPerm(rbac.PermScanStart), server.UpdateScanDetails)
+		protected.POST("/api/scans/:id/rerun", scanWrite, scanPerm(rbac.PermScanStart), server.RerunScan)
+		protected.POST("/api/scans/:id/add-to-project", scanWrite, scanPerm(rbac.PermProjectManage), server.AddScanToProject)
+		protected.DELETE("/api/scans/:id", scanWrite, scanPerm(rbac.PermScanDelete), server.DeleteScan)
+		// Массовые операции; права проверяются по каждому сканированию в обработчике
+		protected.POST("/api/scans/bulk/delete", scanWrite, server.BulkDeleteScans)
+		protected.POST("/api/scans/bulk/move", scanWrite, server.BulkMoveScans)
+		protected.POST("/api/scans/bulk/cancel", scanWrite, server.BulkCancelScans)
+		protected.POST("/api/scans/bulk/rerun", scanWrite, server.BulkRerunScans)
+		protected.POST("/api/scans/bulk/export", reportsRead, server.BulkExportScans)
+		protected.GET("/api/report/:id/:format", reportsRead, scanPerm(rbac.PermView), server.DownloadReport)
+		protected.PUT("/api/projects/:id", projectsAdmin, projectPerm(rbac.PermProjectManage), server.UpdateProject)
+		protected.GET("/api/scans/:id/projects", scanRead, scanPerm(rbac.PermView), server.GetProjectsForScan)
+		protected.GET("/api/vulnerabilities/:id/evidence", scanRead, server.GetVulnerabilityEvidence)
+		protected.POST("/api/webhooks", projectsAdmin, server.CreateWebhook)
+		protected.GET("/api/webhooks", projectsAdmin, server.GetWebhooks)
+		protected.DELETE("/api/webhooks/:id", projectsAdmin, server.DeleteWebhook)
+		protected.GET("/api/webhooks/:id/deliveries", projectsAdmin, server.GetWebhookDeliveries)
+		protected.GET("/api/projects/:id/repository", projectsAdmin, projectPerm(rbac.PermView), server.GetProjectRepository)
+		protected.PUT("/api/projects/:id/repository", projectsAdmin, projectPerm(rbac.PermProjectManage), server.LinkProjectRepository)
+		protected.DELETE("/api/projects/:id/repository", projectsAdmin, projectPerm(rbac.PermProjectManage), server.UnlinkProjectRepository)
+		protected.POST("/api/projects/:id/issues", projectsAdmin, projectPerm(rbac.PermIssuesExport), server.ExportFindingsToIssues)
+		protected.PUT("/api/projects/:id/organization", projectsAdmin, projectPerm(rbac.PermProjectDelete), server.MoveProjectToOrganization)
+		protected.POST("/api/projects/:id/issues/sync", projectsAdmin, projectPerm(rbac.PermIssuesExport), server.SyncProjectIssues)
+		protected.GET("/api/projects/:id/stats", scanRead, projectPerm(rbac.PermView), server.GetProjectStats)
+		protected.GET("/api/projects/:id/retention", projectsAdmin, projectPerm(rbac.PermView), server.GetProjectRetention)
+		protected.PUT("/api/projects/:id/retention", projectsAdmin, projectPerm(rbac.PermProjectManage), server.UpdateProjectRetention)
+		protected.POST("/api/organizations", projectsAdmin, server.CreateOrganization)
+		protected.GET("/api/organizations", projectsAdmin, server.GetOrganizations)
+		protected.DELETE("/api/organizations/:id", projectsAdmin, orgPerm(rbac.PermOrgDelete), server.DeleteOrganization)
+		protected.GET("/api/organizations/:id/members", projectsAdmin, orgPerm(rbac.PermView), server.GetOrganizationMembers)
+		protected.POST("/api/organizations/:id/members", projectsAdmin, orgPerm(rbac.PermMembersManage), server.AddOrganizationMember)
+		protected.PUT("/api/organizations/:id/members/:userId", projectsAdmin, orgPerm(rbac.PermMembersManage), server.UpdateOrganizationMember)
+		protected.DELETE("/api/organizations/:id/members/:userId", projectsAdmin, orgPerm(rbac.PermView), server.RemoveOrganizationMember)
+		protected.GET("/api/organizations/:id/retention", projectsAdmin, orgPerm(rbac.PermView), server.GetOrganizationRetention)
+		protected.PUT("/api/organizations/:id/retention", projectsAdmin, orgPerm(rbac.PermProjectManage), server.UpdateOrganizationRetention)
+		protected.GET("/api/notifications/preferences", sessionOnly, server.GetNotificationPreferences)
+		protected.PUT("/api/notifications/preferences", sessionOnly, server.UpdateNotificationPreferences)
+		protected.GET("/api/sessions", sessionOnly, handlers.GetSessions)
+		protected.DELETE("/api/sessions", sessionOnly, handlers.DeleteOtherSessions)
+		protected.DELETE("/api/sessions/:id", sessionOnly, handlers.DeleteSession)
+		protected.POST("/api/tokens", sessionOnly, handlers.CreateAPIToken)
+		protected.GET("/api/tokens", sessionOnly, handlers.GetAPITokens)
+		protected.DELETE("/api/tokens/:id", sessionOnly, handlers.DeleteAPIToken)
+		protected.GET("/api/identities", sessionOnly, handlers.GetIdentities)
+		protected.DELETE("/api/identities/:id", sessionOnly, handlers.DeleteIdentity)
+		protected.GET("/api/usage", server.GetUsage)
+		protected.GET("/api/stats", scanRead, server.GetStats)
+		protected.GET("/api/audit", auditRead, server.GetAuditEvents)
+		protected.GET("/api/audit/export", auditRead, server.ExportAuditEvents)
 
-	port := os.Getenv("SERVER_PORT")
-	if port == "" {
-		port = "8080"
+		// Консоль администратора доступна только из браузера
+		admin := protected.Group("/api/admin", sessionOnly, middleware.AdminRequired())
+		admin.GET("/users", server.AdminGetUsers)
+		admin.POST("/users/:id/disable", server.AdminDisableUser)
+		admin.POST("/users/:id/enable", server.AdminEnableUser)
+		admin.DELETE("/users/:id", server.AdminDeleteUser)
+		admin.GET("/scans", server.AdminGetScans)
+		admin.POST("/scans/:id/cancel", server.AdminCancelScan)
+		admin.POST("/projects/:id/restore", server.AdminRestoreProject)
+		admin.GET("/queue", server.AdminGetQueue)
+		admin.POST("/reports/purge", server.AdminPurgeReports)
+		admin.POST("/retention/run", server.AdminRunRetention)
 	}
 
+	port := cfg.Server.Port
 	log.Printf("Server starting on http://localhost:%s", port)
 	router.Run(":" + port)
 }
@@ -2,34 +2,174 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 
 	"chimerascan/auth"
+	"chimerascan/models"
+	"chimerascan/rbac"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Токен из заголовка Authorization: Bearer
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[7:]), true
+}
+
+// API-клиенты получают JSON вместо перенаправления на страницу входа
+func wantsJSON(c *gin.Context) bool {
+	if _, ok := bearerToken(c); ok {
+		return true
+	}
+	return strings.HasPrefix(c.Request.URL.Path, "/api/")
+}
+
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if raw, ok := bearerToken(c); ok {
+			user, token, err := auth.AuthenticateAPIToken(raw)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API token"})
+				return
+			}
+
+			if user.DisabledAt != nil {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+				return
+			}
+
+			c.Set("user", user)
+			c.Set("userID", user.ID)
+			c.Set("apiToken", token)
+			c.Next()
+			return
+		}
+
 		user, err := auth.GetUserFromRequest(c)
 		if err != nil || user == nil {
+			if wantsJSON(c) {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+				return
+			}
 			c.Redirect(http.StatusFound, "/")
 			c.Abort()
 			return
 		}
 
+		if user.DisabledAt != nil {
+			auth.RevokeSession(c)
+			if wantsJSON(c) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Account disabled"})
+				return
+			}
+			c.Redirect(http.StatusFound, "/?error=account_disabled")
+			c.Abort()
+			return
+		}
+
 		c.Set("user", user)
 		c.Set("userID", user.ID)
 		c.Next()
 	}
 }
 
+// RequireScope ограничивает доступ по персональному токену областью действия.
+// Запросы с сессией браузера пропускаются без ограничений.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if value, ok := c.Get("apiToken"); ok {
+			if token, ok := value.(*models.APIToken); !ok || !auth.HasScope(token, scope) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error":          "Insufficient token scope",
+					"required_scope": scope,
+				})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// SessionRequired запрещает доступ по персональному токену: управление токенами,
+// сессиями и уведомлениями доступно только из браузера
+func SessionRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("apiToken"); ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This endpoint is not available for API tokens"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// AdminRequired пропускает только администраторов; подключается после AuthRequired
+func AdminRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, _ := c.Get("user")
+		if u, ok := user.(*models.User); !ok || !auth.IsAdmin(u) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Administrator access required"})
+			return
+		}
+		c.Next()
+	}
+}
+
 func OptionalAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user, err := auth.GetUserFromRequest(c)
-		if err == nil && user != nil {
+		if err == nil && user != nil && user.DisabledAt == nil {
 			c.Set("user", user)
 			c.Set("userID", user.ID)
 		}
 		c.Next()
 	}
 }
+
+// Ответ на ошибку проверки прав: недоступный ресурс не отличается от несуществующего
+func abortAccess(c *gin.Context, err error, notFound string) {
+	switch err {
+	case rbac.ErrNotFound:
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": notFound})
+	case rbac.ErrForbidden:
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	default:
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+	}
+}
+
+// RequireProjectPermission проверяет право на проект из параметра :id
+func RequireProjectPermission(perm rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := rbac.AuthorizeProject(c, c.Param("id"), perm); err != nil {
+			abortAccess(c, err, "Project not found")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireScanPermission проверяет право на сканирование из параметра :id
+func RequireScanPermission(perm rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := rbac.AuthorizeScan(c, c.Param("id"), perm); err != nil {
+			abortAccess(c, err, "Scan not found")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireOrganizationPermission проверяет право в организации из параметра :id
+func RequireOrganizationPermission(perm rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := rbac.AuthorizeOrganization(c, c.Param("id"), perm); err != nil {
+			abortAccess(c, err, "Organization not found")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"chimerascan/auth"
+	"chimerascan/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthRequired_APIRespondsWithJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AuthRequired())
+	router.GET("/api/scans", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/dashboard", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/scans", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Authentication required")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/dashboard", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusFound, w.Code, "Browser pages should redirect to login")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/dashboard", nil)
+	req.Header.Set("Authorization", "Bearer invalid")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "Invalid bearer token should get JSON 401")
+}
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	run := func(token *models.APIToken) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			if token != nil {
+				c.Set("apiToken", token)
+			}
+		})
+		router.POST("/api/scan/start", RequireScope(auth.ScopeScanWrite), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/api/scan/start", nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, run(nil).Code, "Browser session is not limited by scopes")
+	assert.Equal(t, http.StatusOK, run(&models.APIToken{Scopes: []string{auth.ScopeScanWrite}}).Code)
+
+	w := run(&models.APIToken{Scopes: []string{auth.ScopeScanRead}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), auth.ScopeScanWrite)
+}
+
+func TestAdminRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth.InitAdmins(nil)
+
+	run := func(user *models.User) int {
+		router := gin.New()
+		router.Use(func(c *gin.Context) { c.Set("user", user) })
+		router.GET("/api/admin/users", AdminRequired(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/admin/users", nil)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	disabledAt := time.Now()
+	assert.Equal(t, http.StatusOK, run(&models.User{IsAdmin: true}))
+	assert.Equal(t, http.StatusForbidden, run(&models.User{}))
+	assert.Equal(t, http.StatusForbidden, run(&models.User{IsAdmin: true, DisabledAt: &disabledAt}))
+
+	auth.InitAdmins([]string{"Root@Example.com", " ops@example.com"})
+	defer auth.InitAdmins(nil)
+	assert.Equal(t, http.StatusOK, run(&models.User{Email: "root@example.com"}), "Admins are bootstrapped from config")
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"chimerascan/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSRFProtect требует CSRF-токен сессии в заголовке X-CSRF-Token (или поле формы csrf_token)
+// для изменяющих запросов. Запросы с персональным токеном в Authorization не зависят от cookie
+// и пропускаются, поэтому middleware подключается после AuthRequired.
+func CSRFProtect() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		if _, ok := c.Get("apiToken"); ok {
+			c.Next()
+			return
+		}
+
+		sent := c.GetHeader(auth.CSRFHeader)
+		if sent == "" {
+			sent = c.PostForm("csrf_token")
+		}
+
+		expected := auth.CSRFToken(c)
+		if expected == "" || subtle.ConstantTimeCompare([]byte(sent), []byte(expected)) != 1 {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Invalid or missing CSRF token"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"chimerascan/auth"
+	"chimerascan/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRFProtect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	run := func(method string, setup func(req *http.Request), token *models.APIToken) int {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			if token != nil {
+				c.Set("apiToken", token)
+			}
+		}, CSRFProtect())
+		router.Handle(method, "/api/projects/1", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/api/projects/1", nil)
+		if setup != nil {
+			setup(req)
+		}
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	withSession := func(req *http.Request) {
+		req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: "session-token"})
+	}
+	validToken := func() string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("GET", "/", nil)
+		withSession(c.Request)
+		return auth.CSRFToken(c)
+	}()
+
+	assert.Equal(t, http.StatusOK, run("GET", withSession, nil), "Safe methods do not need a token")
+	assert.Equal(t, http.StatusForbidden, run("DELETE", withSession, nil), "Cookie alone is not enough")
+	assert.Equal(t, http.StatusForbidden, run("DELETE", func(req *http.Request) {
+		withSession(req)
+		req.Header.Set(auth.CSRFHeader, "forged")
+	}, nil))
+	assert.Equal(t, http.StatusOK, run("DELETE", func(req *http.Request) {
+		withSession(req)
+		req.Header.Set(auth.CSRFHeader, validToken)
+	}, nil))
+	assert.Equal(t, http.StatusForbidden, run("POST", func(req *http.Request) {
+		req.AddCookie(&http.Cookie{Name: auth.SessionCookieName, Value: "other-session"})
+		req.Header.Set(auth.CSRFHeader, validToken)
+	}, nil), "Token is bound to the session")
+	assert.Equal(t, http.StatusOK, run("POST", nil, &models.APIToken{}), "Bearer token clients are exempt")
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"chimerascan/quota"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimit ограничивает частоту запросов к API для каждого токена или пользователя.
+// Страницы интерфейса не ограничиваются.
+func RateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			c.Next()
+			return
+		}
+
+		allowed, wait := quota.API.Allow(quota.ClientKey(c))
+		if !allowed {
+			c.Header("Retry-After", quota.RetryAfterSeconds(wait))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
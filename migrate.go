@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"chimerascan/config"
+	"chimerascan/database"
+)
+
+const migrateUsage = `usage: chimerascan migrate <command>
+
+commands:
+  up [N]        apply all pending migrations or the next N
+  down [N|all]  roll back the last N migrations (default 1) or all of them
+  status        list migrations and whether each is applied
+  version       print the current schema version
+  force V       set the schema version to V and clear the dirty flag without running migrations`
+
+// runMigrate выполняет подкоманду migrate; миграции берутся из исполняемого файла
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s", migrateUsage)
+	}
+
+	if err := database.Open(cfg.Database); err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer database.CloseDB()
+
+	command, args := args[0], args[1:]
+	switch command {
+	case "up":
+		steps, err := stepsArg(args, 0)
+		if err != nil {
+			return err
+		}
+		if err := database.MigrateUp(steps); err != nil {
+			return err
+		}
+		return printVersion()
+
+	case "down":
+		steps := 1
+		if len(args) == 1 && args[0] == "all" {
+			steps = 0
+		} else if n, err := stepsArg(args, 1); err != nil {
+			return err
+		} else {
+			steps = n
+		}
+		if err := database.MigrateDown(steps); err != nil {
+			return err
+		}
+		return printVersion()
+
+	case "status":
+		states, err := database.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, state := range states {
+			mark := "pending"
+			if state.Applied {
+				mark = "applied"
+			}
+			fmt.Printf("%06d  %-8s %s\n", state.Version, mark, state.Name)
+		}
+		return printVersion()
+
+	case "version":
+		return printVersion()
+
+	case "force":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: chimerascan migrate force V")
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < -1 {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		if err := database.ForceMigrationVersion(version); err != nil {
+			return err
+		}
+		return printVersion()
+	}
+
+	return fmt.Errorf("unknown migrate command %q\n\n%s", command, migrateUsage)
+}
+
+// stepsArg разбирает необязательное число миграций
+func stepsArg(args []string, def int) (int, error) {
+	switch len(args) {
+	case 0:
+		return def, nil
+	case 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid number of migrations %q", args[0])
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("too many arguments\n\n%s", migrateUsage)
+}
+
+func printVersion() error {
+	version, dirty, err := database.MigrationVersion()
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		fmt.Println("version: none (no migrations applied)")
+		return nil
+	}
+	if dirty {
+		fmt.Printf("version: %d (dirty)\n", version)
+		return nil
+	}
+	fmt.Printf("version: %d\n", version)
+	return nil
+}
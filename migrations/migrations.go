@@ -0,0 +1,10 @@
+// Package migrations встраивает SQL-миграции в исполняемый файл,
+// поэтому приложение не зависит от рабочего каталога при запуске
+package migrations
+
+import "embed"
+
+// FS содержит каталоги postgres и sqlite с миграциями для каждого драйвера
+//
+//go:embed postgres/*.sql sqlite/*.sql
+var FS embed.FS
@@ -0,0 +1,2 @@
+ALTER TABLE vulnerabilities DROP COLUMN IF EXISTS evidence_key;
+ALTER TABLE vulnerabilities DROP COLUMN IF EXISTS response_size;
@@ -0,0 +1,21 @@
+-- Настройки уведомлений пользователя
+CREATE TABLE notification_preferences (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    email_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    email TEXT,
+    only_high BOOLEAN NOT NULL DEFAULT FALSE,
+    digest_mode VARCHAR(20) NOT NULL DEFAULT 'off',
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Итоги сканирований, ожидающие отправки в дайджесте
+CREATE TABLE notification_digest_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scan_id UUID REFERENCES scans(id) ON DELETE CASCADE,
+    summary JSONB NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    sent_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_notification_digest_items_pending ON notification_digest_items(user_id) WHERE sent_at IS NULL;
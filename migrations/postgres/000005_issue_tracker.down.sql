@@ -0,0 +1,10 @@
+ALTER TABLE vulnerabilities DROP COLUMN IF EXISTS issue_synced_at;
+ALTER TABLE vulnerabilities DROP COLUMN IF EXISTS issue_state;
+ALTER TABLE vulnerabilities DROP COLUMN IF EXISTS issue_number;
+ALTER TABLE vulnerabilities DROP COLUMN IF EXISTS issue_url;
+
+ALTER TABLE projects DROP COLUMN IF EXISTS issue_title_template;
+ALTER TABLE projects DROP COLUMN IF EXISTS issue_token;
+ALTER TABLE projects DROP COLUMN IF EXISTS issue_base_url;
+ALTER TABLE projects DROP COLUMN IF EXISTS issue_repository;
+ALTER TABLE projects DROP COLUMN IF EXISTS issue_provider;
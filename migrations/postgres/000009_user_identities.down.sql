@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_identities;
+ALTER TABLE users ADD CONSTRAINT users_provider_id_key UNIQUE (provider_id);
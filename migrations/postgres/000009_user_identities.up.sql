@@ -0,0 +1,28 @@
+-- Учетные записи внешних провайдеров; один пользователь может привязать несколько
+CREATE TABLE user_identities (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    UNIQUE(provider, subject)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+-- Для входов через OIDC провайдер известен по префиксу
+INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+SELECT id, 'oidc', SUBSTRING(provider_id FROM 6), email, created_at
+FROM users
+WHERE provider_id LIKE 'oidc:%';
+
+-- Для GitHub и GitLab провайдер не сохранялся: такие учетные записи переходят к провайдеру
+-- при первом входе с тем же идентификатором и email
+INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+SELECT id, 'legacy', provider_id, email, created_at
+FROM users
+WHERE provider_id NOT LIKE 'oidc:%';
+
+-- provider_id больше не уникален: одинаковые ID разных провайдеров не должны совпадать
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_provider_id_key;
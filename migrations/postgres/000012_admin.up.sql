@@ -0,0 +1,3 @@
+-- Администраторы и отключенные пользователи
+ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN disabled_at TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,2 @@
+ALTER TABLE scans DROP COLUMN IF EXISTS summarized_at;
+DROP TABLE IF EXISTS retention_policies;
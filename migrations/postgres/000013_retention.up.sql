@@ -0,0 +1,14 @@
+-- Правила хранения сканирований проектов и организаций; NULL - значение наследуется
+-- (проект -> организация -> конфигурация), 0 отключает правило
+CREATE TABLE retention_policies (
+    subject_type VARCHAR(20) NOT NULL CHECK (subject_type IN ('project', 'organization')),
+    subject_id UUID NOT NULL,
+    keep_scans_per_target INTEGER,
+    delete_after_days INTEGER,
+    summary_after_days INTEGER,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (subject_type, subject_id)
+);
+
+-- Время, когда у сканирования остались только итоги: отчеты и HTTP-ответы удалены
+ALTER TABLE scans ADD COLUMN summarized_at TIMESTAMP WITH TIME ZONE;
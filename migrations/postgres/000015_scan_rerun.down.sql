@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_scans_retest_of;
+ALTER TABLE scans DROP COLUMN IF EXISTS retest_of;
+ALTER TABLE scans DROP COLUMN IF EXISTS config;
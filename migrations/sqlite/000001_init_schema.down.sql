@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS vulnerabilities;
+DROP TABLE IF EXISTS scans;
+DROP TABLE IF EXISTS projects;
+DROP TABLE IF EXISTS users;
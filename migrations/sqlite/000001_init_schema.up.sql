@@ -0,0 +1,85 @@
+-- Схема SQLite повторяет схему PostgreSQL: UUID хранятся текстом, JSONB - текстом JSON,
+-- перечисления заменены проверками CHECK. Идентификаторы задает приложение.
+
+-- Таблица пользователей
+CREATE TABLE users (
+    id TEXT PRIMARY KEY NOT NULL,
+    provider_id TEXT NOT NULL,
+    email TEXT NOT NULL,
+    username TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Уникальность provider_id снимается в 000009, поэтому это индекс, а не ограничение таблицы
+CREATE UNIQUE INDEX users_provider_id_key ON users(provider_id);
+
+-- Таблица проектов
+CREATE TABLE projects (
+    id TEXT PRIMARY KEY NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Таблица сканирований
+CREATE TABLE scans (
+    id TEXT PRIMARY KEY NOT NULL,
+    target_url TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'Queued' CHECK (status IN ('Queued', 'In Progress', 'Completed', 'Failed', 'Canceled')),
+    project_id TEXT REFERENCES projects(id) ON DELETE SET NULL,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    raw_nuclei_output TEXT,
+    report_json_path TEXT,
+    report_pdf_path TEXT,
+    report_html_path TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Таблица уязвимостей
+CREATE TABLE vulnerabilities (
+    id TEXT PRIMARY KEY NOT NULL,
+    scan_id TEXT NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+    template_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    severity_ai TEXT NOT NULL,
+    description TEXT,
+    description_ru TEXT,
+    reference TEXT,
+    tags TEXT,
+    classification TEXT,
+    host TEXT NOT NULL,
+    matched_at TEXT,
+    ip TEXT,
+    timestamp TIMESTAMP,
+    curl_command TEXT,
+    request TEXT,
+    response TEXT,
+    metadata TEXT,
+    recommendation_ai TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Индексы для быстрого поиска
+CREATE INDEX idx_vulnerabilities_scan_id ON vulnerabilities(scan_id);
+CREATE INDEX idx_vulnerabilities_severity ON vulnerabilities(severity);
+CREATE INDEX idx_vulnerabilities_severity_ai ON vulnerabilities(severity_ai);
+-- Индексы для таблицы scans
+CREATE INDEX idx_scans_user_id ON scans(user_id);
+CREATE INDEX idx_scans_created_at ON scans(created_at);
+CREATE INDEX idx_scans_user_id_created_at ON scans(user_id, created_at);
+CREATE INDEX idx_scans_project_id ON scans(project_id);
+
+-- Индексы для таблицы projects
+CREATE INDEX idx_projects_user_id ON projects(user_id);
+CREATE INDEX idx_projects_created_at ON projects(created_at);
+CREATE INDEX idx_projects_user_id_created_at ON projects(user_id, created_at);
+
+-- Индекс для таблицы users
+CREATE INDEX idx_users_provider_id ON users(provider_id);
+
+-- Индекс для таблицы vulnerabilities
+CREATE INDEX idx_vulnerabilities_severity_ai_scan_id ON vulnerabilities(severity_ai, scan_id);
@@ -0,0 +1,2 @@
+ALTER TABLE vulnerabilities DROP COLUMN evidence_key;
+ALTER TABLE vulnerabilities DROP COLUMN response_size;
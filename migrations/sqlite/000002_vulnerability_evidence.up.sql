@@ -0,0 +1,3 @@
+-- Вынесенные во внешнее хранилище ответы (сжатые доказательства)
+ALTER TABLE vulnerabilities ADD COLUMN response_size INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE vulnerabilities ADD COLUMN evidence_key TEXT;
@@ -0,0 +1,21 @@
+-- Настройки уведомлений пользователя
+CREATE TABLE notification_preferences (
+    user_id TEXT PRIMARY KEY NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    email TEXT,
+    only_high BOOLEAN NOT NULL DEFAULT FALSE,
+    digest_mode TEXT NOT NULL DEFAULT 'off',
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Итоги сканирований, ожидающие отправки в дайджесте
+CREATE TABLE notification_digest_items (
+    id TEXT PRIMARY KEY NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    scan_id TEXT REFERENCES scans(id) ON DELETE CASCADE,
+    summary TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX idx_notification_digest_items_pending ON notification_digest_items(user_id) WHERE sent_at IS NULL;
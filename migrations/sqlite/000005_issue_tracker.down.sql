@@ -0,0 +1,10 @@
+ALTER TABLE vulnerabilities DROP COLUMN issue_synced_at;
+ALTER TABLE vulnerabilities DROP COLUMN issue_state;
+ALTER TABLE vulnerabilities DROP COLUMN issue_number;
+ALTER TABLE vulnerabilities DROP COLUMN issue_url;
+
+ALTER TABLE projects DROP COLUMN issue_title_template;
+ALTER TABLE projects DROP COLUMN issue_token;
+ALTER TABLE projects DROP COLUMN issue_base_url;
+ALTER TABLE projects DROP COLUMN issue_repository;
+ALTER TABLE projects DROP COLUMN issue_provider;
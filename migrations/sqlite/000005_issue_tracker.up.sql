@@ -0,0 +1,12 @@
+-- Привязка проекта к репозиторию GitHub/GitLab
+ALTER TABLE projects ADD COLUMN issue_provider TEXT;
+ALTER TABLE projects ADD COLUMN issue_repository TEXT;
+ALTER TABLE projects ADD COLUMN issue_base_url TEXT;
+ALTER TABLE projects ADD COLUMN issue_token TEXT;
+ALTER TABLE projects ADD COLUMN issue_title_template TEXT;
+
+-- Созданные по находкам задачи
+ALTER TABLE vulnerabilities ADD COLUMN issue_url TEXT;
+ALTER TABLE vulnerabilities ADD COLUMN issue_number INTEGER;
+ALTER TABLE vulnerabilities ADD COLUMN issue_state TEXT;
+ALTER TABLE vulnerabilities ADD COLUMN issue_synced_at TIMESTAMP;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_identities;
+CREATE UNIQUE INDEX users_provider_id_key ON users(provider_id);
@@ -0,0 +1,32 @@
+-- Учетные записи внешних провайдеров; один пользователь может привязать несколько
+CREATE TABLE user_identities (
+    id TEXT PRIMARY KEY NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(provider, subject)
+);
+
+CREATE INDEX idx_user_identities_user_id ON user_identities(user_id);
+
+-- Для входов через OIDC провайдер известен по префиксу; идентификатор - случайный UUID v4
+INSERT INTO user_identities (id, user_id, provider, subject, email, created_at)
+SELECT lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-'
+           || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+       id, 'oidc', substr(provider_id, 6), email, created_at
+FROM users
+WHERE provider_id LIKE 'oidc:%';
+
+-- Для GitHub и GitLab провайдер не сохранялся: такие учетные записи переходят к провайдеру
+-- при первом входе с тем же идентификатором и email
+INSERT INTO user_identities (id, user_id, provider, subject, email, created_at)
+SELECT lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-'
+           || substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))),
+       id, 'legacy', provider_id, email, created_at
+FROM users
+WHERE provider_id NOT LIKE 'oidc:%';
+
+-- provider_id больше не уникален: одинаковые ID разных провайдеров не должны совпадать
+DROP INDEX IF EXISTS users_provider_id_key;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_scans_user_created_at;
+ALTER TABLE scans DROP COLUMN report_size_bytes;
+DROP TABLE IF EXISTS quotas;
@@ -0,0 +1,16 @@
+-- Индивидуальные квоты пользователей и организаций; NULL - значение по умолчанию из конфигурации
+CREATE TABLE quotas (
+    subject_type TEXT NOT NULL CHECK (subject_type IN ('user', 'organization')),
+    subject_id TEXT NOT NULL,
+    concurrent_scans INTEGER,
+    scans_per_day INTEGER,
+    targets_per_project INTEGER,
+    report_storage_bytes INTEGER,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (subject_type, subject_id)
+);
+
+-- Суммарный размер отчетов сканирования для квоты хранилища
+ALTER TABLE scans ADD COLUMN report_size_bytes INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_scans_user_created_at ON scans(user_id, created_at);
@@ -0,0 +1,4 @@
+-- Архивные проекты доступны только для чтения; удаленные скрыты, но их сканирования
+-- остаются привязанными к проекту, чтобы проект можно было восстановить
+ALTER TABLE projects ADD COLUMN archived_at TIMESTAMP;
+ALTER TABLE projects ADD COLUMN deleted_at TIMESTAMP;
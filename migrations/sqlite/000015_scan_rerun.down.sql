@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_scans_retest_of;
+ALTER TABLE scans DROP COLUMN retest_of;
+ALTER TABLE scans DROP COLUMN config;
@@ -0,0 +1,6 @@
+-- Параметры запуска Nuclei для повторного сканирования и ссылка на сканирование,
+-- результаты которого перепроверяются
+ALTER TABLE scans ADD COLUMN config TEXT;
+ALTER TABLE scans ADD COLUMN retest_of TEXT REFERENCES scans(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_scans_retest_of ON scans(retest_of);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS scan_metadata;
+DROP TABLE IF EXISTS scan_labels;
+ALTER TABLE scans DROP COLUMN notes;
@@ -0,0 +1,19 @@
+-- Метки, заметки и метаданные сканирования (например, SHA сборки, окружение, номер задачи),
+-- по которым сканирования из CI связываются с вызвавшим их развертыванием
+ALTER TABLE scans ADD COLUMN notes TEXT NOT NULL DEFAULT '';
+
+CREATE TABLE scan_labels (
+    scan_id TEXT NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+    label TEXT NOT NULL,
+    PRIMARY KEY (scan_id, label)
+);
+
+CREATE TABLE scan_metadata (
+    scan_id TEXT NOT NULL REFERENCES scans(id) ON DELETE CASCADE,
+    key TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (scan_id, key)
+);
+
+CREATE INDEX idx_scan_labels_label ON scan_labels(label);
+CREATE INDEX idx_scan_metadata_key_value ON scan_metadata(key, value);
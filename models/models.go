@@ -1,40 +1,91 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type User struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	ProviderID string     `json:"provider_id" db:"provider_id"`
+	Email      string     `json:"email" db:"email"`
+	Username   string     `json:"username" db:"username"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	IsAdmin    bool       `json:"is_admin" db:"is_admin"`
+	DisabledAt *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+}
+
+type Identity struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	UserID    uuid.UUID `json:"-" db:"user_id"`
+	Provider  string    `json:"provider" db:"provider"`
+	Subject   string    `json:"subject" db:"subject"`
+	Email     string    `json:"email" db:"email"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type Session struct {
 	ID         uuid.UUID `json:"id" db:"id"`
-	ProviderID string    `json:"provider_id" db:"provider_id"`
-	Email      string    `json:"email" db:"email"`
-	Username   string    `json:"username" db:"username"`
+	UserID     uuid.UUID `json:"-" db:"user_id"`
+	UserAgent  string    `json:"user_agent" db:"user_agent"`
+	IPAddress  string    `json:"ip_address" db:"ip_address"`
 	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at" db:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"`
+	Current    bool      `json:"current"`
 }
 
 type Project struct {
-	ID          uuid.UUID `json:"id" db:"id"`
-	Name        string    `json:"name" db:"name"`
-	Description string    `json:"description" db:"description"`
-	UserID      uuid.UUID `json:"user_id" db:"user_id"`
-	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	ID             uuid.UUID  `json:"id" db:"id"`
+	Name           string     `json:"name" db:"name"`
+	Description    string     `json:"description" db:"description"`
+	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
+	OrganizationID *uuid.UUID `json:"organization_id" db:"organization_id"`
+	ArchivedAt     *time.Time `json:"archived_at" db:"archived_at"`
+	DeletedAt      *time.Time `json:"-" db:"deleted_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+type ProjectRepository struct {
+	ProjectID     uuid.UUID `json:"project_id" db:"id"`
+	Provider      string    `json:"provider" db:"issue_provider"`
+	Repository    string    `json:"repository" db:"issue_repository"`
+	BaseURL       string    `json:"base_url" db:"issue_base_url"`
+	Token         string    `json:"-" db:"issue_token"`
+	TitleTemplate string    `json:"title_template" db:"issue_title_template"`
 }
 
 type Scan struct {
-	ID              uuid.UUID  `json:"id" db:"id"`
-	TargetURL       string     `json:"target_url" db:"target_url"`
-	Status          string     `json:"status" db:"status"`
-	ProjectID       *uuid.UUID `json:"project_id" db:"project_id"`
-	StartedAt       *time.Time `json:"started_at" db:"started_at"`
-	FinishedAt      *time.Time `json:"finished_at" db:"finished_at"`
-	RawNucleiOutput string     `json:"raw_nuclei_output" db:"raw_nuclei_output"`
-	ReportJSONPath  string     `json:"report_json_path" db:"report_json_path"`
-	ReportPDFPath   string     `json:"report_pdf_path" db:"report_pdf_path"`
-	ReportHTMLPath  string     `json:"report_html_path" db:"report_html_path"`
-	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
-	UserID          uuid.UUID  `json:"user_id" db:"user_id"`
+	ID              uuid.UUID         `json:"id" db:"id"`
+	TargetURL       string            `json:"target_url" db:"target_url"`
+	Status          string            `json:"status" db:"status"`
+	ProjectID       *uuid.UUID        `json:"project_id" db:"project_id"`
+	StartedAt       *time.Time        `json:"started_at" db:"started_at"`
+	FinishedAt      *time.Time        `json:"finished_at" db:"finished_at"`
+	RawNucleiOutput string            `json:"raw_nuclei_output" db:"raw_nuclei_output"`
+	ReportJSONPath  string            `json:"report_json_path" db:"report_json_path"`
+	ReportPDFPath   string            `json:"report_pdf_path" db:"report_pdf_path"`
+	ReportHTMLPath  string            `json:"report_html_path" db:"report_html_path"`
+	CreatedAt       time.Time         `json:"created_at" db:"created_at"`
+	UserID          uuid.UUID         `json:"user_id" db:"user_id"`
+	Config          *ScanConfig       `json:"config,omitempty" db:"config"` // JSONB
+	RetestOf        *uuid.UUID        `json:"retest_of" db:"retest_of"`     // сканирование, результаты которого перепроверяются
+	Labels          []string          `json:"labels"`                       // scan_labels
+	Notes           string            `json:"notes" db:"notes"`
+	Metadata        map[string]string `json:"metadata"` // scan_metadata: SHA сборки, окружение, номер задачи
+
+	SeverityCounts map[string]int `json:"severity_counts,omitempty"` // число уязвимостей по критичности в списках
+}
+
+// ScanConfig - параметры запуска Nuclei, сохраненные для повторного сканирования
+type ScanConfig struct {
+	Image     string   `json:"image"`
+	RateLimit int      `json:"rate_limit"`
+	Timeout   int      `json:"timeout"`
+	Templates []string `json:"templates,omitempty"` // только эти шаблоны; пусто - все
+	Targets   []string `json:"targets,omitempty"`   // адреса вместо target_url
 }
 
 type Vulnerability struct {
@@ -56,7 +107,89 @@ type Vulnerability struct {
 	CurlCommand      string     `json:"curl_command" db:"curl_command"`
 	Request          string     `json:"request" db:"request"`
 	Response         string     `json:"response" db:"response"`
+	ResponseSize     int        `json:"response_size" db:"response_size"`
+	EvidenceKey      *string    `json:"-" db:"evidence_key"`
+	IssueURL         *string    `json:"issue_url" db:"issue_url"`
+	IssueNumber      *int       `json:"issue_number" db:"issue_number"`
+	IssueState       *string    `json:"issue_state" db:"issue_state"`
+	IssueSyncedAt    *time.Time `json:"issue_synced_at" db:"issue_synced_at"`
 	Metadata         []byte     `json:"metadata" db:"metadata"` // JSONB stored as []byte
 	RecommendationAI string     `json:"recommendation_ai" db:"recommendation_ai"`
 	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
 }
+
+type Webhook struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	UserID    uuid.UUID  `json:"user_id" db:"user_id"`
+	ProjectID *uuid.UUID `json:"project_id" db:"project_id"`
+	URL       string     `json:"url" db:"url"`
+	Secret    string     `json:"secret,omitempty" db:"secret"`
+	Events    []string   `json:"events" db:"events"` // JSONB
+	Active    bool       `json:"active" db:"active"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	WebhookID      uuid.UUID       `json:"webhook_id" db:"webhook_id"`
+	Event          string          `json:"event" db:"event"`
+	Payload        json.RawMessage `json:"payload" db:"payload"` // JSONB
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	ResponseStatus *int            `json:"response_status" db:"response_status"`
+	LastError      *string         `json:"last_error" db:"last_error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at" db:"delivered_at"`
+}
+
+type NotificationPreferences struct {
+	UserID       uuid.UUID `json:"user_id" db:"user_id"`
+	EmailEnabled bool      `json:"email_enabled" db:"email_enabled"`
+	Email        *string   `json:"email" db:"email"`
+	OnlyHigh     bool      `json:"only_high" db:"only_high"`
+	DigestMode   string    `json:"digest_mode" db:"digest_mode"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type AuditEvent struct {
+	ID             uuid.UUID              `json:"id" db:"id"`
+	ActorID        *uuid.UUID             `json:"actor_id" db:"actor_id"`
+	ActorName      string                 `json:"actor_name" db:"actor_name"`
+	ActorTokenID   *uuid.UUID             `json:"actor_token_id,omitempty" db:"actor_token_id"`
+	Action         string                 `json:"action" db:"action"`
+	TargetType     string                 `json:"target_type" db:"target_type"`
+	TargetID       string                 `json:"target_id" db:"target_id"`
+	OrganizationID *uuid.UUID             `json:"organization_id,omitempty" db:"organization_id"`
+	IPAddress      string                 `json:"ip_address" db:"ip_address"`
+	UserAgent      string                 `json:"user_agent" db:"user_agent"`
+	Details        map[string]interface{} `json:"details,omitempty" db:"details"` // JSONB
+	CreatedAt      time.Time              `json:"created_at" db:"created_at"`
+}
+
+type APIToken struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"-" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	Prefix     string     `json:"prefix" db:"token_prefix"`
+	Token      string     `json:"token,omitempty"`    // только при создании
+	Scopes     []string   `json:"scopes" db:"scopes"` // JSONB
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+}
+
+type Organization struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Name      string    `json:"name" db:"name"`
+	Role      string    `json:"role,omitempty"` // роль текущего пользователя
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+type OrganizationMember struct {
+	OrganizationID uuid.UUID `json:"organization_id" db:"organization_id"`
+	UserID         uuid.UUID `json:"user_id" db:"user_id"`
+	Username       string    `json:"username" db:"username"`
+	Email          string    `json:"email" db:"email"`
+	Role           string    `json:"role" db:"role"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+}
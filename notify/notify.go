@@ -0,0 +1,271 @@
+package notify
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+
+	"chimerascan/config"
+	"chimerascan/database"
+
+	"github.com/google/uuid"
+)
+
+// Режимы отправки уведомлений
+const (
+	DigestOff   = "off"
+	DigestDaily = "daily"
+)
+
+// Finding - находка, выделяемая в письме
+type Finding struct {
+	TemplateID string `json:"template_id"`
+	Name       string `json:"name"`
+	Host       string `json:"host"`
+	MatchedAt  string `json:"matched_at"`
+}
+
+// ScanSummary - итог сканирования для письма
+type ScanSummary struct {
+	ScanID        uuid.UUID         `json:"scan_id"`
+	TargetURL     string            `json:"target_url"`
+	FinishedAt    time.Time         `json:"finished_at"`
+	TotalCount    int               `json:"total_count"`
+	SeverityStats map[string]int    `json:"severity_stats"`
+	NewHigh       []Finding         `json:"new_high"`
+	Reports       map[string]string `json:"reports"`
+}
+
+var (
+	// Default - почтовый клиент; nil, если SMTP не настроен
+	Default *Mailer
+	// DigestInterval - период отправки дайджестов
+	DigestInterval = 24 * time.Hour
+)
+
+// Init настраивает SMTP по конфигурации. Без SMTP_HOST уведомления отключены.
+func Init(cfg config.SMTP) error {
+	if cfg.Host == "" {
+		log.Println("SMTP_HOST not set, email notifications disabled")
+		return nil
+	}
+
+	mailer, err := NewMailer(SMTPConfig{
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		From:     cfg.From,
+	})
+	if err != nil {
+		return err
+	}
+	Default = mailer
+
+	go digestLoop()
+
+	log.Println("Email notifications enabled")
+	return nil
+}
+
+// Enabled сообщает, настроена ли отправка писем
+func Enabled() bool {
+	return Default != nil
+}
+
+// ScanCompleted отправляет итог сканирования владельцу с учетом его настроек
+func ScanCompleted(userID uuid.UUID, summary ScanSummary) {
+	if !Enabled() {
+		return
+	}
+
+	var email string
+	var override sql.NullString
+	var enabled, onlyHigh bool
+	var digestMode string
+	err := database.DB.QueryRow(`
+		SELECT u.email, p.email, COALESCE(p.email_enabled, TRUE), COALESCE(p.only_high, FALSE), COALESCE(p.digest_mode, 'off')
+		FROM users u
+		LEFT JOIN notification_preferences p ON p.user_id = u.id
+		WHERE u.id = $1
+	`, userID).Scan(&email, &override, &enabled, &onlyHigh, &digestMode)
+	if err != nil {
+		log.Printf("Failed to load notification preferences: %v", err)
+		return
+	}
+
+	if !enabled || (onlyHigh && len(summary.NewHigh) == 0) {
+		return
+	}
+	if override.Valid && override.String != "" {
+		email = override.String
+	}
+
+	if digestMode == DigestDaily {
+		summaryJSON, _ := json.Marshal(summary)
+		_, err := database.DB.Exec(`
+			INSERT INTO notification_digest_items (id, user_id, scan_id, summary, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, uuid.New(), userID, summary.ScanID, string(summaryJSON), time.Now())
+		if err != nil {
+			log.Printf("Failed to queue digest item: %v", err)
+		}
+		return
+	}
+
+	subject, body, err := RenderScanSummary(summary)
+	if err != nil {
+		log.Printf("Failed to render scan summary email: %v", err)
+		return
+	}
+
+	if err := Default.Send([]string{email}, subject, body); err != nil {
+		log.Printf("Failed to send scan summary email: %v", err)
+	}
+}
+
+func digestLoop() {
+	ticker := time.NewTicker(DigestInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := SendDigests(); err != nil {
+			log.Printf("Failed to send digests: %v", err)
+		}
+	}
+}
+
+// SendDigests отправляет накопленные итоги одним письмом на пользователя
+func SendDigests() error {
+	rows, err := database.DB.Query(`
+		SELECT i.id, i.user_id, i.summary, COALESCE(NULLIF(p.email, ''), u.email)
+		FROM notification_digest_items i
+		JOIN users u ON u.id = i.user_id
+		LEFT JOIN notification_preferences p ON p.user_id = i.user_id
+		WHERE i.sent_at IS NULL
+		ORDER BY i.user_id, i.created_at
+	`)
+	if err != nil {
+		return err
+	}
+
+	type pending struct {
+		email     string
+		itemIDs   []uuid.UUID
+		summaries []ScanSummary
+	}
+	byUser := map[uuid.UUID]*pending{}
+	var order []uuid.UUID
+
+	for rows.Next() {
+		var itemID, userID uuid.UUID
+		var summaryJSON []byte
+		var email string
+		if err := rows.Scan(&itemID, &userID, &summaryJSON, &email); err != nil {
+			rows.Close()
+			return err
+		}
+
+		var summary ScanSummary
+		if err := json.Unmarshal(summaryJSON, &summary); err != nil {
+			log.Printf("Skipping malformed digest item %s: %v", itemID, err)
+			continue
+		}
+
+		p, ok := byUser[userID]
+		if !ok {
+			p = &pending{email: email}
+			byUser[userID] = p
+			order = append(order, userID)
+		}
+		p.itemIDs = append(p.itemIDs, itemID)
+		p.summaries = append(p.summaries, summary)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, userID := range order {
+		p := byUser[userID]
+
+		subject, body, err := RenderDigest(p.summaries)
+		if err != nil {
+			log.Printf("Failed to render digest for user %s: %v", userID, err)
+			continue
+		}
+		if err := Default.Send([]string{p.email}, subject, body); err != nil {
+			log.Printf("Failed to send digest to user %s: %v", userID, err)
+			continue
+		}
+
+		now := time.Now()
+		for _, itemID := range p.itemIDs {
+			if _, err := database.DB.Exec(`UPDATE notification_digest_items SET sent_at = $1 WHERE id = $2`, now, itemID); err != nil {
+				log.Printf("Failed to mark digest item as sent: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+const summaryTemplate = `Сканирование {{.TargetURL}} завершено {{.FinishedAt.Format "2006-01-02 15:04:05"}}.
+
+Всего находок: {{.TotalCount}}
+  Critical: {{index .SeverityStats "critical"}}
+  High:     {{index .SeverityStats "high"}}
+  Medium:   {{index .SeverityStats "medium"}}
+  Low:      {{index .SeverityStats "low"}}
+  Info:     {{index .SeverityStats "info"}}
+{{if .NewHigh}}
+Новые уязвимости высокого уровня риска:
+{{range .NewHigh}}  ! {{.Name}} ({{.TemplateID}}) - {{.MatchedAt}}
+{{end}}{{end}}
+Отчеты:
+{{range $format, $url := .Reports}}  {{upper $format}}: {{$url}}
+{{end}}`
+
+const digestTemplate = `Итоги сканирований ChimeraScan: {{len .}}
+{{range .}}
+----------------------------------------
+{{template "summary" .}}{{end}}`
+
+var templates = template.Must(
+	template.Must(template.New("summary").Funcs(template.FuncMap{"upper": strings.ToUpper}).Parse(summaryTemplate)).
+		New("digest").Parse(digestTemplate))
+
+// RenderScanSummary формирует тему и текст письма об одном сканировании
+func RenderScanSummary(summary ScanSummary) (string, string, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, "summary", summary); err != nil {
+		return "", "", err
+	}
+
+	subject := fmt.Sprintf("ChimeraScan: сканирование %s завершено", summary.TargetURL)
+	if len(summary.NewHigh) > 0 {
+		subject = fmt.Sprintf("ChimeraScan: %d новых high-уязвимостей на %s", len(summary.NewHigh), summary.TargetURL)
+	}
+	return subject, buf.String(), nil
+}
+
+// RenderDigest формирует тему и текст дайджеста
+func RenderDigest(summaries []ScanSummary) (string, string, error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, "digest", summaries); err != nil {
+		return "", "", err
+	}
+
+	highCount := 0
+	for _, s := range summaries {
+		highCount += len(s.NewHigh)
+	}
+
+	subject := fmt.Sprintf("ChimeraScan: дайджест (%d сканирований, %d новых high)", len(summaries), highCount)
+	return subject, buf.String(), nil
+}
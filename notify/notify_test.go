@@ -0,0 +1,159 @@
+package notify
+
+import (
+	"bufio"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type receivedMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// startFakeSMTP запускает локальный SMTP-сервер, принимающий одно письмо
+func startFakeSMTP(t *testing.T) (string, <-chan receivedMail) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	mails := make(chan receivedMail, 1)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		var mail receivedMail
+		reply("220 localhost ESMTP ready")
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			cmd := strings.ToUpper(line)
+
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL FROM:"):
+				mail.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				mail.to = append(mail.to, strings.Trim(line[len("RCPT TO:"):], "<>"))
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 End data with <CR><LF>.<CR><LF>")
+				var data strings.Builder
+				for {
+					dataLine, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if dataLine == ".\r\n" {
+						break
+					}
+					data.WriteString(dataLine)
+				}
+				mail.data = data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 Bye")
+				mails <- mail
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), mails
+}
+
+func TestMailer_SendToLocalSMTP(t *testing.T) {
+	addr, mails := startFakeSMTP(t)
+	host, port, _ := net.SplitHostPort(addr)
+
+	mailer, err := NewMailer(SMTPConfig{Host: host, Port: port, From: "scanner@example.com"})
+	require.NoError(t, err)
+
+	err = mailer.Send([]string{"dev@example.com"}, "Сканирование завершено", "Всего находок: 3")
+	require.NoError(t, err)
+
+	select {
+	case mail := <-mails:
+		assert.Equal(t, "scanner@example.com", mail.from)
+		assert.Equal(t, []string{"dev@example.com"}, mail.to)
+		assert.Contains(t, mail.data, "Subject: =?utf-8?q?")
+
+		parts := strings.SplitN(mail.data, "\r\n\r\n", 2)
+		require.Len(t, parts, 2)
+		body, _ := io.ReadAll(quotedprintable.NewReader(strings.NewReader(parts[1])))
+		assert.Contains(t, string(body), "Всего находок: 3")
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP server did not receive mail")
+	}
+}
+
+func TestNewMailer_RequiresHostAndFrom(t *testing.T) {
+	_, err := NewMailer(SMTPConfig{From: "a@b.c"})
+	assert.Error(t, err)
+
+	_, err = NewMailer(SMTPConfig{Host: "localhost"})
+	assert.Error(t, err)
+}
+
+func TestBuildMessage_RejectsHeaderInjection(t *testing.T) {
+	_, err := buildMessage("a@b.c", []string{"x@y.z\r\nBcc: evil@example.com"}, "subject", "body")
+	assert.Error(t, err)
+}
+
+func TestRenderScanSummary(t *testing.T) {
+	summary := ScanSummary{
+		ScanID:        uuid.New(),
+		TargetURL:     "https://example.com",
+		FinishedAt:    time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		TotalCount:    4,
+		SeverityStats: map[string]int{"critical": 1, "high": 1, "medium": 1, "low": 1, "info": 0},
+		NewHigh:       []Finding{{TemplateID: "CVE-2021-1234", Name: "RCE", MatchedAt: "https://example.com/x"}},
+		Reports:       map[string]string{"pdf": "http://localhost:8080/api/report/1/pdf"},
+	}
+
+	subject, body, err := RenderScanSummary(summary)
+	require.NoError(t, err)
+
+	assert.Contains(t, subject, "1 новых high")
+	assert.Contains(t, body, "Critical: 1")
+	assert.Contains(t, body, "High:     1")
+	assert.Contains(t, body, "! RCE (CVE-2021-1234) - https://example.com/x")
+	assert.Contains(t, body, "PDF: http://localhost:8080/api/report/1/pdf")
+}
+
+func TestRenderDigest(t *testing.T) {
+	summaries := []ScanSummary{
+		{TargetURL: "https://a.example.com", SeverityStats: map[string]int{}},
+		{TargetURL: "https://b.example.com", SeverityStats: map[string]int{}, NewHigh: []Finding{{Name: "SQLi"}}},
+	}
+
+	subject, body, err := RenderDigest(summaries)
+	require.NoError(t, err)
+
+	assert.Contains(t, subject, "2 сканирований, 1 новых high")
+	assert.Contains(t, body, "https://a.example.com")
+	assert.Contains(t, body, "https://b.example.com")
+}
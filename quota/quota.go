@@ -0,0 +1,231 @@
+package quota
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"chimerascan/config"
+
+	"github.com/google/uuid"
+)
+
+// Виды квот
+const (
+	ConcurrentScans   = "concurrent_scans"
+	ScansPerDay       = "scans_per_day"
+	TargetsPerProject = "targets_per_project"
+	ReportStorage     = "report_storage_bytes"
+)
+
+// Владельцы квот: личные сканирования считаются по пользователю, сканирования проектов организации - по организации
+const (
+	SubjectUser         = "user"
+	SubjectOrganization = "organization"
+)
+
+// Limits - ограничения; 0 означает отсутствие ограничения
+type Limits struct {
+	ConcurrentScans    int   `json:"concurrent_scans"`
+	ScansPerDay        int   `json:"scans_per_day"`
+	TargetsPerProject  int   `json:"targets_per_project"`
+	ReportStorageBytes int64 `json:"report_storage_bytes"`
+}
+
+// Usage - текущее потребление
+type Usage struct {
+	ConcurrentScans    int   `json:"concurrent_scans"`
+	ScansPerDay        int   `json:"scans_per_day"`
+	ReportStorageBytes int64 `json:"report_storage_bytes"`
+
+	// OldestToday - самое раннее сканирование за последние сутки; по нему считается Retry-After
+	OldestToday *time.Time `json:"-"`
+}
+
+// Subject - пользователь или организация, к которым применяются квоты
+type Subject struct {
+	Type string
+	ID   uuid.UUID
+}
+
+// ExceededError - квота исчерпана
+type ExceededError struct {
+	Quota string
+	Limit int64
+	Used  int64
+	// RetryAfter - когда квота освободится; 0, если ожиданием квоту не освободить
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("quota %s exceeded: %d of %d", e.Quota, e.Used, e.Limit)
+}
+
+var (
+	// Defaults - квоты по умолчанию, переопределяются переменными QUOTA_* и таблицей quotas
+	Defaults = Limits{
+		ConcurrentScans:    3,
+		ScansPerDay:        50,
+		TargetsPerProject:  50,
+		ReportStorageBytes: 1 << 30,
+	}
+
+	// Интервал ожидания освобождения слота для параллельных сканирований
+	concurrentRetryAfter = time.Minute
+)
+
+// Init задает квоты по умолчанию и ограничение частоты запросов из конфигурации
+func Init(limits config.Quota, rateLimit config.RateLimit) {
+	Defaults = Limits{
+		ConcurrentScans:    limits.ConcurrentScans,
+		ScansPerDay:        limits.ScansPerDay,
+		TargetsPerProject:  limits.TargetsPerProject,
+		ReportStorageBytes: limits.ReportStorageMB << 20,
+	}
+	API = NewRateLimiter(rateLimit.RPS, rateLimit.Burst)
+}
+
+// Store - данные квот: индивидуальные значения, потребление и блокировки владельцев между экземплярами сервера
+type Store interface {
+	// QuotaLimits применяет к defaults индивидуальные квоты владельца из таблицы quotas
+	QuotaLimits(ctx context.Context, subject Subject, defaults Limits) (Limits, error)
+	// QuotaUsage возвращает потребление; сканирования за сутки считаются начиная с since
+	QuotaUsage(ctx context.Context, subject Subject, since time.Time) (Usage, error)
+	// ProjectTargets возвращает число разных целей проекта и сканировалась ли в нем цель targetURL
+	ProjectTargets(ctx context.Context, projectID uuid.UUID, targetURL string) (targets int, known bool, err error)
+	// LockQuotas блокирует квоты владельцев для других экземпляров сервера до вызова unlock
+	LockQuotas(ctx context.Context, subjects []Subject) (unlock func(), err error)
+}
+
+// LimitsFor возвращает квоты с учетом индивидуальных значений из таблицы quotas
+func LimitsFor(ctx context.Context, st Store, subject Subject) (Limits, error) {
+	return st.QuotaLimits(ctx, subject, Defaults)
+}
+
+// UsageFor возвращает потребление пользователя (личные сканирования) или организации
+func UsageFor(ctx context.Context, st Store, subject Subject) (Usage, error) {
+	return st.QuotaUsage(ctx, subject, time.Now().Add(-24*time.Hour))
+}
+
+// Блокировки владельцев квот в этом процессе
+var subjectLocks sync.Map // Subject -> *sync.Mutex
+
+// LockKey - ключ advisory-блокировки PostgreSQL для владельца
+func (s Subject) LockKey() int64 {
+	h := fnv.New64a()
+	h.Write([]byte(s.Type))
+	h.Write(s.ID[:])
+	return int64(binary.BigEndian.Uint64(h.Sum(nil)))
+}
+
+// Lock блокирует квоты владельцев до вызова unlock. Проверка квоты и сохранение
+// сканирования выполняются под блокировкой, иначе параллельные запросы проходят
+// проверку до того, как любой из них сохранит сканирование.
+// Блокировка процесса дополняется блокировкой хранилища, общей для всех экземпляров сервера
+func Lock(ctx context.Context, st Store, subjects ...Subject) (unlock func(), err error) {
+	// Один порядок блокировок во всех запросах исключает взаимную блокировку
+	subjects = slices.Clone(subjects)
+	slices.SortFunc(subjects, func(a, b Subject) int {
+		if a.Type != b.Type {
+			return strings.Compare(a.Type, b.Type)
+		}
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+	subjects = slices.Compact(subjects)
+
+	var unlocks []func()
+	unlock = func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+
+	for _, subject := range subjects {
+		mu, _ := subjectLocks.LoadOrStore(subject, &sync.Mutex{})
+		mu.(*sync.Mutex).Lock()
+		unlocks = append(unlocks, mu.(*sync.Mutex).Unlock)
+	}
+
+	storeUnlock, err := st.LockQuotas(ctx, subjects)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	unlocks = append(unlocks, storeUnlock)
+	return unlock, nil
+}
+
+// CheckScanStart проверяет квоты перед запуском сканирования цели targetURL в проекте projectID.
+// Возвращает *ExceededError, если квота исчерпана.
+func CheckScanStart(ctx context.Context, st Store, subject Subject, projectID *uuid.UUID, targetURL string) error {
+	return CheckScanStartPending(ctx, st, subject, projectID, targetURL, 0)
+}
+
+// CheckScanStartPending - CheckScanStart с учетом pending сканирований того же владельца,
+// которые уже приняты к запуску (массовый перезапуск), но еще не сохранены
+func CheckScanStartPending(ctx context.Context, st Store, subject Subject, projectID *uuid.UUID, targetURL string, pending int) error {
+	limits, err := LimitsFor(ctx, st, subject)
+	if err != nil {
+		return err
+	}
+	usage, err := UsageFor(ctx, st, subject)
+	if err != nil {
+		return err
+	}
+	usage.ConcurrentScans += pending
+	usage.ScansPerDay += pending
+
+	if limits.ConcurrentScans > 0 && usage.ConcurrentScans >= limits.ConcurrentScans {
+		return &ExceededError{
+			Quota:      ConcurrentScans,
+			Limit:      int64(limits.ConcurrentScans),
+			Used:       int64(usage.ConcurrentScans),
+			RetryAfter: concurrentRetryAfter,
+		}
+	}
+
+	if limits.ScansPerDay > 0 && usage.ScansPerDay >= limits.ScansPerDay {
+		retryAfter := concurrentRetryAfter
+		if usage.OldestToday != nil {
+			retryAfter = time.Until(usage.OldestToday.Add(24 * time.Hour))
+		}
+		return &ExceededError{
+			Quota:      ScansPerDay,
+			Limit:      int64(limits.ScansPerDay),
+			Used:       int64(usage.ScansPerDay),
+			RetryAfter: retryAfter,
+		}
+	}
+
+	if limits.ReportStorageBytes > 0 && usage.ReportStorageBytes >= limits.ReportStorageBytes {
+		return &ExceededError{
+			Quota: ReportStorage,
+			Limit: limits.ReportStorageBytes,
+			Used:  usage.ReportStorageBytes,
+		}
+	}
+
+	if projectID != nil && limits.TargetsPerProject > 0 {
+		targets, known, err := st.ProjectTargets(ctx, *projectID, targetURL)
+		if err != nil {
+			return err
+		}
+
+		// Повторное сканирование уже известной цели квоту не расходует
+		if !known && targets >= limits.TargetsPerProject {
+			return &ExceededError{
+				Quota: TargetsPerProject,
+				Limit: int64(limits.TargetsPerProject),
+				Used:  int64(targets),
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,141 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter_BurstAndRefill(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(2, 3)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := limiter.Allow("user:a")
+		assert.True(t, ok, "request %d within burst", i)
+	}
+
+	ok, wait := limiter.Allow("user:a")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+	assert.Equal(t, "1", RetryAfterSeconds(wait))
+
+	// Корзины клиентов независимы
+	ok, _ = limiter.Allow("user:b")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	assert.Equal(t, 2, limiter.Remaining("user:a"))
+	ok, _ = limiter.Allow("user:a")
+	assert.True(t, ok)
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	limiter := NewRateLimiter(0, 1)
+	for i := 0; i < 5; i++ {
+		ok, _ := limiter.Allow("ip:10.0.0.1")
+		assert.True(t, ok)
+	}
+}
+
+// Хранилище квот с заданными значениями
+type fakeStore struct {
+	limits  map[Subject]Limits
+	usage   Usage
+	targets int
+	known   bool
+	err     error
+}
+
+func (f *fakeStore) QuotaLimits(ctx context.Context, subject Subject, defaults Limits) (Limits, error) {
+	if limits, ok := f.limits[subject]; ok {
+		return limits, f.err
+	}
+	return defaults, f.err
+}
+
+func (f *fakeStore) QuotaUsage(ctx context.Context, subject Subject, since time.Time) (Usage, error) {
+	return f.usage, f.err
+}
+
+func (f *fakeStore) ProjectTargets(ctx context.Context, projectID uuid.UUID, targetURL string) (int, bool, error) {
+	return f.targets, f.known, f.err
+}
+
+func (f *fakeStore) LockQuotas(ctx context.Context, subjects []Subject) (func(), error) {
+	return func() {}, f.err
+}
+
+func TestCheckScanStart_ConcurrentOverride(t *testing.T) {
+	subject := Subject{Type: SubjectOrganization, ID: uuid.New()}
+	now := time.Now()
+	st := &fakeStore{
+		limits: map[Subject]Limits{subject: {ConcurrentScans: 1}},
+		usage:  Usage{ConcurrentScans: 1, ScansPerDay: 1, OldestToday: &now},
+	}
+
+	err := CheckScanStart(context.Background(), st, subject, nil, "https://example.com")
+
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, ConcurrentScans, exceeded.Quota)
+	assert.Equal(t, int64(1), exceeded.Limit)
+	assert.Equal(t, concurrentRetryAfter, exceeded.RetryAfter)
+}
+
+func TestCheckScanStart_TargetsPerProject(t *testing.T) {
+	subject := Subject{Type: SubjectUser, ID: uuid.New()}
+	projectID := uuid.New()
+
+	t.Run("new target rejected", func(t *testing.T) {
+		st := &fakeStore{targets: Defaults.TargetsPerProject}
+
+		err := CheckScanStart(context.Background(), st, subject, &projectID, "https://example.com")
+
+		var exceeded *ExceededError
+		require.ErrorAs(t, err, &exceeded)
+		assert.Equal(t, TargetsPerProject, exceeded.Quota)
+		assert.Zero(t, exceeded.RetryAfter)
+	})
+
+	t.Run("known target allowed", func(t *testing.T) {
+		st := &fakeStore{targets: Defaults.TargetsPerProject, known: true}
+
+		assert.NoError(t, CheckScanStart(context.Background(), st, subject, &projectID, "https://example.com"))
+	})
+}
+
+func TestCheckScanStartPending(t *testing.T) {
+	oldDefaults := Defaults
+	Defaults = Limits{ConcurrentScans: 3}
+	defer func() { Defaults = oldDefaults }()
+
+	subject := Subject{Type: SubjectUser, ID: uuid.New()}
+	now := time.Now()
+	st := &fakeStore{usage: Usage{ConcurrentScans: 1, ScansPerDay: 1, OldestToday: &now}}
+
+	assert.NoError(t, CheckScanStartPending(context.Background(), st, subject, nil, "https://example.com", 1))
+
+	err := CheckScanStartPending(context.Background(), st, subject, nil, "https://example.com", 2)
+	var exceeded *ExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, ConcurrentScans, exceeded.Quota)
+	assert.Equal(t, int64(3), exceeded.Used)
+}
+
+func TestLock_ReleasesProcessLockOnStoreError(t *testing.T) {
+	subject := Subject{Type: SubjectUser, ID: uuid.New()}
+
+	_, err := Lock(context.Background(), &fakeStore{err: errors.New("connection refused")}, subject)
+	require.Error(t, err)
+
+	unlock, err := Lock(context.Background(), &fakeStore{}, subject)
+	require.NoError(t, err, "The failed attempt must not keep the subject locked")
+	unlock()
+}
@@ -0,0 +1,146 @@
+package quota
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"chimerascan/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultRate  = 10.0
+	defaultBurst = 30
+
+	// Корзины, не использовавшиеся дольше этого времени, удаляются
+	bucketIdleTTL = 10 * time.Minute
+)
+
+// API - ограничение частоты запросов к API; nil или нулевая частота отключают ограничение
+var API = NewRateLimiter(defaultRate, defaultBurst)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter - token bucket на каждого клиента: корзина вмещает burst запросов
+// и пополняется со скоростью rate запросов в секунду
+type RateLimiter struct {
+	Rate  float64
+	Burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter создает ограничитель; rate = 0 отключает ограничение
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{
+		Rate:    rate,
+		Burst:   burst,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Enabled сообщает, включено ли ограничение
+func (l *RateLimiter) Enabled() bool {
+	return l != nil && l.Rate > 0
+}
+
+// Корзина клиента с учетом пополнения к текущему моменту; вызывается под mu
+func (l *RateLimiter) refill(key string, now time.Time) *bucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		l.buckets[key] = b
+		return b
+	}
+
+	elapsed := now.Sub(b.last).Seconds()
+	b.tokens = math.Min(float64(l.Burst), b.tokens+elapsed*l.Rate)
+	b.last = now
+	return b
+}
+
+// Allow расходует один запрос клиента key. Если корзина пуста, возвращает время до появления запроса.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	if !l.Enabled() {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b := l.refill(key, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+	return false, wait
+}
+
+// Remaining возвращает число запросов, доступных клиенту прямо сейчас
+func (l *RateLimiter) Remaining(key string) int {
+	if !l.Enabled() {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.buckets[key]; !ok {
+		return l.Burst
+	}
+	return int(l.refill(key, l.now()).tokens)
+}
+
+// Удаление давно неиспользуемых корзин, чтобы карта не росла без ограничений
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketIdleTTL {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > bucketIdleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// ClientKey - ключ корзины запроса: персональный токен, пользователь или IP-адрес
+func ClientKey(c *gin.Context) string {
+	if value, ok := c.Get("apiToken"); ok {
+		if token, ok := value.(*models.APIToken); ok && token != nil {
+			return "token:" + token.ID.String()
+		}
+	}
+	if value, ok := c.Get("userID"); ok {
+		if userID, ok := value.(uuid.UUID); ok {
+			return "user:" + userID.String()
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// RetryAfterSeconds округляет ожидание вверх до целых секунд для заголовка Retry-After
+func RetryAfterSeconds(d time.Duration) string {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
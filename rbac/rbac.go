@@ -0,0 +1,275 @@
+package rbac
+
+import (
+	"database/sql"
+	"errors"
+
+	"chimerascan/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Роли участников организации
+const (
+	RoleOwner   = "owner"
+	RoleAdmin   = "admin"
+	RoleAnalyst = "analyst"
+	RoleViewer  = "viewer"
+)
+
+// Roles - роли в порядке убывания прав
+var Roles = []string{RoleOwner, RoleAdmin, RoleAnalyst, RoleViewer}
+
+// Permission - действие над проектом, сканированием или организацией
+type Permission string
+
+const (
+	// PermView - просмотр проектов, сканирований, находок и отчетов
+	PermView Permission = "view"
+	// PermScanStart - запуск сканирований в проекте
+	PermScanStart Permission = "scan:start"
+	// PermScanStop - остановка сканирований
+	PermScanStop Permission = "scan:stop"
+	// PermScanDelete - удаление сканирований
+	PermScanDelete Permission = "scan:delete"
+	// PermIssuesExport - выгрузка находок в трекер задач
+	PermIssuesExport Permission = "issues:export"
+	// PermProjectManage - изменение проекта, вебхуки, привязка репозитория, перенос сканирований
+	PermProjectManage Permission = "project:manage"
+	// PermProjectDelete - удаление проекта и перенос его в другую организацию
+	PermProjectDelete Permission = "project:delete"
+	// PermMembersManage - управление участниками организации
+	PermMembersManage Permission = "members:manage"
+	// PermOrgDelete - удаление организации
+	PermOrgDelete Permission = "org:delete"
+	// PermAuditView - просмотр журнала аудита организации
+	PermAuditView Permission = "audit:view"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleViewer:  {PermView},
+	RoleAnalyst: {PermView, PermScanStart, PermScanStop, PermIssuesExport},
+	RoleAdmin: {PermView, PermScanStart, PermScanStop, PermIssuesExport,
+		PermScanDelete, PermProjectManage, PermMembersManage, PermAuditView},
+	RoleOwner: {PermView, PermScanStart, PermScanStop, PermIssuesExport,
+		PermScanDelete, PermProjectManage, PermMembersManage, PermAuditView, PermProjectDelete, PermOrgDelete},
+}
+
+var (
+	// ErrNotFound - ресурс не существует или недоступен пользователю
+	ErrNotFound = errors.New("resource not found")
+	// ErrForbidden - роль пользователя не дает права на действие
+	ErrForbidden = errors.New("insufficient permissions")
+)
+
+// IsValidRole проверяет название роли
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Can проверяет, разрешено ли действие роли
+func Can(role string, perm Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Check возвращает ErrNotFound для пустой роли и ErrForbidden, если действие роли не разрешено
+func Check(role string, perm Permission) error {
+	if role == "" {
+		return ErrNotFound
+	}
+	if !Can(role, perm) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Условия видимости для списков; пользователь передается параметром $1.
+// Личные проекты и сканирования видны владельцу, проекты организации - ее участникам.
+const (
+	VisibleProjectsCondition = `((p.organization_id IS NULL AND p.user_id = $1)
+		OR p.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1))`
+	VisibleScansCondition = `((p.organization_id IS NULL AND s.user_id = $1)
+		OR p.organization_id IN (SELECT organization_id FROM organization_members WHERE user_id = $1))`
+)
+
+// Access - роль пользователя и организация, которой принадлежит ресурс
+type Access struct {
+	Role           string
+	OrganizationID *uuid.UUID
+}
+
+// ResolveRole возвращает роль пользователя: для личных ресурсов владелец получает роль owner,
+// для ресурсов организации используется роль участника
+func ResolveRole(ownerID, userID uuid.UUID, orgID *uuid.UUID, memberRole string) string {
+	if orgID == nil {
+		if ownerID == userID {
+			return RoleOwner
+		}
+		return ""
+	}
+	return memberRole
+}
+
+// Виды ресурсов для проверки прав и кеша в контексте запроса
+const (
+	KindProject      = "project"
+	KindScan         = "scan"
+	KindOrganization = "organization"
+)
+
+// ProjectAccess возвращает роль пользователя в проекте; пустая роль - проект недоступен или удален
+func ProjectAccess(projectID, userID uuid.UUID) (Access, error) {
+	return QueryProjectAccess(database.DB, projectID, userID)
+}
+
+// QueryProjectAccess - ProjectAccess для указанного подключения к БД
+func QueryProjectAccess(db *sql.DB, projectID, userID uuid.UUID) (Access, error) {
+	var ownerID uuid.UUID
+	var orgID *uuid.UUID
+	var memberRole sql.NullString
+
+	err := db.QueryRow(`
+		SELECT p.user_id, p.organization_id, m.role
+		FROM projects p
+		LEFT JOIN organization_members m ON m.organization_id = p.organization_id AND m.user_id = $2
+		WHERE p.id = $1 AND p.deleted_at IS NULL
+	`, projectID, userID).Scan(&ownerID, &orgID, &memberRole)
+	if err == sql.ErrNoRows {
+		return Access{}, nil
+	} else if err != nil {
+		return Access{}, err
+	}
+
+	return Access{Role: ResolveRole(ownerID, userID, orgID, memberRole.String), OrganizationID: orgID}, nil
+}
+
+// ScanAccess возвращает роль пользователя для сканирования: по проекту организации или по владельцу
+func ScanAccess(scanID, userID uuid.UUID) (Access, error) {
+	return QueryScanAccess(database.DB, scanID, userID)
+}
+
+// QueryScanAccess - ScanAccess для указанного подключения к БД
+func QueryScanAccess(db *sql.DB, scanID, userID uuid.UUID) (Access, error) {
+	var ownerID uuid.UUID
+	var orgID *uuid.UUID
+	var memberRole sql.NullString
+
+	err := db.QueryRow(`
+		SELECT s.user_id, p.organization_id, m.role
+		FROM scans s
+		LEFT JOIN projects p ON p.id = s.project_id
+		LEFT JOIN organization_members m ON m.organization_id = p.organization_id AND m.user_id = $2
+		WHERE s.id = $1
+	`, scanID, userID).Scan(&ownerID, &orgID, &memberRole)
+	if err == sql.ErrNoRows {
+		return Access{}, nil
+	} else if err != nil {
+		return Access{}, err
+	}
+
+	return Access{Role: ResolveRole(ownerID, userID, orgID, memberRole.String), OrganizationID: orgID}, nil
+}
+
+// OrganizationRole возвращает роль пользователя в организации или пустую строку
+func OrganizationRole(orgID, userID uuid.UUID) (string, error) {
+	return QueryOrganizationRole(database.DB, orgID, userID)
+}
+
+// QueryOrganizationRole - OrganizationRole для указанного подключения к БД
+func QueryOrganizationRole(db *sql.DB, orgID, userID uuid.UUID) (string, error) {
+	var role string
+	err := db.QueryRow(`
+		SELECT role FROM organization_members WHERE organization_id = $1 AND user_id = $2
+	`, orgID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+func organizationAccess(orgID, userID uuid.UUID) (Access, error) {
+	role, err := OrganizationRole(orgID, userID)
+	if err != nil {
+		return Access{}, err
+	}
+	return Access{Role: role, OrganizationID: &orgID}, nil
+}
+
+func cacheKey(kind string, id uuid.UUID) string {
+	return "rbac:" + kind + ":" + id.String()
+}
+
+// Lookup возвращает доступ пользователя к ресурсу по его идентификатору
+type Lookup func(id, userID uuid.UUID) (Access, error)
+
+// accessFor возвращает доступ пользователя запроса к ресурсу, запоминая его в контексте,
+// чтобы middleware и обработчик не выполняли запрос дважды
+func accessFor(c *gin.Context, kind, id string, lookup Lookup) (Access, error) {
+	resourceID, err := uuid.Parse(id)
+	if err != nil {
+		return Access{}, nil
+	}
+
+	key := cacheKey(kind, resourceID)
+	if cached, ok := c.Get(key); ok {
+		return cached.(Access), nil
+	}
+
+	access, err := lookup(resourceID, c.MustGet("userID").(uuid.UUID))
+	if err != nil {
+		return Access{}, err
+	}
+	c.Set(key, access)
+	return access, nil
+}
+
+// Authorize проверяет право пользователя запроса на ресурс, получая доступ через lookup.
+// Результат кешируется в контексте так же, как в AuthorizeProject и AuthorizeScan.
+func Authorize(c *gin.Context, kind, id string, perm Permission, lookup Lookup) error {
+	access, err := accessFor(c, kind, id, lookup)
+	if err != nil {
+		return err
+	}
+	return Check(access.Role, perm)
+}
+
+// AuthorizeProject проверяет право пользователя запроса на проект
+func AuthorizeProject(c *gin.Context, projectID string, perm Permission) error {
+	return Authorize(c, KindProject, projectID, perm, ProjectAccess)
+}
+
+// AuthorizeScan проверяет право пользователя запроса на сканирование
+func AuthorizeScan(c *gin.Context, scanID string, perm Permission) error {
+	return Authorize(c, KindScan, scanID, perm, ScanAccess)
+}
+
+// AuthorizeOrganization проверяет право пользователя запроса в организации
+func AuthorizeOrganization(c *gin.Context, orgID string, perm Permission) error {
+	return Authorize(c, KindOrganization, orgID, perm, organizationAccess)
+}
+
+// OrganizationRoleFor возвращает роль пользователя запроса в организации
+func OrganizationRoleFor(c *gin.Context, orgID string) (string, error) {
+	access, err := accessFor(c, KindOrganization, orgID, organizationAccess)
+	return access.Role, err
+}
+
+// CachedOrganization возвращает организацию ресурса, уже проверенного в этом запросе,
+// или nil для личных и непроверенных ресурсов
+func CachedOrganization(c *gin.Context, kind, id string) *uuid.UUID {
+	resourceID, err := uuid.Parse(id)
+	if err != nil {
+		return nil
+	}
+	if cached, ok := c.Get(cacheKey(kind, resourceID)); ok {
+		return cached.(Access).OrganizationID
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package redaction
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Placeholder подставляется вместо скрытых значений
+const Placeholder = "[REDACTED]"
+
+// Rules - набор правил скрытия чувствительных данных
+type Rules struct {
+	Headers   []string `json:"headers"`
+	Patterns  []string `json:"patterns"`
+	JSONPaths []string `json:"json_paths"`
+}
+
+// DefaultRules возвращает правила, применяемые без дополнительной настройки
+func DefaultRules() Rules {
+	return Rules{
+		Headers: []string{
+			"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie",
+			"X-Api-Key", "X-Auth-Token", "X-CSRF-Token",
+		},
+		Patterns: []string{
+			`(?i)bearer\s+[a-z0-9\-._~+/]+=*`,
+			`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`,
+			`AKIA[0-9A-Z]{16}`,
+			`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`,
+		},
+		JSONPaths: []string{
+			"$..password", "$..passwd", "$..secret", "$..token",
+			"$..access_token", "$..refresh_token", "$..api_key", "$..client_secret",
+		},
+	}
+}
+
+// Engine применяет правила скрытия к тексту запросов, ответов и curl-команд
+type Engine struct {
+	headers   *regexp.Regexp
+	patterns  []*regexp.Regexp
+	jsonPaths [][]string
+}
+
+// Default - движок, используемый при сохранении находок и формировании отчетов
+var Default = MustNew(DefaultRules())
+
+// Init настраивает движок по умолчанию. Если задан rulesFile,
+// правила из JSON-файла добавляются к стандартным.
+func Init(rulesFile string) error {
+	rules := DefaultRules()
+
+	if rulesFile != "" {
+		data, err := os.ReadFile(rulesFile)
+		if err != nil {
+			return fmt.Errorf("failed to read redaction config: %w", err)
+		}
+
+		var extra Rules
+		if err := json.Unmarshal(data, &extra); err != nil {
+			return fmt.Errorf("invalid redaction config: %w", err)
+		}
+
+		rules.Headers = append(rules.Headers, extra.Headers...)
+		rules.Patterns = append(rules.Patterns, extra.Patterns...)
+		rules.JSONPaths = append(rules.JSONPaths, extra.JSONPaths...)
+		log.Printf("Loaded redaction rules from %s", rulesFile)
+	}
+
+	engine, err := New(rules)
+	if err != nil {
+		return err
+	}
+	Default = engine
+	return nil
+}
+
+// New компилирует правила в движок
+func New(rules Rules) (*Engine, error) {
+	engine := &Engine{}
+
+	if len(rules.Headers) > 0 {
+		names := make([]string, len(rules.Headers))
+		for i, name := range rules.Headers {
+			names[i] = regexp.QuoteMeta(strings.TrimSpace(name))
+		}
+		// Значение заголовка заканчивается на конце строки или кавычке (для curl -H '...')
+		engine.headers = regexp.MustCompile(`(?im)(^|[\s'"])(` + strings.Join(names, "|") + `):[ \t]*[^'"\r\n]*`)
+	}
+
+	for _, pattern := range rules.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		engine.patterns = append(engine.patterns, re)
+	}
+
+	for _, path := range rules.JSONPaths {
+		segments, err := parseJSONPath(path)
+		if err != nil {
+			return nil, err
+		}
+		engine.jsonPaths = append(engine.jsonPaths, segments)
+	}
+
+	return engine, nil
+}
+
+// MustNew аналогичен New, но паникует при ошибке в правилах
+func MustNew(rules Rules) *Engine {
+	engine, err := New(rules)
+	if err != nil {
+		panic(err)
+	}
+	return engine
+}
+
+// Apply скрывает чувствительные данные в тексте
+func (e *Engine) Apply(text string) string {
+	if text == "" {
+		return text
+	}
+
+	if e.headers != nil {
+		text = e.headers.ReplaceAllString(text, "${1}${2}: "+Placeholder)
+	}
+
+	if len(e.jsonPaths) > 0 {
+		text = e.redactJSONBody(text)
+	}
+
+	for _, re := range e.patterns {
+		text = re.ReplaceAllString(text, Placeholder)
+	}
+
+	return text
+}
+
+// redactJSONBody скрывает поля JSON в теле HTTP-сообщения или в тексте целиком
+func (e *Engine) redactJSONBody(text string) string {
+	head, body, sep := "", text, ""
+	for _, s := range []string{"\r\n\r\n", "\n\n"} {
+		if idx := strings.Index(text, s); idx >= 0 {
+			head, body, sep = text[:idx], text[idx+len(s):], s
+			break
+		}
+	}
+
+	trimmed := strings.TrimSpace(body)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return text
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal([]byte(trimmed), &doc); err != nil {
+		return text
+	}
+
+	changed := false
+	for _, path := range e.jsonPaths {
+		if redactPath(doc, path) {
+			changed = true
+		}
+	}
+	if !changed {
+		return text
+	}
+
+	redacted, err := json.Marshal(doc)
+	if err != nil {
+		return text
+	}
+	return head + sep + string(redacted)
+}
+
+// parseJSONPath разбирает путь вида $.user.email, $.items.*.token или $..password.
+// Пустой сегмент ("..") означает рекурсивный поиск по всем уровням.
+func parseJSONPath(path string) ([]string, error) {
+	if !strings.HasPrefix(path, "$.") {
+		return nil, fmt.Errorf("invalid redaction JSON path %q: must start with $.", path)
+	}
+	segments := strings.Split(strings.TrimPrefix(path, "$."), ".")
+	if segments[len(segments)-1] == "" {
+		return nil, fmt.Errorf("invalid redaction JSON path %q", path)
+	}
+	return segments, nil
+}
+
+func redactPath(node interface{}, path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	segment := path[0]
+	if segment == "" {
+		// Рекурсивный спуск: пробуем оставшийся путь на текущем уровне и на всех вложенных
+		changed := redactPath(node, path[1:])
+		for _, child := range children(node) {
+			if redactPath(child, path) {
+				changed = true
+			}
+		}
+		return changed
+	}
+
+	changed := false
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if segment != "*" && key != segment {
+				continue
+			}
+			if len(path) == 1 {
+				v[key] = Placeholder
+				changed = true
+			} else if redactPath(child, path[1:]) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		if segment != "*" {
+			return false
+		}
+		for i, child := range v {
+			if len(path) == 1 {
+				v[i] = Placeholder
+				changed = true
+			} else if redactPath(child, path[1:]) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+func children(node interface{}) []interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		result := make([]interface{}, 0, len(v))
+		for _, child := range v {
+			result = append(result, child)
+		}
+		return result
+	case []interface{}:
+		return v
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"chimerascan/database"
+	"chimerascan/storage"
+
+	"github.com/google/uuid"
+)
+
+// Действие над сканированием по итогам проверки правил
+const (
+	keepScan = iota
+	deleteScan
+	summarizeScan
+)
+
+// Завершенное сканирование, к которому применяются правила
+type scanEntry struct {
+	ID           uuid.UUID
+	TargetURL    string
+	ProjectID    *uuid.UUID
+	OrgID        *uuid.UUID
+	UserID       uuid.UUID
+	CreatedAt    time.Time
+	SummarizedAt *time.Time
+}
+
+// Сканирования одной цели считаются отдельно в каждом проекте и у каждого пользователя для личных сканирований
+func (s scanEntry) targetKey() string {
+	if s.ProjectID != nil {
+		return "project:" + s.ProjectID.String() + ":" + s.TargetURL
+	}
+	return "user:" + s.UserID.String() + ":" + s.TargetURL
+}
+
+// Run применяет правила хранения ко всем завершенным сканированиям на момент now.
+// Записи удаляются раньше файлов: сбой оставит лишний файл, а не ссылку на удаленный
+func Run(ctx context.Context, now time.Time) (Result, error) {
+	var result Result
+
+	policies, err := loadPolicies(ctx)
+	if err != nil {
+		return result, err
+	}
+	if Defaults == (Rules{}) && len(policies) == 0 {
+		return result, nil
+	}
+
+	scans, err := loadScans(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	perTarget := map[string]int{}
+	for _, scan := range scans {
+		rules := Defaults
+		if scan.OrgID != nil {
+			rules = rules.Override(policies[Subject{Type: SubjectOrganization, ID: *scan.OrgID}])
+		}
+		if scan.ProjectID != nil {
+			rules = rules.Override(policies[Subject{Type: SubjectProject, ID: *scan.ProjectID}])
+		}
+
+		key := scan.targetKey()
+		perTarget[key]++
+
+		switch decide(rules, scan, perTarget[key], now) {
+		case deleteScan:
+			files, err := deleteScanRow(ctx, scan.ID)
+			if err != nil {
+				log.Printf("Retention: failed to delete scan %s: %v", scan.ID, err)
+				continue
+			}
+			result.ScansDeleted++
+			result.FilesDeleted += removeFiles(ctx, files)
+		case summarizeScan:
+			files, err := summarizeScanRow(ctx, scan.ID, now)
+			if err != nil {
+				log.Printf("Retention: failed to summarize scan %s: %v", scan.ID, err)
+				continue
+			}
+			result.ScansSummarized++
+			result.FilesDeleted += removeFiles(ctx, files)
+		}
+	}
+
+	return result, nil
+}
+
+// decide выбирает действие для сканирования; position - номер сканирования цели, начиная с нового
+func decide(rules Rules, scan scanEntry, position int, now time.Time) int {
+	age := now.Sub(scan.CreatedAt)
+	switch {
+	case rules.KeepScansPerTarget > 0 && position > rules.KeepScansPerTarget:
+		return deleteScan
+	case rules.DeleteAfterDays > 0 && age > days(rules.DeleteAfterDays):
+		return deleteScan
+	case rules.SummaryAfterDays > 0 && age > days(rules.SummaryAfterDays) && scan.SummarizedAt == nil:
+		return summarizeScan
+	}
+	return keepScan
+}
+
+func days(n int) time.Duration {
+	return time.Duration(n) * 24 * time.Hour
+}
+
+func loadPolicies(ctx context.Context) (map[Subject]Policy, error) {
+	rows, err := database.DB.QueryContext(ctx, `
+		SELECT subject_type, subject_id, keep_scans_per_target, delete_after_days, summary_after_days
+		FROM retention_policies
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	policies := map[Subject]Policy{}
+	for rows.Next() {
+		var subject Subject
+		var keep, deleteAfter, summaryAfter sql.NullInt64
+		if err := rows.Scan(&subject.Type, &subject.ID, &keep, &deleteAfter, &summaryAfter); err != nil {
+			return nil, err
+		}
+		policies[subject] = Policy{
+			KeepScansPerTarget: intPtr(keep),
+			DeleteAfterDays:    intPtr(deleteAfter),
+			SummaryAfterDays:   intPtr(summaryAfter),
+		}
+	}
+	return policies, rows.Err()
+}
+
+// Выполняющиеся сканирования не трогаются; порядок от новых к старым нужен для подсчета по целям
+func loadScans(ctx context.Context) ([]scanEntry, error) {
+	rows, err := database.DB.QueryContext(ctx, `
+		SELECT s.id, s.target_url, s.project_id, p.organization_id, s.user_id, s.created_at, s.summarized_at
+		FROM scans s
+		LEFT JOIN projects p ON p.id = s.project_id
+		WHERE s.status IN ('Completed', 'Failed', 'Canceled')
+		ORDER BY s.created_at DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scans []scanEntry
+	for rows.Next() {
+		var scan scanEntry
+		if err := rows.Scan(&scan.ID, &scan.TargetURL, &scan.ProjectID, &scan.OrgID, &scan.UserID,
+			&scan.CreatedAt, &scan.SummarizedAt); err != nil {
+			return nil, err
+		}
+		scans = append(scans, scan)
+	}
+	return scans, rows.Err()
+}
+
+// Ключи файлов сканирования в хранилище: отчеты и вынесенные ответы
+func scanFiles(ctx context.Context, tx *sql.Tx, scanID uuid.UUID) ([]string, error) {
+	var jsonPath, pdfPath, htmlPath sql.NullString
+	err := tx.QueryRowContext(ctx, `
+		SELECT report_json_path, report_pdf_path, report_html_path
+		FROM scans
+		WHERE id = $1
+	`, scanID).Scan(&jsonPath, &pdfPath, &htmlPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, path := range []sql.NullString{jsonPath, pdfPath, htmlPath} {
+		if path.String != "" {
+			keys = append(keys, storage.ReportKey(path.String))
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT evidence_key
+		FROM vulnerabilities
+		WHERE scan_id = $1 AND evidence_key IS NOT NULL
+	`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func deleteScanRow(ctx context.Context, scanID uuid.UUID) ([]string, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	files, err := scanFiles(ctx, tx, scanID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM scans WHERE id = $1`, scanID); err != nil {
+		return nil, err
+	}
+	return files, tx.Commit()
+}
+
+// От сканирования остаются статус, время и находки без запросов, ответов и отчетов
+func summarizeScanRow(ctx context.Context, scanID uuid.UUID, now time.Time) ([]string, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	files, err := scanFiles(ctx, tx, scanID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE vulnerabilities
+		SET request = NULL, response = NULL, curl_command = NULL, evidence_key = NULL, response_size = 0
+		WHERE scan_id = $1
+	`, scanID)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE scans
+		SET raw_nuclei_output = NULL, report_json_path = NULL, report_pdf_path = NULL, report_html_path = NULL,
+		    report_size_bytes = 0, summarized_at = $2
+		WHERE id = $1
+	`, scanID, now)
+	if err != nil {
+		return nil, err
+	}
+	return files, tx.Commit()
+}
+
+func removeFiles(ctx context.Context, keys []string) int {
+	if len(keys) == 0 || storage.Reports == nil {
+		return 0
+	}
+	return storage.DeleteAll(ctx, keys)
+}
@@ -0,0 +1,173 @@
+// Package retention удаляет старые сканирования по правилам хранения проектов и организаций.
+//
+// Правила: хранить не больше N последних сканирований одной цели, удалять сканирования
+// старше X дней и оставлять от сканирований старше Y дней только итоги - находки без
+// HTTP-запросов и ответов, без отчетов и вывода nuclei.
+package retention
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"chimerascan/config"
+	"chimerascan/database"
+
+	"github.com/google/uuid"
+)
+
+// Владельцы правил хранения
+const (
+	SubjectProject      = "project"
+	SubjectOrganization = "organization"
+)
+
+// Subject - проект или организация, для которых заданы правила
+type Subject struct {
+	Type string
+	ID   uuid.UUID
+}
+
+// Policy - правила проекта или организации; nil наследует значение
+// (проект -> организация -> конфигурация), 0 отключает правило
+type Policy struct {
+	KeepScansPerTarget *int `json:"keep_scans_per_target"`
+	DeleteAfterDays    *int `json:"delete_after_days"`
+	SummaryAfterDays   *int `json:"summary_after_days"`
+}
+
+// Rules - действующие правила; 0 отключает правило
+type Rules struct {
+	KeepScansPerTarget int `json:"keep_scans_per_target"`
+	DeleteAfterDays    int `json:"delete_after_days"`
+	SummaryAfterDays   int `json:"summary_after_days"`
+}
+
+// Override возвращает правила, переопределенные заданными в policy значениями
+func (r Rules) Override(policy Policy) Rules {
+	if policy.KeepScansPerTarget != nil {
+		r.KeepScansPerTarget = *policy.KeepScansPerTarget
+	}
+	if policy.DeleteAfterDays != nil {
+		r.DeleteAfterDays = *policy.DeleteAfterDays
+	}
+	if policy.SummaryAfterDays != nil {
+		r.SummaryAfterDays = *policy.SummaryAfterDays
+	}
+	return r
+}
+
+// Result - итог очистки
+type Result struct {
+	ScansDeleted    int `json:"scans_deleted"`
+	ScansSummarized int `json:"scans_summarized"`
+	FilesDeleted    int `json:"files_deleted"`
+}
+
+var (
+	// Defaults - правила для всех сканирований, включая личные; переопределяются таблицей retention_policies
+	Defaults Rules
+	// Interval - период запуска очистки; 0 отключает очистку
+	Interval = time.Hour
+)
+
+// Init задает правила по умолчанию и период очистки из конфигурации
+func Init(cfg config.Retention) {
+	Defaults = Rules{
+		KeepScansPerTarget: cfg.KeepScansPerTarget,
+		DeleteAfterDays:    cfg.DeleteAfterDays,
+		SummaryAfterDays:   cfg.SummaryAfterDays,
+	}
+	Interval = time.Duration(cfg.Interval)
+}
+
+// Start запускает периодическую очистку в фоне
+func Start() {
+	if Interval <= 0 {
+		log.Println("RETENTION_INTERVAL is 0, retention janitor disabled")
+		return
+	}
+
+	go janitorLoop()
+	log.Printf("Retention janitor started, interval %s", Interval)
+}
+
+func janitorLoop() {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		result, err := Run(context.Background(), time.Now())
+		if err != nil {
+			log.Printf("Retention janitor failed: %v", err)
+			continue
+		}
+		if result.ScansDeleted > 0 || result.ScansSummarized > 0 {
+			log.Printf("Retention janitor: %d scans deleted, %d summarized, %d files removed",
+				result.ScansDeleted, result.ScansSummarized, result.FilesDeleted)
+		}
+	}
+}
+
+// GetPolicy возвращает правила проекта или организации; без записи все значения наследуются
+func GetPolicy(subject Subject) (Policy, error) {
+	var policy Policy
+	var keep, deleteAfter, summaryAfter sql.NullInt64
+	err := database.DB.QueryRow(`
+		SELECT keep_scans_per_target, delete_after_days, summary_after_days
+		FROM retention_policies
+		WHERE subject_type = $1 AND subject_id = $2
+	`, subject.Type, subject.ID).Scan(&keep, &deleteAfter, &summaryAfter)
+	if err == sql.ErrNoRows {
+		return policy, nil
+	} else if err != nil {
+		return policy, err
+	}
+
+	policy.KeepScansPerTarget = intPtr(keep)
+	policy.DeleteAfterDays = intPtr(deleteAfter)
+	policy.SummaryAfterDays = intPtr(summaryAfter)
+	return policy, nil
+}
+
+// SetPolicy сохраняет правила проекта или организации
+func SetPolicy(subject Subject, policy Policy) error {
+	_, err := database.DB.Exec(`
+		INSERT INTO retention_policies (subject_type, subject_id, keep_scans_per_target, delete_after_days, summary_after_days, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (subject_type, subject_id) DO UPDATE
+		SET keep_scans_per_target = excluded.keep_scans_per_target,
+		    delete_after_days = excluded.delete_after_days,
+		    summary_after_days = excluded.summary_after_days,
+		    updated_at = excluded.updated_at
+	`, subject.Type, subject.ID, policy.KeepScansPerTarget, policy.DeleteAfterDays, policy.SummaryAfterDays, time.Now())
+	return err
+}
+
+// RulesFor возвращает действующие правила проекта: проект переопределяет организацию,
+// организация - настройки по умолчанию. orgID может быть nil
+func RulesFor(projectID uuid.UUID, orgID *uuid.UUID) (Rules, error) {
+	rules := Defaults
+	if orgID != nil {
+		policy, err := GetPolicy(Subject{Type: SubjectOrganization, ID: *orgID})
+		if err != nil {
+			return rules, err
+		}
+		rules = rules.Override(policy)
+	}
+
+	policy, err := GetPolicy(Subject{Type: SubjectProject, ID: projectID})
+	if err != nil {
+		return rules, err
+	}
+	return rules.Override(policy), nil
+}
+
+func intPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
@@ -0,0 +1,166 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chimerascan/database"
+	"chimerascan/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupDB(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	t.Cleanup(database.CloseDB)
+
+	reports, err := storage.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	oldReports := storage.Reports
+	storage.Reports = reports
+	t.Cleanup(func() { storage.Reports = oldReports })
+
+	oldDefaults := Defaults
+	t.Cleanup(func() { Defaults = oldDefaults })
+}
+
+func exec(t *testing.T, query string, args ...interface{}) {
+	_, err := database.DB.Exec(query, args...)
+	require.NoError(t, err)
+}
+
+func addUser(t *testing.T) uuid.UUID {
+	id := uuid.New()
+	exec(t, `INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, id, id.String(), "user@example.com", "user")
+	return id
+}
+
+func addProject(t *testing.T, userID uuid.UUID, orgID *uuid.UUID) uuid.UUID {
+	id := uuid.New()
+	exec(t, `INSERT INTO projects (id, name, user_id, organization_id) VALUES ($1, $2, $3, $4)`, id, "Site", userID, orgID)
+	return id
+}
+
+// addScan создает завершенное сканирование с отчетом в хранилище
+func addScan(t *testing.T, userID uuid.UUID, projectID *uuid.UUID, target string, createdAt time.Time) uuid.UUID {
+	id := uuid.New()
+	report := id.String() + ".json"
+	exec(t, `
+		INSERT INTO scans (id, target_url, status, project_id, user_id, created_at, raw_nuclei_output, report_json_path, report_size_bytes)
+		VALUES ($1, $2, 'Completed', $3, $4, $5, 'output', $6, 4)
+	`, id, target, projectID, userID, createdAt, "reports/"+report)
+	require.NoError(t, storage.Reports.Put(context.Background(), report, []byte("data"), "application/json"))
+	return id
+}
+
+func scanExists(t *testing.T, id uuid.UUID) bool {
+	var count int
+	require.NoError(t, database.DB.QueryRow(`SELECT COUNT(*) FROM scans WHERE id = $1`, id).Scan(&count))
+	return count == 1
+}
+
+func objectExists(key string) bool {
+	_, err := storage.Reports.Get(context.Background(), key)
+	return err == nil
+}
+
+func intValue(n int) *int {
+	return &n
+}
+
+func TestRun_KeepScansPerTarget(t *testing.T) {
+	setupDB(t)
+	Defaults = Rules{}
+	now := time.Now()
+
+	userID := addUser(t)
+	projectID := addProject(t, userID, nil)
+	require.NoError(t, SetPolicy(Subject{Type: SubjectProject, ID: projectID}, Policy{KeepScansPerTarget: intValue(2)}))
+
+	oldest := addScan(t, userID, &projectID, "https://a.example.com", now.Add(-3*time.Hour))
+	middle := addScan(t, userID, &projectID, "https://a.example.com", now.Add(-2*time.Hour))
+	newest := addScan(t, userID, &projectID, "https://a.example.com", now.Add(-time.Hour))
+	other := addScan(t, userID, &projectID, "https://b.example.com", now.Add(-4*time.Hour))
+	personal := addScan(t, userID, nil, "https://a.example.com", now.Add(-5*time.Hour))
+
+	result, err := Run(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, Result{ScansDeleted: 1, FilesDeleted: 1}, result)
+
+	assert.False(t, scanExists(t, oldest))
+	assert.False(t, objectExists(oldest.String()+".json"), "Report files are removed with the scan")
+	for _, id := range []uuid.UUID{middle, newest, other, personal} {
+		assert.True(t, scanExists(t, id))
+	}
+}
+
+func TestRun_DeleteAndSummarizeByAge(t *testing.T) {
+	setupDB(t)
+	Defaults = Rules{DeleteAfterDays: 90}
+	now := time.Now()
+
+	userID := addUser(t)
+	orgID := uuid.New()
+	exec(t, `INSERT INTO organizations (id, name) VALUES ($1, $2)`, orgID, "Acme")
+	projectID := addProject(t, userID, &orgID)
+	require.NoError(t, SetPolicy(Subject{Type: SubjectOrganization, ID: orgID}, Policy{DeleteAfterDays: intValue(30), SummaryAfterDays: intValue(7)}))
+
+	expired := addScan(t, userID, &projectID, "https://a.example.com", now.AddDate(0, 0, -40))
+	old := addScan(t, userID, &projectID, "https://a.example.com", now.AddDate(0, 0, -10))
+	recent := addScan(t, userID, &projectID, "https://a.example.com", now.AddDate(0, 0, -1))
+	personal := addScan(t, userID, nil, "https://a.example.com", now.AddDate(0, 0, -40))
+
+	evidenceKey := "evidence/" + old.String() + "/response.gz"
+	require.NoError(t, storage.Reports.Put(context.Background(), evidenceKey, []byte("gz"), "application/gzip"))
+	vulnID := uuid.New()
+	exec(t, `
+		INSERT INTO vulnerabilities (id, scan_id, template_id, name, severity, severity_ai, host, request, response, evidence_key, response_size)
+		VALUES ($1, $2, 'xss', 'XSS', 'high', 'high', 'a.example.com', 'GET /', 'HTTP/1.1 200 OK', $3, 100000)
+	`, vulnID, old, evidenceKey)
+
+	result, err := Run(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, Result{ScansDeleted: 1, ScansSummarized: 1, FilesDeleted: 3}, result)
+
+	assert.False(t, scanExists(t, expired))
+	assert.True(t, scanExists(t, personal), "Personal scans follow the 90 day default")
+	assert.True(t, objectExists(recent.String()+".json"))
+
+	var name string
+	var response *string
+	require.NoError(t, database.DB.QueryRow(`SELECT name, response FROM vulnerabilities WHERE id = $1`, vulnID).Scan(&name, &response))
+	assert.Equal(t, "XSS", name, "Findings survive as a summary")
+	assert.Nil(t, response)
+	assert.False(t, objectExists(evidenceKey))
+
+	var reportPath *string
+	var summarizedAt database.NullTime
+	require.NoError(t, database.DB.QueryRow(`SELECT report_json_path, summarized_at FROM scans WHERE id = $1`, old).Scan(&reportPath, &summarizedAt))
+	assert.Nil(t, reportPath)
+	assert.True(t, summarizedAt.Valid)
+
+	result, err = Run(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, Result{}, result, "Summarized scans are not processed again")
+}
+
+func TestRulesFor_ProjectOverridesOrganization(t *testing.T) {
+	setupDB(t)
+	Defaults = Rules{KeepScansPerTarget: 10, DeleteAfterDays: 365}
+
+	orgID, projectID := uuid.New(), uuid.New()
+	require.NoError(t, SetPolicy(Subject{Type: SubjectOrganization, ID: orgID}, Policy{DeleteAfterDays: intValue(30), SummaryAfterDays: intValue(7)}))
+	require.NoError(t, SetPolicy(Subject{Type: SubjectProject, ID: projectID}, Policy{SummaryAfterDays: intValue(0)}))
+
+	rules, err := RulesFor(projectID, &orgID)
+	require.NoError(t, err)
+	assert.Equal(t, Rules{KeepScansPerTarget: 10, DeleteAfterDays: 30, SummaryAfterDays: 0}, rules)
+
+	require.NoError(t, SetPolicy(Subject{Type: SubjectProject, ID: projectID}, Policy{KeepScansPerTarget: intValue(3)}))
+	rules, err = RulesFor(projectID, nil)
+	require.NoError(t, err)
+	assert.Equal(t, Rules{KeepScansPerTarget: 3, DeleteAfterDays: 365}, rules)
+}
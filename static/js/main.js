@@ -1,3 +1,25 @@
+const CSRF_SAFE_METHODS = ['GET', 'HEAD', 'OPTIONS'];
+
+function getCSRFToken() {
+    const meta = document.querySelector('meta[name="csrf-token"]');
+    return meta ? meta.getAttribute('content') : '';
+}
+
+// Все изменяющие запросы к своему серверу получают CSRF-токен сессии
+const nativeFetch = window.fetch.bind(window);
+window.fetch = function(resource, options = {}) {
+    const method = (options.method || (resource instanceof Request ? resource.method : 'GET')).toUpperCase();
+    const url = new URL(resource instanceof Request ? resource.url : resource, window.location.href);
+
+    if (!CSRF_SAFE_METHODS.includes(method) && url.origin === window.location.origin) {
+        const headers = new Headers(options.headers || (resource instanceof Request ? resource.headers : undefined));
+        headers.set('X-CSRF-Token', getCSRFToken());
+        options = { ...options, headers };
+    }
+
+    return nativeFetch(resource, options);
+};
+
 document.addEventListener('DOMContentLoaded', function() {
     console.log('ChimeraScan UI initialized');
     
@@ -303,9 +325,26 @@ if (document.querySelector('.scan-form')) {
     window.scanManager = new ScanManager();
 }
 
+// Загружает все страницы списка, переходя по next_cursor
+async function fetchAllItems(url) {
+    const separator = url.includes('?') ? '&' : '?';
+    let items = [];
+    let cursor = null;
+    do {
+        const pageUrl = `${url}${separator}limit=200` + (cursor ? `&cursor=${encodeURIComponent(cursor)}` : '');
+        const response = await fetch(pageUrl);
+        if (!response.ok) {
+            throw new Error(`HTTP error! status: ${response.status}`);
+        }
+        const page = await response.json();
+        items = items.concat(page.items);
+        cursor = page.next_cursor;
+    } while (cursor);
+    return items;
+}
+
 function loadProjects() {
-    return fetch('/api/projects')
-        .then(response => response.json())
+    return fetchAllItems('/api/projects')
         .catch(error => {
             console.error('Error loading projects:', error);
             return [];
@@ -313,8 +352,7 @@ function loadProjects() {
 }
 
 function loadProjectScans(projectId) {
-    return fetch(`/api/projects/${projectId}/scans`)
-        .then(response => response.json())
+    return fetchAllItems(`/api/projects/${projectId}/scans`)
         .catch(error => {
             console.error('Error loading project scans:', error);
             return [];
@@ -350,8 +388,7 @@ function deleteProject(projectId) {
 }
 
 function loadScans() {
-    return fetch('/api/scans')
-        .then(response => response.json())
+    return fetchAllItems('/api/scans')
         .catch(error => {
             console.error('Error loading scans:', error);
             return [];
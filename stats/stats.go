@@ -0,0 +1,260 @@
+// Package stats считает сводную статистику по сканированиям и находкам для панелей.
+//
+// Находка - уязвимость с одним шаблоном, хостом и адресом на одной цели, сколько бы
+// сканирований ее ни нашли. Находка открыта, если ее нашло последнее завершенное
+// сканирование цели, и исправлена, если более позднее сканирование ее уже не нашло.
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"chimerascan/database"
+	"chimerascan/rbac"
+
+	"github.com/google/uuid"
+)
+
+// TopLimit - число строк в рейтингах хостов и категорий
+const TopLimit = 10
+
+// Scope - набор сканирований, по которым считается статистика
+type Scope struct {
+	// condition - условие над scans s и projects p с параметром $1
+	condition string
+	id        uuid.UUID
+}
+
+// ForUser - сканирования, видимые пользователю: личные и проектов его организаций
+func ForUser(userID uuid.UUID) Scope {
+	return Scope{condition: rbac.VisibleScansCondition, id: userID}
+}
+
+// ForProject - сканирования проекта
+func ForProject(projectID uuid.UUID) Scope {
+	return Scope{condition: `s.project_id = $1`, id: projectID}
+}
+
+// SeverityCount - число находок одной критичности
+type SeverityCount struct {
+	Total int `json:"total"`
+	Open  int `json:"open"`
+	Fixed int `json:"fixed"`
+}
+
+// WeekCount - число сканирований за неделю, начинающуюся в понедельник Week
+type WeekCount struct {
+	Week  string `json:"week"`
+	Scans int    `json:"scans"`
+}
+
+// HostCount - находки на хосте
+type HostCount struct {
+	Host     string `json:"host"`
+	Findings int    `json:"findings"`
+	Open     int    `json:"open"`
+}
+
+// CategoryCount - находки с тегом шаблона
+type CategoryCount struct {
+	Category string `json:"category"`
+	Findings int    `json:"findings"`
+}
+
+// Stats - статистика за период
+type Stats struct {
+	From          time.Time                `json:"from"`
+	To            time.Time                `json:"to"`
+	Scans         int                      `json:"scans"`
+	Findings      SeverityCount            `json:"findings"`
+	BySeverity    map[string]SeverityCount `json:"by_severity"`
+	MTTRHours     *float64                 `json:"mean_time_to_remediate_hours"`
+	ScansPerWeek  []WeekCount              `json:"scans_per_week"`
+	TopHosts      []HostCount              `json:"top_hosts"`
+	TopCategories []CategoryCount          `json:"top_categories"`
+}
+
+// Общая часть запросов по находкам: завершенные сканирования периода, находки и последнее сканирование каждой цели
+func (scope Scope) findingsCTE() string {
+	return `
+		WITH scoped AS (
+			SELECT s.id, s.target_url, s.created_at
+			FROM scans s
+			LEFT JOIN projects p ON p.id = s.project_id
+			WHERE ` + scope.condition + `
+			  AND s.status = 'Completed' AND s.created_at >= $2 AND s.created_at < $3
+		),
+		findings AS (
+			SELECT sc.target_url, v.template_id, v.host, COALESCE(v.matched_at, '') AS matched_at,
+			       LOWER(MAX(v.severity)) AS severity,
+			       MIN(sc.created_at) AS first_seen, MAX(sc.created_at) AS last_seen
+			FROM vulnerabilities v
+			JOIN scoped sc ON sc.id = v.scan_id
+			GROUP BY sc.target_url, v.template_id, v.host, COALESCE(v.matched_at, '')
+		),
+		latest AS (
+			SELECT target_url, MAX(created_at) AS last_scan
+			FROM scoped
+			GROUP BY target_url
+		)`
+}
+
+// Query считает статистику по сканированиям scope, созданным в [from, to)
+func Query(ctx context.Context, scope Scope, from, to time.Time) (*Stats, error) {
+	stats := &Stats{
+		From:          from,
+		To:            to,
+		BySeverity:    map[string]SeverityCount{},
+		ScansPerWeek:  []WeekCount{},
+		TopHosts:      []HostCount{},
+		TopCategories: []CategoryCount{},
+	}
+
+	for _, step := range []func(context.Context, Scope, *Stats) error{
+		querySeverity, queryMTTR, queryScansPerWeek, queryTopHosts, queryTopCategories,
+	} {
+		if err := step(ctx, scope, stats); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+func querySeverity(ctx context.Context, scope Scope, stats *Stats) error {
+	rows, err := database.DB.QueryContext(ctx, scope.findingsCTE()+`
+		SELECT f.severity, COUNT(*), SUM(CASE WHEN f.last_seen = l.last_scan THEN 1 ELSE 0 END)
+		FROM findings f
+		JOIN latest l ON l.target_url = f.target_url
+		GROUP BY f.severity
+	`, scope.id, stats.From, stats.To)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var severity string
+		var count SeverityCount
+		if err := rows.Scan(&severity, &count.Total, &count.Open); err != nil {
+			return err
+		}
+		count.Fixed = count.Total - count.Open
+		stats.BySeverity[severity] = count
+
+		stats.Findings.Total += count.Total
+		stats.Findings.Open += count.Open
+		stats.Findings.Fixed += count.Fixed
+	}
+	return rows.Err()
+}
+
+// Время исправления - от первого обнаружения до первого сканирования цели, которое находку уже не нашло
+func queryMTTR(ctx context.Context, scope Scope, stats *Stats) error {
+	var seconds sql.NullFloat64
+	err := database.DB.QueryRowContext(ctx, scope.findingsCTE()+`,
+		fixed AS (
+			SELECT f.first_seen,
+			       (SELECT MIN(sc.created_at) FROM scoped sc
+			        WHERE sc.target_url = f.target_url AND sc.created_at > f.last_seen) AS fixed_at
+			FROM findings f
+		)
+		SELECT AVG(`+database.SecondsBetween("first_seen", "fixed_at")+`)
+		FROM fixed
+		WHERE fixed_at IS NOT NULL
+	`, scope.id, stats.From, stats.To).Scan(&seconds)
+	if err != nil {
+		return err
+	}
+
+	if seconds.Valid {
+		hours := seconds.Float64 / 3600
+		stats.MTTRHours = &hours
+	}
+	return nil
+}
+
+// Сканирования считаются в любом статусе, включая выполняющиеся и неудачные
+func queryScansPerWeek(ctx context.Context, scope Scope, stats *Stats) error {
+	week := database.WeekStart("s.created_at")
+	rows, err := database.DB.QueryContext(ctx, `
+		SELECT `+week+` AS week, COUNT(*)
+		FROM scans s
+		LEFT JOIN projects p ON p.id = s.project_id
+		WHERE `+scope.condition+` AND s.created_at >= $2 AND s.created_at < $3
+		GROUP BY `+week+`
+		ORDER BY week
+	`, scope.id, stats.From, stats.To)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var count WeekCount
+		if err := rows.Scan(&count.Week, &count.Scans); err != nil {
+			return err
+		}
+		stats.ScansPerWeek = append(stats.ScansPerWeek, count)
+		stats.Scans += count.Scans
+	}
+	return rows.Err()
+}
+
+func queryTopHosts(ctx context.Context, scope Scope, stats *Stats) error {
+	rows, err := database.DB.QueryContext(ctx, scope.findingsCTE()+`
+		SELECT f.host, COUNT(*) AS findings, SUM(CASE WHEN f.last_seen = l.last_scan THEN 1 ELSE 0 END)
+		FROM findings f
+		JOIN latest l ON l.target_url = f.target_url
+		GROUP BY f.host
+		ORDER BY findings DESC, f.host
+		LIMIT $4
+	`, scope.id, stats.From, stats.To, TopLimit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var count HostCount
+		if err := rows.Scan(&count.Host, &count.Findings, &count.Open); err != nil {
+			return err
+		}
+		stats.TopHosts = append(stats.TopHosts, count)
+	}
+	return rows.Err()
+}
+
+// Категории - теги шаблонов nuclei (xss, sqli, cve, misconfig...); находка учитывается в каждом своем теге
+func queryTopCategories(ctx context.Context, scope Scope, stats *Stats) error {
+	rows, err := database.DB.QueryContext(ctx, `
+		WITH scoped AS (
+			SELECT s.id, s.target_url
+			FROM scans s
+			LEFT JOIN projects p ON p.id = s.project_id
+			WHERE `+scope.condition+`
+			  AND s.status = 'Completed' AND s.created_at >= $2 AND s.created_at < $3
+		)
+		SELECT LOWER(t.value) AS category,
+		       COUNT(DISTINCT sc.target_url || '|' || v.template_id || '|' || v.host || '|' || COALESCE(v.matched_at, '')) AS findings
+		FROM vulnerabilities v
+		JOIN scoped sc ON sc.id = v.scan_id
+		`+database.JSONArrayJoin("v.tags", "t")+`
+		GROUP BY LOWER(t.value)
+		ORDER BY findings DESC, category
+		LIMIT $4
+	`, scope.id, stats.From, stats.To, TopLimit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var count CategoryCount
+		if err := rows.Scan(&count.Category, &count.Findings); err != nil {
+			return err
+		}
+		stats.TopCategories = append(stats.TopCategories, count)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,94 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"chimerascan/database"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func exec(t *testing.T, query string, args ...interface{}) {
+	_, err := database.DB.Exec(query, args...)
+	require.NoError(t, err)
+}
+
+func addUser(t *testing.T) uuid.UUID {
+	id := uuid.New()
+	exec(t, `INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, id, id.String(), "user@example.com", "user")
+	return id
+}
+
+func addScan(t *testing.T, userID uuid.UUID, projectID *uuid.UUID, target, status string, createdAt time.Time) uuid.UUID {
+	id := uuid.New()
+	exec(t, `
+		INSERT INTO scans (id, target_url, status, project_id, user_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, id, target, status, projectID, userID, createdAt)
+	return id
+}
+
+func addFinding(t *testing.T, scanID uuid.UUID, templateID, severity, host, tags string) {
+	exec(t, `
+		INSERT INTO vulnerabilities (id, scan_id, template_id, name, severity, severity_ai, host, matched_at, tags)
+		VALUES ($1, $2, $3, $3, $4, $4, $5, $6, $7)
+	`, uuid.New(), scanID, templateID, severity, host, "https://"+host+"/", tags)
+}
+
+func TestQuery(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	now := time.Date(2026, 3, 18, 12, 0, 0, 0, time.UTC) // среда
+	userID, otherID := addUser(t), addUser(t)
+	projectID := uuid.New()
+	exec(t, `INSERT INTO projects (id, name, user_id) VALUES ($1, $2, $3)`, projectID, "Site", userID)
+
+	// Y исправлена вторым сканированием через 10 дней, X остается открытой
+	first := addScan(t, userID, &projectID, "https://a.example.com", "Completed", now.AddDate(0, 0, -20))
+	addFinding(t, first, "xss-reflected", "High", "a.example.com", `["xss","dast"]`)
+	addFinding(t, first, "sqli-error", "medium", "a.example.com", `["sqli","dast"]`)
+	second := addScan(t, userID, &projectID, "https://a.example.com", "Completed", now.AddDate(0, 0, -10))
+	addFinding(t, second, "xss-reflected", "high", "a.example.com", `["xss","dast"]`)
+	addScan(t, userID, &projectID, "https://a.example.com", "Failed", now.AddDate(0, 0, -9))
+
+	personal := addScan(t, userID, nil, "https://b.example.com", "Completed", now.AddDate(0, 0, -2))
+	addFinding(t, personal, "git-config", "low", "b.example.com", `null`)
+
+	foreign := addScan(t, otherID, nil, "https://c.example.com", "Completed", now.AddDate(0, 0, -2))
+	addFinding(t, foreign, "git-config", "critical", "c.example.com", `["exposure"]`)
+
+	ctx := context.Background()
+	result, err := Query(ctx, ForProject(projectID), now.AddDate(0, 0, -30), now)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, result.Scans)
+	assert.Equal(t, SeverityCount{Total: 2, Open: 1, Fixed: 1}, result.Findings)
+	assert.Equal(t, map[string]SeverityCount{
+		"high":   {Total: 1, Open: 1},
+		"medium": {Total: 1, Fixed: 1},
+	}, result.BySeverity)
+	require.NotNil(t, result.MTTRHours)
+	assert.InDelta(t, 240, *result.MTTRHours, 0.01)
+	// воскресенье 8 марта относится к неделе со 2 марта, понедельник 9 марта начинает новую
+	assert.Equal(t, []WeekCount{{"2026-02-23", 1}, {"2026-03-02", 1}, {"2026-03-09", 1}}, result.ScansPerWeek)
+	assert.Equal(t, []HostCount{{Host: "a.example.com", Findings: 2, Open: 1}}, result.TopHosts)
+	assert.Equal(t, []CategoryCount{{"dast", 2}, {"sqli", 1}, {"xss", 1}}, result.TopCategories)
+
+	result, err = Query(ctx, ForUser(userID), now.AddDate(0, 0, -30), now)
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Scans, "Other users' scans stay hidden")
+	assert.Equal(t, SeverityCount{Total: 3, Open: 2, Fixed: 1}, result.Findings)
+	assert.NotContains(t, result.BySeverity, "critical")
+
+	result, err = Query(ctx, ForUser(userID), now.AddDate(0, 0, -5), now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Scans)
+	assert.Equal(t, SeverityCount{Total: 1, Open: 1}, result.Findings)
+	assert.Nil(t, result.MTTRHours, "Nothing was fixed in the period")
+	assert.Empty(t, result.TopCategories)
+}
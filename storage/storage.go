@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path/filepath"
+	"time"
+
+	"chimerascan/config"
+)
+
+var (
+	// ErrNotFound возвращается, если объект отчета отсутствует в хранилище
+	ErrNotFound = errors.New("report object not found")
+	// ErrInvalidKey возвращается для ключей, выходящих за пределы хранилища
+	ErrInvalidKey = errors.New("invalid report key")
+)
+
+// ReportStore описывает хранилище файлов отчетов
+type ReportStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete удаляет объект; ErrNotFound, если его уже нет
+	Delete(ctx context.Context, key string) error
+}
+
+// URLSigner реализуется хранилищами, умеющими выдавать временные ссылки на скачивание
+type URLSigner interface {
+	SignedURL(key string, ttl time.Duration) (string, error)
+}
+
+// Reports - хранилище отчетов, выбранное при запуске приложения
+var Reports ReportStore
+
+// InitReportStore создает хранилище отчетов по конфигурации
+func InitReportStore(cfg config.Storage) error {
+	backend := cfg.Backend
+
+	switch backend {
+	case "", "local":
+		store, err := NewLocalStore(cfg.ReportsDir)
+		if err != nil {
+			return err
+		}
+		Reports = store
+	case "s3":
+		store, err := NewS3Store(S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			PathStyle: cfg.S3.PathStyle,
+		})
+		if err != nil {
+			return err
+		}
+		Reports = store
+	default:
+		return fmt.Errorf("unknown REPORT_STORAGE backend: %s", backend)
+	}
+
+	log.Printf("Report storage initialized: %s", backendName(backend))
+	return nil
+}
+
+func backendName(backend string) string {
+	if backend == "" {
+		return "local"
+	}
+	return backend
+}
+
+// ReportKey переводит сохраненный в БД путь отчета в ключ хранилища.
+// Старые записи содержат путь вида reports/<файл>.
+func ReportKey(path string) string {
+	return filepath.Base(filepath.ToSlash(path))
+}
+
+// DeleteAll удаляет объекты из хранилища отчетов, пропуская пустые ключи и уже удаленные объекты.
+// Возвращает число действительно удаленных объектов; остальные ошибки только записываются в журнал
+func DeleteAll(ctx context.Context, keys []string) int {
+	var removed int
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		switch err := Reports.Delete(ctx, key); err {
+		case nil:
+			removed++
+		case ErrNotFound:
+		default:
+			log.Printf("Failed to delete report object %s: %v", key, err)
+		}
+	}
+	return removed
+}
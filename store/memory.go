@@ -0,0 +1,1195 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"chimerascan/models"
+	"chimerascan/quota"
+	"chimerascan/rbac"
+
+	"github.com/google/uuid"
+)
+
+// Memory - реализация хранилищ в памяти процесса.
+// Повторяет поведение PostgreSQL: видимость по организациям, каскадное удаление
+// уязвимостей и пометку удаленных проектов. Используется в тестах.
+type Memory struct {
+	mu              sync.RWMutex
+	users           map[uuid.UUID]models.User
+	organizations   map[uuid.UUID]models.Organization
+	members         map[uuid.UUID]map[uuid.UUID]string // организация -> пользователь -> роль
+	memberSince     map[membership]time.Time
+	projects        map[uuid.UUID]models.Project
+	scans           map[uuid.UUID]models.Scan
+	vulnerabilities map[uuid.UUID]models.Vulnerability
+	reportSizes     map[uuid.UUID]int64 // сканирование -> размер отчетов
+	quotas          map[quota.Subject]quota.Limits
+	webhooks        map[uuid.UUID]models.Webhook
+	deliveries      map[uuid.UUID][]models.WebhookDelivery // подписка -> доставки
+	notifications   map[uuid.UUID]models.NotificationPreferences
+	repositories    map[uuid.UUID]models.ProjectRepository // проект -> привязка к трекеру задач
+}
+
+var _ Store = (*Memory)(nil)
+
+// membership - участие пользователя в организации
+type membership struct {
+	orgID, userID uuid.UUID
+}
+
+// NewMemory создает пустое хранилище в памяти
+func NewMemory() *Memory {
+	return &Memory{
+		users:           map[uuid.UUID]models.User{},
+		organizations:   map[uuid.UUID]models.Organization{},
+		members:         map[uuid.UUID]map[uuid.UUID]string{},
+		memberSince:     map[membership]time.Time{},
+		projects:        map[uuid.UUID]models.Project{},
+		scans:           map[uuid.UUID]models.Scan{},
+		vulnerabilities: map[uuid.UUID]models.Vulnerability{},
+		reportSizes:     map[uuid.UUID]int64{},
+		quotas:          map[quota.Subject]quota.Limits{},
+		webhooks:        map[uuid.UUID]models.Webhook{},
+		deliveries:      map[uuid.UUID][]models.WebhookDelivery{},
+		notifications:   map[uuid.UUID]models.NotificationPreferences{},
+		repositories:    map[uuid.UUID]models.ProjectRepository{},
+	}
+}
+
+// AddUser добавляет пользователя
+func (m *Memory) AddUser(user models.User) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.users[user.ID] = user
+}
+
+// AddMember добавляет пользователя в организацию с указанной ролью
+func (m *Memory) AddMember(orgID, userID uuid.UUID, role string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.members[orgID] == nil {
+		m.members[orgID] = map[uuid.UUID]string{}
+	}
+	m.members[orgID][userID] = role
+	m.memberSince[membership{orgID, userID}] = time.Now()
+}
+
+// SetQuotaLimits задает индивидуальные квоты владельца вместо квот по умолчанию
+func (m *Memory) SetQuotaLimits(subject quota.Subject, limits quota.Limits) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.quotas[subject] = limits
+}
+
+// AddWebhookDelivery добавляет запись в журнал доставок подписки
+func (m *Memory) AddWebhookDelivery(delivery models.WebhookDelivery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[delivery.WebhookID] = append(m.deliveries[delivery.WebhookID], delivery)
+}
+
+func (m *Memory) GetUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (m *Memory) ListUsers(ctx context.Context) ([]UserSummary, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	users := []UserSummary{}
+	for _, user := range m.users {
+		summary := UserSummary{User: user}
+		for _, scan := range m.scans {
+			if scan.UserID == user.ID {
+				summary.ScanCount++
+			}
+		}
+		users = append(users, summary)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if !users[i].CreatedAt.Equal(users[j].CreatedAt) {
+			return users[i].CreatedAt.Before(users[j].CreatedAt)
+		}
+		return users[i].ID.String() < users[j].ID.String()
+	})
+	return users, nil
+}
+
+func (m *Memory) DisableUser(ctx context.Context, id uuid.UUID, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if user.DisabledAt == nil {
+		user.DisabledAt = &at
+		m.users[id] = user
+	}
+	return nil
+}
+
+func (m *Memory) EnableUser(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.DisabledAt = nil
+	m.users[id] = user
+	return nil
+}
+
+func (m *Memory) DeleteUser(ctx context.Context, id uuid.UUID) (DeletedUser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted DeletedUser
+	if _, ok := m.users[id]; !ok {
+		return deleted, ErrNotFound
+	}
+	for _, roles := range m.members {
+		if roles[id] == rbac.RoleOwner && m.countOwners(roles) == 1 {
+			return deleted, ErrLastOwner
+		}
+	}
+
+	// Проекты и сканирования в организациях переходят к другому владельцу организации
+	for projectID, project := range m.projects {
+		if project.UserID == id && project.OrganizationID != nil {
+			project.UserID = m.organizationHeir(*project.OrganizationID, id)
+			m.projects[projectID] = project
+		}
+	}
+	for scanID, scan := range m.scans {
+		if orgID := m.scanOrganization(scan); scan.UserID == id && orgID != nil {
+			scan.UserID = m.organizationHeir(*orgID, id)
+			m.scans[scanID] = scan
+		}
+	}
+
+	var scanIDs []uuid.UUID
+	for scanID, scan := range m.scans {
+		if scan.UserID != id {
+			continue
+		}
+		scanIDs = append(scanIDs, scanID)
+		if scan.Status == "Queued" || scan.Status == "In Progress" {
+			deleted.ActiveScans = append(deleted.ActiveScans, scanID)
+		}
+		for _, path := range []string{scan.ReportJSONPath, scan.ReportPDFPath, scan.ReportHTMLPath} {
+			if path != "" {
+				deleted.Files.ReportPaths = append(deleted.Files.ReportPaths, path)
+			}
+		}
+	}
+	for vulnID, vuln := range m.vulnerabilities {
+		if slices.Contains(scanIDs, vuln.ScanID) {
+			if vuln.EvidenceKey != nil {
+				deleted.Files.EvidenceKeys = append(deleted.Files.EvidenceKeys, *vuln.EvidenceKey)
+			}
+			delete(m.vulnerabilities, vulnID)
+		}
+	}
+	for _, scanID := range scanIDs {
+		delete(m.scans, scanID)
+		delete(m.reportSizes, scanID)
+	}
+
+	// ON DELETE CASCADE для личных проектов и участия в организациях
+	for projectID, project := range m.projects {
+		if project.UserID == id {
+			m.deleteProjectRecord(projectID)
+		}
+	}
+	for orgID, roles := range m.members {
+		delete(roles, id)
+		delete(m.memberSince, membership{orgID, id})
+	}
+	for webhookID, webhook := range m.webhooks {
+		if webhook.UserID == id {
+			m.deleteWebhookRecord(webhookID)
+		}
+	}
+	delete(m.notifications, id)
+	delete(m.users, id)
+	return deleted, nil
+}
+
+// organizationHeir повторяет выбор нового владельца из Postgres: владелец организации,
+// кроме userID, первый по времени вступления
+func (m *Memory) organizationHeir(orgID, userID uuid.UUID) uuid.UUID {
+	var heir uuid.UUID
+	var since time.Time
+	for memberID, role := range m.members[orgID] {
+		if role != rbac.RoleOwner || memberID == userID {
+			continue
+		}
+		joined := m.memberSince[membership{orgID, memberID}]
+		if heir == uuid.Nil || joined.Before(since) || (joined.Equal(since) && memberID.String() < heir.String()) {
+			heir, since = memberID, joined
+		}
+	}
+	return heir
+}
+
+// Число владельцев среди участников организации
+func (m *Memory) countOwners(roles map[uuid.UUID]string) int {
+	owners := 0
+	for _, role := range roles {
+		if role == rbac.RoleOwner {
+			owners++
+		}
+	}
+	return owners
+}
+
+func (m *Memory) OrganizationRole(ctx context.Context, orgID, userID uuid.UUID) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.members[orgID][userID], nil
+}
+
+// Роль участника организации; для личных ресурсов - пустая строка
+func (m *Memory) memberRole(orgID *uuid.UUID, userID uuid.UUID) string {
+	if orgID == nil {
+		return ""
+	}
+	return m.members[*orgID][userID]
+}
+
+func (m *Memory) FindUser(ctx context.Context, login string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if id, err := uuid.Parse(login); err == nil {
+		if user, ok := m.users[id]; ok {
+			return &user, nil
+		}
+		return nil, ErrNotFound
+	}
+
+	var found []models.User
+	for _, user := range m.users {
+		if user.Username == login || user.Email == login {
+			found = append(found, user)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		return &found[0], nil
+	default:
+		return nil, ErrAmbiguous
+	}
+}
+
+func (m *Memory) CreateOrganization(ctx context.Context, org *models.Organization, ownerID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := *org
+	stored.Role = ""
+	m.organizations[org.ID] = stored
+	m.members[org.ID] = map[uuid.UUID]string{ownerID: rbac.RoleOwner}
+	m.memberSince[membership{org.ID, ownerID}] = org.CreatedAt
+	return nil
+}
+
+func (m *Memory) ListOrganizations(ctx context.Context, userID uuid.UUID) ([]models.Organization, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	organizations := []models.Organization{}
+	for id, org := range m.organizations {
+		if role := m.members[id][userID]; role != "" {
+			org.Role = role
+			organizations = append(organizations, org)
+		}
+	}
+	sort.Slice(organizations, func(i, j int) bool {
+		if organizations[i].Name != organizations[j].Name {
+			return organizations[i].Name < organizations[j].Name
+		}
+		return organizations[i].ID.String() < organizations[j].ID.String()
+	})
+	return organizations, nil
+}
+
+func (m *Memory) DeleteOrganization(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.organizations[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.organizations, id)
+	for userID := range m.members[id] {
+		delete(m.memberSince, membership{id, userID})
+	}
+	delete(m.members, id)
+
+	// ON DELETE CASCADE для проектов организации
+	for projectID, project := range m.projects {
+		if project.OrganizationID != nil && *project.OrganizationID == id {
+			m.deleteProjectRecord(projectID)
+		}
+	}
+	return nil
+}
+
+// Удаление записи проекта: сканирования остаются без проекта (ON DELETE SET NULL),
+// подписки на проект удаляются (ON DELETE CASCADE)
+func (m *Memory) deleteProjectRecord(id uuid.UUID) {
+	delete(m.projects, id)
+	delete(m.repositories, id)
+	for scanID, scan := range m.scans {
+		if scan.ProjectID != nil && *scan.ProjectID == id {
+			scan.ProjectID = nil
+			m.scans[scanID] = scan
+		}
+	}
+	for webhookID, webhook := range m.webhooks {
+		if webhook.ProjectID != nil && *webhook.ProjectID == id {
+			m.deleteWebhookRecord(webhookID)
+		}
+	}
+}
+
+// Удаление подписки вместе с журналом доставок
+func (m *Memory) deleteWebhookRecord(id uuid.UUID) {
+	delete(m.webhooks, id)
+	delete(m.deliveries, id)
+}
+
+func (m *Memory) ListOrganizationMembers(ctx context.Context, orgID uuid.UUID) ([]models.OrganizationMember, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	members := []models.OrganizationMember{}
+	for userID, role := range m.members[orgID] {
+		user, ok := m.users[userID]
+		if !ok {
+			continue
+		}
+		members = append(members, models.OrganizationMember{
+			OrganizationID: orgID,
+			UserID:         userID,
+			Username:       user.Username,
+			Email:          user.Email,
+			Role:           role,
+			CreatedAt:      m.memberSince[membership{orgID, userID}],
+		})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Username != members[j].Username {
+			return members[i].Username < members[j].Username
+		}
+		return members[i].UserID.String() < members[j].UserID.String()
+	})
+	return members, nil
+}
+
+func (m *Memory) AddOrganizationMember(ctx context.Context, member models.OrganizationMember) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.members[member.OrganizationID][member.UserID] != "" {
+		return ErrExists
+	}
+	if m.members[member.OrganizationID] == nil {
+		m.members[member.OrganizationID] = map[uuid.UUID]string{}
+	}
+	m.members[member.OrganizationID][member.UserID] = member.Role
+	m.memberSince[membership{member.OrganizationID, member.UserID}] = member.CreatedAt
+	return nil
+}
+
+// keepOwner повторяет проверку последнего владельца из Postgres
+func (m *Memory) keepOwner(orgID, userID uuid.UUID) error {
+	role := m.members[orgID][userID]
+	if role == "" {
+		return ErrNotFound
+	}
+	if role == rbac.RoleOwner && m.countOwners(m.members[orgID]) == 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (m *Memory) UpdateOrganizationMember(ctx context.Context, orgID, userID uuid.UUID, role string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.members[orgID][userID] == "" {
+		return ErrNotFound
+	}
+	if role != rbac.RoleOwner {
+		if err := m.keepOwner(orgID, userID); err != nil {
+			return err
+		}
+	}
+	m.members[orgID][userID] = role
+	return nil
+}
+
+func (m *Memory) RemoveOrganizationMember(ctx context.Context, orgID, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.keepOwner(orgID, userID); err != nil {
+		return err
+	}
+	delete(m.members[orgID], userID)
+	delete(m.memberSince, membership{orgID, userID})
+	for webhookID, webhook := range m.webhooks {
+		if webhook.UserID != userID || webhook.ProjectID == nil {
+			continue
+		}
+		if project, ok := m.projects[*webhook.ProjectID]; ok && project.OrganizationID != nil && *project.OrganizationID == orgID {
+			m.deleteWebhookRecord(webhookID)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) CreateProject(ctx context.Context, project *models.Project) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.projects[project.ID] = *project
+	return nil
+}
+
+func (m *Memory) GetProject(ctx context.Context, id uuid.UUID) (*models.Project, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	project, ok := m.projects[id]
+	if !ok || project.DeletedAt != nil {
+		return nil, ErrNotFound
+	}
+	return &project, nil
+}
+
+func (m *Memory) ListProjects(ctx context.Context, userID uuid.UUID, query ProjectQuery) (Page[models.Project], error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var projects []models.Project
+	for _, project := range m.projects {
+		if project.DeletedAt != nil || (project.ArchivedAt != nil) != query.Archived {
+			continue
+		}
+		if query.Name != "" && !strings.Contains(strings.ToLower(project.Name), strings.ToLower(query.Name)) {
+			continue
+		}
+		if project.OrganizationID == nil && project.UserID == userID || m.memberRole(project.OrganizationID, userID) != "" {
+			projects = append(projects, project)
+		}
+	}
+
+	return paginate(projects, func(project models.Project) (string, uuid.UUID) {
+		return ProjectSortValue(project, query.Sort.Field), project.ID
+	}, query.Sort, query.After, query.Limit), nil
+}
+
+// paginate сортирует записи по ключу и возвращает страницу после курсора
+func paginate[T any](items []T, key func(T) (string, uuid.UUID), sort Sort, after *Cursor, limit int) Page[T] {
+	less := func(a, b T) int {
+		av, aid := key(a)
+		bv, bid := key(b)
+		order := strings.Compare(av, bv)
+		if order == 0 {
+			order = strings.Compare(aid.String(), bid.String())
+		}
+		if sort.Desc {
+			order = -order
+		}
+		return order
+	}
+	slices.SortFunc(items, less)
+
+	page := Page[T]{Items: []T{}, Total: len(items)}
+	for _, item := range items {
+		if after != nil {
+			value, id := key(item)
+			order := strings.Compare(value, after.Value)
+			if order == 0 {
+				order = strings.Compare(id.String(), after.ID.String())
+			}
+			if sort.Desc {
+				order = -order
+			}
+			if order <= 0 {
+				continue
+			}
+		}
+		if limit > 0 && len(page.Items) == limit {
+			page.HasMore = true
+			break
+		}
+		page.Items = append(page.Items, item)
+	}
+	return page
+}
+
+func (m *Memory) updateProject(id uuid.UUID, deleted bool, update func(project *models.Project)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	project, ok := m.projects[id]
+	if !ok || (project.DeletedAt != nil) != deleted {
+		return ErrNotFound
+	}
+	update(&project)
+	m.projects[id] = project
+	return nil
+}
+
+func (m *Memory) UpdateProject(ctx context.Context, id uuid.UUID, name, description string) error {
+	return m.updateProject(id, false, func(project *models.Project) {
+		project.Name, project.Description = name, description
+	})
+}
+
+func (m *Memory) SetProjectArchived(ctx context.Context, id uuid.UUID, archived bool) error {
+	return m.updateProject(id, false, func(project *models.Project) {
+		if !archived {
+			project.ArchivedAt = nil
+		} else if project.ArchivedAt == nil {
+			now := time.Now()
+			project.ArchivedAt = &now
+		}
+	})
+}
+
+func (m *Memory) DeleteProject(ctx context.Context, id uuid.UUID) error {
+	return m.updateProject(id, false, func(project *models.Project) {
+		now := time.Now()
+		project.DeletedAt = &now
+	})
+}
+
+func (m *Memory) RestoreProject(ctx context.Context, id uuid.UUID) error {
+	return m.updateProject(id, true, func(project *models.Project) { project.DeletedAt = nil })
+}
+
+func (m *Memory) MoveProject(ctx context.Context, id uuid.UUID, orgID *uuid.UUID, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	project, ok := m.projects[id]
+	if !ok {
+		return ErrNotFound
+	}
+	project.OrganizationID = orgID
+	if orgID == nil {
+		project.UserID = userID
+	}
+	m.projects[id] = project
+	return nil
+}
+
+func (m *Memory) ProjectAccess(ctx context.Context, id, userID uuid.UUID) (rbac.Access, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	project, ok := m.projects[id]
+	if !ok || project.DeletedAt != nil {
+		return rbac.Access{}, nil
+	}
+	role := rbac.ResolveRole(project.UserID, userID, project.OrganizationID, m.memberRole(project.OrganizationID, userID))
+	return rbac.Access{Role: role, OrganizationID: project.OrganizationID}, nil
+}
+
+// Организация проекта сканирования или nil
+func (m *Memory) scanOrganization(scan models.Scan) *uuid.UUID {
+	if scan.ProjectID == nil {
+		return nil
+	}
+	return m.projects[*scan.ProjectID].OrganizationID
+}
+
+func (m *Memory) CreateScan(ctx context.Context, scan *models.Scan) error {
+	return m.CreateScans(ctx, []*models.Scan{scan})
+}
+
+func (m *Memory) CreateScans(ctx context.Context, scans []*models.Scan) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, scan := range scans {
+		stored := *scan
+		stored.Labels, stored.Metadata = scanDetails(scan.Labels, scan.Metadata)
+		m.scans[scan.ID] = stored
+	}
+	return nil
+}
+
+// Копии меток и метаданных; метки упорядочены, как в Postgres
+func scanDetails(labels []string, metadata map[string]string) ([]string, map[string]string) {
+	labels = slices.Sorted(slices.Values(labels))
+	if labels == nil {
+		labels = []string{}
+	}
+	copied := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		copied[key] = value
+	}
+	return labels, copied
+}
+
+func (m *Memory) GetScan(ctx context.Context, id uuid.UUID) (*models.Scan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	scan, ok := m.scans[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	scan.RawNucleiOutput = ""
+	scan.Labels, scan.Metadata = scanDetails(scan.Labels, scan.Metadata)
+	return &scan, nil
+}
+
+func (m *Memory) ListScans(ctx context.Context, userID uuid.UUID, query ScanQuery) (Page[models.Scan], error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var scans []models.Scan
+	for _, scan := range m.scans {
+		orgID := m.scanOrganization(scan)
+		if !(orgID == nil && scan.UserID == userID || m.memberRole(orgID, userID) != "") {
+			continue
+		}
+		if len(query.Statuses) > 0 && !slices.Contains(query.Statuses, scan.Status) ||
+			query.ProjectID != nil && (scan.ProjectID == nil || *scan.ProjectID != *query.ProjectID) ||
+			query.Target != "" && !strings.Contains(strings.ToLower(scan.TargetURL), strings.ToLower(query.Target)) ||
+			query.Since != nil && scan.CreatedAt.Before(*query.Since) ||
+			query.Until != nil && !scan.CreatedAt.Before(*query.Until) {
+			continue
+		}
+		if !hasLabels(scan, query.Labels) || !hasMetadata(scan, query.Metadata) {
+			continue
+		}
+		scan.RawNucleiOutput, scan.Config = "", nil
+		scan.Labels, scan.Metadata = scanDetails(scan.Labels, scan.Metadata)
+		scans = append(scans, scan)
+	}
+
+	page := paginate(scans, func(scan models.Scan) (string, uuid.UUID) {
+		return ScanSortValue(scan, query.Sort.Field), scan.ID
+	}, query.Sort, query.After, query.Limit)
+
+	for i := range page.Items {
+		page.Items[i].SeverityCounts = map[string]int{}
+		for _, vuln := range m.vulnerabilities {
+			if vuln.ScanID == page.Items[i].ID {
+				page.Items[i].SeverityCounts[strings.ToLower(vuln.Severity)]++
+			}
+		}
+	}
+	return page, nil
+}
+
+func hasLabels(scan models.Scan, labels []string) bool {
+	for _, label := range labels {
+		if !slices.Contains(scan.Labels, label) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasMetadata(scan models.Scan, metadata map[string]string) bool {
+	for key, value := range metadata {
+		if stored, ok := scan.Metadata[key]; !ok || stored != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Memory) updateScan(id uuid.UUID, update func(scan *models.Scan)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	scan, ok := m.scans[id]
+	if !ok {
+		return ErrNotFound
+	}
+	update(&scan)
+	m.scans[id] = scan
+	return nil
+}
+
+func (m *Memory) UpdateScanDetails(ctx context.Context, id uuid.UUID, labels []string, notes string, metadata map[string]string) error {
+	return m.updateScan(id, func(scan *models.Scan) {
+		scan.Labels, scan.Metadata = scanDetails(labels, metadata)
+		scan.Notes = notes
+	})
+}
+
+func (m *Memory) SetScanProject(ctx context.Context, id uuid.UUID, projectID *uuid.UUID) error {
+	return m.updateScan(id, func(scan *models.Scan) { scan.ProjectID = projectID })
+}
+
+func (m *Memory) SetScansProject(ctx context.Context, ids []uuid.UUID, projectID *uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.hasScans(ids) {
+		return ErrNotFound
+	}
+	for _, id := range ids {
+		scan := m.scans[id]
+		scan.ProjectID = projectID
+		m.scans[id] = scan
+	}
+	return nil
+}
+
+// hasScans сообщает, есть ли все сканирования; вызывается под блокировкой
+func (m *Memory) hasScans(ids []uuid.UUID) bool {
+	for _, id := range ids {
+		if _, ok := m.scans[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Memory) UpdateScanStatus(ctx context.Context, id uuid.UUID, status string, startedAt *time.Time) error {
+	return m.updateScan(id, func(scan *models.Scan) {
+		scan.Status = status
+		if startedAt != nil {
+			scan.StartedAt = startedAt
+		}
+	})
+}
+
+func (m *Memory) StartScan(ctx context.Context, id uuid.UUID, startedAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	scan, ok := m.scans[id]
+	if !ok || scan.Status != "Queued" {
+		return ErrNotFound
+	}
+	scan.Status = "In Progress"
+	scan.StartedAt = &startedAt
+	m.scans[id] = scan
+	return nil
+}
+
+func (m *Memory) CancelScans(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var canceled []uuid.UUID
+	for _, id := range ids {
+		scan, ok := m.scans[id]
+		if !ok || (scan.Status != "Queued" && scan.Status != "In Progress") {
+			continue
+		}
+		scan.Status = "Canceled"
+		m.scans[id] = scan
+		canceled = append(canceled, id)
+	}
+	return canceled, nil
+}
+
+func (m *Memory) CompleteScan(ctx context.Context, id uuid.UUID, completion ScanCompletion) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	scan, ok := m.scans[id]
+	if !ok || scan.Status != "In Progress" {
+		return ErrNotFound
+	}
+	finishedAt := completion.FinishedAt
+	scan.Status = "Completed"
+	scan.FinishedAt = &finishedAt
+	scan.RawNucleiOutput = completion.RawNucleiOutput
+	scan.ReportJSONPath = completion.ReportPaths["json"]
+	scan.ReportPDFPath = completion.ReportPaths["pdf"]
+	scan.ReportHTMLPath = completion.ReportPaths["html"]
+	m.scans[id] = scan
+	m.reportSizes[id] = completion.ReportSize
+	return nil
+}
+
+func (m *Memory) ScanFiles(ctx context.Context, id uuid.UUID) (ScanFiles, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var files ScanFiles
+	scan, ok := m.scans[id]
+	if !ok {
+		return files, ErrNotFound
+	}
+	for _, path := range []string{scan.ReportJSONPath, scan.ReportPDFPath, scan.ReportHTMLPath} {
+		if path != "" {
+			files.ReportPaths = append(files.ReportPaths, path)
+		}
+	}
+	for _, vuln := range m.vulnerabilities {
+		if vuln.ScanID == id && vuln.EvidenceKey != nil {
+			files.EvidenceKeys = append(files.EvidenceKeys, *vuln.EvidenceKey)
+		}
+	}
+	sort.Strings(files.EvidenceKeys)
+	return files, nil
+}
+
+func (m *Memory) DeleteScan(ctx context.Context, id uuid.UUID) error {
+	return m.DeleteScans(ctx, []uuid.UUID{id})
+}
+
+func (m *Memory) DeleteScans(ctx context.Context, ids []uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.hasScans(ids) {
+		return ErrNotFound
+	}
+	for _, id := range ids {
+		delete(m.scans, id)
+		delete(m.reportSizes, id)
+	}
+
+	// ON DELETE CASCADE
+	for vulnID, vuln := range m.vulnerabilities {
+		if slices.Contains(ids, vuln.ScanID) {
+			delete(m.vulnerabilities, vulnID)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) ScanAccess(ctx context.Context, id, userID uuid.UUID) (rbac.Access, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	scan, ok := m.scans[id]
+	if !ok {
+		return rbac.Access{}, nil
+	}
+	orgID := m.scanOrganization(scan)
+	return rbac.Access{Role: rbac.ResolveRole(scan.UserID, userID, orgID, m.memberRole(orgID, userID)), OrganizationID: orgID}, nil
+}
+
+func (m *Memory) ListActiveScans(ctx context.Context) ([]ActiveScan, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	scans := []ActiveScan{}
+	for _, scan := range m.scans {
+		user, ok := m.users[scan.UserID]
+		if ok && (scan.Status == "Queued" || scan.Status == "In Progress") {
+			scans = append(scans, ActiveScan{Scan: scan, Username: user.Username})
+		}
+	}
+	sort.Slice(scans, func(i, j int) bool {
+		if !scans[i].CreatedAt.Equal(scans[j].CreatedAt) {
+			return scans[i].CreatedAt.Before(scans[j].CreatedAt)
+		}
+		return scans[i].ID.String() < scans[j].ID.String()
+	})
+	return scans, nil
+}
+
+func (m *Memory) ScanQueue(ctx context.Context) (int, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var queued, inProgress int
+	for _, scan := range m.scans {
+		switch scan.Status {
+		case "Queued":
+			queued++
+		case "In Progress":
+			inProgress++
+		}
+	}
+	return queued, inProgress, nil
+}
+
+func (m *Memory) ReportsFinishedBefore(ctx context.Context, before time.Time) (map[uuid.UUID][]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	reports := map[uuid.UUID][]string{}
+	for id, scan := range m.scans {
+		paths := []string{scan.ReportJSONPath, scan.ReportPDFPath, scan.ReportHTMLPath}
+		if scan.FinishedAt != nil && scan.FinishedAt.Before(before) && slices.ContainsFunc(paths, func(path string) bool { return path != "" }) {
+			reports[id] = paths
+		}
+	}
+	return reports, nil
+}
+
+func (m *Memory) ClearScanReports(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	scan, ok := m.scans[id]
+	if !ok {
+		return ErrNotFound
+	}
+	scan.ReportJSONPath, scan.ReportPDFPath, scan.ReportHTMLPath = "", "", ""
+	m.scans[id] = scan
+	delete(m.reportSizes, id)
+	return nil
+}
+
+func (m *Memory) CreateVulnerability(ctx context.Context, vuln *models.Vulnerability) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.scans[vuln.ScanID]; !ok {
+		return ErrNotFound
+	}
+	v := *vuln
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+	m.vulnerabilities[v.ID] = v
+	return nil
+}
+
+func (m *Memory) GetVulnerability(ctx context.Context, id uuid.UUID) (*models.Vulnerability, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	vuln, ok := m.vulnerabilities[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &vuln, nil
+}
+
+func (m *Memory) ListVulnerabilities(ctx context.Context, scanID uuid.UUID) ([]models.Vulnerability, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var vulns []models.Vulnerability
+	for _, vuln := range m.vulnerabilities {
+		if vuln.ScanID == scanID {
+			vulns = append(vulns, vuln)
+		}
+	}
+	sort.Slice(vulns, func(i, j int) bool { return vulns[i].CreatedAt.Before(vulns[j].CreatedAt) })
+	return vulns, nil
+}
+
+func (m *Memory) FindingSeen(ctx context.Context, scanID uuid.UUID, templateID, matchedAt string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cur, ok := m.scans[scanID]
+	if !ok {
+		return false, nil
+	}
+	for _, vuln := range m.vulnerabilities {
+		if vuln.TemplateID != templateID || vuln.MatchedAt != matchedAt || vuln.ScanID == scanID {
+			continue
+		}
+		scan := m.scans[vuln.ScanID]
+		if scan.TargetURL != cur.TargetURL {
+			continue
+		}
+		switch {
+		case cur.ProjectID != nil:
+			if scan.ProjectID != nil && *scan.ProjectID == *cur.ProjectID {
+				return true, nil
+			}
+		case scan.ProjectID == nil && scan.UserID == cur.UserID:
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *Memory) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := *webhook
+	w.Events = slices.Clone(w.Events)
+	m.webhooks[w.ID] = w
+	return nil
+}
+
+func (m *Memory) ListWebhooks(ctx context.Context, userID uuid.UUID) ([]models.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	webhooks := []models.Webhook{}
+	for _, webhook := range m.webhooks {
+		if webhook.UserID == userID {
+			webhook.Secret = ""
+			webhook.Events = slices.Clone(webhook.Events)
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].CreatedAt.After(webhooks[j].CreatedAt) })
+	return webhooks, nil
+}
+
+func (m *Memory) DeleteWebhook(ctx context.Context, id, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if webhook, ok := m.webhooks[id]; !ok || webhook.UserID != userID {
+		return ErrNotFound
+	}
+	m.deleteWebhookRecord(id)
+	return nil
+}
+
+func (m *Memory) ListWebhookDeliveries(ctx context.Context, id, userID uuid.UUID, limit int) ([]models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if webhook, ok := m.webhooks[id]; !ok || webhook.UserID != userID {
+		return nil, ErrNotFound
+	}
+	deliveries := slices.Clone(m.deliveries[id])
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// activeProject сообщает, есть ли неудаленный проект
+func (m *Memory) activeProject(id uuid.UUID) bool {
+	project, ok := m.projects[id]
+	return ok && project.DeletedAt == nil
+}
+
+func (m *Memory) ProjectRepository(ctx context.Context, projectID uuid.UUID) (*models.ProjectRepository, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.activeProject(projectID) {
+		return nil, ErrNotFound
+	}
+	repo := m.repositories[projectID]
+	repo.ProjectID = projectID
+	return &repo, nil
+}
+
+func (m *Memory) LinkProjectRepository(ctx context.Context, repo models.ProjectRepository) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.activeProject(repo.ProjectID) {
+		return ErrNotFound
+	}
+	if old := m.repositories[repo.ProjectID]; repo.Token == "" && old.Provider == repo.Provider && old.BaseURL == repo.BaseURL {
+		repo.Token = old.Token
+	}
+	m.repositories[repo.ProjectID] = repo
+	return nil
+}
+
+func (m *Memory) UnlinkProjectRepository(ctx context.Context, projectID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.activeProject(projectID) {
+		return ErrNotFound
+	}
+	delete(m.repositories, projectID)
+	return nil
+}
+
+func (m *Memory) ProjectIssues(ctx context.Context, projectID uuid.UUID) (map[uuid.UUID]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	issues := map[uuid.UUID]int{}
+	for id, vuln := range m.vulnerabilities {
+		scan := m.scans[vuln.ScanID]
+		if vuln.IssueNumber != nil && scan.ProjectID != nil && *scan.ProjectID == projectID {
+			issues[id] = *vuln.IssueNumber
+		}
+	}
+	return issues, nil
+}
+
+// Обновление уязвимости; ErrNotFound, если ее нет
+func (m *Memory) updateVulnerability(id uuid.UUID, update func(vuln *models.Vulnerability)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	vuln, ok := m.vulnerabilities[id]
+	if !ok {
+		return ErrNotFound
+	}
+	update(&vuln)
+	m.vulnerabilities[id] = vuln
+	return nil
+}
+
+func (m *Memory) SetVulnerabilityIssue(ctx context.Context, id uuid.UUID, url string, number int, state string, syncedAt time.Time) error {
+	return m.updateVulnerability(id, func(vuln *models.Vulnerability) {
+		vuln.IssueURL, vuln.IssueNumber, vuln.IssueState, vuln.IssueSyncedAt = &url, &number, &state, &syncedAt
+	})
+}
+
+func (m *Memory) SetVulnerabilityIssueState(ctx context.Context, id uuid.UUID, state string, syncedAt time.Time) error {
+	return m.updateVulnerability(id, func(vuln *models.Vulnerability) {
+		vuln.IssueState, vuln.IssueSyncedAt = &state, &syncedAt
+	})
+}
+
+func (m *Memory) NotificationPreferences(ctx context.Context, userID uuid.UUID) (*models.NotificationPreferences, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	prefs, ok := m.notifications[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &prefs, nil
+}
+
+func (m *Memory) SaveNotificationPreferences(ctx context.Context, prefs models.NotificationPreferences) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifications[prefs.UserID] = prefs
+	return nil
+}
+
+func (m *Memory) QuotaLimits(ctx context.Context, subject quota.Subject, defaults quota.Limits) (quota.Limits, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if limits, ok := m.quotas[subject]; ok {
+		return limits, nil
+	}
+	return defaults, nil
+}
+
+func (m *Memory) QuotaUsage(ctx context.Context, subject quota.Subject, since time.Time) (quota.Usage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var usage quota.Usage
+	for id, scan := range m.scans {
+		orgID := m.scanOrganization(scan)
+		if subject.Type == quota.SubjectOrganization {
+			if orgID == nil || *orgID != subject.ID {
+				continue
+			}
+		} else if orgID != nil || scan.UserID != subject.ID {
+			continue
+		}
+
+		if scan.Status == "Queued" || scan.Status == "In Progress" {
+			usage.ConcurrentScans++
+		}
+		if !scan.CreatedAt.Before(since) {
+			usage.ScansPerDay++
+			if usage.OldestToday == nil || scan.CreatedAt.Before(*usage.OldestToday) {
+				createdAt := scan.CreatedAt
+				usage.OldestToday = &createdAt
+			}
+		}
+		usage.ReportStorageBytes += m.reportSizes[id]
+	}
+	return usage, nil
+}
+
+func (m *Memory) ProjectTargets(ctx context.Context, projectID uuid.UUID, targetURL string) (int, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	targets := map[string]bool{}
+	for _, scan := range m.scans {
+		if scan.ProjectID != nil && *scan.ProjectID == projectID {
+			targets[scan.TargetURL] = true
+		}
+	}
+	return len(targets), targets[targetURL], nil
+}
+
+// LockQuotas ничего не делает: хранилище в памяти принадлежит одному процессу
+func (m *Memory) LockQuotas(ctx context.Context, subjects []quota.Subject) (func(), error) {
+	return func() {}, nil
+}
@@ -0,0 +1,1494 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"chimerascan/database"
+	"chimerascan/models"
+	"chimerascan/quota"
+	"chimerascan/rbac"
+
+	"github.com/google/uuid"
+)
+
+// Postgres - реализация хранилищ поверх PostgreSQL
+type Postgres struct {
+	db *sql.DB
+}
+
+var _ Store = (*Postgres)(nil)
+
+// NewPostgres создает хранилище поверх открытого подключения
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// JSONB передается строкой: []byte драйвер отправил бы как bytea
+func jsonValue(data []byte) interface{} {
+	if data == nil {
+		return nil
+	}
+	return string(data)
+}
+
+// Обновление одной записи; ErrNotFound, если запись не найдена
+func (p *Postgres) execOne(ctx context.Context, query string, args ...interface{}) error {
+	return execOne(ctx, p.db, query, args...)
+}
+
+// execer - *sql.DB или *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// execOne выполняет запрос и возвращает ErrNotFound, если он не затронул ни одной строки
+func execOne(ctx context.Context, db execer, query string, args ...interface{}) error {
+	result, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) GetUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	err := p.db.QueryRowContext(ctx, `
+		SELECT id, provider_id, email, username, created_at, is_admin, disabled_at
+		FROM users
+		WHERE id = $1
+	`, id).Scan(&user.ID, &user.ProviderID, &user.Email, &user.Username, &user.CreatedAt, &user.IsAdmin, &user.DisabledAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (p *Postgres) ListUsers(ctx context.Context) ([]UserSummary, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT u.id, u.provider_id, u.email, u.username, u.created_at, u.is_admin, u.disabled_at,
+		       (SELECT COUNT(*) FROM scans s WHERE s.user_id = u.id)
+		FROM users u
+		ORDER BY u.created_at, u.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserSummary{}
+	for rows.Next() {
+		var user UserSummary
+		if err := rows.Scan(&user.ID, &user.ProviderID, &user.Email, &user.Username, &user.CreatedAt,
+			&user.IsAdmin, &user.DisabledAt, &user.ScanCount); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (p *Postgres) DisableUser(ctx context.Context, id uuid.UUID, at time.Time) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		if err := execOne(ctx, tx, `UPDATE users SET disabled_at = COALESCE(disabled_at, $1) WHERE id = $2`, at, id); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, id)
+		return err
+	})
+}
+
+func (p *Postgres) EnableUser(ctx context.Context, id uuid.UUID) error {
+	return p.execOne(ctx, `UPDATE users SET disabled_at = NULL WHERE id = $1`, id)
+}
+
+func (p *Postgres) DeleteUser(ctx context.Context, id uuid.UUID) (DeletedUser, error) {
+	var deleted DeletedUser
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		var soleOwner int
+		err := tx.QueryRowContext(ctx, `
+			SELECT COUNT(*)
+			FROM organization_members m
+			WHERE m.user_id = $1 AND m.role = $2 AND NOT EXISTS (
+				SELECT 1 FROM organization_members o
+				WHERE o.organization_id = m.organization_id AND o.role = $2 AND o.user_id <> $1
+			)
+		`, id, rbac.RoleOwner).Scan(&soleOwner)
+		if err != nil {
+			return err
+		}
+		if soleOwner > 0 {
+			return ErrLastOwner
+		}
+
+		// Проекты и сканирования в организациях принадлежат организации: они переходят
+		// к другому ее владельцу, а не удаляются каскадно вместе с пользователем.
+		// Такой владелец есть всегда - последнего владельца удалить нельзя
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE projects
+			SET user_id = (`+organizationHeir("projects.organization_id")+`)
+			WHERE user_id = $1 AND organization_id IS NOT NULL
+		`, id, rbac.RoleOwner); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE scans
+			SET user_id = (`+organizationHeir("(SELECT organization_id FROM projects WHERE id = scans.project_id)")+`)
+			WHERE user_id = $1 AND project_id IN (SELECT id FROM projects WHERE organization_id IS NOT NULL)
+		`, id, rbac.RoleOwner); err != nil {
+			return err
+		}
+
+		// Файлы собираются до удаления: вместе с пользователем каскадно удаляются его личные
+		// сканирования и их уязвимости
+		deleted, err = userScanFiles(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		return execOne(ctx, tx, `DELETE FROM users WHERE id = $1`, id)
+	})
+	return deleted, err
+}
+
+// organizationHeir - подзапрос, выбирающий владельца организации orgID (выражение SQL)
+// вместо удаляемого пользователя $1: первого по времени вступления. $2 - роль владельца
+func organizationHeir(orgID string) string {
+	return `
+		SELECT m.user_id
+		FROM organization_members m
+		WHERE m.organization_id = ` + orgID + ` AND m.role = $2 AND m.user_id <> $1
+		ORDER BY m.created_at, m.user_id
+		LIMIT 1`
+}
+
+// Файлы и выполняющиеся сканирования пользователя
+func userScanFiles(ctx context.Context, tx *sql.Tx, userID uuid.UUID) (DeletedUser, error) {
+	var deleted DeletedUser
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, status, report_json_path, report_pdf_path, report_html_path
+		FROM scans
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return deleted, err
+	}
+	for rows.Next() {
+		var scanID uuid.UUID
+		var status string
+		var jsonPath, pdfPath, htmlPath sql.NullString
+		if err := rows.Scan(&scanID, &status, &jsonPath, &pdfPath, &htmlPath); err != nil {
+			rows.Close()
+			return deleted, err
+		}
+		if status == "Queued" || status == "In Progress" {
+			deleted.ActiveScans = append(deleted.ActiveScans, scanID)
+		}
+		for _, path := range []sql.NullString{jsonPath, pdfPath, htmlPath} {
+			if path.String != "" {
+				deleted.Files.ReportPaths = append(deleted.Files.ReportPaths, path.String)
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return deleted, err
+	}
+
+	rows, err = tx.QueryContext(ctx, `
+		SELECT v.evidence_key
+		FROM vulnerabilities v
+		JOIN scans s ON s.id = v.scan_id
+		WHERE s.user_id = $1 AND v.evidence_key IS NOT NULL
+	`, userID)
+	if err != nil {
+		return deleted, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return deleted, err
+		}
+		deleted.Files.EvidenceKeys = append(deleted.Files.EvidenceKeys, key)
+	}
+	return deleted, rows.Err()
+}
+
+func (p *Postgres) OrganizationRole(ctx context.Context, orgID, userID uuid.UUID) (string, error) {
+	return rbac.QueryOrganizationRole(p.db, orgID, userID)
+}
+
+func (p *Postgres) FindUser(ctx context.Context, login string) (*models.User, error) {
+	condition := `username = $1 OR email = $1`
+	var arg interface{} = login
+	if id, err := uuid.Parse(login); err == nil {
+		condition, arg = `id = $1`, id
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT id, provider_id, email, username, created_at, is_admin, disabled_at
+		FROM users
+		WHERE `+condition+`
+		LIMIT 2
+	`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.ProviderID, &user.Email, &user.Username, &user.CreatedAt, &user.IsAdmin, &user.DisabledAt); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	switch len(users) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		return &users[0], nil
+	default:
+		return nil, ErrAmbiguous
+	}
+}
+
+func (p *Postgres) CreateOrganization(ctx context.Context, org *models.Organization, ownerID uuid.UUID) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO organizations (id, name, created_at) VALUES ($1, $2, $3)
+		`, org.ID, org.Name, org.CreatedAt)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO organization_members (organization_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)
+		`, org.ID, ownerID, rbac.RoleOwner, org.CreatedAt)
+		return err
+	})
+}
+
+func (p *Postgres) ListOrganizations(ctx context.Context, userID uuid.UUID) ([]models.Organization, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT o.id, o.name, m.role, o.created_at
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY o.name, o.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	organizations := []models.Organization{}
+	for rows.Next() {
+		var org models.Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.Role, &org.CreatedAt); err != nil {
+			return nil, err
+		}
+		organizations = append(organizations, org)
+	}
+	return organizations, rows.Err()
+}
+
+func (p *Postgres) DeleteOrganization(ctx context.Context, id uuid.UUID) error {
+	return p.execOne(ctx, `DELETE FROM organizations WHERE id = $1`, id)
+}
+
+func (p *Postgres) ListOrganizationMembers(ctx context.Context, orgID uuid.UUID) ([]models.OrganizationMember, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT m.organization_id, m.user_id, u.username, u.email, m.role, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY u.username, u.id
+	`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []models.OrganizationMember{}
+	for rows.Next() {
+		var member models.OrganizationMember
+		if err := rows.Scan(&member.OrganizationID, &member.UserID, &member.Username,
+			&member.Email, &member.Role, &member.CreatedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+func (p *Postgres) AddOrganizationMember(ctx context.Context, member models.OrganizationMember) error {
+	err := p.execOne(ctx, `
+		INSERT INTO organization_members (organization_id, user_id, role, created_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (organization_id, user_id) DO NOTHING
+	`, member.OrganizationID, member.UserID, member.Role, member.CreatedAt)
+	if err == ErrNotFound {
+		return ErrExists
+	}
+	return err
+}
+
+// keepOwner возвращает ErrLastOwner, если участник - единственный владелец организации;
+// ErrNotFound, если он в ней не состоит
+func keepOwner(ctx context.Context, tx *sql.Tx, orgID, userID uuid.UUID) error {
+	var role string
+	var otherOwners int
+	err := tx.QueryRowContext(ctx, `
+		SELECT m.role, (
+			SELECT COUNT(*) FROM organization_members o
+			WHERE o.organization_id = m.organization_id AND o.role = $3 AND o.user_id <> m.user_id
+		)
+		FROM organization_members m
+		WHERE m.organization_id = $1 AND m.user_id = $2
+	`, orgID, userID, rbac.RoleOwner).Scan(&role, &otherOwners)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+	if role == rbac.RoleOwner && otherOwners == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+func (p *Postgres) UpdateOrganizationMember(ctx context.Context, orgID, userID uuid.UUID, role string) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		if role != rbac.RoleOwner {
+			if err := keepOwner(ctx, tx, orgID, userID); err != nil {
+				return err
+			}
+		}
+		return execOne(ctx, tx, `
+			UPDATE organization_members SET role = $1 WHERE organization_id = $2 AND user_id = $3
+		`, role, orgID, userID)
+	})
+}
+
+func (p *Postgres) RemoveOrganizationMember(ctx context.Context, orgID, userID uuid.UUID) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		if err := keepOwner(ctx, tx, orgID, userID); err != nil {
+			return err
+		}
+		if err := execOne(ctx, tx, `
+			DELETE FROM organization_members WHERE organization_id = $1 AND user_id = $2
+		`, orgID, userID); err != nil {
+			return err
+		}
+
+		// Вебхуки бывшего участника на проекты организации больше не должны получать события
+		_, err := tx.ExecContext(ctx, `
+			DELETE FROM webhooks
+			WHERE user_id = $1 AND project_id IN (SELECT id FROM projects WHERE organization_id = $2)
+		`, userID, orgID)
+		return err
+	})
+}
+
+func (p *Postgres) CreateProject(ctx context.Context, project *models.Project) error {
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO projects (id, name, description, user_id, organization_id, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+		project.ID, project.Name, project.Description, project.UserID, project.OrganizationID, project.CreatedAt)
+	return err
+}
+
+func (p *Postgres) GetProject(ctx context.Context, id uuid.UUID) (*models.Project, error) {
+	var project models.Project
+	var description sql.NullString
+	err := p.db.QueryRowContext(ctx, `
+		SELECT id, name, description, user_id, organization_id, archived_at, created_at
+		FROM projects
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id).Scan(&project.ID, &project.Name, &description, &project.UserID, &project.OrganizationID, &project.ArchivedAt, &project.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	project.Description = description.String
+	return &project, nil
+}
+
+func (p *Postgres) ListProjects(ctx context.Context, userID uuid.UUID, query ProjectQuery) (Page[models.Project], error) {
+	var page Page[models.Project]
+
+	q := &listQuery{}
+	q.arg(userID)
+	q.add(rbac.VisibleProjectsCondition)
+	q.add(`p.deleted_at IS NULL`)
+	if query.Archived {
+		q.add(`p.archived_at IS NOT NULL`)
+	} else {
+		q.add(`p.archived_at IS NULL`)
+	}
+	if query.Name != "" {
+		q.add(`LOWER(p.name) LIKE ` + q.arg(likePattern(query.Name)) + ` ESCAPE '\'`)
+	}
+
+	if err := p.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM projects p WHERE `+q.condition(), q.args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	column := map[string]string{"name": "p.name"}[query.Sort.Field]
+	if column == "" {
+		column = "p.created_at"
+	}
+	orderBy, err := q.keyset(column, "p.id", query.Sort, query.After)
+	if err != nil {
+		return page, err
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT p.id, p.name, p.description, p.user_id, p.organization_id, p.created_at, p.archived_at
+		FROM projects p
+		WHERE `+q.condition()+`
+		ORDER BY `+orderBy+q.limit(query.Limit), q.args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	page.Items = []models.Project{}
+	for rows.Next() {
+		var project models.Project
+		var description sql.NullString
+		err := rows.Scan(&project.ID, &project.Name, &description, &project.UserID, &project.OrganizationID, &project.CreatedAt, &project.ArchivedAt)
+		if err != nil {
+			return page, err
+		}
+		project.Description = description.String
+		page.Items = append(page.Items, project)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+
+	page.Items, page.HasMore = trimPage(page.Items, query.Limit)
+	return page, nil
+}
+
+func (p *Postgres) UpdateProject(ctx context.Context, id uuid.UUID, name, description string) error {
+	return p.execOne(ctx, `
+		UPDATE projects
+		SET name = $1, description = $2
+		WHERE id = $3 AND deleted_at IS NULL
+	`, name, description, id)
+}
+
+func (p *Postgres) SetProjectArchived(ctx context.Context, id uuid.UUID, archived bool) error {
+	if archived {
+		return p.execOne(ctx, `
+			UPDATE projects
+			SET archived_at = COALESCE(archived_at, $1)
+			WHERE id = $2 AND deleted_at IS NULL
+		`, time.Now(), id)
+	}
+	return p.execOne(ctx, `
+		UPDATE projects
+		SET archived_at = NULL
+		WHERE id = $1 AND deleted_at IS NULL
+	`, id)
+}
+
+func (p *Postgres) DeleteProject(ctx context.Context, id uuid.UUID) error {
+	return p.execOne(ctx, `
+		UPDATE projects
+		SET deleted_at = $1
+		WHERE id = $2 AND deleted_at IS NULL
+	`, time.Now(), id)
+}
+
+func (p *Postgres) RestoreProject(ctx context.Context, id uuid.UUID) error {
+	return p.execOne(ctx, `
+		UPDATE projects
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`, id)
+}
+
+func (p *Postgres) ProjectAccess(ctx context.Context, id, userID uuid.UUID) (rbac.Access, error) {
+	return rbac.QueryProjectAccess(p.db, id, userID)
+}
+
+func (p *Postgres) MoveProject(ctx context.Context, id uuid.UUID, orgID *uuid.UUID, userID uuid.UUID) error {
+	if orgID != nil {
+		return p.execOne(ctx, `UPDATE projects SET organization_id = $1 WHERE id = $2`, orgID, id)
+	}
+	return p.execOne(ctx, `UPDATE projects SET organization_id = NULL, user_id = $1 WHERE id = $2`, userID, id)
+}
+
+func (p *Postgres) CreateScan(ctx context.Context, scan *models.Scan) error {
+	return p.CreateScans(ctx, []*models.Scan{scan})
+}
+
+func (p *Postgres) CreateScans(ctx context.Context, scans []*models.Scan) error {
+	configs := make([]*string, len(scans))
+	for i, scan := range scans {
+		if scan.Config == nil {
+			continue
+		}
+		data, err := json.Marshal(scan.Config)
+		if err != nil {
+			return err
+		}
+		value := string(data)
+		configs[i] = &value
+	}
+
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		for i, scan := range scans {
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO scans (id, target_url, status, project_id, started_at, user_id, created_at, config, retest_of, notes)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			`, scan.ID, scan.TargetURL, scan.Status, scan.ProjectID, scan.StartedAt, scan.UserID, scan.CreatedAt, configs[i], scan.RetestOf, scan.Notes)
+			if err != nil {
+				return err
+			}
+			if err := insertScanDetails(ctx, tx, scan.ID, scan.Labels, scan.Metadata); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetScan возвращает сканирование без сырого вывода Nuclei
+func (p *Postgres) GetScan(ctx context.Context, id uuid.UUID) (*models.Scan, error) {
+	var scan models.Scan
+	var jsonPath, pdfPath, htmlPath, config sql.NullString
+	err := p.db.QueryRowContext(ctx, `
+		SELECT id, target_url, status, project_id, started_at, finished_at,
+		       report_json_path, report_pdf_path, report_html_path, created_at, user_id, config, retest_of, notes
+		FROM scans
+		WHERE id = $1
+	`, id).Scan(
+		&scan.ID, &scan.TargetURL, &scan.Status, &scan.ProjectID, &scan.StartedAt, &scan.FinishedAt,
+		&jsonPath, &pdfPath, &htmlPath, &scan.CreatedAt, &scan.UserID, &config, &scan.RetestOf, &scan.Notes,
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	scan.ReportJSONPath, scan.ReportPDFPath, scan.ReportHTMLPath = jsonPath.String, pdfPath.String, htmlPath.String
+	if config.Valid {
+		scan.Config = &models.ScanConfig{}
+		if err := json.Unmarshal([]byte(config.String), scan.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	scans := []models.Scan{scan}
+	if err := p.scanDetails(ctx, scans); err != nil {
+		return nil, err
+	}
+	return &scans[0], nil
+}
+
+func (p *Postgres) UpdateScanDetails(ctx context.Context, id uuid.UUID, labels []string, notes string, metadata map[string]string) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, `UPDATE scans SET notes = $1 WHERE id = $2`, notes, id)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM scan_labels WHERE scan_id = $1`, id); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM scan_metadata WHERE scan_id = $1`, id); err != nil {
+			return err
+		}
+		return insertScanDetails(ctx, tx, id, labels, metadata)
+	})
+}
+
+// Запись меток и метаданных сканирования
+func insertScanDetails(ctx context.Context, tx *sql.Tx, id uuid.UUID, labels []string, metadata map[string]string) error {
+	for _, label := range labels {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO scan_labels (scan_id, label) VALUES ($1, $2)`, id, label); err != nil {
+			return err
+		}
+	}
+	for key, value := range metadata {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO scan_metadata (scan_id, key, value) VALUES ($1, $2, $3)`, id, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Выполнение fn в транзакции: откат при ошибке, иначе фиксация
+func (p *Postgres) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *Postgres) ListScans(ctx context.Context, userID uuid.UUID, query ScanQuery) (Page[models.Scan], error) {
+	var page Page[models.Scan]
+
+	q := &listQuery{}
+	q.arg(userID)
+	q.add(rbac.VisibleScansCondition)
+	if len(query.Statuses) > 0 {
+		statuses := make([]string, len(query.Statuses))
+		for i, status := range query.Statuses {
+			statuses[i] = q.arg(status)
+		}
+		q.add(`s.status IN (` + strings.Join(statuses, ", ") + `)`)
+	}
+	if query.ProjectID != nil {
+		q.add(`s.project_id = ` + q.arg(*query.ProjectID))
+	}
+	if query.Target != "" {
+		q.add(`LOWER(s.target_url) LIKE ` + q.arg(likePattern(query.Target)) + ` ESCAPE '\'`)
+	}
+	if query.Since != nil {
+		q.add(`s.created_at >= ` + q.arg(*query.Since))
+	}
+	if query.Until != nil {
+		q.add(`s.created_at < ` + q.arg(*query.Until))
+	}
+	for _, label := range query.Labels {
+		q.add(`EXISTS (SELECT 1 FROM scan_labels l WHERE l.scan_id = s.id AND l.label = ` + q.arg(label) + `)`)
+	}
+	keys := make([]string, 0, len(query.Metadata))
+	for key := range query.Metadata {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		q.add(`EXISTS (SELECT 1 FROM scan_metadata m WHERE m.scan_id = s.id AND m.key = ` + q.arg(key) + ` AND m.value = ` + q.arg(query.Metadata[key]) + `)`)
+	}
+
+	const from = `FROM scans s LEFT JOIN projects p ON p.id = s.project_id`
+	if err := p.db.QueryRowContext(ctx, `SELECT COUNT(*) `+from+` WHERE `+q.condition(), q.args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	column := map[string]string{"target_url": "s.target_url", "status": scanStatusRankSQL}[query.Sort.Field]
+	if column == "" {
+		column = "s.created_at"
+	}
+	orderBy, err := q.keyset(column, "s.id", query.Sort, query.After)
+	if err != nil {
+		return page, err
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT s.id, s.target_url, s.status, s.project_id, s.started_at, s.finished_at, s.created_at, s.user_id, s.retest_of, s.notes
+		`+from+`
+		WHERE `+q.condition()+`
+		ORDER BY `+orderBy+q.limit(query.Limit), q.args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	page.Items = []models.Scan{}
+	for rows.Next() {
+		var scan models.Scan
+		err := rows.Scan(
+			&scan.ID, &scan.TargetURL, &scan.Status, &scan.ProjectID,
+			&scan.StartedAt, &scan.FinishedAt, &scan.CreatedAt, &scan.UserID, &scan.RetestOf, &scan.Notes,
+		)
+		if err != nil {
+			return page, err
+		}
+		page.Items = append(page.Items, scan)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+
+	page.Items, page.HasMore = trimPage(page.Items, query.Limit)
+	if err := p.scanDetails(ctx, page.Items); err != nil {
+		return page, err
+	}
+	return page, p.severityCounts(ctx, page.Items)
+}
+
+// scanStatusRankSQL - позиция статуса как в scanStatusRank: порядок значений ENUM в PostgreSQL
+// и строк в SQLite различается, поэтому сортировка идет по явному рангу
+var scanStatusRankSQL = func() string {
+	var b strings.Builder
+	b.WriteString("CASE s.status")
+	for _, status := range ScanStatuses {
+		fmt.Fprintf(&b, " WHEN '%s' THEN '%s'", status, scanStatusRank(status))
+	}
+	fmt.Fprintf(&b, " ELSE '%d' END", len(ScanStatuses))
+	return b.String()
+}()
+
+// listQuery собирает условия и параметры запроса списка
+type listQuery struct {
+	where []string
+	args  []interface{}
+}
+
+// arg добавляет параметр и возвращает его обозначение в запросе
+func (q *listQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+func (q *listQuery) add(condition string) {
+	q.where = append(q.where, condition)
+}
+
+func (q *listQuery) condition() string {
+	return strings.Join(q.where, " AND ")
+}
+
+// keyset добавляет условие "после курсора" и возвращает ORDER BY по column и idColumn.
+// Курсор по created_at хранит время, по остальным полям - строку
+func (q *listQuery) keyset(column, idColumn string, sort Sort, after *Cursor) (string, error) {
+	direction, compare := "ASC", ">"
+	if sort.Desc {
+		direction, compare = "DESC", "<"
+	}
+
+	if after != nil {
+		var value interface{} = after.Value
+		if strings.HasSuffix(column, ".created_at") {
+			t, err := time.Parse(cursorTimeFormat, after.Value)
+			if err != nil {
+				return "", ErrInvalidCursor
+			}
+			value = t
+		}
+		v, id := q.arg(value), q.arg(after.ID)
+		q.add(fmt.Sprintf(`(%[1]s %[3]s %[4]s OR (%[1]s = %[4]s AND %[2]s %[3]s %[5]s))`, column, idColumn, compare, v, id))
+	}
+	return fmt.Sprintf("%s %s, %s %s", column, direction, idColumn, direction), nil
+}
+
+// limit возвращает LIMIT на одну запись больше страницы, чтобы узнать, есть ли следующая
+func (q *listQuery) limit(limit int) string {
+	if limit <= 0 {
+		return ""
+	}
+	return ` LIMIT ` + q.arg(limit+1)
+}
+
+// Шаблон LIKE для поиска подстроки без учета регистра
+func likePattern(substring string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(strings.ToLower(substring))
+	return "%" + escaped + "%"
+}
+
+// Отрезает лишнюю запись, запрошенную для проверки следующей страницы
+func trimPage[T any](items []T, limit int) ([]T, bool) {
+	if limit > 0 && len(items) > limit {
+		return items[:limit], true
+	}
+	return items, false
+}
+
+// Заполняет SeverityCounts сканирований одним запросом
+func (p *Postgres) severityCounts(ctx context.Context, scans []models.Scan) error {
+	if len(scans) == 0 {
+		return nil
+	}
+
+	index := make(map[uuid.UUID]int, len(scans))
+	args := make([]interface{}, len(scans))
+	for i := range scans {
+		scans[i].SeverityCounts = map[string]int{}
+		index[scans[i].ID] = i
+		args[i] = scans[i].ID
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT scan_id, LOWER(severity), COUNT(*)
+		FROM vulnerabilities
+		WHERE scan_id IN (`+placeholders(1, len(scans))+`)
+		GROUP BY scan_id, LOWER(severity)
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var scanID uuid.UUID
+		var severity string
+		var count int
+		if err := rows.Scan(&scanID, &severity, &count); err != nil {
+			return err
+		}
+		scans[index[scanID]].SeverityCounts[severity] = count
+	}
+	return rows.Err()
+}
+
+// Заполняет метки и метаданные сканирований
+func (p *Postgres) scanDetails(ctx context.Context, scans []models.Scan) error {
+	if len(scans) == 0 {
+		return nil
+	}
+
+	index := make(map[uuid.UUID]int, len(scans))
+	args := make([]interface{}, len(scans))
+	for i := range scans {
+		scans[i].Labels, scans[i].Metadata = []string{}, map[string]string{}
+		index[scans[i].ID] = i
+		args[i] = scans[i].ID
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT scan_id, label FROM scan_labels
+		WHERE scan_id IN (`+placeholders(1, len(scans))+`)
+		ORDER BY label
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var scanID uuid.UUID
+		var label string
+		if err := rows.Scan(&scanID, &label); err != nil {
+			return err
+		}
+		scans[index[scanID]].Labels = append(scans[index[scanID]].Labels, label)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = p.db.QueryContext(ctx, `
+		SELECT scan_id, key, value FROM scan_metadata
+		WHERE scan_id IN (`+placeholders(1, len(scans))+`)
+	`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var scanID uuid.UUID
+		var key, value string
+		if err := rows.Scan(&scanID, &key, &value); err != nil {
+			return err
+		}
+		scans[index[scanID]].Metadata[key] = value
+	}
+	return rows.Err()
+}
+
+// Параметры $first, $first+1, ... через запятую
+func placeholders(first, count int) string {
+	list := make([]string, count)
+	for i := range list {
+		list[i] = fmt.Sprintf("$%d", first+i)
+	}
+	return strings.Join(list, ", ")
+}
+
+func (p *Postgres) SetScanProject(ctx context.Context, id uuid.UUID, projectID *uuid.UUID) error {
+	return p.execOne(ctx, `
+		UPDATE scans
+		SET project_id = $1
+		WHERE id = $2
+	`, projectID, id)
+}
+
+func (p *Postgres) SetScansProject(ctx context.Context, ids []uuid.UUID, projectID *uuid.UUID) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if err := execOne(ctx, tx, `UPDATE scans SET project_id = $1 WHERE id = $2`, projectID, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (p *Postgres) UpdateScanStatus(ctx context.Context, id uuid.UUID, status string, startedAt *time.Time) error {
+	if startedAt != nil {
+		return p.execOne(ctx, `UPDATE scans SET status = $1, started_at = $2 WHERE id = $3`, status, *startedAt, id)
+	}
+	return p.execOne(ctx, `UPDATE scans SET status = $1 WHERE id = $2`, status, id)
+}
+
+func (p *Postgres) StartScan(ctx context.Context, id uuid.UUID, startedAt time.Time) error {
+	return p.execOne(ctx, `
+		UPDATE scans
+		SET status = 'In Progress', started_at = $1
+		WHERE id = $2 AND status = 'Queued'
+	`, startedAt, id)
+}
+
+func (p *Postgres) CancelScans(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error) {
+	var canceled []uuid.UUID
+	err := p.inTx(ctx, func(tx *sql.Tx) error {
+		canceled = nil
+		for _, id := range ids {
+			err := execOne(ctx, tx, `
+				UPDATE scans
+				SET status = 'Canceled'
+				WHERE id = $1 AND status IN ('Queued', 'In Progress')
+			`, id)
+			if err == nil {
+				canceled = append(canceled, id)
+			} else if err != ErrNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return canceled, nil
+}
+
+func (p *Postgres) CompleteScan(ctx context.Context, id uuid.UUID, completion ScanCompletion) error {
+	return p.execOne(ctx, `
+		UPDATE scans
+		SET status = $1, finished_at = $2, raw_nuclei_output = $3,
+		    report_json_path = $4, report_pdf_path = $5, report_html_path = $6, report_size_bytes = $7
+		WHERE id = $8 AND status = 'In Progress'
+	`,
+		"Completed", completion.FinishedAt, completion.RawNucleiOutput,
+		completion.ReportPaths["json"], completion.ReportPaths["pdf"], completion.ReportPaths["html"], completion.ReportSize,
+		id,
+	)
+}
+
+func (p *Postgres) ScanFiles(ctx context.Context, id uuid.UUID) (ScanFiles, error) {
+	var files ScanFiles
+	var jsonPath, pdfPath, htmlPath sql.NullString
+	err := p.db.QueryRowContext(ctx, `
+		SELECT report_json_path, report_pdf_path, report_html_path
+		FROM scans
+		WHERE id = $1
+	`, id).Scan(&jsonPath, &pdfPath, &htmlPath)
+	if err == sql.ErrNoRows {
+		return files, ErrNotFound
+	} else if err != nil {
+		return files, err
+	}
+	for _, path := range []sql.NullString{jsonPath, pdfPath, htmlPath} {
+		if path.String != "" {
+			files.ReportPaths = append(files.ReportPaths, path.String)
+		}
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT evidence_key
+		FROM vulnerabilities
+		WHERE scan_id = $1 AND evidence_key IS NOT NULL
+	`, id)
+	if err != nil {
+		return files, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return files, err
+		}
+		files.EvidenceKeys = append(files.EvidenceKeys, key)
+	}
+	return files, rows.Err()
+}
+
+func (p *Postgres) DeleteScan(ctx context.Context, id uuid.UUID) error {
+	return p.execOne(ctx, `
+		DELETE FROM scans
+		WHERE id = $1
+	`, id)
+}
+
+func (p *Postgres) DeleteScans(ctx context.Context, ids []uuid.UUID) error {
+	return p.inTx(ctx, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if err := execOne(ctx, tx, `DELETE FROM scans WHERE id = $1`, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (p *Postgres) ScanAccess(ctx context.Context, id, userID uuid.UUID) (rbac.Access, error) {
+	return rbac.QueryScanAccess(p.db, id, userID)
+}
+
+func (p *Postgres) ListActiveScans(ctx context.Context) ([]ActiveScan, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT s.id, s.target_url, s.status, s.project_id, s.started_at, s.created_at, s.user_id, u.username
+		FROM scans s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.status IN ('Queued', 'In Progress')
+		ORDER BY s.created_at, s.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scans := []ActiveScan{}
+	for rows.Next() {
+		var scan ActiveScan
+		if err := rows.Scan(&scan.ID, &scan.TargetURL, &scan.Status, &scan.ProjectID,
+			&scan.StartedAt, &scan.CreatedAt, &scan.UserID, &scan.Username); err != nil {
+			return nil, err
+		}
+		scans = append(scans, scan)
+	}
+	return scans, rows.Err()
+}
+
+func (p *Postgres) ScanQueue(ctx context.Context) (int, int, error) {
+	var queued, inProgress int
+	err := p.db.QueryRowContext(ctx, `
+		SELECT COUNT(CASE WHEN status = 'Queued' THEN 1 END),
+		       COUNT(CASE WHEN status = 'In Progress' THEN 1 END)
+		FROM scans
+		WHERE status IN ('Queued', 'In Progress')
+	`).Scan(&queued, &inProgress)
+	return queued, inProgress, err
+}
+
+func (p *Postgres) ReportsFinishedBefore(ctx context.Context, before time.Time) (map[uuid.UUID][]string, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT id, report_json_path, report_pdf_path, report_html_path
+		FROM scans
+		WHERE finished_at < $1
+		  AND (COALESCE(report_json_path, '') <> '' OR COALESCE(report_pdf_path, '') <> '' OR COALESCE(report_html_path, '') <> '')
+	`, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := map[uuid.UUID][]string{}
+	for rows.Next() {
+		var scanID uuid.UUID
+		var jsonPath, pdfPath, htmlPath sql.NullString
+		if err := rows.Scan(&scanID, &jsonPath, &pdfPath, &htmlPath); err != nil {
+			return nil, err
+		}
+		reports[scanID] = []string{jsonPath.String, pdfPath.String, htmlPath.String}
+	}
+	return reports, rows.Err()
+}
+
+func (p *Postgres) ClearScanReports(ctx context.Context, id uuid.UUID) error {
+	return p.execOne(ctx, `
+		UPDATE scans
+		SET report_json_path = NULL, report_pdf_path = NULL, report_html_path = NULL, report_size_bytes = 0
+		WHERE id = $1
+	`, id)
+}
+
+func (p *Postgres) CreateVulnerability(ctx context.Context, v *models.Vulnerability) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO vulnerabilities (
+			id, scan_id, template_id, name, severity, severity_ai, description,
+			description_ru, reference, tags, classification, host, matched_at, ip,
+			timestamp, curl_command, request, response, metadata, recommendation_ai,
+			response_size, evidence_key
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22)
+	`,
+		v.ID, v.ScanID, v.TemplateID, v.Name, v.Severity, v.SeverityAI, v.Description,
+		v.DescriptionRu, jsonValue(v.Reference), jsonValue(v.Tags), jsonValue(v.Classification), v.Host, v.MatchedAt, v.IP,
+		v.Timestamp, v.CurlCommand, v.Request, v.Response, jsonValue(v.Metadata), v.RecommendationAI,
+		v.ResponseSize, v.EvidenceKey,
+	)
+	return err
+}
+
+const vulnerabilityColumns = `
+	v.id, v.scan_id, v.template_id, v.name, v.severity, v.severity_ai, v.description, v.description_ru,
+	v.reference, v.tags, v.classification, v.host, v.matched_at, v.ip, v.timestamp,
+	v.curl_command, v.request, v.response, v.response_size, v.evidence_key,
+	v.issue_url, v.issue_number, v.issue_state, v.issue_synced_at,
+	v.metadata, v.recommendation_ai, v.created_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanVulnerability(row rowScanner) (*models.Vulnerability, error) {
+	var v models.Vulnerability
+	var description, descriptionRu, matchedAt, ip, curlCommand, request, response, recommendation sql.NullString
+	err := row.Scan(
+		&v.ID, &v.ScanID, &v.TemplateID, &v.Name, &v.Severity, &v.SeverityAI, &description, &descriptionRu,
+		&v.Reference, &v.Tags, &v.Classification, &v.Host, &matchedAt, &ip, &v.Timestamp,
+		&curlCommand, &request, &response, &v.ResponseSize, &v.EvidenceKey,
+		&v.IssueURL, &v.IssueNumber, &v.IssueState, &v.IssueSyncedAt,
+		&v.Metadata, &recommendation, &v.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	v.Description, v.DescriptionRu = description.String, descriptionRu.String
+	v.MatchedAt, v.IP = matchedAt.String, ip.String
+	v.CurlCommand, v.Request, v.Response = curlCommand.String, request.String, response.String
+	v.RecommendationAI = recommendation.String
+	return &v, nil
+}
+
+func (p *Postgres) GetVulnerability(ctx context.Context, id uuid.UUID) (*models.Vulnerability, error) {
+	v, err := scanVulnerability(p.db.QueryRowContext(ctx, `
+		SELECT `+vulnerabilityColumns+`
+		FROM vulnerabilities v
+		WHERE v.id = $1
+	`, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return v, err
+}
+
+func (p *Postgres) ListVulnerabilities(ctx context.Context, scanID uuid.UUID) ([]models.Vulnerability, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT `+vulnerabilityColumns+`
+		FROM vulnerabilities v
+		WHERE v.scan_id = $1
+		ORDER BY v.created_at
+	`, scanID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vulns []models.Vulnerability
+	for rows.Next() {
+		v, err := scanVulnerability(rows)
+		if err != nil {
+			return nil, err
+		}
+		vulns = append(vulns, *v)
+	}
+	return vulns, rows.Err()
+}
+
+func (p *Postgres) FindingSeen(ctx context.Context, scanID uuid.UUID, templateID, matchedAt string) (bool, error) {
+	var seen bool
+	err := p.db.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1
+			FROM vulnerabilities v
+			JOIN scans s ON s.id = v.scan_id
+			JOIN scans cur ON cur.id = $1
+			WHERE s.target_url = cur.target_url AND s.id <> cur.id
+			  AND (s.project_id = cur.project_id OR (cur.project_id IS NULL AND s.project_id IS NULL AND s.user_id = cur.user_id))
+			  AND v.template_id = $2 AND v.matched_at = $3
+		)
+	`, scanID, templateID, matchedAt).Scan(&seen)
+	return seen, err
+}
+
+func (p *Postgres) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return err
+	}
+	_, err = p.db.ExecContext(ctx, `
+		INSERT INTO webhooks (id, user_id, project_id, url, secret, events, active, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, webhook.ID, webhook.UserID, webhook.ProjectID, webhook.URL, webhook.Secret, string(events), webhook.Active, webhook.CreatedAt)
+	return err
+}
+
+func (p *Postgres) ListWebhooks(ctx context.Context, userID uuid.UUID) ([]models.Webhook, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT id, project_id, url, events, active, created_at
+		FROM webhooks
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		webhook := models.Webhook{UserID: userID}
+		var events []byte
+		if err := rows.Scan(&webhook.ID, &webhook.ProjectID, &webhook.URL, &events, &webhook.Active, &webhook.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(events, &webhook.Events); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+func (p *Postgres) DeleteWebhook(ctx context.Context, id, userID uuid.UUID) error {
+	return p.execOne(ctx, `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`, id, userID)
+}
+
+func (p *Postgres) ListWebhookDeliveries(ctx context.Context, id, userID uuid.UUID, limit int) ([]models.WebhookDelivery, error) {
+	var exists bool
+	err := p.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM webhooks WHERE id = $1 AND user_id = $2)
+	`, id, userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT id, webhook_id, event, payload, status, attempts, response_status, last_error, created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE webhook_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts,
+			&d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			return nil, err
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (p *Postgres) ProjectRepository(ctx context.Context, projectID uuid.UUID) (*models.ProjectRepository, error) {
+	var repo models.ProjectRepository
+	var provider, repository, baseURL, token, titleTemplate sql.NullString
+	err := p.db.QueryRowContext(ctx, `
+		SELECT id, issue_provider, issue_repository, issue_base_url, issue_token, issue_title_template
+		FROM projects
+		WHERE id = $1 AND deleted_at IS NULL
+	`, projectID).Scan(&repo.ProjectID, &provider, &repository, &baseURL, &token, &titleTemplate)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	repo.Provider, repo.Repository, repo.BaseURL = provider.String, repository.String, baseURL.String
+	repo.Token, repo.TitleTemplate = token.String, titleTemplate.String
+	return &repo, nil
+}
+
+func (p *Postgres) LinkProjectRepository(ctx context.Context, repo models.ProjectRepository) error {
+	return p.execOne(ctx, `
+		UPDATE projects
+		SET issue_token = CASE
+		        WHEN $4 <> '' THEN $4
+		        WHEN issue_provider = $1 AND COALESCE(issue_base_url, '') = $3 THEN issue_token
+		    END,
+		    issue_provider = $1, issue_repository = $2, issue_base_url = $3, issue_title_template = $5
+		WHERE id = $6 AND deleted_at IS NULL
+	`, repo.Provider, repo.Repository, repo.BaseURL, repo.Token, repo.TitleTemplate, repo.ProjectID)
+}
+
+func (p *Postgres) UnlinkProjectRepository(ctx context.Context, projectID uuid.UUID) error {
+	return p.execOne(ctx, `
+		UPDATE projects
+		SET issue_provider = NULL, issue_repository = NULL, issue_base_url = NULL,
+		    issue_token = NULL, issue_title_template = NULL
+		WHERE id = $1 AND deleted_at IS NULL
+	`, projectID)
+}
+
+func (p *Postgres) ProjectIssues(ctx context.Context, projectID uuid.UUID) (map[uuid.UUID]int, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT v.id, v.issue_number
+		FROM vulnerabilities v
+		JOIN scans s ON s.id = v.scan_id
+		WHERE s.project_id = $1 AND v.issue_number IS NOT NULL
+	`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	issues := map[uuid.UUID]int{}
+	for rows.Next() {
+		var id uuid.UUID
+		var number int
+		if err := rows.Scan(&id, &number); err != nil {
+			return nil, err
+		}
+		issues[id] = number
+	}
+	return issues, rows.Err()
+}
+
+func (p *Postgres) SetVulnerabilityIssue(ctx context.Context, id uuid.UUID, url string, number int, state string, syncedAt time.Time) error {
+	return p.execOne(ctx, `
+		UPDATE vulnerabilities
+		SET issue_url = $1, issue_number = $2, issue_state = $3, issue_synced_at = $4
+		WHERE id = $5
+	`, url, number, state, syncedAt, id)
+}
+
+func (p *Postgres) SetVulnerabilityIssueState(ctx context.Context, id uuid.UUID, state string, syncedAt time.Time) error {
+	return p.execOne(ctx, `
+		UPDATE vulnerabilities SET issue_state = $1, issue_synced_at = $2 WHERE id = $3
+	`, state, syncedAt, id)
+}
+
+func (p *Postgres) NotificationPreferences(ctx context.Context, userID uuid.UUID) (*models.NotificationPreferences, error) {
+	prefs := models.NotificationPreferences{UserID: userID}
+	err := p.db.QueryRowContext(ctx, `
+		SELECT email_enabled, email, only_high, digest_mode, updated_at
+		FROM notification_preferences
+		WHERE user_id = $1
+	`, userID).Scan(&prefs.EmailEnabled, &prefs.Email, &prefs.OnlyHigh, &prefs.DigestMode, &prefs.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+func (p *Postgres) SaveNotificationPreferences(ctx context.Context, prefs models.NotificationPreferences) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO notification_preferences (user_id, email_enabled, email, only_high, digest_mode, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE
+		SET email_enabled = $2, email = $3, only_high = $4, digest_mode = $5, updated_at = $6
+	`, prefs.UserID, prefs.EmailEnabled, prefs.Email, prefs.OnlyHigh, prefs.DigestMode, prefs.UpdatedAt)
+	return err
+}
+
+func (p *Postgres) QuotaLimits(ctx context.Context, subject quota.Subject, defaults quota.Limits) (quota.Limits, error) {
+	limits := defaults
+
+	var concurrent, perDay, targets, storageBytes sql.NullInt64
+	err := p.db.QueryRowContext(ctx, `
+		SELECT concurrent_scans, scans_per_day, targets_per_project, report_storage_bytes
+		FROM quotas
+		WHERE subject_type = $1 AND subject_id = $2
+	`, subject.Type, subject.ID).Scan(&concurrent, &perDay, &targets, &storageBytes)
+	if err == sql.ErrNoRows {
+		return limits, nil
+	} else if err != nil {
+		return limits, err
+	}
+
+	if concurrent.Valid {
+		limits.ConcurrentScans = int(concurrent.Int64)
+	}
+	if perDay.Valid {
+		limits.ScansPerDay = int(perDay.Int64)
+	}
+	if targets.Valid {
+		limits.TargetsPerProject = int(targets.Int64)
+	}
+	if storageBytes.Valid {
+		limits.ReportStorageBytes = storageBytes.Int64
+	}
+	return limits, nil
+}
+
+func (p *Postgres) QuotaUsage(ctx context.Context, subject quota.Subject, since time.Time) (quota.Usage, error) {
+	condition := `p.organization_id IS NULL AND s.user_id = $1`
+	if subject.Type == quota.SubjectOrganization {
+		condition = `p.organization_id = $1`
+	}
+
+	var usage quota.Usage
+	var oldest database.NullTime
+	err := p.db.QueryRowContext(ctx, `
+		SELECT COUNT(CASE WHEN s.status IN ('Queued', 'In Progress') THEN 1 END),
+		       COUNT(CASE WHEN s.created_at >= $2 THEN 1 END),
+		       MIN(CASE WHEN s.created_at >= $2 THEN s.created_at END),
+		       COALESCE(SUM(s.report_size_bytes), 0)
+		FROM scans s
+		LEFT JOIN projects p ON p.id = s.project_id
+		WHERE `+condition, subject.ID, since).
+		Scan(&usage.ConcurrentScans, &usage.ScansPerDay, &oldest, &usage.ReportStorageBytes)
+	if err != nil {
+		return usage, err
+	}
+
+	if oldest.Valid {
+		usage.OldestToday = &oldest.Time
+	}
+	return usage, nil
+}
+
+func (p *Postgres) ProjectTargets(ctx context.Context, projectID uuid.UUID, targetURL string) (int, bool, error) {
+	var targets, sameTarget int
+	err := p.db.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT target_url), COUNT(CASE WHEN target_url = $2 THEN 1 END)
+		FROM scans
+		WHERE project_id = $1
+	`, projectID, targetURL).Scan(&targets, &sameTarget)
+	return targets, sameTarget > 0, err
+}
+
+// LockQuotas берет advisory-блокировки на отдельном соединении: они принадлежат сессии
+// и снимаются на том же соединении. SQLite работает на одном узле, и ему достаточно
+// блокировки процесса
+func (p *Postgres) LockQuotas(ctx context.Context, subjects []quota.Subject) (func(), error) {
+	if database.IsSQLite() {
+		return func() {}, nil
+	}
+
+	conn, err := p.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	unlock := func() {
+		if _, err := conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock_all()`); err != nil {
+			// Соединение с блокировками не должно вернуться в пул
+			conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	for _, subject := range subjects {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, subject.LockKey()); err != nil {
+			unlock()
+			return nil, err
+		}
+	}
+	return unlock, nil
+}
@@ -0,0 +1,630 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"slices"
+	"testing"
+	"time"
+
+	"chimerascan/database"
+	"chimerascan/models"
+	"chimerascan/quota"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgres_CreateVulnerabilityPassesJSONAsText(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	vuln := models.Vulnerability{
+		ID:     uuid.New(),
+		ScanID: uuid.New(),
+		Tags:   []byte(`["xss"]`),
+	}
+
+	args := make([]driver.Value, 22)
+	for i := range args {
+		args[i] = sqlmock.AnyArg()
+	}
+	args[8] = nil       // reference
+	args[9] = `["xss"]` // tags
+	mock.ExpectExec(`INSERT INTO vulnerabilities`).WithArgs(args...).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	require.NoError(t, NewPostgres(db).CreateVulnerability(context.Background(), &vuln))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgres_NotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	id := uuid.New()
+	mock.ExpectQuery(`SELECT id, target_url, status, project_id`).WithArgs(id).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(`UPDATE scans SET status = \$1 WHERE id = \$2`).WithArgs("Failed", id).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	p := NewPostgres(db)
+
+	_, err = p.GetScan(context.Background(), id)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, p.UpdateScanStatus(context.Background(), id, "Failed", nil), ErrNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgres_ProjectScans(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	ctx := context.Background()
+	p := NewPostgres(database.DB)
+	userID := uuid.New()
+	_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, userID, userID.String(), "user@example.com", "user")
+	require.NoError(t, err)
+
+	project := models.Project{ID: uuid.New(), Name: "Site", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, p.CreateProject(ctx, &project))
+
+	now := time.Now()
+	var ids []uuid.UUID
+	for i, status := range []string{"Completed", "Failed", "Completed"} {
+		scan := models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: status, ProjectID: &project.ID, UserID: userID, CreatedAt: now.Add(time.Duration(i) * time.Hour)}
+		require.NoError(t, p.CreateScan(ctx, &scan))
+		ids = append(ids, scan.ID)
+	}
+	for _, severity := range []string{"High", "high", "info"} {
+		vuln := models.Vulnerability{ID: uuid.New(), ScanID: ids[2], TemplateID: "xss", Name: "XSS", Severity: severity, SeverityAI: severity, Host: "example.com"}
+		require.NoError(t, p.CreateVulnerability(ctx, &vuln))
+	}
+
+	page, err := p.ListScans(ctx, userID, ScanQuery{ProjectID: &project.ID, Statuses: []string{"Completed"}, Sort: Sort{Field: "created_at", Desc: true}, Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, page.Total)
+	assert.True(t, page.HasMore)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, ids[2], page.Items[0].ID)
+	assert.Equal(t, map[string]int{"high": 2, "info": 1}, page.Items[0].SeverityCounts)
+
+	var got []uuid.UUID
+	after := (*Cursor)(nil)
+	for {
+		page, err = p.ListScans(ctx, userID, ScanQuery{ProjectID: &project.ID, Sort: Sort{Field: "created_at", Desc: true}, After: after, Limit: 2})
+		require.NoError(t, err)
+		assert.Equal(t, 3, page.Total)
+		for _, scan := range page.Items {
+			got = append(got, scan.ID)
+		}
+		if !page.HasMore {
+			break
+		}
+		last := page.Items[len(page.Items)-1]
+		after = &Cursor{Value: ScanSortValue(last, "created_at"), ID: last.ID}
+	}
+	assert.Equal(t, []uuid.UUID{ids[2], ids[1], ids[0]}, got)
+
+	page, err = p.ListScans(ctx, userID, ScanQuery{Sort: Sort{Field: "status"}, After: &Cursor{Value: ScanSortValue(models.Scan{Status: "Completed"}, "status"), ID: maxUUID(ids[0], ids[2])}})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, ids[1], page.Items[0].ID)
+
+	_, err = p.ListScans(ctx, userID, ScanQuery{After: &Cursor{Value: "yesterday"}})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	require.NoError(t, p.SetProjectArchived(ctx, project.ID, true))
+	projects, err := p.ListProjects(ctx, userID, ProjectQuery{Archived: true, Name: "sit"})
+	require.NoError(t, err)
+	require.Len(t, projects.Items, 1)
+	assert.NotNil(t, projects.Items[0].ArchivedAt)
+	projects, err = p.ListProjects(ctx, userID, ProjectQuery{Archived: true, Name: "%"})
+	require.NoError(t, err)
+	assert.Empty(t, projects.Items)
+
+	require.NoError(t, p.DeleteProject(ctx, project.ID))
+	assert.ErrorIs(t, p.DeleteProject(ctx, project.ID), ErrNotFound)
+	_, err = p.GetProject(ctx, project.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	access, err := p.ProjectAccess(ctx, project.ID, userID)
+	require.NoError(t, err)
+	assert.Empty(t, access.Role, "Deleted projects are inaccessible")
+
+	require.NoError(t, p.RestoreProject(ctx, project.ID))
+	restored, err := p.GetProject(ctx, project.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, restored.ArchivedAt, "Restore keeps the project archived")
+}
+
+// Сортировка по статусу идет в порядке жизненного цикла во всех хранилищах
+func TestListScans_StatusSortOrder(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	ctx := context.Background()
+	userID := uuid.New()
+	_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, userID, userID.String(), "user@example.com", "user")
+	require.NoError(t, err)
+
+	for name, s := range map[string]Store{"sqlite": NewPostgres(database.DB), "memory": NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			for _, status := range []string{"Canceled", "Queued", "Failed", "In Progress", "Completed"} {
+				scan := models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: status, UserID: userID, CreatedAt: time.Now()}
+				require.NoError(t, s.CreateScan(ctx, &scan))
+			}
+
+			for _, desc := range []bool{false, true} {
+				var got []string
+				after := (*Cursor)(nil)
+				for {
+					page, err := s.ListScans(ctx, userID, ScanQuery{Sort: Sort{Field: "status", Desc: desc}, After: after, Limit: 2})
+					require.NoError(t, err)
+					for _, scan := range page.Items {
+						got = append(got, scan.Status)
+					}
+					if !page.HasMore {
+						break
+					}
+					last := page.Items[len(page.Items)-1]
+					after = &Cursor{Value: ScanSortValue(last, "status"), ID: last.ID}
+				}
+
+				want := slices.Clone(ScanStatuses)
+				if desc {
+					slices.Reverse(want)
+				}
+				assert.Equal(t, want, got)
+			}
+		})
+	}
+}
+
+func maxUUID(a, b uuid.UUID) uuid.UUID {
+	if a.String() > b.String() {
+		return a
+	}
+	return b
+}
+
+func TestPostgres_ScanConfig(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	ctx := context.Background()
+	p := NewPostgres(database.DB)
+	userID := uuid.New()
+	_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, userID, userID.String(), "user@example.com", "user")
+	require.NoError(t, err)
+
+	original := models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: "Completed", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, p.CreateScan(ctx, &original))
+	retest := models.Scan{
+		ID: uuid.New(), TargetURL: "https://example.com", Status: "Queued", UserID: userID, CreatedAt: time.Now(),
+		Config:   &models.ScanConfig{Image: "nuclei", RateLimit: 10, Timeout: 30, Templates: []string{"xss"}, Targets: []string{"https://example.com/a"}},
+		RetestOf: &original.ID,
+	}
+	require.NoError(t, p.CreateScan(ctx, &retest))
+
+	scan, err := p.GetScan(ctx, original.ID)
+	require.NoError(t, err)
+	assert.Nil(t, scan.Config)
+	assert.Nil(t, scan.RetestOf)
+
+	scan, err = p.GetScan(ctx, retest.ID)
+	require.NoError(t, err)
+	assert.Equal(t, retest.Config, scan.Config)
+	assert.Equal(t, &original.ID, scan.RetestOf)
+
+	page, err := p.ListScans(ctx, userID, ScanQuery{Sort: Sort{Field: "created_at", Desc: true}})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, &original.ID, page.Items[0].RetestOf)
+
+	require.NoError(t, p.DeleteScan(ctx, original.ID))
+	scan, err = p.GetScan(ctx, retest.ID)
+	require.NoError(t, err)
+	assert.Nil(t, scan.RetestOf, "Retests outlive the original scan")
+}
+
+func TestPostgres_ScanDetails(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	ctx := context.Background()
+	p := NewPostgres(database.DB)
+	userID := uuid.New()
+	_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, userID, userID.String(), "user@example.com", "user")
+	require.NoError(t, err)
+
+	tagged := models.Scan{
+		ID: uuid.New(), TargetURL: "https://example.com", Status: "Completed", UserID: userID, CreatedAt: time.Now(),
+		Labels: []string{"release", "ci"}, Notes: "Deploy", Metadata: map[string]string{"env": "prod", "build_sha": "4f2a9c1"},
+	}
+	plain := models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: "Completed", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, p.CreateScan(ctx, &tagged))
+	require.NoError(t, p.CreateScan(ctx, &plain))
+
+	scan, err := p.GetScan(ctx, tagged.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ci", "release"}, scan.Labels)
+	assert.Equal(t, "Deploy", scan.Notes)
+	assert.Equal(t, tagged.Metadata, scan.Metadata)
+
+	list := func(query ScanQuery) []uuid.UUID {
+		page, err := p.ListScans(ctx, userID, query)
+		require.NoError(t, err)
+		var ids []uuid.UUID
+		for _, scan := range page.Items {
+			ids = append(ids, scan.ID)
+		}
+		return ids
+	}
+	assert.Equal(t, []uuid.UUID{tagged.ID}, list(ScanQuery{Labels: []string{"ci", "release"}}))
+	assert.Equal(t, []uuid.UUID{tagged.ID}, list(ScanQuery{Metadata: map[string]string{"env": "prod", "build_sha": "4f2a9c1"}}))
+	assert.Empty(t, list(ScanQuery{Labels: []string{"ci"}, Metadata: map[string]string{"env": "staging"}}))
+	assert.Len(t, list(ScanQuery{}), 2)
+
+	require.NoError(t, p.UpdateScanDetails(ctx, tagged.ID, []string{"hotfix"}, "", map[string]string{"ticket": "SEC-12"}))
+	scan, err = p.GetScan(ctx, tagged.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hotfix"}, scan.Labels)
+	assert.Empty(t, scan.Notes)
+	assert.Equal(t, map[string]string{"ticket": "SEC-12"}, scan.Metadata)
+	assert.ErrorIs(t, p.UpdateScanDetails(ctx, uuid.New(), nil, "", nil), ErrNotFound)
+
+	require.NoError(t, p.DeleteScan(ctx, tagged.ID))
+	var count int
+	require.NoError(t, database.DB.QueryRow(`SELECT COUNT(*) FROM scan_labels`).Scan(&count))
+	assert.Zero(t, count)
+}
+
+func TestPostgres_BulkScans(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	ctx := context.Background()
+	p := NewPostgres(database.DB)
+	userID := uuid.New()
+	_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, userID, userID.String(), "user@example.com", "user")
+	require.NoError(t, err)
+	project := models.Project{ID: uuid.New(), Name: "Site", UserID: userID, CreatedAt: time.Now()}
+	require.NoError(t, p.CreateProject(ctx, &project))
+
+	newScan := func(status string) *models.Scan {
+		return &models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: status, UserID: userID, CreatedAt: time.Now(), Labels: []string{"ci"}}
+	}
+	queued, completed := newScan("Queued"), newScan("Completed")
+	require.NoError(t, p.CreateScans(ctx, []*models.Scan{queued, completed}))
+
+	// Повтор идентификатора откатывает всю вставку
+	extra := newScan("Queued")
+	assert.Error(t, p.CreateScans(ctx, []*models.Scan{extra, queued}))
+	_, err = p.GetScan(ctx, extra.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	canceled, err := p.CancelScans(ctx, []uuid.UUID{queued.ID, completed.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{queued.ID}, canceled)
+	scan, err := p.GetScan(ctx, completed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Completed", scan.Status)
+
+	assert.ErrorIs(t, p.SetScansProject(ctx, []uuid.UUID{queued.ID, uuid.New()}, &project.ID), ErrNotFound)
+	scan, err = p.GetScan(ctx, queued.ID)
+	require.NoError(t, err)
+	assert.Nil(t, scan.ProjectID, "Move is rolled back")
+	require.NoError(t, p.SetScansProject(ctx, []uuid.UUID{queued.ID, completed.ID}, &project.ID))
+	scan, err = p.GetScan(ctx, completed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &project.ID, scan.ProjectID)
+
+	assert.ErrorIs(t, p.DeleteScans(ctx, []uuid.UUID{queued.ID, uuid.New()}), ErrNotFound)
+	_, err = p.GetScan(ctx, queued.ID)
+	assert.NoError(t, err, "Delete is rolled back")
+	require.NoError(t, p.DeleteScans(ctx, []uuid.UUID{queued.ID, completed.ID}))
+	var count int
+	require.NoError(t, database.DB.QueryRow(`SELECT COUNT(*) FROM scans`).Scan(&count))
+	assert.Zero(t, count)
+}
+
+func TestPostgres_CanceledScanStaysCanceled(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	ctx := context.Background()
+	p := NewPostgres(database.DB)
+	userID := uuid.New()
+	_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, userID, userID.String(), "user@example.com", "user")
+	require.NoError(t, err)
+
+	newScan := func() *models.Scan {
+		return &models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: "Queued", UserID: userID, CreatedAt: time.Now()}
+	}
+	completion := ScanCompletion{FinishedAt: time.Now(), ReportPaths: map[string]string{"json": "scan.json"}}
+
+	// Отмена до запуска: сканирование не переходит в работу
+	early := newScan()
+	require.NoError(t, p.CreateScan(ctx, early))
+	_, err = p.CancelScans(ctx, []uuid.UUID{early.ID})
+	require.NoError(t, err)
+	assert.ErrorIs(t, p.StartScan(ctx, early.ID, time.Now()), ErrNotFound)
+
+	// Отмена во время работы: сканирование не завершается
+	running := newScan()
+	require.NoError(t, p.CreateScan(ctx, running))
+	require.NoError(t, p.StartScan(ctx, running.ID, time.Now()))
+	_, err = p.CancelScans(ctx, []uuid.UUID{running.ID})
+	require.NoError(t, err)
+	assert.ErrorIs(t, p.CompleteScan(ctx, running.ID, completion), ErrNotFound)
+
+	for _, id := range []uuid.UUID{early.ID, running.ID} {
+		scan, err := p.GetScan(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "Canceled", scan.Status)
+		assert.Empty(t, scan.ReportJSONPath)
+	}
+
+	completed := newScan()
+	require.NoError(t, p.CreateScan(ctx, completed))
+	require.NoError(t, p.StartScan(ctx, completed.ID, time.Now()))
+	require.NoError(t, p.CompleteScan(ctx, completed.ID, completion))
+	scan, err := p.GetScan(ctx, completed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Completed", scan.Status)
+	assert.NotNil(t, scan.StartedAt)
+}
+
+// Потребление квот одинаково считается во всех хранилищах
+func TestQuotaUsage(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	ctx := context.Background()
+	userID, orgID := uuid.New(), uuid.New()
+	_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, userID, userID.String(), "user@example.com", "user")
+	require.NoError(t, err)
+	_, err = database.DB.Exec(`INSERT INTO organizations (id, name, created_at) VALUES ($1, $2, $3)`, orgID, "Acme", time.Now())
+	require.NoError(t, err)
+
+	for name, s := range map[string]Store{"sqlite": NewPostgres(database.DB), "memory": NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			personal := models.Project{ID: uuid.New(), Name: "Personal", UserID: userID, CreatedAt: time.Now()}
+			shared := models.Project{ID: uuid.New(), Name: "Shared", UserID: userID, OrganizationID: &orgID, CreatedAt: time.Now()}
+			require.NoError(t, s.CreateProject(ctx, &personal))
+			require.NoError(t, s.CreateProject(ctx, &shared))
+
+			old := time.Now().Add(-48 * time.Hour)
+			scans := []models.Scan{
+				{ID: uuid.New(), TargetURL: "https://a.example.com", Status: "In Progress", UserID: userID, CreatedAt: old},
+				{ID: uuid.New(), TargetURL: "https://b.example.com", Status: "Queued", ProjectID: &personal.ID, UserID: userID, CreatedAt: time.Now()},
+				{ID: uuid.New(), TargetURL: "https://c.example.com", Status: "Queued", ProjectID: &shared.ID, UserID: userID, CreatedAt: time.Now()},
+			}
+			for i := range scans {
+				require.NoError(t, s.CreateScan(ctx, &scans[i]))
+			}
+			require.NoError(t, s.CompleteScan(ctx, scans[0].ID, ScanCompletion{FinishedAt: time.Now(), ReportSize: 100}))
+
+			usage, err := s.QuotaUsage(ctx, quota.Subject{Type: quota.SubjectUser, ID: userID}, time.Now().Add(-24*time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 1, usage.ConcurrentScans, "Organization scans are not counted for the user")
+			assert.Equal(t, 1, usage.ScansPerDay)
+			assert.Equal(t, int64(100), usage.ReportStorageBytes)
+			if assert.NotNil(t, usage.OldestToday) {
+				assert.WithinDuration(t, scans[1].CreatedAt, *usage.OldestToday, time.Second)
+			}
+
+			usage, err = s.QuotaUsage(ctx, quota.Subject{Type: quota.SubjectOrganization, ID: orgID}, time.Now().Add(-24*time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, 1, usage.ConcurrentScans)
+			assert.Equal(t, 1, usage.ScansPerDay)
+
+			targets, known, err := s.ProjectTargets(ctx, shared.ID, "https://c.example.com")
+			require.NoError(t, err)
+			assert.Equal(t, 1, targets)
+			assert.True(t, known)
+
+			limits, err := s.QuotaLimits(ctx, quota.Subject{Type: quota.SubjectUser, ID: userID}, quota.Limits{ScansPerDay: 5})
+			require.NoError(t, err)
+			assert.Equal(t, quota.Limits{ScansPerDay: 5}, limits, "No overrides")
+		})
+	}
+}
+
+func TestPostgres_DisableUserRevokesSessions(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	ctx := context.Background()
+	p := NewPostgres(database.DB)
+	userID := uuid.New()
+	_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, userID, userID.String(), "user@example.com", "user")
+	require.NoError(t, err)
+	_, err = database.DB.Exec(`INSERT INTO sessions (id, user_id, token_hash, expires_at) VALUES ($1, $2, $3, $4)`, uuid.New(), userID, "hash", time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	disabledAt := time.Now().Add(-time.Hour)
+	require.NoError(t, p.DisableUser(ctx, userID, disabledAt))
+	require.NoError(t, p.DisableUser(ctx, userID, time.Now()))
+
+	user, err := p.GetUser(ctx, userID)
+	require.NoError(t, err)
+	if assert.NotNil(t, user.DisabledAt) {
+		assert.WithinDuration(t, disabledAt, *user.DisabledAt, time.Second, "The first disable time is kept")
+	}
+	var sessions int
+	require.NoError(t, database.DB.QueryRow(`SELECT COUNT(*) FROM sessions WHERE user_id = $1`, userID).Scan(&sessions))
+	assert.Zero(t, sessions)
+
+	assert.ErrorIs(t, p.DisableUser(ctx, uuid.New(), time.Now()), ErrNotFound)
+}
+
+func TestWebhooks(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	ctx := context.Background()
+	for name, s := range map[string]Store{"sqlite": NewPostgres(database.DB), "memory": NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			ownerID, memberID := uuid.New(), uuid.New()
+			for _, id := range []uuid.UUID{ownerID, memberID} {
+				_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, id, id.String(), id.String()+"@example.com", id.String())
+				require.NoError(t, err)
+			}
+			org := models.Organization{ID: uuid.New(), Name: "Acme", CreatedAt: time.Now()}
+			require.NoError(t, s.CreateOrganization(ctx, &org, ownerID))
+			require.NoError(t, s.AddOrganizationMember(ctx, models.OrganizationMember{OrganizationID: org.ID, UserID: memberID, Role: "analyst", CreatedAt: time.Now()}))
+			project := models.Project{ID: uuid.New(), Name: "Shared", UserID: ownerID, OrganizationID: &org.ID, CreatedAt: time.Now()}
+			require.NoError(t, s.CreateProject(ctx, &project))
+
+			personal := models.Webhook{ID: uuid.New(), UserID: memberID, URL: "https://hooks.example.com/a", Secret: "s", Events: []string{"scan.completed"}, Active: true, CreatedAt: time.Now().Add(-time.Minute)}
+			shared := models.Webhook{ID: uuid.New(), UserID: memberID, ProjectID: &project.ID, URL: "https://hooks.example.com/b", Secret: "s", Events: []string{}, Active: true, CreatedAt: time.Now()}
+			require.NoError(t, s.CreateWebhook(ctx, &personal))
+			require.NoError(t, s.CreateWebhook(ctx, &shared))
+
+			webhooks, err := s.ListWebhooks(ctx, memberID)
+			require.NoError(t, err)
+			if assert.Len(t, webhooks, 2) {
+				assert.Equal(t, shared.ID, webhooks[0].ID, "Newest first")
+				assert.Equal(t, personal.Events, webhooks[1].Events)
+				assert.Empty(t, webhooks[1].Secret, "Secrets are not listed")
+			}
+
+			_, err = s.ListWebhookDeliveries(ctx, personal.ID, ownerID, 100)
+			assert.ErrorIs(t, err, ErrNotFound, "Deliveries of another user's webhook")
+			deliveries, err := s.ListWebhookDeliveries(ctx, personal.ID, memberID, 100)
+			require.NoError(t, err)
+			assert.Empty(t, deliveries)
+
+			// Бывший участник не получает события проектов организации
+			require.NoError(t, s.RemoveOrganizationMember(ctx, org.ID, memberID))
+			webhooks, err = s.ListWebhooks(ctx, memberID)
+			require.NoError(t, err)
+			if assert.Len(t, webhooks, 1) {
+				assert.Equal(t, personal.ID, webhooks[0].ID)
+			}
+
+			assert.ErrorIs(t, s.DeleteWebhook(ctx, personal.ID, ownerID), ErrNotFound)
+			require.NoError(t, s.DeleteWebhook(ctx, personal.ID, memberID))
+			assert.ErrorIs(t, s.DeleteWebhook(ctx, personal.ID, memberID), ErrNotFound)
+		})
+	}
+}
+
+func TestFindingSeen(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	ctx := context.Background()
+	for name, s := range map[string]Store{"sqlite": NewPostgres(database.DB), "memory": NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			alice, bob := uuid.New(), uuid.New()
+			for _, id := range []uuid.UUID{alice, bob} {
+				_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, id, id.String(), id.String()+"@example.com", id.String())
+				require.NoError(t, err)
+			}
+			project := models.Project{ID: uuid.New(), Name: "Shared", UserID: alice, CreatedAt: time.Now()}
+			require.NoError(t, s.CreateProject(ctx, &project))
+
+			scan := func(userID uuid.UUID, projectID *uuid.UUID, target string) uuid.UUID {
+				scan := models.Scan{ID: uuid.New(), TargetURL: target, Status: "Completed", ProjectID: projectID, UserID: userID, CreatedAt: time.Now()}
+				require.NoError(t, s.CreateScan(ctx, &scan))
+				return scan.ID
+			}
+			found := func(scanID uuid.UUID) {
+				require.NoError(t, s.CreateVulnerability(ctx, &models.Vulnerability{ID: uuid.New(), ScanID: scanID, TemplateID: "sqli", MatchedAt: "https://example.com/?id=1", Severity: "high"}))
+			}
+			seen := func(scanID uuid.UUID) bool {
+				seen, err := s.FindingSeen(ctx, scanID, "sqli", "https://example.com/?id=1")
+				require.NoError(t, err)
+				return seen
+			}
+
+			first := scan(alice, &project.ID, "https://example.com")
+			found(first)
+			assert.False(t, seen(first), "The scan itself is not history")
+			assert.True(t, seen(scan(bob, &project.ID, "https://example.com")))
+			assert.False(t, seen(scan(bob, &project.ID, "https://other.example.com")), "Another target")
+			assert.False(t, seen(scan(alice, nil, "https://example.com")), "Project history does not leak into personal scans")
+
+			found(scan(bob, nil, "https://example.com"))
+			assert.True(t, seen(scan(bob, nil, "https://example.com")))
+			assert.False(t, seen(scan(alice, nil, "https://example.com")), "Personal history of another user")
+		})
+	}
+}
+
+func TestDeleteUser_KeepsOrganizationResources(t *testing.T) {
+	require.NoError(t, database.InitTestDB())
+	defer database.CloseDB()
+
+	ctx := context.Background()
+	for name, s := range map[string]Store{"sqlite": NewPostgres(database.DB), "memory": NewMemory()} {
+		t.Run(name, func(t *testing.T) {
+			ownerID, memberID, bobID := uuid.New(), uuid.New(), uuid.New()
+			for _, id := range []uuid.UUID{ownerID, memberID, bobID} {
+				_, err := database.DB.Exec(`INSERT INTO users (id, provider_id, email, username) VALUES ($1, $2, $3, $4)`, id, id.String(), id.String()+"@example.com", id.String())
+				require.NoError(t, err)
+				if m, ok := s.(*Memory); ok {
+					m.AddUser(models.User{ID: id, Username: id.String()})
+				}
+			}
+			org := models.Organization{ID: uuid.New(), Name: "Acme", CreatedAt: time.Now()}
+			require.NoError(t, s.CreateOrganization(ctx, &org, ownerID))
+			for _, id := range []uuid.UUID{memberID, bobID} {
+				require.NoError(t, s.AddOrganizationMember(ctx, models.OrganizationMember{OrganizationID: org.ID, UserID: id, Role: "analyst", CreatedAt: time.Now()}))
+			}
+
+			shared := models.Project{ID: uuid.New(), Name: "Shared", UserID: memberID, OrganizationID: &org.ID, CreatedAt: time.Now()}
+			personal := models.Project{ID: uuid.New(), Name: "Personal", UserID: memberID, CreatedAt: time.Now()}
+			require.NoError(t, s.CreateProject(ctx, &shared))
+			require.NoError(t, s.CreateProject(ctx, &personal))
+
+			scan := func(userID uuid.UUID, projectID *uuid.UUID) models.Scan {
+				scan := models.Scan{ID: uuid.New(), TargetURL: "https://example.com", Status: "Queued", ProjectID: projectID, UserID: userID, CreatedAt: time.Now()}
+				require.NoError(t, s.CreateScan(ctx, &scan))
+				require.NoError(t, s.StartScan(ctx, scan.ID, time.Now()))
+				return scan
+			}
+			memberShared := scan(memberID, &shared.ID)
+			bobShared := scan(bobID, &shared.ID)
+			memberPersonal := scan(memberID, &personal.ID)
+			require.NoError(t, s.CompleteScan(ctx, bobShared.ID, ScanCompletion{FinishedAt: time.Now(), ReportPaths: map[string]string{"json": "bob.json"}}))
+			require.NoError(t, s.CompleteScan(ctx, memberPersonal.ID, ScanCompletion{FinishedAt: time.Now(), ReportPaths: map[string]string{"json": "personal.json"}}))
+			vuln := models.Vulnerability{ID: uuid.New(), ScanID: bobShared.ID, TemplateID: "xss", Name: "XSS", Severity: "high"}
+			require.NoError(t, s.CreateVulnerability(ctx, &vuln))
+
+			deleted, err := s.DeleteUser(ctx, memberID)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"personal.json"}, deleted.Files.ReportPaths, "Only personal scans are removed")
+			assert.Empty(t, deleted.ActiveScans, "Organization scans keep running")
+
+			project, err := s.GetProject(ctx, shared.ID)
+			require.NoError(t, err, "The organization project survives")
+			assert.Equal(t, ownerID, project.UserID, "It passes to the organization owner")
+			_, err = s.GetProject(ctx, personal.ID)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			// Сканирования проекта организации остаются в проекте; сканирование удаленного
+			// участника переходит к владельцу организации
+			for id, userID := range map[uuid.UUID]uuid.UUID{memberShared.ID: ownerID, bobShared.ID: bobID} {
+				kept, err := s.GetScan(ctx, id)
+				require.NoError(t, err)
+				assert.Equal(t, userID, kept.UserID)
+				if assert.NotNil(t, kept.ProjectID) {
+					assert.Equal(t, shared.ID, *kept.ProjectID)
+				}
+			}
+			_, err = s.GetVulnerability(ctx, vuln.ID)
+			assert.NoError(t, err)
+			_, err = s.GetScan(ctx, memberPersonal.ID)
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			members, err := s.ListOrganizationMembers(ctx, org.ID)
+			require.NoError(t, err)
+			assert.Len(t, members, 2)
+		})
+	}
+}
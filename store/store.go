@@ -0,0 +1,301 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"chimerascan/models"
+	"chimerascan/quota"
+	"chimerascan/rbac"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound возвращается, когда запись не найдена
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidCursor возвращается, когда курсор не подходит к сортировке списка
+	ErrInvalidCursor = errors.New("invalid cursor")
+	// ErrLastOwner возвращается, когда действие оставило бы организацию без владельца
+	ErrLastOwner = errors.New("last organization owner")
+	// ErrExists возвращается, когда запись уже существует
+	ErrExists = errors.New("already exists")
+	// ErrAmbiguous возвращается, когда под условие поиска подходят несколько записей
+	ErrAmbiguous = errors.New("ambiguous")
+)
+
+// UserSummary - пользователь с числом его сканирований
+type UserSummary struct {
+	models.User
+	ScanCount int
+}
+
+// DeletedUser - что нужно убрать после удаления пользователя: файлы его удаленных сканирований
+// во внешнем хранилище и процессы тех из них, которые еще выполнялись
+type DeletedUser struct {
+	Files       ScanFiles
+	ActiveScans []uuid.UUID
+}
+
+// UserStore - доступ к пользователям и их ролям в организациях
+type UserStore interface {
+	GetUser(ctx context.Context, id uuid.UUID) (*models.User, error)
+	// FindUser ищет пользователя по ID, имени или email. Имя одного пользователя может
+	// совпадать с email другого - тогда возвращается ErrAmbiguous, а не случайный из них
+	FindUser(ctx context.Context, login string) (*models.User, error)
+	// ListUsers возвращает всех пользователей в порядке регистрации
+	ListUsers(ctx context.Context) ([]UserSummary, error)
+	// DisableUser запрещает вход и завершает сессии пользователя; время отключения не перезаписывается
+	DisableUser(ctx context.Context, id uuid.UUID, at time.Time) error
+	EnableUser(ctx context.Context, id uuid.UUID) error
+	// DeleteUser удаляет пользователя вместе с его личными проектами и сканированиями.
+	// Его проекты и сканирования в организациях переходят к другому владельцу организации;
+	// ErrLastOwner, если он последний владелец организации
+	DeleteUser(ctx context.Context, id uuid.UUID) (DeletedUser, error)
+	// OrganizationRole возвращает роль пользователя в организации или пустую строку
+	OrganizationRole(ctx context.Context, orgID, userID uuid.UUID) (string, error)
+}
+
+// Sort - поле и направление сортировки списка; при равных значениях записи упорядочиваются по ID
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// Cursor - позиция в списке: значение поля сортировки и ID последней записи предыдущей страницы
+type Cursor struct {
+	Value string
+	ID    uuid.UUID
+}
+
+// Page - страница списка и общее число записей, подходящих под фильтр
+type Page[T any] struct {
+	Items   []T
+	Total   int
+	HasMore bool // после страницы есть еще записи
+}
+
+// Поля сортировки списков
+var (
+	ScanSortFields    = []string{"created_at", "target_url", "status"}
+	ProjectSortFields = []string{"created_at", "name"}
+)
+
+// ScanStatuses - статусы сканирования в порядке жизненного цикла; в этом порядке они и сортируются
+var ScanStatuses = []string{"Queued", "In Progress", "Completed", "Failed", "Canceled"}
+
+// scanStatusRank - позиция статуса в ScanStatuses; неизвестные статусы идут последними
+func scanStatusRank(status string) string {
+	rank := slices.Index(ScanStatuses, status)
+	if rank < 0 {
+		rank = len(ScanStatuses)
+	}
+	return strconv.Itoa(rank)
+}
+
+// ScanQuery - фильтр, сортировка и страница списка сканирований
+type ScanQuery struct {
+	Statuses  []string          // пустой список - любой статус
+	ProjectID *uuid.UUID        // только сканирования проекта
+	Target    string            // подстрока адреса цели без учета регистра
+	Since     *time.Time        // созданы не раньше
+	Until     *time.Time        // созданы раньше
+	Labels    []string          // есть все метки
+	Metadata  map[string]string // есть все пары ключ-значение
+	Sort      Sort
+	After     *Cursor
+	Limit     int // 0 - без ограничения
+}
+
+// ProjectQuery - фильтр, сортировка и страница списка проектов
+type ProjectQuery struct {
+	Archived bool   // только архивные проекты вместо активных
+	Name     string // подстрока названия без учета регистра
+	Sort     Sort
+	After    *Cursor
+	Limit    int // 0 - без ограничения
+}
+
+// Формат времени в курсоре: фиксированная ширина, чтобы строки сравнивались как время
+const cursorTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// ScanSortValue возвращает значение поля сортировки сканирования для курсора
+func ScanSortValue(scan models.Scan, field string) string {
+	switch field {
+	case "target_url":
+		return scan.TargetURL
+	case "status":
+		return scanStatusRank(scan.Status)
+	default:
+		return scan.CreatedAt.UTC().Format(cursorTimeFormat)
+	}
+}
+
+// ProjectSortValue возвращает значение поля сортировки проекта для курсора
+func ProjectSortValue(project models.Project, field string) string {
+	if field == "name" {
+		return project.Name
+	}
+	return project.CreatedAt.UTC().Format(cursorTimeFormat)
+}
+
+// OrganizationStore - организации и их участники
+type OrganizationStore interface {
+	// CreateOrganization создает организацию, ownerID становится ее владельцем
+	CreateOrganization(ctx context.Context, org *models.Organization, ownerID uuid.UUID) error
+	// ListOrganizations возвращает организации пользователя с его ролью
+	ListOrganizations(ctx context.Context, userID uuid.UUID) ([]models.Organization, error)
+	// DeleteOrganization удаляет организацию вместе с ее проектами
+	DeleteOrganization(ctx context.Context, id uuid.UUID) error
+	ListOrganizationMembers(ctx context.Context, orgID uuid.UUID) ([]models.OrganizationMember, error)
+	// AddOrganizationMember добавляет участника; ErrExists, если он уже состоит в организации
+	AddOrganizationMember(ctx context.Context, member models.OrganizationMember) error
+	// UpdateOrganizationMember меняет роль участника; ErrLastOwner, если это последний владелец
+	UpdateOrganizationMember(ctx context.Context, orgID, userID uuid.UUID, role string) error
+	// RemoveOrganizationMember исключает участника и удаляет его вебхуки на проекты организации;
+	// ErrLastOwner, если это последний владелец
+	RemoveOrganizationMember(ctx context.Context, orgID, userID uuid.UUID) error
+}
+
+// ProjectStore - доступ к проектам
+type ProjectStore interface {
+	CreateProject(ctx context.Context, project *models.Project) error
+	// GetProject возвращает проект, в том числе архивный; удаленный проект не найден
+	GetProject(ctx context.Context, id uuid.UUID) (*models.Project, error)
+	// ListProjects возвращает страницу проектов, видимых пользователю
+	ListProjects(ctx context.Context, userID uuid.UUID, query ProjectQuery) (Page[models.Project], error)
+	UpdateProject(ctx context.Context, id uuid.UUID, name, description string) error
+	// SetProjectArchived переносит проект в архив или возвращает из него
+	SetProjectArchived(ctx context.Context, id uuid.UUID, archived bool) error
+	// DeleteProject помечает проект удаленным; его сканирования остаются в проекте
+	DeleteProject(ctx context.Context, id uuid.UUID) error
+	// RestoreProject восстанавливает удаленный проект
+	RestoreProject(ctx context.Context, id uuid.UUID) error
+	// ProjectAccess возвращает роль пользователя в проекте; пустая роль - проект недоступен
+	ProjectAccess(ctx context.Context, id, userID uuid.UUID) (rbac.Access, error)
+	// MoveProject переносит проект в организацию; без организации проект становится личным проектом userID
+	MoveProject(ctx context.Context, id uuid.UUID, orgID *uuid.UUID, userID uuid.UUID) error
+}
+
+// ScanCompletion - результат завершенного сканирования
+type ScanCompletion struct {
+	FinishedAt      time.Time
+	RawNucleiOutput string
+	ReportPaths     map[string]string
+	ReportSize      int64
+}
+
+// ScanFiles - файлы сканирования во внешнем хранилище: пути отчетов из scans
+// и ключи сжатых ответов, вынесенных из уязвимостей
+type ScanFiles struct {
+	ReportPaths  []string
+	EvidenceKeys []string
+}
+
+// ActiveScan - сканирование в очереди или выполняющееся, с именем запустившего его пользователя
+type ActiveScan struct {
+	models.Scan
+	Username string
+}
+
+// ScanStore - доступ к сканированиям
+type ScanStore interface {
+	CreateScan(ctx context.Context, scan *models.Scan) error
+	// CreateScans сохраняет сканирования в одной транзакции: все или ни одного
+	CreateScans(ctx context.Context, scans []*models.Scan) error
+	GetScan(ctx context.Context, id uuid.UUID) (*models.Scan, error)
+	// ListScans возвращает страницу сканирований, видимых пользователю, с числом уязвимостей по критичности
+	ListScans(ctx context.Context, userID uuid.UUID, query ScanQuery) (Page[models.Scan], error)
+	SetScanProject(ctx context.Context, id uuid.UUID, projectID *uuid.UUID) error
+	// SetScansProject переносит сканирования в проект в одной транзакции; ErrNotFound, если какого-то нет
+	SetScansProject(ctx context.Context, ids []uuid.UUID, projectID *uuid.UUID) error
+	// UpdateScanDetails заменяет метки, заметки и метаданные сканирования
+	UpdateScanDetails(ctx context.Context, id uuid.UUID, labels []string, notes string, metadata map[string]string) error
+	// UpdateScanStatus меняет статус; startedAt, если задан, обновляет время начала
+	UpdateScanStatus(ctx context.Context, id uuid.UUID, status string, startedAt *time.Time) error
+	// StartScan переводит сканирование из Queued в In Progress; ErrNotFound, если оно уже не в очереди
+	StartScan(ctx context.Context, id uuid.UUID, startedAt time.Time) error
+	// CancelScans в одной транзакции отменяет сканирования в статусе Queued или In Progress
+	// и возвращает отмененные; остальные не меняются
+	CancelScans(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, error)
+	// CompleteScan завершает сканирование в статусе In Progress; ErrNotFound, если его отменили
+	CompleteScan(ctx context.Context, id uuid.UUID, completion ScanCompletion) error
+	// ScanFiles возвращает файлы сканирования, которые нужно удалить вместе с ним
+	ScanFiles(ctx context.Context, id uuid.UUID) (ScanFiles, error)
+	// DeleteScan удаляет сканирование вместе с его уязвимостями; файлы остаются в хранилище
+	DeleteScan(ctx context.Context, id uuid.UUID) error
+	// DeleteScans удаляет сканирования в одной транзакции; ErrNotFound, если какого-то нет
+	DeleteScans(ctx context.Context, ids []uuid.UUID) error
+	// ScanAccess возвращает роль пользователя для сканирования; пустая роль - сканирование недоступно
+	ScanAccess(ctx context.Context, id, userID uuid.UUID) (rbac.Access, error)
+	// ListActiveScans возвращает сканирования всех пользователей в очереди или выполняющиеся
+	ListActiveScans(ctx context.Context) ([]ActiveScan, error)
+	// ScanQueue возвращает число сканирований в очереди и выполняющихся
+	ScanQueue(ctx context.Context) (queued, inProgress int, err error)
+	// ReportsFinishedBefore возвращает пути отчетов сканирований, завершенных до before
+	ReportsFinishedBefore(ctx context.Context, before time.Time) (map[uuid.UUID][]string, error)
+	// ClearScanReports забывает отчеты сканирования; файлы остаются в хранилище
+	ClearScanReports(ctx context.Context, id uuid.UUID) error
+}
+
+// VulnerabilityStore - доступ к найденным уязвимостям
+type VulnerabilityStore interface {
+	CreateVulnerability(ctx context.Context, vuln *models.Vulnerability) error
+	GetVulnerability(ctx context.Context, id uuid.UUID) (*models.Vulnerability, error)
+	ListVulnerabilities(ctx context.Context, scanID uuid.UUID) ([]models.Vulnerability, error)
+	// FindingSeen сообщает, находили ли шаблон по тому же адресу прошлые сканирования той же цели:
+	// в том же проекте, а для сканирования без проекта - личные сканирования того же пользователя
+	FindingSeen(ctx context.Context, scanID uuid.UUID, templateID, matchedAt string) (bool, error)
+}
+
+// WebhookStore - подписки пользователей на события сканирований и журнал их доставок
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	// ListWebhooks возвращает подписки пользователя без секретов, новые первыми
+	ListWebhooks(ctx context.Context, userID uuid.UUID) ([]models.Webhook, error)
+	// DeleteWebhook удаляет подписку пользователя; ErrNotFound, если ее нет
+	DeleteWebhook(ctx context.Context, id, userID uuid.UUID) error
+	// ListWebhookDeliveries возвращает последние limit доставок подписки пользователя, новые первыми;
+	// ErrNotFound, если подписки нет
+	ListWebhookDeliveries(ctx context.Context, id, userID uuid.UUID, limit int) ([]models.WebhookDelivery, error)
+}
+
+// IssueStore - привязка проектов к трекерам задач и задачи, созданные по находкам
+type IssueStore interface {
+	// ProjectRepository возвращает привязку проекта к репозиторию; токен остается в том виде,
+	// в каком был сохранен
+	ProjectRepository(ctx context.Context, projectID uuid.UUID) (*models.ProjectRepository, error)
+	// LinkProjectRepository сохраняет привязку. Пустой токен оставляет прежний, только если
+	// провайдер и адрес трекера не изменились, иначе токен сбрасывается
+	LinkProjectRepository(ctx context.Context, repo models.ProjectRepository) error
+	UnlinkProjectRepository(ctx context.Context, projectID uuid.UUID) error
+	// ProjectIssues возвращает номера задач, созданных по находкам проекта
+	ProjectIssues(ctx context.Context, projectID uuid.UUID) (map[uuid.UUID]int, error)
+	// SetVulnerabilityIssue запоминает задачу, созданную по находке
+	SetVulnerabilityIssue(ctx context.Context, id uuid.UUID, url string, number int, state string, syncedAt time.Time) error
+	// SetVulnerabilityIssueState обновляет состояние задачи находки
+	SetVulnerabilityIssueState(ctx context.Context, id uuid.UUID, state string, syncedAt time.Time) error
+}
+
+// NotificationStore - настройки уведомлений пользователей
+type NotificationStore interface {
+	// NotificationPreferences возвращает настройки пользователя; ErrNotFound, если он их не сохранял
+	NotificationPreferences(ctx context.Context, userID uuid.UUID) (*models.NotificationPreferences, error)
+	SaveNotificationPreferences(ctx context.Context, prefs models.NotificationPreferences) error
+}
+
+// Store объединяет все хранилища одной реализации
+type Store interface {
+	UserStore
+	OrganizationStore
+	ProjectStore
+	ScanStore
+	VulnerabilityStore
+	WebhookStore
+	IssueStore
+	NotificationStore
+	quota.Store
+}
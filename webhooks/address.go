@@ -0,0 +1,136 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress - адрес подписчика ведет во внутреннюю сеть: петлевой, частный,
+// link-local (в том числе адрес метаданных облака) и другие служебные адреса
+var ErrForbiddenAddress = errors.New("webhook address is not allowed")
+
+// Служебные сети, не покрытые методами net.IP
+var forbiddenNetworks = parseNetworks(
+	"0.0.0.0/8",     // "эта" сеть
+	"100.64.0.0/10", // CGNAT, в том числе метаданные некоторых облаков
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // тестирование производительности
+	"240.0.0.0/4",   // зарезервировано, включая 255.255.255.255
+	"64:ff9b::/96",  // NAT64 с внутренними IPv4
+	"2001:db8::/32", // документация
+)
+
+// Доставка во внутреннюю сеть; включается только в тестах с локальным сервером
+var allowPrivateAddresses = false
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// isPublicIP сообщает, можно ли отправлять события на адрес
+func isPublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range forbiddenNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateURL проверяет адрес подписчика при создании подписки: схема http или https
+// и хост, все адреса которого публичные. При доставке адрес проверяется еще раз,
+// потому что DNS может вернуть другой адрес
+func ValidateURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("missing host")
+	}
+	if allowPrivateAddresses {
+		return nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if !isPublicIP(ip) {
+			return ErrForbiddenAddress
+		}
+		return nil
+	}
+	if strings.EqualFold(strings.TrimSuffix(host, "."), "localhost") {
+		return ErrForbiddenAddress
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve host %s", host)
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return ErrForbiddenAddress
+		}
+	}
+	return nil
+}
+
+// checkDialAddress не дает подключиться к внутреннему адресу, в том числе
+// после перенаправления или смены записи DNS
+func checkDialAddress(network, address string, _ syscall.RawConn) error {
+	if allowPrivateAddresses {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublicIP(net.ParseIP(host)) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
+
+// newClient создает HTTP-клиент доставки. Прокси из окружения не используется:
+// иначе проверялся бы адрес прокси, а не подписчика
+func newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: checkDialAddress}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
@@ -0,0 +1,258 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"chimerascan/database"
+
+	"github.com/google/uuid"
+)
+
+// Типы событий жизненного цикла сканирования
+const (
+	EventScanQueued    = "scan.queued"
+	EventScanStarted   = "scan.started"
+	EventScanCompleted = "scan.completed"
+	EventScanFailed    = "scan.failed"
+	EventScanCanceled  = "scan.canceled"
+	EventFindingHigh   = "finding.high"
+)
+
+// Events - все поддерживаемые типы событий
+var Events = []string{
+	EventScanQueued, EventScanStarted, EventScanCompleted,
+	EventScanFailed, EventScanCanceled, EventFindingHigh,
+}
+
+// Заголовки исходящих запросов
+const (
+	HeaderEvent     = "X-ChimeraScan-Event"
+	HeaderDelivery  = "X-ChimeraScan-Delivery"
+	HeaderSignature = "X-ChimeraScan-Signature"
+)
+
+var (
+	// MaxAttempts - число попыток доставки, после которого доставка считается неудачной
+	MaxAttempts = 5
+	// BaseBackoff - задержка перед второй попыткой; далее удваивается
+	BaseBackoff = 2 * time.Second
+
+	client  = newClient()
+	enabled bool
+)
+
+// Event - событие, рассылаемое подписчикам
+type Event struct {
+	ID        uuid.UUID              `json:"id"`
+	Type      string                 `json:"event"`
+	UserID    uuid.UUID              `json:"-"`
+	ProjectID *uuid.UUID             `json:"-"`
+	CreatedAt time.Time              `json:"created_at"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+type subscription struct {
+	ID     uuid.UUID
+	URL    string
+	Secret string
+}
+
+// Start включает рассылку событий
+func Start() {
+	enabled = true
+	log.Println("Webhook dispatcher started")
+}
+
+// Enabled сообщает, включена ли рассылка
+func Enabled() bool {
+	return enabled
+}
+
+// IsValidEvent проверяет тип события
+func IsValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Emit асинхронно рассылает событие всем подходящим подпискам
+func Emit(event Event) {
+	if !enabled {
+		return
+	}
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	go dispatch(event)
+}
+
+func dispatch(event Event) {
+	subs, err := subscriptionsFor(event)
+	if err != nil {
+		log.Printf("Failed to load webhook subscriptions: %v", err)
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode webhook payload: %v", err)
+		return
+	}
+
+	for _, sub := range subs {
+		deliveryID := uuid.New()
+		_, err := database.DB.Exec(`
+			INSERT INTO webhook_deliveries (id, webhook_id, event, payload, status, created_at)
+			VALUES ($1, $2, $3, $4, 'pending', $5)
+		`, deliveryID, sub.ID, event.Type, string(payload), time.Now())
+		if err != nil {
+			log.Printf("Failed to record webhook delivery: %v", err)
+			continue
+		}
+
+		go deliver(sub, deliveryID, event.Type, payload)
+	}
+}
+
+// subscriptionsFor выбирает активные подписки: общие подписки владельца сканирования
+// и подписки на проект события, включая созданные другими участниками организации
+func subscriptionsFor(event Event) ([]subscription, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, url, secret, events, project_id
+		FROM webhooks
+		WHERE active = TRUE AND ((user_id = $1 AND project_id IS NULL) OR project_id = $2)
+	`, event.UserID, event.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []subscription
+	for rows.Next() {
+		var sub subscription
+		var eventsJSON []byte
+		var projectID *uuid.UUID
+		if err := rows.Scan(&sub.ID, &sub.URL, &sub.Secret, &eventsJSON, &projectID); err != nil {
+			return nil, err
+		}
+
+		if projectID != nil && (event.ProjectID == nil || *projectID != *event.ProjectID) {
+			continue
+		}
+
+		var events []string
+		json.Unmarshal(eventsJSON, &events)
+		if !matchesEvent(events, event.Type) {
+			continue
+		}
+
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// matchesEvent проверяет фильтр подписки; пустой фильтр означает все события
+func matchesEvent(filter []string, event string) bool {
+	if len(filter) == 0 {
+		return true
+	}
+	for _, e := range filter {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver отправляет событие с повторами и экспоненциальной задержкой
+func deliver(sub subscription, deliveryID uuid.UUID, eventType string, payload []byte) {
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		status, err := send(sub, deliveryID, eventType, payload)
+
+		var lastError *string
+		if err != nil {
+			msg := err.Error()
+			lastError = &msg
+		}
+
+		deliveryStatus := "pending"
+		var deliveredAt *time.Time
+		if err == nil {
+			deliveryStatus = "succeeded"
+			now := time.Now()
+			deliveredAt = &now
+		} else if attempt == MaxAttempts {
+			deliveryStatus = "failed"
+		}
+
+		var responseStatus *int
+		if status != 0 {
+			responseStatus = &status
+		}
+
+		_, dbErr := database.DB.Exec(`
+			UPDATE webhook_deliveries
+			SET status = $1, attempts = $2, response_status = $3, last_error = $4, delivered_at = $5
+			WHERE id = $6
+		`, deliveryStatus, attempt, responseStatus, lastError, deliveredAt, deliveryID)
+		if dbErr != nil {
+			log.Printf("Failed to update webhook delivery: %v", dbErr)
+		}
+
+		if err == nil {
+			return
+		}
+
+		log.Printf("Webhook delivery %s attempt %d failed: %v", deliveryID, attempt, err)
+		if attempt < MaxAttempts {
+			time.Sleep(BaseBackoff << (attempt - 1))
+		}
+	}
+}
+
+func send(sub subscription, deliveryID uuid.UUID, eventType string, payload []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "ChimeraScan-Webhook/1.0")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDelivery, deliveryID.String())
+	req.Header.Set(HeaderSignature, "sha256="+Sign(sub.Secret, payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign вычисляет подпись HMAC-SHA256 тела запроса в шестнадцатеричном виде
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
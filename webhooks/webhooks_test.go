@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"chimerascan/database"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	// Эталонное значение HMAC-SHA256 из RFC 4231 (тестовый случай 2)
+	signature := Sign("Jefe", []byte("what do ya want for nothing?"))
+	assert.Equal(t, "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843", signature)
+}
+
+func TestMatchesEvent(t *testing.T) {
+	assert.True(t, matchesEvent(nil, EventScanCompleted), "Empty filter should match all events")
+	assert.True(t, matchesEvent([]string{EventScanFailed, EventScanCompleted}, EventScanCompleted))
+	assert.False(t, matchesEvent([]string{EventScanFailed}, EventScanCompleted))
+}
+
+func TestDeliver_RetriesUntilSuccess(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	oldBackoff := BaseBackoff
+	BaseBackoff = time.Millisecond
+	defer func() { BaseBackoff = oldBackoff }()
+	allowLocalServer(t)
+
+	payload := []byte(`{"event":"scan.completed"}`)
+	secret := "s3cret"
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "sha256="+Sign(secret, body), r.Header.Get(HeaderSignature))
+		assert.Equal(t, EventScanCompleted, r.Header.Get(HeaderEvent))
+
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	deliveryID := uuid.New()
+
+	mock.ExpectExec(`UPDATE webhook_deliveries SET status = \$1, attempts = \$2`).
+		WithArgs("pending", 1, 502, sqlmock.AnyArg(), nil, deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE webhook_deliveries SET status = \$1, attempts = \$2`).
+		WithArgs("succeeded", 2, 200, nil, sqlmock.AnyArg(), deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	deliver(subscription{ID: uuid.New(), URL: server.URL, Secret: secret}, deliveryID, EventScanCompleted, payload)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeliver_GivesUpAfterMaxAttempts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Failed to create mock DB: %v", err)
+	}
+	defer db.Close()
+
+	oldDB := database.DB
+	database.DB = db
+	defer func() { database.DB = oldDB }()
+
+	oldBackoff, oldAttempts := BaseBackoff, MaxAttempts
+	BaseBackoff, MaxAttempts = time.Millisecond, 2
+	defer func() { BaseBackoff, MaxAttempts = oldBackoff, oldAttempts }()
+	allowLocalServer(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	deliveryID := uuid.New()
+
+	mock.ExpectExec(`UPDATE webhook_deliveries`).
+		WithArgs("pending", 1, 500, sqlmock.AnyArg(), nil, deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE webhook_deliveries`).
+		WithArgs("failed", 2, 500, sqlmock.AnyArg(), nil, deliveryID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	deliver(subscription{ID: uuid.New(), URL: server.URL, Secret: "x"}, deliveryID, EventScanFailed, []byte(`{}`))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// allowLocalServer разрешает доставку на httptest-сервер по петлевому адресу
+func allowLocalServer(t *testing.T) {
+	allowPrivateAddresses = true
+	t.Cleanup(func() { allowPrivateAddresses = false })
+}
+
+func TestValidateURL(t *testing.T) {
+	ctx := context.Background()
+	for _, rawURL := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[fd00:ec2::254]/",
+		"http://100.100.100.200/",
+		"http://0.0.0.0/",
+		"http://[::ffff:127.0.0.1]/",
+	} {
+		assert.ErrorIs(t, ValidateURL(ctx, rawURL), ErrForbiddenAddress, rawURL)
+	}
+
+	assert.Error(t, ValidateURL(ctx, "ftp://93.184.216.34/hook"))
+	assert.NoError(t, ValidateURL(ctx, "https://93.184.216.34/hook"))
+	assert.NoError(t, ValidateURL(ctx, "https://[2606:2800:220:1:248:1893:25c8:1946]/hook"))
+}
+
+func TestSend_RefusesPrivateAddressAtDialTime(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer server.Close()
+
+	// Адрес мог пройти проверку при создании и смениться в DNS
+	_, err := send(subscription{ID: uuid.New(), URL: server.URL, Secret: "x"}, uuid.New(), EventScanCompleted, []byte(`{}`))
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+	assert.Zero(t, atomic.LoadInt32(&calls))
+}